@@ -7,31 +7,180 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const (
+	dejsonFromJSON     = "json"
+	dejsonFromPostgres = "postgres"
+)
+
+var dejsonFrom = dejsonFromJSON
+
+const dejsonPageSize = 500
+
+// dejsonUsers copies every user and their API tokens to the active
+// repository and returns their IDs there by old ID. Users keep their ID
+// unless someone else already has it. Users that already exist there
+// by username are kept as-is.
+func dejsonUsers(sourceUsers videostore.UserRepo, sourceTokens videostore.APITokenRepo) map[uint]uint {
+	importer, ok := app.users.(videostore.UserImporter)
+	if !ok {
+		log.Fatal("the active user repo can't import users")
+	}
+
+	users, err := sourceUsers.All()
+	if err != nil {
+		log.Fatalf("error fetching users from %v repo: %+v", dejsonFrom, err)
+	}
+
+	ids := make(map[uint]uint, len(users))
+	for _, user := range users {
+		existing, err := app.users.FindByUsername(user.Username)
+		if err == nil {
+			ids[user.ID] = existing.ID
+			log.Printf("user %v already exists as %v, skipping", user.Username, existing.ID)
+			dejsonTokens(sourceTokens, user.ID, existing.ID)
+			continue
+		}
+		if err != videostore.ErrorUserNotFound {
+			log.Fatalf("error finding user %v: %+v", user.Username, err)
+		}
+
+		userID := user.ID
+		_, err = app.users.FindById(userID)
+		var savedUser videostore.User
+		if err == videostore.ErrorUserNotFound {
+			savedUser, err = importer.Import(user)
+		} else if err == nil {
+			user.ID = 0
+			savedUser, err = app.users.Save(user)
+		}
+		if err != nil {
+			log.Fatalf("error saving user %v: %+v", user.Username, err)
+		}
+		ids[userID] = savedUser.ID
+		log.Printf("saved user %v as %v", user.Username, savedUser.ID)
+
+		dejsonTokens(sourceTokens, userID, savedUser.ID)
+	}
+	return ids
+}
+
+// dejsonTokens copies the API tokens of a user to the active repository,
+// skipping tokens that are already there
+func dejsonTokens(sourceTokens videostore.APITokenRepo, sourceUserID uint, userID uint) {
+	tokens, err := sourceTokens.ForUser(sourceUserID)
+	if err != nil {
+		log.Fatalf("error fetching api tokens from %v repo: %+v", dejsonFrom, err)
+	}
+
+	for _, token := range tokens {
+		_, err := app.tokens.FindByHash(token.TokenHash)
+		if err == nil {
+			log.Printf("api token %v already exists, skipping", token.Name)
+			continue
+		}
+		if err != videostore.ErrorAPITokenNotFound {
+			log.Fatalf("error finding api token %v: %+v", token.Name, err)
+		}
+
+		token.ID = 0
+		token.UserID = userID
+		if _, err := app.tokens.Save(token); err != nil {
+			log.Fatalf("error saving api token %v: %+v", token.Name, err)
+		}
+		log.Printf("saved api token %v for user %v", token.Name, userID)
+	}
+}
+
+// dejsonTagAliases copies every tag alias to the active repository
+func dejsonTagAliases(sourceRepo videostore.VideoRepo) {
+	aliases, err := sourceRepo.TagAliases()
+	if err != nil {
+		log.Fatalf("error fetching tag aliases from %v repo: %+v", dejsonFrom, err)
+	}
+
+	for _, alias := range aliases {
+		if _, err := app.repo.SetTagAlias(alias.Alias, alias.Tag); err != nil {
+			log.Fatalf("error saving tag alias %v: %+v", alias.Alias, err)
+		}
+		log.Printf("saved tag alias %v -> %v", alias.Alias, alias.Tag)
+	}
+}
+
 // dejsonCmd represents the dejson command
 var dejsonCmd = &cobra.Command{
 	Use:   "dejson",
-	Short: "Migrate data from the JSON video repository to the active repository",
+	Short: "Migrate videos, users and tag aliases from the JSON (or Postgres) repository to the active repository",
 	Run: func(cmd *cobra.Command, args []string) {
-		dummyRepo := app.makeDummyRepo()
+		var sourceRepo videostore.VideoRepo
+		var sourceUsers videostore.UserRepo
+		var sourceTokens videostore.APITokenRepo
+		switch dejsonFrom {
+		case dejsonFromJSON:
+			if !app.config.UsePostgres && !app.config.UseSQLite {
+				log.Fatal("the JSON repo is already active, set CREAMY_POSTGRES or CREAMY_SQLITE to pick a destination")
+			}
+			sourceRepo = app.makeDummyRepo()
+			sourceUsers = videostore.NewDummyUserRepo(app.fs)
+			sourceTokens = videostore.NewDummyAPITokenRepo(app.fs)
+		case dejsonFromPostgres:
+			if app.config.UsePostgres {
+				log.Fatal("the Postgres repo is already active, unset CREAMY_POSTGRES to pick a different destination")
+			}
+			db := app.makePostgresDB()
+			sourceRepo = videostore.NewPostgresVideoRepo(*db)
+			sourceUsers = videostore.NewPostgresUserRepo(*db)
+			sourceTokens = videostore.NewPostgresAPITokenRepo(*db)
+		default:
+			log.Fatalf("unsupported source %v, expected %v or %v", dejsonFrom, dejsonFromJSON, dejsonFromPostgres)
+		}
 
-		videos, err := dummyRepo.All(videostore.VideoFilter{}, 10000, 0)
-		if err != nil {
-			log.Fatalf("error fetching all videos from JSON repo: %+v", err)
+		if app.config.UsePostgres {
+			app.requireMigrated()
 		}
 
-		for _, video := range videos {
-			videoID := video.ID
-			video.ID = 0 // trigger a re-save instead of update
-			savedVideo, err := app.repo.Save(video)
+		importer, ok := app.repo.(videostore.VideoImporter)
+		if !ok {
+			log.Fatal("the active video repo can't import videos")
+		}
+
+		ownerIDs := dejsonUsers(sourceUsers, sourceTokens)
+		dejsonTagAliases(sourceRepo)
+
+		for offset := uint(0); ; offset += dejsonPageSize {
+			videos, err := sourceRepo.All(videostore.VideoFilter{}, dejsonPageSize, offset)
 			if err != nil {
-				log.Fatalf("error saving video %+v: %+v", video, err)
+				log.Fatalf("error fetching videos from %v repo: %+v", dejsonFrom, err)
+			}
+
+			for _, video := range videos {
+				// stored media paths contain the ID, so it's kept.
+				// Anything already there was copied by an earlier run.
+				_, err := app.repo.FindById(video.ID)
+				if err == nil {
+					log.Printf("video %v already exists, skipping", video.ID)
+					continue
+				}
+				if err != videostore.ErrorVideoNotFound {
+					log.Fatalf("error finding video %v: %+v", video.ID, err)
+				}
+
+				video.OwnerID = ownerIDs[video.OwnerID]
+				savedVideo, err := importer.Import(video)
+				if err != nil {
+					log.Fatalf("error saving video %+v: %+v", video, err)
+				}
+				log.Printf("saved video %+v", savedVideo)
+			}
+
+			if len(videos) < dejsonPageSize {
+				break
 			}
-			video.ID = videoID
-			log.Printf("saved video %+v as %+v", video, savedVideo)
 		}
 	},
 }
 
 func init() {
+	dejsonCmd.Flags().StringVar(&dejsonFrom, "from", dejsonFromJSON, "repo to migrate from, json or postgres")
+
 	rootCmd.AddCommand(dejsonCmd)
 }
@@ -1,54 +1,280 @@
 package cmd
 
 import (
+	"database/sql"
 	"log"
+	"mime"
+	"net/url"
+	"strings"
 
 	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/jobs"
 	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/AlbinoDrought/creamy-videos/web"
 	"github.com/go-pg/pg"
 )
 
 type application struct {
 	config appConfig
 	fs     files.FileSystem
+	db     *pg.DB
+	sqlite *sql.DB
 	repo   videostore.VideoRepo
+	users  videostore.UserRepo
+	tokens videostore.APITokenRepo
+	jobs   *jobs.Queue
 }
 
 func (instance application) makeDummyRepo() videostore.VideoRepo {
 	return videostore.NewDummyVideoRepo(instance.fs)
 }
 
-func (instance application) makePostgresRepo() videostore.VideoRepo {
-	db := pg.Connect(&pg.Options{
+func (instance application) makePostgresDB() *pg.DB {
+	return pg.Connect(&pg.Options{
 		User:     instance.config.PostgresUser,
 		Password: instance.config.PostgresPassword,
 		Addr:     instance.config.PostgresAddress,
 		Database: instance.config.PostgresDatabase,
 	})
 	// db never closed
+}
 
-	return videostore.NewPostgresVideoRepo(*db)
+func (instance application) makePostgresRepo() videostore.VideoRepo {
+	return videostore.NewPostgresVideoRepo(*instance.db)
 }
 
-func makeApp(cfg appConfig) (instance application) {
-	instance.config = cfg
+func (instance application) makeSQLiteDB() *sql.DB {
+	db, err := videostore.OpenSQLite(instance.config.SQLitePath)
+	if err != nil {
+		log.Fatalf("failed to open %v: %+v", instance.config.SQLitePath, err)
+	}
+	return db
+	// db never closed
+}
+
+func (instance application) makeSQLiteRepo() videostore.VideoRepo {
+	return videostore.NewSQLiteVideoRepo(instance.sqlite)
+}
+
+func (instance application) makeAuth() *web.Auth {
+	auth := web.NewAuth(
+		instance.users,
+		instance.tokens,
+		instance.config.SessionKey,
+		instance.config.SessionTTL,
+		strings.HasPrefix(instance.config.AppURL, "https://"),
+	)
+
+	auth.ProxyHeader = instance.config.ProxyHeader
+	auth.ProxyCIDRs = instance.config.ProxyCIDRs
+	auth.ExternalRole = instance.config.ExternalRole
+
+	if instance.config.OIDCIssuer != "" {
+		auth.OIDC = web.NewOIDC(
+			instance.config.OIDCIssuer,
+			instance.config.OIDCClientID,
+			instance.config.OIDCClientSecret,
+			instance.config.AppURL+"/login/oidc/callback",
+			instance.config.OIDCUsernameClaim,
+		)
+	}
 
-	instance.fs = files.TransformFileSystem(
-		files.LocalFileSystem(instance.config.LocalVideoDirectory),
+	return auth
+}
+
+// makeStorage opens the configured place videos are kept, without any at-rest protection
+func (cfg appConfig) makeStorage() files.FileSystem {
+	if cfg.Storage == storageS3 {
+		fs, err := files.S3FileSystem(files.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			Prefix:    cfg.S3Prefix,
+		})
+		if err != nil {
+			log.Fatalf("failed to set up s3 storage: %+v", err)
+		}
+		return fs
+	}
+
+	return files.LocalFileSystem(cfg.LocalVideoDirectory)
+}
+
+// wrapFileSystem applies the configured at-rest obfuscation or encryption to fs
+func (cfg appConfig) wrapFileSystem(fs files.FileSystem) files.FileSystem {
+	if cfg.FilesystemMode == filesystemModeNone {
+		return fs
+	}
+
+	if cfg.FilesystemMode == filesystemModeEncrypted {
+		return files.EncryptedFileSystem(fs, cfg.FilesystemSecret)
+	}
+
+	return files.TransformFileSystem(
+		fs,
 		func(p []byte) {
 			for i := range p {
-				p[i] = p[i] ^ instance.config.FilesystemKey
+				p[i] = p[i] ^ cfg.FilesystemKey
 			}
 		},
 	)
+}
+
+// registerMediaTypes makes sure generated media is served with the
+// content types players expect. They are picky about HLS and tracks,
+// so don't leave them up to the host's mime.types.
+func registerMediaTypes() {
+	mime.AddExtensionType(".m3u8", "application/vnd.apple.mpegurl")
+	mime.AddExtensionType(".ts", "video/mp2t")
+	mime.AddExtensionType(".vtt", "text/vtt")
+}
+
+// mediaSigner returns what to sign media redirects with, or nil to serve media ourselves
+func (instance application) mediaSigner() files.URLSigner {
+	switch instance.config.MediaRedirect {
+	case mediaRedirectS3:
+		signer, ok := instance.fs.(files.URLSigner)
+		if !ok {
+			log.Fatal("storage does not support signed urls")
+		}
+		return signer
+	case mediaRedirectSigned:
+		return files.HMACURLSigner(instance.config.MediaURL, instance.config.MediaSecret)
+	}
 
+	return nil
+}
+
+// mediaOrigin is where media requests are redirected to, if anywhere
+func (instance application) mediaOrigin() string {
+	signer := instance.mediaSigner()
+	if signer == nil {
+		return ""
+	}
+
+	signed, err := signer.SignURL("/", instance.config.MediaRedirectTTL)
+	if err != nil {
+		log.Fatalf("failed to sign media url: %+v", err)
+	}
+	parsed, err := url.Parse(signed)
+	if err != nil || parsed.Host == "" {
+		log.Fatalf("media is redirected to an invalid url %v", signed)
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+// registerJobHandlers teaches the queue how to process each kind of job
+func (instance application) registerJobHandlers() {
+	withVideo := func(process func(video videostore.Video) error) jobs.Handler {
+		return func(job videostore.Job) error {
+			video, err := instance.repo.FindById(job.VideoID)
+			if err == videostore.ErrorVideoNotFound {
+				return jobs.Permanent(err)
+			}
+			if err != nil {
+				return err
+			}
+			return process(video)
+		}
+	}
+
+	instance.jobs.Handle(videostore.JobKindProbe, withVideo(func(video videostore.Video) error {
+		_, err := videostore.ProbeVideo(video, instance.repo, instance.fs)
+		return err
+	}))
+
+	instance.jobs.Handle(videostore.JobKindThumbnail, withVideo(func(video videostore.Video) error {
+		_, err := videostore.GenerateThumbnail(video, instance.repo, instance.fs)
+		return err
+	}))
+
+	instance.jobs.Handle(videostore.JobKindCustomThumbnail, withVideo(func(video videostore.Video) error {
+		// an image was uploaded since
+		if video.ThumbnailTimestamp == nil {
+			return nil
+		}
+		_, err := videostore.GenerateThumbnailAt(video, instance.repo, instance.fs, *video.ThumbnailTimestamp)
+		if err == videostore.ErrorThumbnailTimestampInvalid {
+			return jobs.Permanent(err)
+		}
+		return err
+	}))
+
+	instance.jobs.Handle(videostore.JobKindPreview, withVideo(func(video videostore.Video) error {
+		_, err := videostore.GeneratePreview(video, instance.repo, instance.fs)
+		return err
+	}))
+
+	instance.jobs.Handle(videostore.JobKindSprites, withVideo(func(video videostore.Video) error {
+		_, err := videostore.GenerateSprites(video, instance.repo, instance.fs)
+		return err
+	}))
+
+	// transcoding is slow and takes a lot of space, so it's opt-in
+	if instance.config.Transcode {
+		instance.jobs.Handle(videostore.JobKindTranscode, withVideo(func(video videostore.Video) error {
+			_, err := videostore.TranscodeVideo(video, instance.repo, instance.fs)
+			return err
+		}))
+	}
+}
+
+func makeApp(cfg appConfig) (instance application) {
+	instance.config = cfg
+
+	log.Printf("Storage: %v", instance.config.Storage)
+	log.Printf("Filesystem: %v", instance.config.FilesystemMode)
+	instance.fs = instance.config.wrapFileSystem(instance.config.makeStorage())
+
+	var jobRepo videostore.JobRepo
 	if instance.config.UsePostgres {
 		log.Println("Video Repo: Postgres")
+		instance.db = instance.makePostgresDB()
 		instance.repo = instance.makePostgresRepo()
+		jobRepo = videostore.NewPostgresJobRepo(*instance.db)
+		instance.users = videostore.NewPostgresUserRepo(*instance.db)
+		instance.tokens = videostore.NewPostgresAPITokenRepo(*instance.db)
+	} else if instance.config.UseSQLite {
+		log.Println("Video Repo: SQLite")
+		instance.sqlite = instance.makeSQLiteDB()
+		instance.repo = instance.makeSQLiteRepo()
+		jobRepo = videostore.NewSQLiteJobRepo(instance.sqlite)
+		instance.users = videostore.NewSQLiteUserRepo(instance.sqlite)
+		instance.tokens = videostore.NewSQLiteAPITokenRepo(instance.sqlite)
 	} else {
 		log.Println("Video Repo: JSON")
 		instance.repo = instance.makeDummyRepo()
+		jobRepo = videostore.NewDummyJobRepo(instance.fs)
+		instance.users = videostore.NewDummyUserRepo(instance.fs)
+		instance.tokens = videostore.NewDummyAPITokenRepo(instance.fs)
 	}
 
+	instance.jobs = jobs.NewQueue(jobRepo)
+	instance.registerJobHandlers()
+
 	return instance
 }
+
+// syncConfigUsers creates or updates the users listed in CREAMY_USERS.
+// Users removed from the list are left alone.
+func (instance application) syncConfigUsers() {
+	for _, configured := range instance.config.Users {
+		user, err := instance.users.FindByUsername(configured.Username)
+		if err != nil && err != videostore.ErrorUserNotFound {
+			log.Fatalf("failed to find user %v: %+v", configured.Username, err)
+		}
+		if user.Role == configured.Role && user.PasswordHash == configured.PasswordHash {
+			continue
+		}
+
+		user.Username = configured.Username
+		user.Role = configured.Role
+		user.PasswordHash = configured.PasswordHash
+		if _, err := instance.users.Save(user); err != nil {
+			log.Fatalf("failed to save user %v: %+v", configured.Username, err)
+		}
+		log.Printf("updated user %v from CREAMY_USERS", user.Username)
+	}
+}
@@ -3,13 +3,43 @@ package cmd
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"fmt"
 	"log"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	filesystemModeNone      = "none"
+	filesystemModeXOR       = "xor"
+	filesystemModeEncrypted = "aes-gcm"
+
+	minFilesystemSecretSize = 32
+
+	storageLocal = "local"
+	storageS3    = "s3"
+
+	mediaRedirectNone   = ""
+	mediaRedirectS3     = "s3"
+	mediaRedirectSigned = "signed"
 )
 
 type appConfig struct {
 	AppURL              string
+	Storage             string
 	LocalVideoDirectory string
+	S3Endpoint          string
+	S3Region            string
+	S3Bucket            string
+	S3AccessKey         string
+	S3SecretKey         string
+	S3Prefix            string
 	HTTPVideoDirectory  string
 	Port                string
 	UsePostgres         bool
@@ -17,10 +47,34 @@ type appConfig struct {
 	PostgresPassword    string
 	PostgresAddress     string
 	PostgresDatabase    string
+	UseSQLite           bool
+	SQLitePath          string
 	FilesystemKey       byte
+	FilesystemMode      string
+	FilesystemSecretB64 string
+	FilesystemSecret    []byte
+	MediaRedirect       string
+	MediaRedirectTTL    time.Duration
+	MediaURL            string
+	MediaSecretB64      string
+	MediaSecret         []byte
+	MediaPort           string
 	XSRFKeyB64          string
 	XSRFKey             []byte
+	SessionKeyB64       string
+	SessionKey          []byte
+	SessionTTL          time.Duration
+	Users               []videostore.User
+	ProxyHeader         string
+	ProxyCIDRs          []*net.IPNet
+	OIDCIssuer          string
+	OIDCClientID        string
+	OIDCClientSecret    string
+	OIDCUsernameClaim   string
+	ExternalRole        string
 	ReadOnly            bool
+	Workers             int
+	Transcode           bool
 }
 
 func envDefault(name string, backup string) string {
@@ -34,7 +88,14 @@ func envDefault(name string, backup string) string {
 func makeConfig() appConfig {
 	cfg := appConfig{
 		AppURL:              envDefault("CREAMY_APP_URL", ""),
+		Storage:             envDefault("CREAMY_STORAGE", storageLocal),
 		LocalVideoDirectory: envDefault("CREAMY_VIDEO_DIR", "dummyvideos"),
+		S3Endpoint:          envDefault("CREAMY_S3_ENDPOINT", "https://s3.us-east-1.amazonaws.com"),
+		S3Region:            envDefault("CREAMY_S3_REGION", "us-east-1"),
+		S3Bucket:            envDefault("CREAMY_S3_BUCKET", ""),
+		S3AccessKey:         envDefault("CREAMY_S3_ACCESS_KEY", ""),
+		S3SecretKey:         envDefault("CREAMY_S3_SECRET_KEY", ""),
+		S3Prefix:            envDefault("CREAMY_S3_PREFIX", ""),
 		HTTPVideoDirectory:  envDefault("CREAMY_HTTP_VIDEO_DIR", "/static/videos/"),
 		Port:                envDefault("CREAMY_HTTP_PORT", "3000"),
 		UsePostgres:         envDefault("CREAMY_POSTGRES", "false") == "true",
@@ -42,31 +103,215 @@ func makeConfig() appConfig {
 		PostgresPassword:    envDefault("CREAMY_POSTGRES_PASSWORD", "postgres"),
 		PostgresDatabase:    envDefault("CREAMY_POSTGRES_DATABASE", "postgres"),
 		PostgresAddress:     envDefault("CREAMY_POSTGRES_ADDRESS", "localhost:5432"),
+		UseSQLite:           envDefault("CREAMY_SQLITE", "false") == "true",
+		SQLitePath:          envDefault("CREAMY_SQLITE_PATH", "creamy-videos.sqlite"),
+		MediaRedirect:       envDefault("CREAMY_MEDIA_REDIRECT", mediaRedirectNone),
+		MediaURL:            envDefault("CREAMY_MEDIA_URL", ""),
+		MediaSecretB64:      envDefault("CREAMY_MEDIA_SECRET_B64", ""),
+		MediaPort:           envDefault("CREAMY_MEDIA_PORT", "3001"),
 		XSRFKeyB64:          envDefault("CREAMY_XSRF_KEY_B64", ""),
+		SessionKeyB64:       envDefault("CREAMY_SESSION_KEY_B64", ""),
+		ProxyHeader:         envDefault("CREAMY_PROXY_HEADER", ""),
+		OIDCIssuer:          envDefault("CREAMY_OIDC_ISSUER", ""),
+		OIDCClientID:        envDefault("CREAMY_OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:    envDefault("CREAMY_OIDC_CLIENT_SECRET", ""),
+		OIDCUsernameClaim:   envDefault("CREAMY_OIDC_USERNAME_CLAIM", "preferred_username"),
+		ExternalRole:        envDefault("CREAMY_EXTERNAL_ROLE", videostore.RoleViewer),
 		FilesystemKey:       0x69, // hardcoded for now
+		FilesystemMode:      envDefault("CREAMY_FILESYSTEM_MODE", filesystemModeXOR),
+		FilesystemSecretB64: envDefault("CREAMY_FILESYSTEM_SECRET_B64", ""),
 		ReadOnly:            envDefault("CREAMY_READ_ONLY", "false") == "true",
+		Transcode:           envDefault("CREAMY_TRANSCODE", "false") == "true",
+	}
+
+	var err error
+	cfg.Workers, err = strconv.Atoi(envDefault("CREAMY_WORKERS", "2"))
+	if err != nil || cfg.Workers < 1 {
+		log.Fatal("CREAMY_WORKERS must be a positive number")
+	}
+
+	if cfg.UsePostgres && cfg.UseSQLite {
+		log.Fatal("CREAMY_POSTGRES and CREAMY_SQLITE can't both be true")
+	}
+
+	switch cfg.Storage {
+	case storageLocal:
+	case storageS3:
+		if cfg.S3Bucket == "" {
+			log.Fatal("CREAMY_S3_BUCKET must be set when CREAMY_STORAGE=s3")
+		}
+	default:
+		log.Fatalf("CREAMY_STORAGE is set to an unsupported value %v", cfg.Storage)
+	}
+
+	switch cfg.FilesystemMode {
+	case filesystemModeNone:
+	case filesystemModeXOR:
+	case filesystemModeEncrypted:
+		cfg.FilesystemSecret, err = decodeFilesystemSecret(cfg.FilesystemSecretB64)
+		if err != nil {
+			log.Fatal("CREAMY_FILESYSTEM_SECRET_B64 is set to an invalid value:", err)
+		}
+	default:
+		log.Fatalf("CREAMY_FILESYSTEM_MODE is set to an unsupported value %v", cfg.FilesystemMode)
+	}
+
+	cfg.MediaRedirectTTL, err = time.ParseDuration(envDefault("CREAMY_MEDIA_REDIRECT_TTL", "1h"))
+	if err != nil || cfg.MediaRedirectTTL <= 0 {
+		log.Fatal("CREAMY_MEDIA_REDIRECT_TTL must be a positive duration like 15m")
+	}
+
+	if cfg.MediaSecretB64 != "" {
+		cfg.MediaSecret, err = decodeFilesystemSecret(cfg.MediaSecretB64)
+		if err != nil {
+			log.Fatal("CREAMY_MEDIA_SECRET_B64 is set to an invalid value:", err)
+		}
+	}
+
+	switch cfg.MediaRedirect {
+	case mediaRedirectNone:
+	case mediaRedirectS3:
+		// the bucket hands out bytes as they are stored
+		if cfg.Storage != storageS3 || cfg.FilesystemMode != filesystemModeNone {
+			log.Fatal("CREAMY_MEDIA_REDIRECT=s3 requires CREAMY_STORAGE=s3 and CREAMY_FILESYSTEM_MODE=none")
+		}
+	case mediaRedirectSigned:
+		if cfg.MediaURL == "" || cfg.MediaSecret == nil {
+			log.Fatal("CREAMY_MEDIA_REDIRECT=signed requires CREAMY_MEDIA_URL and CREAMY_MEDIA_SECRET_B64")
+		}
+	default:
+		log.Fatalf("CREAMY_MEDIA_REDIRECT is set to an unsupported value %v", cfg.MediaRedirect)
 	}
 
 	if cfg.XSRFKeyB64 == "" && !cfg.ReadOnly {
-		cfg.XSRFKeyB64 = randomXSRFKeyB64()
+		cfg.XSRFKeyB64 = randomKeyB64("CREAMY_XSRF_KEY_B64", "XSRF will be invalid upon restart")
 	}
 	if cfg.XSRFKeyB64 != "" {
-		var err error
 		cfg.XSRFKey, err = base64.StdEncoding.DecodeString(cfg.XSRFKeyB64)
 		if err != nil {
 			log.Fatal("CREAMY_XSRF_KEY_B64 is set to an invalid value:", err)
 		}
 	}
 
+	if cfg.SessionKeyB64 == "" && !cfg.ReadOnly {
+		cfg.SessionKeyB64 = randomKeyB64("CREAMY_SESSION_KEY_B64", "everyone will be logged out upon restart")
+	}
+	if cfg.SessionKeyB64 != "" {
+		cfg.SessionKey, err = base64.StdEncoding.DecodeString(cfg.SessionKeyB64)
+		if err != nil {
+			log.Fatal("CREAMY_SESSION_KEY_B64 is set to an invalid value:", err)
+		}
+	}
+
+	cfg.SessionTTL, err = time.ParseDuration(envDefault("CREAMY_SESSION_TTL", "720h"))
+	if err != nil || cfg.SessionTTL <= 0 {
+		log.Fatal("CREAMY_SESSION_TTL must be a positive duration like 24h")
+	}
+
+	cfg.Users, err = parseConfigUsers(envDefault("CREAMY_USERS", ""))
+	if err != nil {
+		log.Fatal("CREAMY_USERS is set to an invalid value:", err)
+	}
+
+	cfg.ProxyCIDRs, err = parseCIDRs(envDefault("CREAMY_PROXY_CIDRS", ""))
+	if err != nil {
+		log.Fatal("CREAMY_PROXY_CIDRS is set to an invalid value:", err)
+	}
+	if cfg.ProxyHeader != "" && len(cfg.ProxyCIDRs) == 0 {
+		log.Fatal("CREAMY_PROXY_HEADER requires CREAMY_PROXY_CIDRS, or anyone could claim to be anyone")
+	}
+
+	if cfg.OIDCIssuer != "" && (cfg.OIDCClientID == "" || cfg.AppURL == "") {
+		log.Fatal("CREAMY_OIDC_ISSUER requires CREAMY_OIDC_CLIENT_ID and CREAMY_APP_URL")
+	}
+
+	if !videostore.ValidRole(cfg.ExternalRole) {
+		log.Fatalf("CREAMY_EXTERNAL_ROLE is set to an unsupported value %v", cfg.ExternalRole)
+	}
+
 	return cfg
 }
 
-func randomXSRFKeyB64() string {
+func decodeFilesystemSecret(secretB64 string) ([]byte, error) {
+	secret, err := base64.StdEncoding.DecodeString(secretB64)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) < minFilesystemSecretSize {
+		return nil, fmt.Errorf("secret must be at least %v bytes, got %v", minFilesystemSecretSize, len(secret))
+	}
+	return secret, nil
+}
+
+// parseConfigUsers reads comma-separated username:role:bcrypt-hash entries,
+// see `creamy-videos users hash`
+func parseConfigUsers(value string) ([]videostore.User, error) {
+	var users []videostore.User
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("%q should look like username:role:bcrypt-hash", entry)
+		}
+		if !videostore.ValidRole(parts[1]) {
+			return nil, fmt.Errorf("%v has unknown role %q", parts[0], parts[1])
+		}
+		if _, err := bcrypt.Cost([]byte(parts[2])); err != nil {
+			return nil, fmt.Errorf("%v has a bad password hash: %v", parts[0], err)
+		}
+
+		users = append(users, videostore.User{
+			Username:     parts[0],
+			Role:         parts[1],
+			PasswordHash: parts[2],
+		})
+	}
+	return users, nil
+}
+
+// parseCIDRs reads comma-separated CIDRs,
+// single addresses are taken as-is
+func parseCIDRs(value string) ([]*net.IPNet, error) {
+	var cidrs []*net.IPNet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP or CIDR", entry)
+			}
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			cidrs = append(cidrs, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, cidr, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+// randomKeyB64 makes up a key for env when none was configured
+func randomKeyB64(env string, consequence string) string {
 	bytes := make([]byte, 64)
 	if _, err := rand.Read(bytes); err != nil {
-		log.Fatal("CREAMY_XSRF_KEY_B64 is unset and an error was encountered during generation:", err)
+		log.Fatalf("%v is unset and an error was encountered during generation: %v", env, err)
 	}
 	str := base64.StdEncoding.EncodeToString(bytes)
-	log.Printf("CREAMY_XSRF_KEY_B64 is not specified, using CREAMY_XSRF_KEY_B64=%v (XSRF will be invalid upon restart)", str)
+	log.Printf("%v is not specified, using %v=%v (%v)", env, env, str, consequence)
 	return str
 }
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+)
+
+type videoGetter func() []videostore.Video
+
+// makeVideoGetter pages through all videos if all is set,
+// otherwise through the videos with the given ids
+func makeVideoGetter(all bool, args []string) videoGetter {
+	// loop over all videos
+	if all {
+		currentOffset := uint(0)
+		limit := uint(100)
+		return func() []videostore.Video {
+			videos, err := app.repo.All(videostore.VideoFilter{}, limit, currentOffset)
+			if err != nil {
+				log.Fatalf("error fetching videos: %+v", err)
+			}
+			currentOffset += limit
+			return videos
+		}
+	}
+
+	// loop over selected ids
+	ids := make([]uint, len(args))
+	for i, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			log.Fatalf("error converting to int: %+v", err)
+		}
+		ids[i] = uint(id)
+	}
+
+	return func() []videostore.Video {
+		if len(ids) == 0 {
+			return []videostore.Video{}
+		}
+
+		id := ids[0]
+		ids = ids[1:]
+
+		video, err := app.repo.FindById(id)
+		if err != nil {
+			log.Fatalf("error fetching video: %+v", err)
+		}
+
+		return []videostore.Video{video}
+	}
+}
+
+// eachVideo calls fn for every video returned by getter
+func eachVideo(getter videoGetter, fn func(video videostore.Video)) {
+	var videos []videostore.Video
+	for {
+		videos = getter()
+		if len(videos) == 0 {
+			break
+		}
+		for _, video := range videos {
+			fn(video)
+		}
+	}
+}
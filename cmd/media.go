@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/spf13/cobra"
+)
+
+var mediaCmd = &cobra.Command{
+	Use:   "media",
+	Short: "Serve videos to signed URLs only, for CREAMY_MEDIA_REDIRECT=signed",
+	Long: `Serve videos to signed URLs only.
+
+Run this beside "serve" with CREAMY_MEDIA_REDIRECT=signed, so video bytes
+are handed out here instead of by the main server. Both need the same
+CREAMY_MEDIA_SECRET_B64, and CREAMY_MEDIA_URL should point here.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if app.config.MediaSecret == nil {
+			log.Fatal("CREAMY_MEDIA_SECRET_B64 is required")
+		}
+
+		registerMediaTypes()
+		fileServer := http.FileServer(files.AdaptToHTTPFileSystem(app.fs, false))
+		http.Handle("/", files.VerifySignedURLs(app.config.MediaSecret, fileServer))
+
+		log.Printf("Serving signed media on %s\n", app.config.MediaPort)
+		log.Fatal(http.ListenAndServe(":"+app.config.MediaPort, nil))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mediaCmd)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Manage the Postgres schema",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if !app.config.UsePostgres {
+			log.Fatal("migrations only apply to Postgres, set CREAMY_POSTGRES=true")
+		}
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List migrations and whether they have been applied",
+	Run: func(cmd *cobra.Command, args []string) {
+		statuses, err := videostore.PostgresMigrationStatus(*app.db)
+		if err != nil {
+			log.Fatalf("failed to read migrations: %+v", err)
+		}
+
+		for _, status := range statuses {
+			if status.Applied {
+				log.Printf("%v %v: applied %v", status.Version, status.Name, status.TimeApplied)
+			} else {
+				log.Printf("%v %v: pending", status.Version, status.Name)
+			}
+		}
+	},
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply all pending migrations",
+	Run: func(cmd *cobra.Command, args []string) {
+		app.migrateUp()
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Revert the most recently applied migration",
+	Run: func(cmd *cobra.Command, args []string) {
+		migration, err := videostore.MigratePostgresDown(*app.db)
+		if err == videostore.ErrorNoMigrations {
+			log.Println("no migrations to revert")
+			return
+		}
+		if err != nil {
+			log.Fatalf("failed to revert migration: %+v", err)
+		}
+
+		log.Printf("reverted %v %v", migration.Version, migration.Name)
+	},
+}
+
+// migrateUp applies pending Postgres migrations, or dies trying
+func (instance application) migrateUp() {
+	migrations, err := videostore.MigratePostgresUp(*instance.db)
+	for _, migration := range migrations {
+		log.Printf("applied %v %v", migration.Version, migration.Name)
+	}
+	if err != nil {
+		log.Fatalf("failed to migrate: %+v", err)
+	}
+	if len(migrations) == 0 {
+		log.Println("schema is up to date")
+	}
+}
+
+// requireMigrated dies if Postgres migrations are pending
+func (instance application) requireMigrated() {
+	pending, err := videostore.PendingPostgresMigrations(*instance.db)
+	if err != nil {
+		log.Fatalf("failed to read migrations: %+v", err)
+	}
+	if pending > 0 {
+		log.Fatalf("%v migrations are pending, run `creamy-videos migrate up` first", pending)
+	}
+}
+
+func init() {
+	migrateCmd.AddCommand(migrateStatusCmd)
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+
+	rootCmd.AddCommand(migrateCmd)
+}
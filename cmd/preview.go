@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/spf13/cobra"
+)
+
+var previewForAllVideos = false
+
+var previewCommand = &cobra.Command{
+	Use:   "preview [-a to regen all] [video ids]",
+	Short: "Regenerate hover preview for given video, or all videos",
+	Run: func(cmd *cobra.Command, args []string) {
+		eachVideo(makeVideoGetter(previewForAllVideos, args), func(video videostore.Video) {
+			_, err := videostore.GeneratePreview(video, app.repo, app.fs)
+			if err == nil {
+				log.Printf("generated preview for %+v", video.ID)
+			} else {
+				log.Printf("failed to generate preview for %+v: %+v", video.ID, err)
+			}
+		})
+	},
+}
+
+func init() {
+	previewCommand.Flags().BoolVarP(&previewForAllVideos, "all", "a", false, "if true, regenerate _all_ previews")
+
+	rootCmd.AddCommand(previewCommand)
+}
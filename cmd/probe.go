@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/spf13/cobra"
+)
+
+var probeAllVideos = false
+
+var probeCommand = &cobra.Command{
+	Use:   "probe [-a to probe all] [video ids]",
+	Short: "Read duration, resolution and codecs for given video, or all videos",
+	Run: func(cmd *cobra.Command, args []string) {
+		eachVideo(makeVideoGetter(probeAllVideos, args), func(video videostore.Video) {
+			probed, err := videostore.ProbeVideo(video, app.repo, app.fs)
+			if err == nil {
+				log.Printf("probed %+v: %vx%v %v/%v %.1fs", video.ID, probed.Width, probed.Height, probed.VideoCodec, probed.AudioCodec, probed.Duration)
+			} else {
+				log.Printf("failed to probe %+v: %+v", video.ID, err)
+			}
+		})
+	},
+}
+
+func init() {
+	probeCommand.Flags().BoolVarP(&probeAllVideos, "all", "a", false, "if true, probe _all_ videos")
+
+	rootCmd.AddCommand(probeCommand)
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/spf13/cobra"
+)
+
+const rekeyProgressFile = ".rekey-progress.json"
+
+var (
+	rekeyTargetDirectory string
+	rekeyTargetMode      string
+	rekeyTargetSecretB64 string
+	rekeyDryRun          bool
+)
+
+// rekeyProgress is stored unencrypted beside the new library so an
+// interrupted rekey can pick up where it left off
+type rekeyProgress struct {
+	Videos    map[uint]bool `json:"videos"`
+	RootFiles bool          `json:"root_files"`
+}
+
+func loadRekeyProgress(progressPath string) rekeyProgress {
+	progress := rekeyProgress{
+		Videos: map[uint]bool{},
+	}
+
+	data, err := os.ReadFile(progressPath)
+	if os.IsNotExist(err) {
+		return progress
+	}
+	if err != nil {
+		log.Fatalf("error reading rekey progress: %+v", err)
+	}
+	if err := json.Unmarshal(data, &progress); err != nil {
+		log.Fatalf("error decoding rekey progress: %+v", err)
+	}
+	if progress.Videos == nil {
+		progress.Videos = map[uint]bool{}
+	}
+
+	return progress
+}
+
+func (progress rekeyProgress) save(progressPath string) {
+	data, _ := json.Marshal(progress)
+	if err := os.WriteFile(progressPath, data, 0600); err != nil {
+		log.Fatalf("error saving rekey progress: %+v", err)
+	}
+}
+
+func rekeyFile(from files.FileSystem, to files.FileSystem, name string) error {
+	source, err := from.Open(name)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	return files.PipeTo(to, name, source)
+}
+
+// rekeyTree copies root and everything below it from one filesystem
+// to the other, decoding and re-encoding along the way
+func rekeyTree(from files.FileSystem, to files.FileSystem, root string) error {
+	return files.Walk(from, root, func(name string, info os.FileInfo) error {
+		if info.IsDir() {
+			if rekeyDryRun {
+				return nil
+			}
+			return to.MkdirAll(name, os.ModePerm)
+		}
+
+		log.Printf("rekeying %v (%v bytes)", name, info.Size())
+		if rekeyDryRun {
+			return nil
+		}
+		return rekeyFile(from, to, name)
+	})
+}
+
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey --to-dir [dir]",
+	Short: "Re-encrypt all stored media into a new directory using a new key",
+	Long: `Re-encrypt all stored media into a new directory using a new key.
+
+Every video is read through the active filesystem configuration and written
+to --to-dir using the target mode and secret. The existing library is never
+modified, so an interrupted run leaves it intact; re-running the same command
+resumes from the last completed video. Stop the server before rekeying.
+
+Once finished, point CREAMY_VIDEO_DIR at the new directory and update
+CREAMY_FILESYSTEM_MODE and CREAMY_FILESYSTEM_SECRET_B64 to match.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if rekeyTargetDirectory == "" {
+			log.Fatal("--to-dir is required")
+		}
+		if app.config.Storage != storageLocal {
+			log.Fatalf("rekey only supports CREAMY_STORAGE=%v, copy the library to a local directory first", storageLocal)
+		}
+
+		sourceDirectory, _ := filepath.Abs(app.config.LocalVideoDirectory)
+		targetDirectory, _ := filepath.Abs(rekeyTargetDirectory)
+		if app.config.Storage == storageLocal && sourceDirectory == targetDirectory {
+			log.Fatal("--to-dir must not be the current video directory")
+		}
+
+		target := app.config
+		target.LocalVideoDirectory = rekeyTargetDirectory
+		target.FilesystemMode = rekeyTargetMode
+		target.FilesystemSecretB64 = rekeyTargetSecretB64
+		switch target.FilesystemMode {
+		case filesystemModeNone:
+		case filesystemModeXOR:
+		case filesystemModeEncrypted:
+			var err error
+			target.FilesystemSecret, err = decodeFilesystemSecret(target.FilesystemSecretB64)
+			if err != nil {
+				log.Fatalf("invalid target secret: %+v", err)
+			}
+		default:
+			log.Fatalf("unsupported target mode %v", target.FilesystemMode)
+		}
+
+		var targetFS files.FileSystem
+		if !rekeyDryRun {
+			targetFS = target.wrapFileSystem(files.LocalFileSystem(target.LocalVideoDirectory))
+		}
+
+		if !rekeyDryRun {
+			if err := os.MkdirAll(target.LocalVideoDirectory, os.ModePerm); err != nil {
+				log.Fatalf("error creating %v: %+v", target.LocalVideoDirectory, err)
+			}
+		}
+
+		progressPath := path.Join(target.LocalVideoDirectory, rekeyProgressFile)
+		progress := loadRekeyProgress(progressPath)
+
+		offset := uint(0)
+		const limit = 100
+		for {
+			videos, err := app.repo.All(videostore.VideoFilter{}, limit, offset)
+			if err != nil {
+				log.Fatalf("error fetching videos: %+v", err)
+			}
+			if len(videos) == 0 {
+				break
+			}
+			offset += limit
+
+			for _, video := range videos {
+				if progress.Videos[video.ID] {
+					log.Printf("skipping %v, already rekeyed", video.ID)
+					continue
+				}
+
+				for _, name := range videostore.VideoFiles(video) {
+					if !rekeyDryRun {
+						if err := targetFS.MkdirAll(path.Dir(name), os.ModePerm); err != nil {
+							log.Fatalf("error rekeying video %v: %+v", video.ID, err)
+						}
+					}
+					err := rekeyTree(app.fs, targetFS, name)
+					if err != nil && !app.fs.IsNotExist(err) {
+						log.Fatalf("error rekeying video %v: %+v", video.ID, err)
+					}
+				}
+
+				if !rekeyDryRun {
+					progress.Videos[video.ID] = true
+					progress.save(progressPath)
+				}
+			}
+		}
+
+		// top-level files like dummy.json hold repository state
+		// and are copied last so they match the rekeyed videos
+		if !progress.RootFiles {
+			root, err := app.fs.Open("/")
+			if err != nil {
+				log.Fatalf("error opening video directory: %+v", err)
+			}
+			entries, err := root.Readdir(-1)
+			root.Close()
+			if err != nil {
+				log.Fatalf("error listing video directory: %+v", err)
+			}
+
+			for _, entry := range entries {
+				if entry.IsDir() || entry.Name() == rekeyProgressFile {
+					continue
+				}
+				log.Printf("rekeying %v (%v bytes)", entry.Name(), entry.Size())
+				if rekeyDryRun {
+					continue
+				}
+				if err := rekeyFile(app.fs, targetFS, entry.Name()); err != nil {
+					log.Fatalf("error rekeying %v: %+v", entry.Name(), err)
+				}
+			}
+
+			if !rekeyDryRun {
+				progress.RootFiles = true
+				progress.save(progressPath)
+			}
+		}
+
+		if rekeyDryRun {
+			log.Print("dry run complete, nothing was written")
+			return
+		}
+
+		log.Printf(
+			"rekey complete, start creamy-videos with CREAMY_VIDEO_DIR=%v CREAMY_FILESYSTEM_MODE=%v and the new secret",
+			target.LocalVideoDirectory,
+			target.FilesystemMode,
+		)
+	},
+}
+
+func init() {
+	rekeyCmd.Flags().StringVar(&rekeyTargetDirectory, "to-dir", "", "empty directory to write the rekeyed library to")
+	rekeyCmd.Flags().StringVar(&rekeyTargetMode, "to-mode", filesystemModeEncrypted, "filesystem mode of the rekeyed library")
+	rekeyCmd.Flags().StringVar(&rekeyTargetSecretB64, "to-secret-b64", envDefault("CREAMY_REKEY_SECRET_B64", ""), "Base64-encoded secret for the rekeyed library, defaults to $CREAMY_REKEY_SECRET_B64")
+	rekeyCmd.Flags().BoolVar(&rekeyDryRun, "dry-run", false, "if true, only list what would be rekeyed")
+
+	rootCmd.AddCommand(rekeyCmd)
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"strings"
@@ -12,11 +13,32 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var serveAutoMigrate = false
+
 // serveCmd represents the serve command
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Provide videos, UI, and API over HTTP",
 	Run: func(cmd *cobra.Command, args []string) {
+		if app.config.UsePostgres {
+			if serveAutoMigrate {
+				app.migrateUp()
+			} else {
+				app.requireMigrated()
+			}
+		}
+
+		registerMediaTypes()
+
+		var auth *web.Auth
+		if !app.config.ReadOnly {
+			app.syncConfigUsers()
+			auth = app.makeAuth()
+			if users, err := app.users.All(); err == nil && len(users) == 0 {
+				log.Println("Nobody can log in yet, add a user with `creamy-videos users add <username>` or CREAMY_USERS")
+			}
+		}
+
 		fileServer := http.FileServer(files.AdaptToHTTPFileSystem(app.fs, false))
 
 		r := mux.NewRouter()
@@ -30,17 +52,25 @@ var serveCmd = &cobra.Command{
 		}
 		var apiHandler http.Handler
 		if app.config.ReadOnly {
-			apiHandler = web.NewReadOnlyAPI(publicAssetUrlGenerator, app.fs, app.repo)
+			apiHandler = web.NewReadOnlyAPI(publicAssetUrlGenerator, app.fs, app.repo, app.jobs)
 		} else {
-			apiHandler = web.NewWriteableAPI(publicAssetUrlGenerator, app.fs, app.repo)
+			apiHandler = web.NewWriteableAPI(publicAssetUrlGenerator, app.fs, app.repo, app.jobs, auth)
 		}
 		r.PathPrefix("/api/").Handler(apiHandler)
 
 		// mount video files:
+		var mediaHandler http.Handler = fileServer
+		if signer := app.mediaSigner(); signer != nil {
+			mediaHandler = web.NewSignedRedirectHandler(signer, app.config.MediaRedirectTTL, fileServer)
+		}
+		mediaHandler = web.NewVisibleMediaHandler(app.repo, app.config.XSRFKey, mediaHandler)
+		if auth != nil {
+			mediaHandler = auth.TokenMiddleware(mediaHandler)
+		}
 		r.PathPrefix(app.config.HTTPVideoDirectory).Handler(
 			http.StripPrefix(
 				strings.TrimRight(app.config.HTTPVideoDirectory, "/"),
-				fileServer,
+				mediaHandler,
 			),
 		)
 
@@ -48,21 +78,36 @@ var serveCmd = &cobra.Command{
 		// mount non-SPA UI:
 		var cUI2Handler http.Handler
 		if app.config.ReadOnly {
-			cUI2Handler = web.NewReadOnlyCUI2(publicRootUrlGenerator, publicAssetUrlGenerator, app.repo)
+			cUI2Handler = web.NewReadOnlyCUI2(publicRootUrlGenerator, publicAssetUrlGenerator, app.mediaOrigin(), app.repo)
 		} else {
-			cUI2Handler = web.NewWriteableCUI2(publicRootUrlGenerator, publicAssetUrlGenerator, app.fs, app.repo, app.config.XSRFKey)
+			cUI2Handler = web.NewWriteableCUI2(publicRootUrlGenerator, publicAssetUrlGenerator, app.mediaOrigin(), app.fs, app.repo, app.jobs, app.config.XSRFKey, auth)
 		}
 		r.PathPrefix("/").Handler(cUI2Handler)
 
 		http.Handle("/", r)
 
+		// a read-only instance may share its repo with a writeable one,
+		// leave processing up to that instance
+		if !app.config.ReadOnly {
+			if err := app.jobs.Start(context.Background(), app.config.Workers); err != nil {
+				log.Fatalf("failed to start job workers: %+v", err)
+			}
+			log.Printf("Started %v job workers\n", app.config.Workers)
+		}
+
 		log.Printf("Remote URL: %s\n", app.config.AppURL)
-		log.Printf("Serving videos from %s on %s\n", app.config.LocalVideoDirectory, app.config.HTTPVideoDirectory)
+		if app.config.Storage == storageS3 {
+			log.Printf("Serving videos from s3 bucket %s on %s\n", app.config.S3Bucket, app.config.HTTPVideoDirectory)
+		} else {
+			log.Printf("Serving videos from %s on %s\n", app.config.LocalVideoDirectory, app.config.HTTPVideoDirectory)
+		}
 		log.Printf("Listening on %s\n", app.config.Port)
 		log.Fatal(http.ListenAndServe(":"+app.config.Port, nil))
 	},
 }
 
 func init() {
+	serveCmd.Flags().BoolVar(&serveAutoMigrate, "auto-migrate", false, "if true, apply pending Postgres migrations before serving")
+
 	rootCmd.AddCommand(serveCmd)
 }
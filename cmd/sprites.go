@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/spf13/cobra"
+)
+
+var spritesForAllVideos = false
+
+var spritesCommand = &cobra.Command{
+	Use:   "sprites [-a to regen all] [video ids]",
+	Short: "Regenerate scrubbing previews for given video, or all videos",
+	Run: func(cmd *cobra.Command, args []string) {
+		eachVideo(makeVideoGetter(spritesForAllVideos, args), func(video videostore.Video) {
+			_, err := videostore.GenerateSprites(video, app.repo, app.fs)
+			if err == nil {
+				log.Printf("generated sprites for %+v", video.ID)
+			} else {
+				log.Printf("failed to generate sprites for %+v: %+v", video.ID, err)
+			}
+		})
+	},
+}
+
+func init() {
+	spritesCommand.Flags().BoolVarP(&spritesForAllVideos, "all", "a", false, "if true, regenerate _all_ sprites")
+
+	rootCmd.AddCommand(spritesCommand)
+}
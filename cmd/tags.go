@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/spf13/cobra"
+)
+
+var tagsCmd = &cobra.Command{
+	Use:   "tags",
+	Short: "List tags and how many videos use them",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if app.config.UsePostgres {
+			app.requireMigrated()
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		tags, err := app.repo.Tags(videostore.VideoFilter{})
+		if err != nil {
+			log.Fatalf("failed to list tags: %+v", err)
+		}
+
+		for _, tag := range tags {
+			fmt.Printf("%v\t%v\n", tag.Count, tag.Tag)
+		}
+	},
+}
+
+var tagsRenameCmd = &cobra.Command{
+	Use:   "rename <from> <to>",
+	Short: "Rename a tag on every video",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		changed, err := app.repo.MergeTags([]string{args[0]}, args[1])
+		if err != nil {
+			log.Fatalf("failed to rename tag: %+v", err)
+		}
+
+		log.Printf("renamed %v to %v on %v videos", args[0], args[1], changed)
+	},
+}
+
+var tagsMergeCmd = &cobra.Command{
+	Use:   "merge <into> <from>...",
+	Short: "Replace each of the from tags with into on every video",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		changed, err := app.repo.MergeTags(args[1:], args[0])
+		if err != nil {
+			log.Fatalf("failed to merge tags: %+v", err)
+		}
+
+		log.Printf("merged %v into %v on %v videos", args[1:], args[0], changed)
+	},
+}
+
+var tagsAliasesCmd = &cobra.Command{
+	Use:   "aliases",
+	Short: "List tag aliases",
+	Run: func(cmd *cobra.Command, args []string) {
+		aliases, err := app.repo.TagAliases()
+		if err != nil {
+			log.Fatalf("failed to list tag aliases: %+v", err)
+		}
+
+		for _, alias := range aliases {
+			fmt.Printf("%v\t%v\n", alias.Alias, alias.Tag)
+		}
+	},
+}
+
+var tagsAliasCmd = &cobra.Command{
+	Use:   "alias <alias> <tag>",
+	Short: "Replace alias with tag whenever a video is saved, and on every existing video",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		alias, err := app.repo.SetTagAlias(args[0], args[1])
+		if err != nil {
+			log.Fatalf("failed to save tag alias: %+v", err)
+		}
+
+		changed, err := app.repo.MergeTags([]string{alias.Alias}, alias.Tag)
+		if err != nil {
+			log.Fatalf("failed to merge %v into %v: %+v", alias.Alias, alias.Tag, err)
+		}
+
+		log.Printf("aliased %v to %v, updated %v videos", alias.Alias, alias.Tag, changed)
+	},
+}
+
+var tagsUnaliasCmd = &cobra.Command{
+	Use:   "unalias <alias>",
+	Short: "Delete a tag alias",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := app.repo.DeleteTagAlias(args[0]); err != nil {
+			log.Fatalf("failed to delete tag alias: %+v", err)
+		}
+
+		log.Printf("deleted alias %v", args[0])
+	},
+}
+
+func init() {
+	tagsCmd.AddCommand(tagsRenameCmd)
+	tagsCmd.AddCommand(tagsMergeCmd)
+	tagsCmd.AddCommand(tagsAliasesCmd)
+	tagsCmd.AddCommand(tagsAliasCmd)
+	tagsCmd.AddCommand(tagsUnaliasCmd)
+
+	rootCmd.AddCommand(tagsCmd)
+}
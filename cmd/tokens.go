@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/spf13/cobra"
+)
+
+var tokensCmd = &cobra.Command{
+	Use:   "tokens <username>",
+	Short: "List a user's API tokens",
+	Args:  cobra.ExactArgs(1),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if app.config.UsePostgres {
+			app.requireMigrated()
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		user := findUser(args[0])
+		tokens, err := app.tokens.ForUser(user.ID)
+		if err != nil {
+			log.Fatalf("failed to list tokens: %+v", err)
+		}
+
+		for _, token := range tokens {
+			fmt.Printf("%v\t%v\t%v\t%v\n", token.ID, token.Name, strings.Join(token.Scopes, ","), token.TimeCreated)
+		}
+	},
+}
+
+var tokensCreateScopes = []string{videostore.ScopeRead}
+
+var tokensCreateCmd = &cobra.Command{
+	Use:   "create <username> <name>",
+	Short: "Create an API token, printing it to stdout",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		user := findUser(args[0])
+		token, secret, err := videostore.NewAPIToken(user, args[1], tokensCreateScopes)
+		if err != nil {
+			log.Fatalf("failed to create token: %+v", err)
+		}
+
+		token, err = app.tokens.Save(token)
+		if err != nil {
+			log.Fatalf("failed to save token: %+v", err)
+		}
+
+		log.Printf("created token %v for %v, it won't be shown again", token.ID, user.Username)
+		fmt.Println(secret)
+	},
+}
+
+var tokensRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Short: "Revoke an API token",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("bad token id %v", args[0])
+		}
+
+		token, err := app.tokens.FindById(uint(id))
+		if err != nil {
+			log.Fatalf("failed to find token %v: %+v", id, err)
+		}
+		if err := app.tokens.Delete(token); err != nil {
+			log.Fatalf("failed to revoke token: %+v", err)
+		}
+		log.Printf("revoked token %v", token.ID)
+	},
+}
+
+func init() {
+	tokensCreateCmd.Flags().StringSliceVar(&tokensCreateScopes, "scopes", tokensCreateScopes, "some of read, upload, edit or delete")
+
+	tokensCmd.AddCommand(tokensCreateCmd)
+	tokensCmd.AddCommand(tokensRevokeCmd)
+
+	rootCmd.AddCommand(tokensCmd)
+}
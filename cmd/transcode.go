@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/spf13/cobra"
+)
+
+var transcodeAllVideos = false
+
+var transcodeCommand = &cobra.Command{
+	Use:   "transcode [-a to transcode all] [video ids]",
+	Short: "Generate HLS renditions for given video, or all videos",
+	Run: func(cmd *cobra.Command, args []string) {
+		eachVideo(makeVideoGetter(transcodeAllVideos, args), func(video videostore.Video) {
+			transcoded, err := videostore.TranscodeVideo(video, app.repo, app.fs)
+			if err == nil {
+				log.Printf("transcoded %+v to %v", video.ID, transcoded.Renditions)
+			} else {
+				log.Printf("failed to transcode %+v: %+v", video.ID, err)
+			}
+		})
+	},
+}
+
+func init() {
+	transcodeCommand.Flags().BoolVarP(&transcodeAllVideos, "all", "a", false, "if true, transcode _all_ videos")
+
+	rootCmd.AddCommand(transcodeCommand)
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/spf13/cobra"
+)
+
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "List users who can log in",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if app.config.UsePostgres {
+			app.requireMigrated()
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		users, err := app.users.All()
+		if err != nil {
+			log.Fatalf("failed to list users: %+v", err)
+		}
+
+		for _, user := range users {
+			fmt.Printf("%v\t%v\t%v\n", user.ID, user.Username, user.Role)
+		}
+	},
+}
+
+// readPassword takes the first line of stdin, so it can be piped in
+func readPassword() string {
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalf("failed to read password: %+v", err)
+	}
+	return strings.TrimRight(line, "\r\n")
+}
+
+func findUser(username string) videostore.User {
+	user, err := app.users.FindByUsername(username)
+	if err != nil {
+		log.Fatalf("failed to find user %v: %+v", username, err)
+	}
+	return user
+}
+
+func savePassword(user videostore.User) videostore.User {
+	if err := user.SetPassword(readPassword()); err != nil {
+		log.Fatalf("failed to set password: %+v", err)
+	}
+
+	user, err := app.users.Save(user)
+	if err != nil {
+		log.Fatalf("failed to save user: %+v", err)
+	}
+	return user
+}
+
+var usersAddRole = videostore.RoleUploader
+
+var usersAddCmd = &cobra.Command{
+	Use:   "add <username>",
+	Short: "Add a user, reading their password from stdin",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !videostore.ValidRole(usersAddRole) {
+			log.Fatal(videostore.ErrorRoleInvalid)
+		}
+		user := savePassword(videostore.User{Username: args[0], Role: usersAddRole})
+		log.Printf("added %v %v (%v)", user.Role, user.Username, user.ID)
+	},
+}
+
+var usersPasswdCmd = &cobra.Command{
+	Use:   "passwd <username>",
+	Short: "Change a user's password, reading it from stdin. This logs them out everywhere.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		user := savePassword(findUser(args[0]))
+		log.Printf("changed password of %v", user.Username)
+	},
+}
+
+var usersRoleCmd = &cobra.Command{
+	Use:   "role <username> <viewer|uploader|editor|admin>",
+	Short: "Change what a user is allowed to do",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		user := findUser(args[0])
+		user.Role = args[1]
+		user, err := app.users.Save(user)
+		if err != nil {
+			log.Fatalf("failed to save user: %+v", err)
+		}
+		log.Printf("%v is now %v", user.Username, user.Role)
+	},
+}
+
+var usersHashCmd = &cobra.Command{
+	Use:   "hash",
+	Short: "Hash a password read from stdin, for use in CREAMY_USERS",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var user videostore.User
+		if err := user.SetPassword(readPassword()); err != nil {
+			log.Fatalf("failed to hash password: %+v", err)
+		}
+		fmt.Println(user.PasswordHash)
+	},
+}
+
+var usersDeleteCmd = &cobra.Command{
+	Use:   "delete <username>",
+	Short: "Delete a user",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		user := findUser(args[0])
+		if err := app.users.Delete(user); err != nil {
+			log.Fatalf("failed to delete user: %+v", err)
+		}
+		log.Printf("deleted user %v", user.Username)
+	},
+}
+
+func init() {
+	usersAddCmd.Flags().StringVar(&usersAddRole, "role", usersAddRole, "one of viewer, uploader, editor or admin")
+
+	usersCmd.AddCommand(usersAddCmd)
+	usersCmd.AddCommand(usersPasswdCmd)
+	usersCmd.AddCommand(usersRoleCmd)
+	usersCmd.AddCommand(usersHashCmd)
+	usersCmd.AddCommand(usersDeleteCmd)
+
+	rootCmd.AddCommand(usersCmd)
+}
@@ -0,0 +1,351 @@
+package files
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+// Encrypted files are laid out as:
+//
+//	magic (8 bytes) | salt (32 bytes) | chunk 0 | chunk 1 | ... | final chunk
+//
+// Each chunk holds up to encryptedChunkSize bytes of plaintext sealed with
+// AES-256-GCM under a key derived from the filesystem secret and the per-file
+// salt. The nonce is the chunk index, with a flag marking the final chunk so
+// a truncated file fails authentication instead of silently losing its tail.
+// Fixed-size chunks let us seek without decrypting everything before the
+// requested offset, which http.FileServer needs for range requests.
+const (
+	encryptedMagic      = "CREAMYE1"
+	encryptedSaltSize   = 32
+	encryptedHeaderSize = len(encryptedMagic) + encryptedSaltSize
+	encryptedChunkSize  = 64 * 1024
+	encryptedTagSize    = 16
+	encryptedSealedSize = encryptedChunkSize + encryptedTagSize
+)
+
+// ErrEncryptedFileInvalid is returned when a file does not look like
+// something written by EncryptedFileSystem, or fails authentication.
+var ErrEncryptedFileInvalid = errors.New("encrypted file is invalid or was tampered with")
+
+type encryptedFileSystem struct {
+	FileSystem
+	secret []byte
+}
+
+// EncryptedFileSystem wraps fs so all file contents are encrypted at rest
+// using chunked AES-256-GCM with a random per-file salt.
+// Opened files remain seekable and report their plaintext size.
+func EncryptedFileSystem(fs FileSystem, secret []byte) FileSystem {
+	return encryptedFileSystem{
+		fs,
+		secret,
+	}
+}
+
+func (fs encryptedFileSystem) aead(salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, fs.secret)
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptedNonce(index uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, index)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptedPlaintextSize converts the stored size of an encrypted file
+// into the size of the plaintext it holds.
+func encryptedPlaintextSize(storedSize int64) (int64, error) {
+	body := storedSize - int64(encryptedHeaderSize)
+	if body < encryptedTagSize {
+		return 0, ErrEncryptedFileInvalid
+	}
+
+	chunks := body / encryptedSealedSize
+	if remainder := body % encryptedSealedSize; remainder != 0 {
+		if remainder < encryptedTagSize {
+			return 0, ErrEncryptedFileInvalid
+		}
+		chunks++
+	}
+
+	return body - chunks*encryptedTagSize, nil
+}
+
+type sizedFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (fi sizedFileInfo) Size() int64 {
+	return fi.size
+}
+
+func plaintextFileInfo(info os.FileInfo) (os.FileInfo, error) {
+	if info.IsDir() {
+		return info, nil
+	}
+	size, err := encryptedPlaintextSize(info.Size())
+	if err != nil {
+		return nil, err
+	}
+	return sizedFileInfo{info, size}, nil
+}
+
+func (fs encryptedFileSystem) Stat(name string) (os.FileInfo, error) {
+	info, err := fs.FileSystem.Stat(name)
+	if err != nil {
+		return info, err
+	}
+	return plaintextFileInfo(info)
+}
+
+func (fs encryptedFileSystem) Create(name string) (WriteableFile, error) {
+	salt := make([]byte, encryptedSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := fs.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fs.FileSystem.Create(name)
+	if err != nil {
+		return file, err
+	}
+
+	if _, err := file.Write(append([]byte(encryptedMagic), salt...)); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &writeableEncryptedFile{
+		file: file,
+		aead: aead,
+		buf:  make([]byte, 0, encryptedChunkSize),
+	}, nil
+}
+
+func (fs encryptedFileSystem) Open(path string) (ReadableFile, error) {
+	file, err := fs.FileSystem.Open(path)
+	if err != nil {
+		return file, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if stat.IsDir() {
+		return readableEncryptedDirectory{file}, nil
+	}
+
+	size, err := encryptedPlaintextSize(stat.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	header := make([]byte, encryptedHeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if string(header[:len(encryptedMagic)]) != encryptedMagic {
+		file.Close()
+		return nil, ErrEncryptedFileInvalid
+	}
+
+	aead, err := fs.aead(header[len(encryptedMagic):])
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &readableEncryptedFile{
+		ReadableFile: file,
+		aead:         aead,
+		info:         sizedFileInfo{stat, size},
+		size:         size,
+		storedSize:   stat.Size(),
+		chunkIndex:   -1,
+	}, nil
+}
+
+type writeableEncryptedFile struct {
+	file  WriteableFile
+	aead  cipher.AEAD
+	buf   []byte
+	index uint64
+
+	// after a failed write or once closed, nothing else is sealed,
+	// so a stray Close can't append to a file written since
+	closed bool
+	err    error
+}
+
+func (f *writeableEncryptedFile) seal(chunk []byte, final bool) error {
+	sealed := f.aead.Seal(nil, encryptedNonce(f.index, final), chunk, nil)
+	f.index++
+	_, err := f.file.Write(sealed)
+	return err
+}
+
+func (f *writeableEncryptedFile) Write(p []byte) (n int, err error) {
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.err != nil {
+		return 0, f.err
+	}
+
+	for len(p) > 0 {
+		// only seal a full chunk once we know more data follows it,
+		// otherwise Close would have nothing left to mark as final
+		if len(f.buf) == encryptedChunkSize {
+			if err := f.seal(f.buf, false); err != nil {
+				f.err = err
+				return n, err
+			}
+			f.buf = f.buf[:0]
+		}
+
+		copied := copy(f.buf[len(f.buf):encryptedChunkSize], p)
+		f.buf = f.buf[:len(f.buf)+copied]
+		p = p[copied:]
+		n += copied
+	}
+
+	return n, nil
+}
+
+func (f *writeableEncryptedFile) Close() error {
+	if f.closed {
+		return f.err
+	}
+	f.closed = true
+
+	if f.err == nil {
+		f.err = f.seal(f.buf, true)
+	}
+	f.buf = f.buf[:0]
+	if closeErr := f.file.Close(); f.err == nil {
+		f.err = closeErr
+	}
+	return f.err
+}
+
+type readableEncryptedFile struct {
+	ReadableFile
+	aead       cipher.AEAD
+	info       os.FileInfo
+	size       int64
+	storedSize int64
+	pos        int64
+	chunk      []byte
+	chunkIndex int64
+}
+
+func (f *readableEncryptedFile) loadChunk(index int64) error {
+	if f.chunkIndex == index {
+		return nil
+	}
+
+	offset := int64(encryptedHeaderSize) + index*encryptedSealedSize
+	length := f.storedSize - offset
+	if length > encryptedSealedSize {
+		length = encryptedSealedSize
+	}
+
+	if _, err := f.ReadableFile.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(f.ReadableFile, sealed); err != nil {
+		return err
+	}
+
+	final := offset+length == f.storedSize
+	chunk, err := f.aead.Open(sealed[:0], encryptedNonce(uint64(index), final), sealed, nil)
+	if err != nil {
+		f.chunkIndex = -1
+		return ErrEncryptedFileInvalid
+	}
+
+	f.chunk = chunk
+	f.chunkIndex = index
+	return nil
+}
+
+func (f *readableEncryptedFile) Read(p []byte) (n int, err error) {
+	if f.pos >= f.size {
+		return 0, io.EOF
+	}
+
+	index := f.pos / encryptedChunkSize
+	if err := f.loadChunk(index); err != nil {
+		return 0, err
+	}
+
+	n = copy(p, f.chunk[f.pos-index*encryptedChunkSize:])
+	f.pos += int64(n)
+	return n, nil
+}
+
+func (f *readableEncryptedFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return f.pos, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return f.pos, errors.New("negative position")
+	}
+
+	f.pos = offset
+	return f.pos, nil
+}
+
+func (f *readableEncryptedFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+type readableEncryptedDirectory struct {
+	ReadableFile
+}
+
+func (d readableEncryptedDirectory) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := d.ReadableFile.Readdir(count)
+	for i, info := range infos {
+		if plaintextInfo, plaintextErr := plaintextFileInfo(info); plaintextErr == nil {
+			infos[i] = plaintextInfo
+		}
+	}
+	return infos, err
+}
@@ -0,0 +1,170 @@
+package files
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func writeEncrypted(t *testing.T, fs FileSystem, name string, b []byte) {
+	err := PipeTo(fs, name, bytes.NewReader(b))
+	assert.Nil(t, err)
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	root := "test-encrypted-roundtrip"
+
+	efs := EncryptedFileSystem(LocalFileSystem(root), testSecret)
+	defer os.RemoveAll(root)
+
+	sizes := []int{
+		0,
+		1,
+		encryptedChunkSize - 1,
+		encryptedChunkSize,
+		encryptedChunkSize + 1,
+		encryptedChunkSize*3 + 1234,
+	}
+
+	for _, size := range sizes {
+		b := make([]byte, size)
+		rand.Read(b)
+
+		writeEncrypted(t, efs, "foo.bin", b)
+
+		stored, err := os.ReadFile(path.Join(root, "foo.bin"))
+		assert.Nil(t, err)
+		// a byte or two can turn up in the ciphertext by chance
+		if size > 16 {
+			assert.False(t, bytes.Contains(stored, b), "plaintext should not be stored")
+		}
+
+		stat, err := efs.Stat("foo.bin")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(size), stat.Size())
+
+		file, err := efs.Open("foo.bin")
+		assert.Nil(t, err)
+		readBytes, err := io.ReadAll(file)
+		file.Close()
+
+		assert.Nil(t, err)
+		assert.Equal(t, b, readBytes)
+	}
+}
+
+func TestEncryptedSeek(t *testing.T) {
+	root := "test-encrypted-seek"
+
+	efs := EncryptedFileSystem(LocalFileSystem(root), testSecret)
+	defer os.RemoveAll(root)
+
+	b := make([]byte, encryptedChunkSize*2+500)
+	rand.Read(b)
+	writeEncrypted(t, efs, "foo.bin", b)
+
+	file, err := efs.Open("foo.bin")
+	assert.Nil(t, err)
+	defer file.Close()
+
+	// http.ServeContent finds the size by seeking to the end
+	end, err := file.Seek(0, io.SeekEnd)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(b)), end)
+
+	offsets := []int64{
+		encryptedChunkSize + 10,
+		5,
+		encryptedChunkSize - 3,
+		int64(len(b)) - 20,
+	}
+	for _, offset := range offsets {
+		pos, err := file.Seek(offset, io.SeekStart)
+		assert.Nil(t, err)
+		assert.Equal(t, offset, pos)
+
+		readBytes := make([]byte, 16)
+		_, err = io.ReadFull(file, readBytes)
+		assert.Nil(t, err)
+		assert.Equal(t, b[offset:offset+16], readBytes)
+	}
+}
+
+func TestEncryptedTampering(t *testing.T) {
+	root := "test-encrypted-tampering"
+
+	efs := EncryptedFileSystem(LocalFileSystem(root), testSecret)
+	defer os.RemoveAll(root)
+
+	b := make([]byte, encryptedChunkSize+100)
+	rand.Read(b)
+	writeEncrypted(t, efs, "foo.bin", b)
+
+	stored, err := os.ReadFile(path.Join(root, "foo.bin"))
+	assert.Nil(t, err)
+
+	// flipped byte
+	flipped := append([]byte{}, stored...)
+	flipped[encryptedHeaderSize+10] ^= 0x01
+	assert.Nil(t, os.WriteFile(path.Join(root, "flipped.bin"), flipped, 0644))
+
+	file, err := efs.Open("flipped.bin")
+	assert.Nil(t, err)
+	_, err = io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, ErrEncryptedFileInvalid, err)
+
+	// truncated to a chunk boundary
+	truncated := stored[:encryptedHeaderSize+encryptedSealedSize]
+	assert.Nil(t, os.WriteFile(path.Join(root, "truncated.bin"), truncated, 0644))
+
+	file, err = efs.Open("truncated.bin")
+	assert.Nil(t, err)
+	_, err = io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, ErrEncryptedFileInvalid, err)
+
+	// wrong key
+	wrongKey := EncryptedFileSystem(LocalFileSystem(root), []byte("not the right key at all, sorry!"))
+	file, err = wrongKey.Open("foo.bin")
+	assert.Nil(t, err)
+	_, err = io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, ErrEncryptedFileInvalid, err)
+}
+
+func TestEncryptedCloseTwice(t *testing.T) {
+	root := "test-encrypted-close-twice"
+
+	efs := EncryptedFileSystem(LocalFileSystem(root), testSecret)
+	defer os.RemoveAll(root)
+
+	first, err := efs.Create("foo.bin")
+	assert.Nil(t, err)
+	_, err = first.Write([]byte("abandoned"))
+	assert.Nil(t, err)
+	assert.Nil(t, first.Close())
+
+	b := []byte("replacement")
+	writeEncrypted(t, efs, "foo.bin", b)
+
+	// a second close must not append another chunk to the new file
+	assert.Nil(t, first.Close())
+	_, err = first.Write([]byte("more"))
+	assert.Equal(t, os.ErrClosed, err)
+
+	file, err := efs.Open("foo.bin")
+	assert.Nil(t, err)
+	readBytes, err := io.ReadAll(file)
+	file.Close()
+
+	assert.Nil(t, err)
+	assert.Equal(t, b, readBytes)
+}
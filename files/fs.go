@@ -3,6 +3,7 @@ package files
 import (
 	"io"
 	"os"
+	"path"
 )
 
 // ReadableFile is a file that we can read data from
@@ -33,9 +34,83 @@ func PipeTo(fs FileSystem, filePath string, reader io.Reader) error {
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
 	_, err = io.Copy(file, reader)
 
+	// some filesystems only finish writing on close
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+
 	return err
 }
+
+// WalkFunc is called by Walk for every file and directory it visits
+type WalkFunc func(name string, info os.FileInfo) error
+
+// Walk calls fn for root and everything below it, depth-first
+func Walk(fs FileSystem, root string, fn WalkFunc) error {
+	info, err := fs.Stat(root)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(root, info); err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return nil
+	}
+
+	dir, err := fs.Open(root)
+	if err != nil {
+		return err
+	}
+	children, err := dir.Readdir(-1)
+	dir.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		if err := Walk(fs, path.Join(root, child.Name()), fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveAll removes name and, if it is a directory, everything below it.
+// It returns nil if name does not exist.
+func RemoveAll(fs FileSystem, name string) error {
+	info, err := fs.Stat(name)
+	if fs.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		dir, err := fs.Open(name)
+		if err != nil {
+			return err
+		}
+		children, err := dir.Readdir(-1)
+		dir.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, child := range children {
+			if err := RemoveAll(fs, path.Join(name, child.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return fs.Remove(name)
+}
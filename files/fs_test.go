@@ -0,0 +1,30 @@
+package files
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveAll(t *testing.T) {
+	root := "test-removeall"
+	fs := LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	assert.Nil(t, fs.MkdirAll("1/hls/720p", os.ModePerm))
+	assert.Nil(t, PipeTo(fs, "1/video.mp4", strings.NewReader("video")))
+	assert.Nil(t, PipeTo(fs, "1/hls/master.m3u8", strings.NewReader("playlist")))
+	assert.Nil(t, PipeTo(fs, "1/hls/720p/segment000.ts", strings.NewReader("segment")))
+
+	assert.Nil(t, RemoveAll(fs, "1/hls"))
+
+	_, err := fs.Stat("1/hls")
+	assert.True(t, fs.IsNotExist(err))
+	_, err = fs.Stat("1/video.mp4")
+	assert.Nil(t, err)
+
+	// removing something that isn't there is fine
+	assert.Nil(t, RemoveAll(fs, "1/hls"))
+}
@@ -0,0 +1,742 @@
+package files
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const s3DefaultPartSize = 8 * 1024 * 1024
+
+// s3 accepts this instead of a body hash, letting us stream uploads
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Config locates a bucket on Amazon S3,
+// or anything compatible with it like MinIO
+type S3Config struct {
+	Endpoint  string // scheme and host, like https://s3.us-east-1.amazonaws.com
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// Prefix is prepended to every key, so several libraries can share a bucket
+	Prefix string
+	// PartSize is how much of an upload is buffered in memory before it is sent.
+	// S3 rejects parts smaller than 5 MiB.
+	PartSize int64
+	Client   *http.Client
+}
+
+type s3FileSystem struct {
+	config   S3Config
+	endpoint *url.URL
+}
+
+// S3FileSystem stores files as objects in an S3-compatible bucket.
+// Buckets have no directories: MkdirAll does nothing, and any key
+// with others below it is treated as a directory.
+func S3FileSystem(config S3Config) (FileSystem, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3 endpoint %q must include a scheme and host", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket must be set")
+	}
+	if config.PartSize <= 0 {
+		config.PartSize = s3DefaultPartSize
+	}
+	if config.Client == nil {
+		config.Client = http.DefaultClient
+	}
+
+	return s3FileSystem{
+		config,
+		endpoint,
+	}, nil
+}
+
+func (fs s3FileSystem) key(name string) string {
+	return strings.TrimPrefix(path.Join(fs.config.Prefix, path.Clean("/"+name)), "/")
+}
+
+// objectURL builds a path-style URL, which works with MinIO without extra DNS setup
+func (fs s3FileSystem) objectURL(key string, query url.Values) *url.URL {
+	u := *fs.endpoint
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + fs.config.Bucket + "/" + key
+	u.RawPath = s3EscapePath(u.Path)
+	u.RawQuery = s3CanonicalQuery(query)
+	return &u
+}
+
+func (fs s3FileSystem) do(method string, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, fs.objectURL(key, query).String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	signS3Request(req, fs.config.AccessKey, fs.config.SecretKey, fs.config.Region, s3UnsignedPayload, time.Now())
+
+	resp, err := fs.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &os.PathError{Op: method, Path: key, Err: os.ErrNotExist}
+	}
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("s3 %v %v: %v %s", method, key, resp.Status, message)
+}
+
+func (fs s3FileSystem) MkdirAll(dirPath string, perm os.FileMode) error {
+	return nil
+}
+
+func (fs s3FileSystem) Create(name string) (WriteableFile, error) {
+	return &s3WriteableFile{
+		fs:  fs,
+		key: fs.key(name),
+	}, nil
+}
+
+func (fs s3FileSystem) Remove(name string) error {
+	resp, err := fs.do("DELETE", fs.key(name), nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (fs s3FileSystem) Stat(name string) (os.FileInfo, error) {
+	key := fs.key(name)
+	if key == strings.TrimPrefix(path.Clean("/"+fs.config.Prefix), "/") {
+		return s3FileInfo{name: path.Base("/" + key), dir: true}, nil
+	}
+
+	resp, err := fs.do("HEAD", key, nil, nil, nil)
+	if err == nil {
+		resp.Body.Close()
+		modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+		return s3FileInfo{
+			name:    path.Base(key),
+			size:    resp.ContentLength,
+			modTime: modTime,
+		}, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	result, err := fs.list(key+"/", "", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Contents) > 0 || len(result.CommonPrefixes) > 0 {
+		return s3FileInfo{name: path.Base(key), dir: true}, nil
+	}
+
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+func (fs s3FileSystem) IsNotExist(err error) bool {
+	return os.IsNotExist(err)
+}
+
+func (fs s3FileSystem) Open(name string) (ReadableFile, error) {
+	info, err := fs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return &s3Directory{
+			fs:   fs,
+			key:  fs.key(name),
+			info: info,
+		}, nil
+	}
+
+	return &s3ReadableFile{
+		fs:   fs,
+		key:  fs.key(name),
+		info: info,
+	}, nil
+}
+
+type s3ListResult struct {
+	IsTruncated           bool
+	NextContinuationToken string
+	Contents              []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	CommonPrefixes []struct {
+		Prefix string
+	}
+}
+
+// list returns a single page of keys under prefix,
+// grouping anything below the next "/" into CommonPrefixes
+func (fs s3FileSystem) list(prefix string, continuationToken string, maxKeys int) (s3ListResult, error) {
+	var result s3ListResult
+
+	query := url.Values{
+		"list-type": {"2"},
+		"prefix":    {prefix},
+		"delimiter": {"/"},
+	}
+	if continuationToken != "" {
+		query.Set("continuation-token", continuationToken)
+	}
+	if maxKeys > 0 {
+		query.Set("max-keys", strconv.Itoa(maxKeys))
+	}
+
+	resp, err := fs.do("GET", "", query, nil, nil)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	return result, err
+}
+
+type s3FileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (info s3FileInfo) Name() string       { return info.name }
+func (info s3FileInfo) Size() int64        { return info.size }
+func (info s3FileInfo) ModTime() time.Time { return info.modTime }
+func (info s3FileInfo) IsDir() bool        { return info.dir }
+func (info s3FileInfo) Sys() any           { return nil }
+
+func (info s3FileInfo) Mode() os.FileMode {
+	if info.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// s3ReadableFile reads an object using ranged GETs.
+// Sequential reads share a single request, seeking starts a new one.
+type s3ReadableFile struct {
+	fs     s3FileSystem
+	key    string
+	info   os.FileInfo
+	offset int64
+
+	body       io.ReadCloser
+	bodyOffset int64
+}
+
+func (file *s3ReadableFile) Read(p []byte) (int, error) {
+	if file.offset >= file.info.Size() {
+		return 0, io.EOF
+	}
+
+	if file.body == nil || file.bodyOffset != file.offset {
+		file.closeBody()
+		resp, err := file.fs.do("GET", file.key, nil, http.Header{
+			"Range": {fmt.Sprintf("bytes=%v-", file.offset)},
+		}, nil)
+		if err != nil {
+			return 0, err
+		}
+		file.body = resp.Body
+		file.bodyOffset = file.offset
+	}
+
+	n, err := file.body.Read(p)
+	file.offset += int64(n)
+	file.bodyOffset += int64(n)
+
+	if err == io.EOF {
+		file.closeBody()
+		if file.offset < file.info.Size() {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+
+	return n, err
+}
+
+func (file *s3ReadableFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += file.offset
+	case io.SeekEnd:
+		offset += file.info.Size()
+	default:
+		return file.offset, fmt.Errorf("invalid whence %v", whence)
+	}
+
+	if offset < 0 {
+		return file.offset, fmt.Errorf("negative position %v", offset)
+	}
+
+	file.offset = offset
+	return offset, nil
+}
+
+func (file *s3ReadableFile) closeBody() {
+	if file.body != nil {
+		file.body.Close()
+		file.body = nil
+	}
+}
+
+func (file *s3ReadableFile) Close() error {
+	file.closeBody()
+	return nil
+}
+
+func (file *s3ReadableFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: file.key, Err: fmt.Errorf("not a directory")}
+}
+
+func (file *s3ReadableFile) Stat() (os.FileInfo, error) {
+	return file.info, nil
+}
+
+type s3Directory struct {
+	fs      s3FileSystem
+	key     string
+	info    os.FileInfo
+	entries []os.FileInfo
+	loaded  bool
+}
+
+func (dir *s3Directory) load() error {
+	prefix := dir.key + "/"
+	if dir.key == "" {
+		prefix = ""
+	}
+
+	token := ""
+	for {
+		result, err := dir.fs.list(prefix, token, 0)
+		if err != nil {
+			return err
+		}
+
+		for _, commonPrefix := range result.CommonPrefixes {
+			dir.entries = append(dir.entries, s3FileInfo{
+				name: path.Base(strings.TrimSuffix(commonPrefix.Prefix, "/")),
+				dir:  true,
+			})
+		}
+		for _, object := range result.Contents {
+			if object.Key == prefix {
+				// some tools leave empty "directory" objects behind
+				continue
+			}
+			dir.entries = append(dir.entries, s3FileInfo{
+				name:    path.Base(object.Key),
+				size:    object.Size,
+				modTime: object.LastModified,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Slice(dir.entries, func(i, j int) bool {
+		return dir.entries[i].Name() < dir.entries[j].Name()
+	})
+	dir.loaded = true
+	return nil
+}
+
+func (dir *s3Directory) Readdir(count int) ([]os.FileInfo, error) {
+	if !dir.loaded {
+		if err := dir.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	if count <= 0 {
+		entries := dir.entries
+		dir.entries = nil
+		return entries, nil
+	}
+
+	if len(dir.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(dir.entries) {
+		count = len(dir.entries)
+	}
+	entries := dir.entries[:count]
+	dir.entries = dir.entries[count:]
+	return entries, nil
+}
+
+func (dir *s3Directory) Read(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: dir.key, Err: fmt.Errorf("is a directory")}
+}
+
+func (dir *s3Directory) Seek(offset int64, whence int) (int64, error) {
+	return 0, &os.PathError{Op: "seek", Path: dir.key, Err: fmt.Errorf("is a directory")}
+}
+
+func (dir *s3Directory) Close() error {
+	return nil
+}
+
+func (dir *s3Directory) Stat() (os.FileInfo, error) {
+	return dir.info, nil
+}
+
+type s3CompletedPart struct {
+	PartNumber int
+	ETag       string
+}
+
+// s3WriteableFile buffers writes and sends them as a multipart upload,
+// or a single PUT if everything fits in one part.
+// Nothing is visible in the bucket until Close succeeds.
+type s3WriteableFile struct {
+	fs     s3FileSystem
+	key    string
+	buffer bytes.Buffer
+
+	uploadID string
+	parts    []s3CompletedPart
+	err      error
+	closed   bool
+}
+
+func (file *s3WriteableFile) contentHeader() http.Header {
+	header := http.Header{}
+	if contentType := mime.TypeByExtension(path.Ext(file.key)); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return header
+}
+
+func (file *s3WriteableFile) Write(p []byte) (int, error) {
+	if file.err != nil {
+		return 0, file.err
+	}
+	if file.closed {
+		return 0, os.ErrClosed
+	}
+
+	file.buffer.Write(p)
+	for int64(file.buffer.Len()) >= file.fs.config.PartSize {
+		if err := file.uploadPart(file.buffer.Next(int(file.fs.config.PartSize))); err != nil {
+			file.err = err
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (file *s3WriteableFile) uploadPart(data []byte) error {
+	if file.uploadID == "" {
+		resp, err := file.fs.do("POST", file.key, url.Values{"uploads": {""}}, file.contentHeader(), nil)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		var initiated struct {
+			UploadId string
+		}
+		if err := xml.NewDecoder(resp.Body).Decode(&initiated); err != nil {
+			return err
+		}
+		file.uploadID = initiated.UploadId
+	}
+
+	partNumber := len(file.parts) + 1
+	resp, err := file.fs.do("PUT", file.key, url.Values{
+		"partNumber": {strconv.Itoa(partNumber)},
+		"uploadId":   {file.uploadID},
+	}, nil, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	file.parts = append(file.parts, s3CompletedPart{
+		PartNumber: partNumber,
+		ETag:       resp.Header.Get("ETag"),
+	})
+	return nil
+}
+
+func (file *s3WriteableFile) Close() error {
+	if file.closed {
+		return file.err
+	}
+	file.closed = true
+
+	if file.err == nil {
+		file.err = file.finish()
+	}
+
+	if file.err != nil && file.uploadID != "" {
+		// don't leave orphaned parts around, they cost money
+		if resp, err := file.fs.do("DELETE", file.key, url.Values{"uploadId": {file.uploadID}}, nil, nil); err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	return file.err
+}
+
+func (file *s3WriteableFile) finish() error {
+	if file.uploadID == "" {
+		resp, err := file.fs.do("PUT", file.key, nil, file.contentHeader(), file.buffer.Bytes())
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	if file.buffer.Len() > 0 {
+		if err := file.uploadPart(file.buffer.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}{Parts: file.parts})
+	if err != nil {
+		return err
+	}
+
+	resp, err := file.fs.do("POST", file.key, url.Values{"uploadId": {file.uploadID}}, nil, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// completion can fail after the 200 has been sent
+	var completed struct {
+		XMLName xml.Name
+		Message string
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&completed); err != nil {
+		return err
+	}
+	if completed.XMLName.Local == "Error" {
+		return fmt.Errorf("s3 failed to complete upload of %v: %v", file.key, completed.Message)
+	}
+
+	return nil
+}
+
+// s3Escape percent-encodes everything but unreserved characters,
+// as required when building canonical requests
+func s3Escape(s string, keepSlash bool) string {
+	var sb strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', keepSlash && b == '/':
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+	return sb.String()
+}
+
+func s3EscapePath(p string) string {
+	return s3Escape(p, true)
+}
+
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, s3Escape(key, false)+"="+s3Escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+func s3SigningKey(secretKey string, date string, region string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	return hmacSHA256(key, "aws4_request")
+}
+
+func s3Signature(secretKey string, date string, region string, amzDate string, scope string, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex(canonicalRequest),
+	}, "\n")
+
+	return hex.EncodeToString(hmacSHA256(s3SigningKey(secretKey, date, region), stringToSign))
+}
+
+// presignS3URL signs u using query parameters instead of headers,
+// so it can be handed to a browser
+func presignS3URL(u *url.URL, accessKey string, secretKey string, region string, ttl time.Duration, now time.Time) string {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	scope := date + "/" + region + "/s3/aws4_request"
+
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", accessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalURI := u.EscapedPath()
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		canonicalURI,
+		s3CanonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+
+	query.Set("X-Amz-Signature", s3Signature(secretKey, date, region, amzDate, scope, canonicalRequest))
+
+	signed := *u
+	signed.RawQuery = s3CanonicalQuery(query)
+	return signed.String()
+}
+
+// SignURL returns a presigned GET URL for the object
+func (fs s3FileSystem) SignURL(name string, ttl time.Duration) (string, error) {
+	// S3 refuses anything longer than a week
+	if ttl > 7*24*time.Hour {
+		ttl = 7 * 24 * time.Hour
+	}
+	return presignS3URL(fs.objectURL(fs.key(name), nil), fs.config.AccessKey, fs.config.SecretKey, fs.config.Region, ttl, time.Now()), nil
+}
+
+// signS3Request adds AWS Signature Version 4 headers to req.
+// Host, Range, Content-Type and any X-Amz-* headers are signed.
+func signS3Request(req *http.Request, accessKey string, secretKey string, region string, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{
+		"host": host,
+	}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "range" || lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalURI := req.URL.EscapedPath()
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+
+	signature := s3Signature(secretKey, date, region, amzDate, scope, canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v",
+		accessKey, scope, signedHeaders, signature,
+	))
+}
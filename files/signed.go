@@ -0,0 +1,82 @@
+package files
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// URLSigner hands out short-lived URLs for files,
+// so they can be fetched without going through this server
+type URLSigner interface {
+	SignURL(name string, ttl time.Duration) (string, error)
+}
+
+type hmacURLSigner struct {
+	baseURL string
+	secret  []byte
+	now     func() time.Time
+}
+
+// HMACURLSigner signs URLs below baseURL that VerifySignedURLs accepts
+func HMACURLSigner(baseURL string, secret []byte) URLSigner {
+	return hmacURLSigner{
+		strings.TrimRight(baseURL, "/"),
+		secret,
+		time.Now,
+	}
+}
+
+func signedURLSignature(secret []byte, name string, expires string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(name + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (signer hmacURLSigner) SignURL(name string, ttl time.Duration) (string, error) {
+	name = path.Clean("/" + name)
+	expires := strconv.FormatInt(signer.now().Add(ttl).Unix(), 10)
+
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {signedURLSignature(signer.secret, name, expires)},
+	}
+
+	return signer.baseURL + (&url.URL{Path: name}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+// VerifySignedURLs only lets through requests for URLs signed by HMACURLSigner
+// with the same secret, that haven't expired yet
+func VerifySignedURLs(secret []byte, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		expires := query.Get("expires")
+		expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || time.Now().Unix() > expiresUnix {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		expected := signedURLSignature(secret, path.Clean("/"+r.URL.Path), expires)
+		if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		// the signed URL is good until it expires, and so is anything cached from it
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%v", expiresUnix-time.Now().Unix()))
+		next.ServeHTTP(w, r)
+	})
+}
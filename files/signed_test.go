@@ -0,0 +1,52 @@
+package files
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignedURLs(t *testing.T) {
+	secret := []byte("01234567890123456789012345678901")
+	signer := HMACURLSigner("https://media.example.com/", secret)
+
+	handler := VerifySignedURLs(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video"))
+	}))
+	get := func(target string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec.Code
+	}
+
+	signed, err := signer.SignURL("1/my video.mp4", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(signed, "https://media.example.com/1/my%20video.mp4?"))
+	assert.Equal(t, http.StatusOK, get(signed))
+
+	// signatures only work for the file they were made for
+	assert.Equal(t, http.StatusForbidden, get(strings.Replace(signed, "1/my%20video.mp4", "2/my%20video.mp4", 1)))
+	assert.Equal(t, http.StatusForbidden, get("https://media.example.com/1/my%20video.mp4"))
+
+	// or a different secret
+	other, _ := HMACURLSigner("https://media.example.com", []byte("another secret, just as long!!!!")).SignURL("1/my video.mp4", time.Minute)
+	assert.Equal(t, http.StatusForbidden, get(other))
+
+	// and not forever
+	expired := signer.(hmacURLSigner)
+	expired.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	old, _ := expired.SignURL("1/my video.mp4", time.Minute)
+	assert.Equal(t, http.StatusForbidden, get(old))
+
+	// extending the expiry breaks the signature
+	parsed, _ := url.Parse(old)
+	query := parsed.Query()
+	query.Set("expires", "99999999999")
+	parsed.RawQuery = query.Encode()
+	assert.Equal(t, http.StatusForbidden, get(parsed.String()))
+}
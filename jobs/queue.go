@@ -0,0 +1,196 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+)
+
+const (
+	defaultMaxAttempts  = 5
+	defaultPollInterval = 10 * time.Second
+)
+
+// Handler performs a single job. Returning an error schedules a retry,
+// unless the error is wrapped with Permanent or attempts have run out.
+type Handler func(job videostore.Job) error
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error as one that retrying won't fix,
+// like the job's video having been deleted
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// Backoff returns how long to wait before retrying a job
+// that has failed the given number of times
+func Backoff(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+	return delay
+}
+
+// Queue persists jobs to a JobRepo and runs them on a pool of workers
+type Queue struct {
+	Repo         videostore.JobRepo
+	MaxAttempts  int
+	PollInterval time.Duration
+	Backoff      func(attempts int) time.Duration
+
+	handlers     map[string]Handler
+	handlersLock sync.RWMutex
+	wake         chan struct{}
+}
+
+func NewQueue(repo videostore.JobRepo) *Queue {
+	return &Queue{
+		Repo:         repo,
+		MaxAttempts:  defaultMaxAttempts,
+		PollInterval: defaultPollInterval,
+		Backoff:      Backoff,
+		handlers:     map[string]Handler{},
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Handle registers the handler used to perform jobs of the given kind
+func (q *Queue) Handle(kind string, handler Handler) {
+	q.handlersLock.Lock()
+	defer q.handlersLock.Unlock()
+	q.handlers[kind] = handler
+}
+
+// Handles reports whether a handler is registered for kind
+func (q *Queue) Handles(kind string) bool {
+	_, ok := q.handler(kind)
+	return ok
+}
+
+func (q *Queue) handler(kind string) (Handler, bool) {
+	q.handlersLock.RLock()
+	defer q.handlersLock.RUnlock()
+	handler, ok := q.handlers[kind]
+	return handler, ok
+}
+
+// Enqueue persists a new job to be run as soon as a worker is free
+func (q *Queue) Enqueue(kind string, videoID uint) (videostore.Job, error) {
+	job, err := q.Repo.Save(videostore.Job{
+		Kind:        kind,
+		VideoID:     videoID,
+		Status:      videostore.JobStatusPending,
+		MaxAttempts: q.MaxAttempts,
+		RunAfter:    time.Now(),
+	})
+	if err != nil {
+		return job, err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+		// a worker has already been woken up
+	}
+
+	return job, nil
+}
+
+// RunOnce claims and performs a single job, returning
+// videostore.ErrorNoJobs if there was nothing to do
+func (q *Queue) RunOnce() error {
+	job, err := q.Repo.Claim(time.Now())
+	if err != nil {
+		return err
+	}
+
+	handler, ok := q.handler(job.Kind)
+	if ok {
+		err = q.perform(handler, job)
+	} else {
+		err = Permanent(fmt.Errorf("no handler for job kind %v", job.Kind))
+	}
+
+	if err == nil {
+		job.Status = videostore.JobStatusDone
+		job.LastError = ""
+	} else {
+		job.LastError = err.Error()
+		var permanent permanentError
+		if errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts {
+			job.Status = videostore.JobStatusFailed
+			log.Printf("job %v (%v for video %v) failed: %+v", job.ID, job.Kind, job.VideoID, err)
+		} else {
+			job.Status = videostore.JobStatusPending
+			job.RunAfter = time.Now().Add(q.Backoff(job.Attempts))
+			log.Printf("job %v (%v for video %v) failed, retrying after %v: %+v", job.ID, job.Kind, job.VideoID, job.RunAfter, err)
+		}
+	}
+
+	_, saveErr := q.Repo.Save(job)
+	return saveErr
+}
+
+// perform runs the handler, converting panics into errors
+// so one bad video can't take down the whole server
+func (q *Queue) perform(handler Handler, job videostore.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(job)
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for {
+		err := q.RunOnce()
+		if err == nil {
+			continue
+		}
+		if err != videostore.ErrorNoJobs {
+			log.Printf("error running job: %+v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-time.After(q.PollInterval):
+		}
+	}
+}
+
+// Start requeues jobs interrupted by a previous shutdown,
+// then runs workers until the context is cancelled
+func (q *Queue) Start(ctx context.Context, workers int) error {
+	if err := q.Repo.Requeue(); err != nil {
+		return err
+	}
+
+	for i := 0; i < workers; i++ {
+		go q.work(ctx)
+	}
+
+	return nil
+}
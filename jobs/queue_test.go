@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, Backoff(1))
+	assert.Equal(t, 60*time.Second, Backoff(2))
+	assert.Equal(t, 120*time.Second, Backoff(3))
+	assert.Equal(t, time.Hour, Backoff(100))
+}
+
+func TestQueueRetries(t *testing.T) {
+	root := "test-queue-retries"
+	defer os.RemoveAll(root)
+
+	q := NewQueue(videostore.NewDummyJobRepo(files.LocalFileSystem(root)))
+	q.MaxAttempts = 3
+	q.Backoff = func(attempts int) time.Duration { return 0 }
+
+	calls := 0
+	q.Handle("flaky", func(job videostore.Job) error {
+		calls++
+		if calls < 2 {
+			return errors.New("try again")
+		}
+		return nil
+	})
+
+	job, err := q.Enqueue("flaky", 1)
+	assert.Nil(t, err)
+
+	assert.Nil(t, q.RunOnce())
+	job, _ = q.Repo.FindById(job.ID)
+	assert.Equal(t, videostore.JobStatusPending, job.Status)
+	assert.Equal(t, "try again", job.LastError)
+
+	assert.Nil(t, q.RunOnce())
+	job, _ = q.Repo.FindById(job.ID)
+	assert.Equal(t, videostore.JobStatusDone, job.Status)
+	assert.Equal(t, 2, job.Attempts)
+
+	assert.Equal(t, videostore.ErrorNoJobs, q.RunOnce())
+}
+
+func TestQueueGivesUp(t *testing.T) {
+	root := "test-queue-gives-up"
+	defer os.RemoveAll(root)
+
+	q := NewQueue(videostore.NewDummyJobRepo(files.LocalFileSystem(root)))
+	q.MaxAttempts = 2
+	q.Backoff = func(attempts int) time.Duration { return 0 }
+
+	q.Handle("broken", func(job videostore.Job) error {
+		return errors.New("nope")
+	})
+	q.Handle("gone", func(job videostore.Job) error {
+		return Permanent(videostore.ErrorVideoNotFound)
+	})
+
+	broken, _ := q.Enqueue("broken", 1)
+	gone, _ := q.Enqueue("gone", 2)
+	unknown, _ := q.Enqueue("unknown", 3)
+
+	for q.RunOnce() == nil {
+	}
+
+	broken, _ = q.Repo.FindById(broken.ID)
+	assert.Equal(t, videostore.JobStatusFailed, broken.Status)
+	assert.Equal(t, 2, broken.Attempts)
+
+	gone, _ = q.Repo.FindById(gone.ID)
+	assert.Equal(t, videostore.JobStatusFailed, gone.Status)
+	assert.Equal(t, 1, gone.Attempts)
+
+	unknown, _ = q.Repo.FindById(unknown.ID)
+	assert.Equal(t, videostore.JobStatusFailed, unknown.Status)
+}
+
+func TestQueueRequeuesInterruptedJobs(t *testing.T) {
+	root := "test-queue-requeue"
+	defer os.RemoveAll(root)
+
+	fs := files.LocalFileSystem(root)
+	q := NewQueue(videostore.NewDummyJobRepo(fs))
+
+	job, _ := q.Enqueue("thumbnail", 1)
+	claimed, err := q.Repo.Claim(time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, job.ID, claimed.ID)
+
+	// pretend the server restarted mid-job
+	restarted := NewQueue(videostore.NewDummyJobRepo(fs))
+	assert.Nil(t, restarted.Repo.Requeue())
+
+	job, _ = restarted.Repo.FindById(job.ID)
+	assert.Equal(t, videostore.JobStatusPending, job.Status)
+}
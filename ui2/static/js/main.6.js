@@ -0,0 +1,545 @@
+/**
+ * Submit the form containing the element
+ * @param {HTMLElement} el
+ */
+window.cvSubmitNearestForm = function (el) {
+  var form = null;
+  var parent = el;
+  for (var i = 0; i < 10; i += 1) {
+    parent = parent.parentNode;
+    if (!parent) {
+      break;
+    }
+    if (parent.tagName === 'FORM') {
+      form = parent;
+      break;
+    }
+  }
+  if (!form) {
+    console.error('Element: ', el);
+    throw new Error('No form found around element');
+  }
+
+  form.submit();
+};
+
+/**
+ * Replace the content of a page entirely
+ * @param {string} html 
+ */
+window.cvReplacePage = function (html) {
+  var doc = document.open();
+  doc.write(html);
+  doc.close();
+};
+
+// when we're looking at a list of videos
+// and we click one
+// the video should automatically play
+// -----
+// the reality:
+// 1. we click a video link
+// 2. the user-interaction context is lost for the new page
+// 3. the browser complains and doesn't play the video
+// -----
+// the solution: use SPA-style page loading.
+// I tried using hx-boost (HTMX) originally,
+// but I had some issues with videos continuing to play in the background,
+// the page scrolling to the bottom weirdly, etc.
+// the below code works great on my machine:
+window.addEventListener('popstate', function (e) {
+  // when the user goes back, reload the page from the server
+  if (e.state && e.state.scrollY) {
+    window.sessionStorage.setItem('cvBoostScrollY', e.state.scrollY);
+  }
+  window.location.reload();
+});
+var cvBoostScrollY = window.sessionStorage.getItem('cvBoostScrollY');
+if (cvBoostScrollY) {
+  cvBoostScrollY = parseInt(cvBoostScrollY, 10);
+  window.sessionStorage.removeItem('cvBoostScrollY');
+  window.scroll({ top: cvBoostScrollY });
+}
+window.cvPerformBind = function () {
+  // fix above user-interaction issue
+  document.querySelectorAll('a[cv-boost="true"]').forEach(function (el) {
+    if (el.cvBoundBoost) {
+      return;
+    }
+    el.cvBoundBoost = true;
+
+    el.addEventListener('click', function (e) {
+      if (e.ctrlKey) {
+        return; // user is attempting to open this in a new window!
+      }
+      e.preventDefault();
+      var target = el.getAttribute('href');
+      fetch(target)
+        .then(function (resp) {
+          return resp.text();
+        })
+        .then(function (text) {
+          window.history.replaceState({ scrollY: window.scrollY }, '');
+          window.history.pushState({}, '', target);
+          window.cvReplacePage(text);
+        })
+        .catch(function (ex) {
+          // try to fallback to regular navigation if we break something
+          console.error(ex);
+          window.location.href = target;
+          throw ex;
+        });
+    });
+  });
+
+  // click a button multiple times to submit a form
+  document.querySelectorAll('a[cv-confirm]').forEach(function (el) {
+    if (el.cvBoundConfirm) {
+      return;
+    }
+    el.cvBoundConfirm = true;
+
+    var clicks = 0;
+    var requiredClicks = 4;
+    var timeoutHandle;
+    var originalText = el.innerText;
+
+    var updateText = function () {
+      if (clicks === 0) {
+        el.innerText = originalText;
+      } else {
+        var remainingClicks = requiredClicks - clicks;
+        el.innerText = [
+          'Click',
+          remainingClicks,
+          'more',
+          remainingClicks === 1 ? 'time' : 'times',
+          'to confirm',
+        ].join(' ');
+      }
+    };
+
+    var resetClicks = function () {
+      clicks = 0;
+      timeoutHandle = false;
+      updateText();
+    };
+
+    var target = document.querySelector(el.getAttribute('cv-confirm'));
+    if (!target) {
+      console.error('cv-confirm element target not found!', el);
+      return;
+    }
+    el.addEventListener('click', function (e) {
+      e.preventDefault();
+      clearTimeout(timeoutHandle);
+      clicks += 1;
+      if (clicks >= requiredClicks) {
+        clicks = 0;
+        updateText();
+        target.submit();
+      } else {
+        updateText();
+        timeoutHandle = setTimeout(resetClicks, 2000);
+      }
+    });
+  });
+
+  // if a file input is filled, default a text input to the file's name
+  document.querySelectorAll('input[type="file"][cv-filename-default-to]').forEach(function (el) {
+    if (el.cvBoundFilenameDefault) {
+      return;
+    }
+    el.cvBoundFilenameDefault = true;
+
+    var target = document.querySelector(el.getAttribute('cv-filename-default-to'));
+    if (!target) {
+      console.error('cv-filename-default-to element target not found!', el);
+      return;
+    }
+    el.addEventListener('change', function (e) {
+      var file = e.target.files[0];
+      if (!target.value) {
+        target.value = file.name;
+      }
+    });
+  });
+
+  // upload files in resumable chunks using the tus protocol,
+  // the regular multipart form still works without JS
+  document.querySelectorAll('form[cv-resumable-upload]').forEach(function (form) {
+    if (form.cvBoundResumableUpload) {
+      return;
+    }
+    form.cvBoundResumableUpload = true;
+
+    if (!window.fetch || !window.Blob || !window.localStorage) {
+      return;
+    }
+
+    var endpoint = form.getAttribute('cv-resumable-upload');
+    var chunkSize = 8 * 1024 * 1024;
+    var maxRetries = 10;
+    var fileInput = form.querySelector('input[type="file"]');
+    var submitButton = form.querySelector('[type="submit"]');
+    var progress = form.querySelector('[cv-upload-progress]');
+    var uploading = false;
+
+    var tusHeaders = function (extra) {
+      var headers = { 'Tus-Resumable': '1.0.0' };
+      Object.keys(extra || {}).forEach(function (key) {
+        headers[key] = extra[key];
+      });
+      return headers;
+    };
+
+    var encodeMetadata = function (metadata) {
+      return Object.keys(metadata).map(function (key) {
+        // btoa only accepts latin1, so encode unicode as UTF-8 first
+        return key + ' ' + btoa(unescape(encodeURIComponent(metadata[key])));
+      }).join(',');
+    };
+
+    var showProgress = function (offset, length) {
+      var percent = length > 0 ? Math.floor((offset / length) * 100) : 100;
+      submitButton.innerText = 'Uploading ' + percent + '%';
+      if (progress) {
+        progress.hidden = false;
+        progress.querySelector('.bar').style.width = percent + '%';
+      }
+    };
+
+    var showError = function (message) {
+      uploading = false;
+      submitButton.disabled = false;
+      submitButton.innerText = 'Resume Upload';
+      console.error(message);
+      window.alert('Upload interrupted: ' + message + '\nSubmit again to resume.');
+    };
+
+    var createUpload = function (file, storageKey) {
+      return fetch(endpoint, {
+        method: 'POST',
+        headers: tusHeaders({
+          'Upload-Length': String(file.size),
+          'Upload-Metadata': encodeMetadata({
+            filename: file.name,
+            title: form.querySelector('[name="title"]').value,
+            tags: form.querySelector('[name="tags"]').value,
+            description: form.querySelector('[name="description"]').value,
+            visibility: form.querySelector('[name="visibility"]').value,
+          }),
+        }),
+      }).then(function (resp) {
+        if (resp.status !== 201) {
+          throw new Error('Failed to start upload (' + resp.status + ')');
+        }
+        var location = new URL(resp.headers.get('Location'), window.location.href).toString();
+        window.localStorage.setItem(storageKey, location);
+        return { location: location, offset: 0 };
+      });
+    };
+
+    // find the offset of a previous upload of the same file, or start fresh
+    var resumeUpload = function (file, storageKey) {
+      var location = window.localStorage.getItem(storageKey);
+      if (!location) {
+        return createUpload(file, storageKey);
+      }
+      return fetch(location, { method: 'HEAD', headers: tusHeaders() })
+        .then(function (resp) {
+          if (resp.status !== 200) {
+            window.localStorage.removeItem(storageKey);
+            return createUpload(file, storageKey);
+          }
+          return {
+            location: location,
+            offset: parseInt(resp.headers.get('Upload-Offset'), 10),
+            videoID: resp.headers.get('Creamy-Video-ID'),
+          };
+        });
+    };
+
+    var sendChunks = function (file, upload, retries) {
+      if (upload.videoID) {
+        return Promise.resolve(upload.videoID);
+      }
+      showProgress(upload.offset, file.size);
+      return fetch(upload.location, {
+        method: 'PATCH',
+        headers: tusHeaders({
+          'Content-Type': 'application/offset+octet-stream',
+          'Upload-Offset': String(upload.offset),
+        }),
+        body: file.slice(upload.offset, upload.offset + chunkSize),
+      }).then(function (resp) {
+        if (resp.status !== 204) {
+          throw new Error('Chunk rejected (' + resp.status + ')');
+        }
+        upload.offset = parseInt(resp.headers.get('Upload-Offset'), 10);
+        upload.videoID = resp.headers.get('Creamy-Video-ID');
+        return sendChunks(file, upload, 0);
+      }).catch(function (ex) {
+        if (retries >= maxRetries) {
+          throw ex;
+        }
+        // wait a bit, ask the server where we left off, then keep going
+        return new Promise(function (resolve) {
+          setTimeout(resolve, Math.min(1000 * Math.pow(2, retries), 30000));
+        }).then(function () {
+          return fetch(upload.location, { method: 'HEAD', headers: tusHeaders() });
+        }).then(function (resp) {
+          if (resp.status !== 200) {
+            throw ex;
+          }
+          upload.offset = parseInt(resp.headers.get('Upload-Offset'), 10);
+          upload.videoID = resp.headers.get('Creamy-Video-ID');
+          return sendChunks(file, upload, retries + 1);
+        }, function () {
+          return sendChunks(file, upload, retries + 1);
+        });
+      });
+    };
+
+    form.addEventListener('submit', function (e) {
+      var file = fileInput && fileInput.files[0];
+      if (!file) {
+        return; // let the browser complain about the required input
+      }
+      e.preventDefault();
+      if (uploading) {
+        return;
+      }
+      uploading = true;
+      submitButton.disabled = true;
+
+      var storageKey = 'cvUpload:' + [file.name, file.size, file.lastModified].join(':');
+      resumeUpload(file, storageKey)
+        .then(function (upload) {
+          return sendChunks(file, upload, 0);
+        })
+        .then(function (videoID) {
+          window.localStorage.removeItem(storageKey);
+          window.location.href = '/watch/' + videoID;
+        })
+        .catch(function (ex) {
+          showError(ex.message);
+        });
+    });
+  });
+
+  // suggest existing tags while typing in a comma-separated tag input,
+  // without JS the input is still a plain text field
+  document.querySelectorAll('input[cv-tag-suggest]').forEach(function (el) {
+    if (el.cvBoundTagSuggest) {
+      return;
+    }
+    el.cvBoundTagSuggest = true;
+
+    if (!window.fetch) {
+      return;
+    }
+
+    var endpoint = el.getAttribute('cv-tag-suggest');
+    var datalist = document.createElement('datalist');
+    datalist.id = 'cv-tag-suggest-' + Math.random().toString(36).slice(2);
+    el.after(datalist);
+    el.setAttribute('list', datalist.id);
+    el.setAttribute('autocomplete', 'off');
+
+    var debounceHandle;
+    var lastPrefix = null;
+
+    var suggest = function () {
+      // only the tag being typed is completed,
+      // each option keeps the tags before it
+      var parts = el.value.split(',');
+      var prefix = parts.pop().trim();
+      var typed = parts.map(function (tag) {
+        return tag.trim();
+      }).filter(function (tag) {
+        return tag !== '';
+      });
+      if (prefix === '' || prefix === lastPrefix) {
+        return;
+      }
+      lastPrefix = prefix;
+
+      fetch(endpoint + '?q=' + encodeURIComponent(prefix))
+        .then(function (resp) {
+          if (!resp.ok) {
+            throw new Error('Failed to suggest tags (' + resp.status + ')');
+          }
+          return resp.json();
+        })
+        .then(function (suggestions) {
+          datalist.replaceChildren.apply(datalist, suggestions.filter(function (suggestion) {
+            return typed.indexOf(suggestion.tag) === -1;
+          }).map(function (suggestion) {
+            var option = document.createElement('option');
+            option.value = typed.concat([suggestion.tag]).join(', ');
+            option.label = suggestion.tag + ' (' + suggestion.count + ')';
+            return option;
+          }));
+        })
+        .catch(function (ex) {
+          console.error(ex);
+        });
+    };
+
+    el.addEventListener('input', function () {
+      clearTimeout(debounceHandle);
+      debounceHandle = setTimeout(suggest, 150);
+    });
+  });
+
+  document.querySelectorAll('[cv-infinite-scroll]').forEach(function (el) {
+    if (el.cvBoundInfiniteScroll) {
+      return;
+    }
+    el.cvBoundInfiniteScroll = true;
+
+    var checkAlmostScrolledIntoView = function () {
+      if (window.scrollY < el.getBoundingClientRect().top - 250) {
+        return;
+      }
+      document.removeEventListener('scroll', checkAlmostScrolledIntoView);
+      
+      var target = el.getAttribute('cv-infinite-scroll');
+      if (!target) {
+        return;
+      }
+      fetch(target)
+        .then(function (resp) {
+          return resp.text();
+        })
+        .then(function (text) {
+          var domParser = new DOMParser();
+          var targetDocument = domParser.parseFromString(text, 'text/html');
+          var targetContainer = targetDocument.querySelector('[cv-infinite-scroll-data]');
+          if (!targetContainer) {
+            throw new Error('Fetched target ' + target + ' but found no [cv-infinite-scroll-data]');
+          }
+          el.after(...targetContainer.children);
+          // re-bind newly inserted elements
+          window.cvPerformBind();
+        })
+    };
+    document.addEventListener('scroll', checkAlmostScrolledIntoView);
+  });
+
+  // play animated previews while hovering a video in a list,
+  // the still thumbnail stays visible until the preview is playing
+  document.querySelectorAll('video[cv-hover-preview]').forEach(function (preview) {
+    if (preview.cvBoundHoverPreview) {
+      return;
+    }
+    preview.cvBoundHoverPreview = true;
+
+    var card = preview.closest('.card');
+    if (!card) {
+      return;
+    }
+
+    preview.addEventListener('playing', function () {
+      card.classList.add('previewing');
+    });
+    card.addEventListener('mouseenter', function () {
+      var playing = preview.play();
+      if (playing && playing.catch) {
+        playing.catch(function () {
+          // autoplay refused or preview missing, keep showing the thumbnail
+        });
+      }
+    });
+    card.addEventListener('mouseleave', function () {
+      card.classList.remove('previewing');
+      preview.pause();
+      preview.currentTime = 0;
+    });
+  });
+
+  // show sprite-sheet previews while hovering the scrub bar below a video,
+  // clicking the bar seeks to that point
+  document.querySelectorAll('[cv-scrub-bar]').forEach(function (bar) {
+    if (bar.cvBoundScrubBar) {
+      return;
+    }
+    bar.cvBoundScrubBar = true;
+
+    var video = document.querySelector(bar.getAttribute('cv-scrub-bar'));
+    var track = video && video.querySelector('track[kind="metadata"]');
+    var preview = bar.querySelector('[cv-scrub-preview]');
+    var progress = bar.querySelector('[cv-scrub-progress]');
+    if (!track || !preview) {
+      return;
+    }
+    // cues of disabled tracks are never loaded
+    track.track.mode = 'hidden';
+
+    var timeAt = function (e) {
+      var rect = bar.getBoundingClientRect();
+      var ratio = Math.min(Math.max((e.clientX - rect.left) / rect.width, 0), 1);
+      return ratio * video.duration;
+    };
+
+    var cueAt = function (time) {
+      var cues = track.track.cues;
+      if (!cues || !cues.length) {
+        return null;
+      }
+      for (var i = 0; i < cues.length; i += 1) {
+        if (time >= cues[i].startTime && time < cues[i].endTime) {
+          return cues[i];
+        }
+      }
+      return cues[cues.length - 1];
+    };
+
+    bar.addEventListener('mousemove', function (e) {
+      var time = timeAt(e);
+      var cue = isNaN(time) ? null : cueAt(time);
+      if (!cue) {
+        preview.hidden = true;
+        return;
+      }
+
+      // cues look like sprites.jpg#xywh=160,0,160,90
+      var parts = cue.text.split('#xywh=');
+      var xywh = parts[1].split(',').map(Number);
+      var sheet = new URL(parts[0], track.src).href;
+      var left = e.clientX - bar.getBoundingClientRect().left - xywh[2] / 2;
+
+      preview.style.backgroundImage = 'url("' + sheet + '")';
+      preview.style.backgroundPosition = (-xywh[0]) + 'px ' + (-xywh[1]) + 'px';
+      preview.style.width = xywh[2] + 'px';
+      preview.style.height = xywh[3] + 'px';
+      preview.style.left = Math.min(Math.max(left, 0), bar.clientWidth - xywh[2]) + 'px';
+      preview.hidden = false;
+    });
+    bar.addEventListener('mouseleave', function () {
+      preview.hidden = true;
+    });
+    bar.addEventListener('click', function (e) {
+      var time = timeAt(e);
+      if (!isNaN(time)) {
+        video.currentTime = time;
+      }
+    });
+
+    if (progress) {
+      video.addEventListener('timeupdate', function () {
+        progress.style.width = (100 * video.currentTime / video.duration) + '%';
+      });
+    }
+  });
+};
+window.cvPerformBind();
+
+console.log(`
+# AlbinoDrought/creamy-videos
+
+Repo: https://github.com/AlbinoDrought/creamy-videos
+
+Source: https://${window.location.host}/source.tar.gz
+`);
@@ -1,6 +1,10 @@
 package tmpl
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+)
 
 type PublicURLGenerator func(relativeURL string) string
 
@@ -10,9 +14,46 @@ type AppState struct {
 	Sortable      bool
 	SearchText    string
 
+	// User is who is logged in, if anyone
+	User videostore.User
+
 	XSRFToken func() string
 
 	PUG PublicURLGenerator
+
+	// MediaOrigin is where media requests are redirected to,
+	// like https://media.example.com, empty if they aren't
+	MediaOrigin string
+}
+
+// ContentSecurityPolicy only allows our own resources,
+// and media from MediaOrigin
+func (state AppState) ContentSecurityPolicy() string {
+	media := "img-src 'self'"
+	if state.MediaOrigin != "" {
+		sources := "'self' " + state.MediaOrigin
+		media = "img-src " + sources + "; media-src " + sources + "; connect-src " + sources
+	}
+	return "default-src 'self'; " + media + "; script-src 'self'; style-src 'self'; require-trusted-types-for 'script'; base-uri 'self'; form-action 'self'"
+}
+
+// links to changes are shown to anyone logged out too,
+// they're asked to log in first
+
+func (state AppState) CanUpload() bool {
+	return !state.ReadOnly && (!state.User.Exists() || state.User.Can(videostore.PermissionUpload))
+}
+
+func (state AppState) CanEdit(video videostore.Video) bool {
+	return !state.ReadOnly && (!state.User.Exists() || state.User.CanEdit(video))
+}
+
+func (state AppState) CanDelete(video videostore.Video) bool {
+	return !state.ReadOnly && (!state.User.Exists() || state.User.CanDelete(video))
+}
+
+func (state AppState) CanEditTags() bool {
+	return !state.ReadOnly && (!state.User.Exists() || state.User.Can(videostore.PermissionEditAny))
 }
 
 type Paging struct {
@@ -26,6 +67,42 @@ type VideoFormState struct {
 	Title       string
 	Tags        string
 	Description string
+	Visibility  string
+
+	ThumbnailTimestamp string
+}
+
+type TagFormState struct {
+	Error string
+	From  string
+	Into  string
+}
+
+type TokenFormState struct {
+	Error  string
+	Name   string
+	Scopes []string
+
+	// Secret of a token that was just created
+	Secret string
+}
+
+type ShareFormState struct {
+	Error string
+	TTL   string
+
+	// Link that was just made and when it stops working
+	Link    string
+	Expires string
+}
+
+type LoginFormState struct {
+	Error    string
+	Username string
+	Next     string
+
+	// SSO offers logging in through an OIDC provider
+	SSO bool
 }
 
 type paginationPage struct {
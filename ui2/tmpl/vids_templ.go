@@ -22,6 +22,15 @@ func classes(c ...string) string {
 	return strings.Join(c, " ")
 }
 
+func contains(list []string, item string) bool {
+	for _, found := range list {
+		if found == item {
+			return true
+		}
+	}
+	return false
+}
+
 func classIf(name string, condition bool) string {
 	if condition {
 		return name
@@ -41,10 +50,61 @@ func videoDeleteURL(video videostore.Video) templ.SafeURL {
 	return templ.SafeURL(fmt.Sprintf("/delete/%v", video.ID))
 }
 
+func videoShareURL(video videostore.Video) templ.SafeURL {
+	return templ.SafeURL(fmt.Sprintf("/share/%v", video.ID))
+}
+
 func tagSearchURL(tag string) templ.SafeURL {
 	return templ.SafeURL("/search?tags=" + url.QueryEscape(tag))
 }
 
+func ownerSearchURL(user videostore.User) templ.SafeURL {
+	return templ.SafeURL(fmt.Sprintf("/search?owner_id=%v", user.ID))
+}
+
+func formatDuration(seconds float64) string {
+	total := int(seconds)
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, (total/60)%60, total%60)
+	}
+	return fmt.Sprintf("%d:%02d", total/60, total%60)
+}
+
+func formatBytes(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %v", size, units[unit])
+	}
+	return fmt.Sprintf("%.1f %v", value, units[unit])
+}
+
+func videoMetadata(video videostore.Video) string {
+	var parts []string
+	if video.Duration > 0 {
+		parts = append(parts, formatDuration(video.Duration))
+	}
+	if video.Height > 0 {
+		parts = append(parts, fmt.Sprintf("%vx%v", video.Width, video.Height))
+	}
+	if video.VideoCodec != "" {
+		codecs := video.VideoCodec
+		if video.AudioCodec != "" {
+			codecs += "/" + video.AudioCodec
+		}
+		parts = append(parts, codecs)
+	}
+	if video.Size > 0 {
+		parts = append(parts, formatBytes(video.Size))
+	}
+	return strings.Join(parts, " · ")
+}
+
 func plural(count int, singular string, plural string) string {
 	if count == 1 {
 		return singular
@@ -52,9 +112,18 @@ func plural(count int, singular string, plural string) string {
 	return plural
 }
 
+// tagCloudClass scales tags from size-1 to size-5
+// by how often they're used compared to the most used tag
+func tagCloudClass(count uint, max uint) string {
+	if max == 0 {
+		return "size-1"
+	}
+	return fmt.Sprintf("size-%v", 1+4*count/max)
+}
+
 // components:
 
-func sortDropdown(direction string, fluid bool) templ.Component {
+func visibilityField(visibility string) templ.Component {
 	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 		templBuffer, templIsBuffer := w.(*bytes.Buffer)
 		if !templIsBuffer {
@@ -67,8 +136,122 @@ func sortDropdown(direction string, fluid bool) templ.Component {
 			var_1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		var var_2 = []any{classes("sort-dropdown", classIf("fluid", fluid))}
-		err = templ.RenderCSSItems(ctx, templBuffer, var_2...)
+		_, err = templBuffer.WriteString("<div class=\"field\"><label>")
+		if err != nil {
+			return err
+		}
+		var_2 := `Visibility`
+		_, err = templBuffer.WriteString(var_2)
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString("</label><select name=\"visibility\"><option value=\"")
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString(templ.EscapeString(videostore.VisibilityPublic))
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString("\"")
+		if err != nil {
+			return err
+		}
+		if visibility == videostore.VisibilityPublic {
+			_, err = templBuffer.WriteString(" selected")
+			if err != nil {
+				return err
+			}
+		}
+		_, err = templBuffer.WriteString(">")
+		if err != nil {
+			return err
+		}
+		var_3 := `Public: listed for everyone`
+		_, err = templBuffer.WriteString(var_3)
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString("</option><option value=\"")
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString(templ.EscapeString(videostore.VisibilityUnlisted))
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString("\"")
+		if err != nil {
+			return err
+		}
+		if visibility == videostore.VisibilityUnlisted {
+			_, err = templBuffer.WriteString(" selected")
+			if err != nil {
+				return err
+			}
+		}
+		_, err = templBuffer.WriteString(">")
+		if err != nil {
+			return err
+		}
+		var_4 := `Unlisted: anyone with the link`
+		_, err = templBuffer.WriteString(var_4)
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString("</option><option value=\"")
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString(templ.EscapeString(videostore.VisibilityPrivate))
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString("\"")
+		if err != nil {
+			return err
+		}
+		if visibility == videostore.VisibilityPrivate {
+			_, err = templBuffer.WriteString(" selected")
+			if err != nil {
+				return err
+			}
+		}
+		_, err = templBuffer.WriteString(">")
+		if err != nil {
+			return err
+		}
+		var_5 := `Private: only you and editors`
+		_, err = templBuffer.WriteString(var_5)
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString("</option></select></div>")
+		if err != nil {
+			return err
+		}
+		if !templIsBuffer {
+			_, err = templBuffer.WriteTo(w)
+		}
+		return err
+	})
+}
+
+func sortDropdown(direction string, fluid bool) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+		templBuffer, templIsBuffer := w.(*bytes.Buffer)
+		if !templIsBuffer {
+			templBuffer = templ.GetBuffer()
+			defer templ.ReleaseBuffer(templBuffer)
+		}
+		ctx = templ.InitializeContext(ctx)
+		var_6 := templ.GetChildren(ctx)
+		if var_6 == nil {
+			var_6 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		var var_7 = []any{classes("sort-dropdown", classIf("fluid", fluid))}
+		err = templ.RenderCSSItems(ctx, templBuffer, var_7...)
 		if err != nil {
 			return err
 		}
@@ -76,7 +259,7 @@ func sortDropdown(direction string, fluid bool) templ.Component {
 		if err != nil {
 			return err
 		}
-		_, err = templBuffer.WriteString(templ.EscapeString(templ.CSSClasses(var_2).String()))
+		_, err = templBuffer.WriteString(templ.EscapeString(templ.CSSClasses(var_7).String()))
 		if err != nil {
 			return err
 		}
@@ -94,8 +277,8 @@ func sortDropdown(direction string, fluid bool) templ.Component {
 		if err != nil {
 			return err
 		}
-		var_3 := `Sort: Newest`
-		_, err = templBuffer.WriteString(var_3)
+		var_8 := `Sort: Newest`
+		_, err = templBuffer.WriteString(var_8)
 		if err != nil {
 			return err
 		}
@@ -113,8 +296,8 @@ func sortDropdown(direction string, fluid bool) templ.Component {
 		if err != nil {
 			return err
 		}
-		var_4 := `Sort: Oldest`
-		_, err = templBuffer.WriteString(var_4)
+		var_9 := `Sort: Oldest`
+		_, err = templBuffer.WriteString(var_9)
 		if err != nil {
 			return err
 		}
@@ -132,8 +315,8 @@ func sortDropdown(direction string, fluid bool) templ.Component {
 		if err != nil {
 			return err
 		}
-		var_5 := `Sort: A-Z`
-		_, err = templBuffer.WriteString(var_5)
+		var_10 := `Sort: A-Z`
+		_, err = templBuffer.WriteString(var_10)
 		if err != nil {
 			return err
 		}
@@ -151,8 +334,46 @@ func sortDropdown(direction string, fluid bool) templ.Component {
 		if err != nil {
 			return err
 		}
-		var_6 := `Sort: Z-A`
-		_, err = templBuffer.WriteString(var_6)
+		var_11 := `Sort: Z-A`
+		_, err = templBuffer.WriteString(var_11)
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString("</option><option value=\"longest\"")
+		if err != nil {
+			return err
+		}
+		if direction == "longest" {
+			_, err = templBuffer.WriteString(" selected")
+			if err != nil {
+				return err
+			}
+		}
+		_, err = templBuffer.WriteString(">")
+		if err != nil {
+			return err
+		}
+		var_12 := `Sort: Longest`
+		_, err = templBuffer.WriteString(var_12)
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString("</option><option value=\"shortest\"")
+		if err != nil {
+			return err
+		}
+		if direction == "shortest" {
+			_, err = templBuffer.WriteString(" selected")
+			if err != nil {
+				return err
+			}
+		}
+		_, err = templBuffer.WriteString(">")
+		if err != nil {
+			return err
+		}
+		var_13 := `Sort: Shortest`
+		_, err = templBuffer.WriteString(var_13)
 		if err != nil {
 			return err
 		}
@@ -175,17 +396,17 @@ func videoThumbnail(pug PublicURLGenerator, video videostore.Video) templ.Compon
 			defer templ.ReleaseBuffer(templBuffer)
 		}
 		ctx = templ.InitializeContext(ctx)
-		var_7 := templ.GetChildren(ctx)
-		if var_7 == nil {
-			var_7 = templ.NopComponent
+		var_14 := templ.GetChildren(ctx)
+		if var_14 == nil {
+			var_14 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
 		_, err = templBuffer.WriteString("<a cv-boost=\"true\" href=\"")
 		if err != nil {
 			return err
 		}
-		var var_8 templ.SafeURL = videoURL(video)
-		_, err = templBuffer.WriteString(templ.EscapeString(string(var_8)))
+		var var_15 templ.SafeURL = videoURL(video)
+		_, err = templBuffer.WriteString(templ.EscapeString(string(var_15)))
 		if err != nil {
 			return err
 		}
@@ -215,12 +436,26 @@ func videoThumbnail(pug PublicURLGenerator, video videostore.Video) templ.Compon
 				return err
 			}
 		}
+		if video.Preview != "" {
+			_, err = templBuffer.WriteString("<video class=\"preview\" cv-hover-preview src=\"")
+			if err != nil {
+				return err
+			}
+			_, err = templBuffer.WriteString(templ.EscapeString(pug(video.Preview)))
+			if err != nil {
+				return err
+			}
+			_, err = templBuffer.WriteString("\" muted loop playsinline preload=\"none\"></video>")
+			if err != nil {
+				return err
+			}
+		}
 		_, err = templBuffer.WriteString("</div><div class=\"content\"><span class=\"header\">")
 		if err != nil {
 			return err
 		}
-		var var_9 string = video.Title
-		_, err = templBuffer.WriteString(templ.EscapeString(var_9))
+		var var_16 string = video.Title
+		_, err = templBuffer.WriteString(templ.EscapeString(var_16))
 		if err != nil {
 			return err
 		}
@@ -243,9 +478,9 @@ func videoGrid(pug PublicURLGenerator, videos []videostore.Video) templ.Componen
 			defer templ.ReleaseBuffer(templBuffer)
 		}
 		ctx = templ.InitializeContext(ctx)
-		var_10 := templ.GetChildren(ctx)
-		if var_10 == nil {
-			var_10 = templ.NopComponent
+		var_17 := templ.GetChildren(ctx)
+		if var_17 == nil {
+			var_17 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
 		_, err = templBuffer.WriteString("<div class=\"ui stackable grid\">")
@@ -292,9 +527,9 @@ func pagingLinks(p Paging) templ.Component {
 			defer templ.ReleaseBuffer(templBuffer)
 		}
 		ctx = templ.InitializeContext(ctx)
-		var_11 := templ.GetChildren(ctx)
-		if var_11 == nil {
-			var_11 = templ.NopComponent
+		var_18 := templ.GetChildren(ctx)
+		if var_18 == nil {
+			var_18 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
 		_, err = templBuffer.WriteString("<div class=\"ui inverted pagination menu\" cv-infinite-scroll=\"")
@@ -315,8 +550,8 @@ func pagingLinks(p Paging) templ.Component {
 				if err != nil {
 					return err
 				}
-				var var_12 string = page.Page
-				_, err = templBuffer.WriteString(templ.EscapeString(var_12))
+				var var_19 string = page.Page
+				_, err = templBuffer.WriteString(templ.EscapeString(var_19))
 				if err != nil {
 					return err
 				}
@@ -325,8 +560,8 @@ func pagingLinks(p Paging) templ.Component {
 					return err
 				}
 			} else {
-				var var_13 = []any{classes("item", classIf("active", page.Active))}
-				err = templ.RenderCSSItems(ctx, templBuffer, var_13...)
+				var var_20 = []any{classes("item", classIf("active", page.Active))}
+				err = templ.RenderCSSItems(ctx, templBuffer, var_20...)
 				if err != nil {
 					return err
 				}
@@ -334,7 +569,7 @@ func pagingLinks(p Paging) templ.Component {
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString(templ.EscapeString(templ.CSSClasses(var_13).String()))
+				_, err = templBuffer.WriteString(templ.EscapeString(templ.CSSClasses(var_20).String()))
 				if err != nil {
 					return err
 				}
@@ -342,8 +577,8 @@ func pagingLinks(p Paging) templ.Component {
 				if err != nil {
 					return err
 				}
-				var var_14 templ.SafeURL = templ.SafeURL(page.URL)
-				_, err = templBuffer.WriteString(templ.EscapeString(string(var_14)))
+				var var_21 templ.SafeURL = templ.SafeURL(page.URL)
+				_, err = templBuffer.WriteString(templ.EscapeString(string(var_21)))
 				if err != nil {
 					return err
 				}
@@ -351,8 +586,8 @@ func pagingLinks(p Paging) templ.Component {
 				if err != nil {
 					return err
 				}
-				var var_15 string = page.Page
-				_, err = templBuffer.WriteString(templ.EscapeString(var_15))
+				var var_22 string = page.Page
+				_, err = templBuffer.WriteString(templ.EscapeString(var_22))
 				if err != nil {
 					return err
 				}
@@ -381,9 +616,9 @@ func xsrf(state AppState) templ.Component {
 			defer templ.ReleaseBuffer(templBuffer)
 		}
 		ctx = templ.InitializeContext(ctx)
-		var_16 := templ.GetChildren(ctx)
-		if var_16 == nil {
-			var_16 = templ.NopComponent
+		var_23 := templ.GetChildren(ctx)
+		if var_23 == nil {
+			var_23 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
 		_, err = templBuffer.WriteString("<input type=\"hidden\" name=\"_xsrf\" value=\"")
@@ -407,7 +642,7 @@ func xsrf(state AppState) templ.Component {
 
 // wrappers:
 
-func page(title string, description string, image string) templ.Component {
+func page(state AppState, title string, description string, image string) templ.Component {
 	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 		templBuffer, templIsBuffer := w.(*bytes.Buffer)
 		if !templIsBuffer {
@@ -415,17 +650,25 @@ func page(title string, description string, image string) templ.Component {
 			defer templ.ReleaseBuffer(templBuffer)
 		}
 		ctx = templ.InitializeContext(ctx)
-		var_17 := templ.GetChildren(ctx)
-		if var_17 == nil {
-			var_17 = templ.NopComponent
+		var_24 := templ.GetChildren(ctx)
+		if var_24 == nil {
+			var_24 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		_, err = templBuffer.WriteString("<!doctype html><html lang=\"en\"><head><meta charset=\"utf-8\"><meta http-equiv=\"X-UA-Compatible\" content=\"IE=edge\"><meta name=\"viewport\" content=\"width=device-width,initial-scale=1.0\"><meta name=\"theme-color\" content=\"#1b1b1b\"><meta http-equiv=\"Content-Security-Policy\" content=\"default-src &#39;self&#39;; img-src &#39;self&#39;; script-src &#39;self&#39;; style-src &#39;self&#39;; require-trusted-types-for &#39;script&#39;; base-uri &#39;self&#39;; form-action &#39;self&#39;\"><link rel=\"icon\" href=\"/favicon.ico\"><title>")
+		_, err = templBuffer.WriteString("<!doctype html><html lang=\"en\"><head><meta charset=\"utf-8\"><meta http-equiv=\"X-UA-Compatible\" content=\"IE=edge\"><meta name=\"viewport\" content=\"width=device-width,initial-scale=1.0\"><meta name=\"theme-color\" content=\"#1b1b1b\"><meta http-equiv=\"Content-Security-Policy\" content=\"")
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString(templ.EscapeString(state.ContentSecurityPolicy()))
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString("\"><link rel=\"icon\" href=\"/favicon.ico\"><title>")
 		if err != nil {
 			return err
 		}
-		var var_18 string = title
-		_, err = templBuffer.WriteString(templ.EscapeString(var_18))
+		var var_25 string = title
+		_, err = templBuffer.WriteString(templ.EscapeString(var_25))
 		if err != nil {
 			return err
 		}
@@ -433,8 +676,8 @@ func page(title string, description string, image string) templ.Component {
 		if err != nil {
 			return err
 		}
-		var_19 := `| creamy-videos`
-		_, err = templBuffer.WriteString(var_19)
+		var_26 := `| creamy-videos`
+		_, err = templBuffer.WriteString(var_26)
 		if err != nil {
 			return err
 		}
@@ -510,11 +753,11 @@ func page(title string, description string, image string) templ.Component {
 				return err
 			}
 		}
-		_, err = templBuffer.WriteString("<link href=\"/css/semantic.min.0.css\" rel=\"stylesheet\"><link href=\"/css/main.0.css\" rel=\"stylesheet\"><script defer src=\"/js/main.1.js\" type=\"text/javascript\"></script></head><body>")
+		_, err = templBuffer.WriteString("<link href=\"/css/semantic.min.0.css\" rel=\"stylesheet\"><link href=\"/css/main.5.css\" rel=\"stylesheet\"><script defer src=\"/js/main.6.js\" type=\"text/javascript\"></script></head><body>")
 		if err != nil {
 			return err
 		}
-		err = var_17.Render(ctx, templBuffer)
+		err = var_24.Render(ctx, templBuffer)
 		if err != nil {
 			return err
 		}
@@ -537,17 +780,17 @@ func app(state AppState) templ.Component {
 			defer templ.ReleaseBuffer(templBuffer)
 		}
 		ctx = templ.InitializeContext(ctx)
-		var_20 := templ.GetChildren(ctx)
-		if var_20 == nil {
-			var_20 = templ.NopComponent
+		var_27 := templ.GetChildren(ctx)
+		if var_27 == nil {
+			var_27 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
 		_, err = templBuffer.WriteString("<div id=\"app\"><div class=\"ui fixed inverted main menu\"><div class=\"ui container\"><a href=\"/\" class=\"header item\"><img alt=\"Creamy Videos Logo\" class=\"logo\" src=\"/img/icon.png\"> ")
 		if err != nil {
 			return err
 		}
-		var_21 := `Creamy Videos`
-		_, err = templBuffer.WriteString(var_21)
+		var_28 := `Creamy Videos`
+		_, err = templBuffer.WriteString(var_28)
 		if err != nil {
 			return err
 		}
@@ -555,8 +798,17 @@ func app(state AppState) templ.Component {
 		if err != nil {
 			return err
 		}
-		var_22 := `Home`
-		_, err = templBuffer.WriteString(var_22)
+		var_29 := `Home`
+		_, err = templBuffer.WriteString(var_29)
+		if err != nil {
+			return err
+		}
+		_, err = templBuffer.WriteString("</a><a href=\"/tags\" class=\"item\">")
+		if err != nil {
+			return err
+		}
+		var_30 := `Tags`
+		_, err = templBuffer.WriteString(var_30)
 		if err != nil {
 			return err
 		}
@@ -564,13 +816,13 @@ func app(state AppState) templ.Component {
 		if err != nil {
 			return err
 		}
-		if !state.ReadOnly {
+		if state.CanUpload() {
 			_, err = templBuffer.WriteString("<a href=\"/upload\" class=\"item\">")
 			if err != nil {
 				return err
 			}
-			var_23 := `Upload`
-			_, err = templBuffer.WriteString(var_23)
+			var_31 := `Upload`
+			_, err = templBuffer.WriteString(var_31)
 			if err != nil {
 				return err
 			}
@@ -579,6 +831,83 @@ func app(state AppState) templ.Component {
 				return err
 			}
 		}
+		if !state.ReadOnly {
+			if state.User.Exists() {
+				if state.User.Can(videostore.PermissionUpload) {
+					_, err = templBuffer.WriteString("<a href=\"")
+					if err != nil {
+						return err
+					}
+					var var_32 templ.SafeURL = ownerSearchURL(state.User)
+					_, err = templBuffer.WriteString(templ.EscapeString(string(var_32)))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\" class=\"item\">")
+					if err != nil {
+						return err
+					}
+					var_33 := `My uploads`
+					_, err = templBuffer.WriteString(var_33)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</a>")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString(" <a href=\"/tokens\" class=\"item\">")
+				if err != nil {
+					return err
+				}
+				var_34 := `API tokens`
+				_, err = templBuffer.WriteString(var_34)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</a> <form method=\"POST\" action=\"/logout\" class=\"item\">")
+				if err != nil {
+					return err
+				}
+				err = xsrf(state).Render(ctx, templBuffer)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("<button type=\"submit\" class=\"ui inverted basic compact button\">")
+				if err != nil {
+					return err
+				}
+				var_35 := `Log out `
+				_, err = templBuffer.WriteString(var_35)
+				if err != nil {
+					return err
+				}
+				var var_36 string = state.User.Username
+				_, err = templBuffer.WriteString(templ.EscapeString(var_36))
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</button></form>")
+				if err != nil {
+					return err
+				}
+			} else {
+				_, err = templBuffer.WriteString("<a href=\"/login\" class=\"item\">")
+				if err != nil {
+					return err
+				}
+				var_37 := `Log in`
+				_, err = templBuffer.WriteString(var_37)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</a>")
+				if err != nil {
+					return err
+				}
+			}
+		}
 		_, err = templBuffer.WriteString("<form method=\"GET\" action=\"/search\" class=\"not-small right menu\">")
 		if err != nil {
 			return err
@@ -635,7 +964,7 @@ func app(state AppState) templ.Component {
 		if err != nil {
 			return err
 		}
-		err = var_20.Render(ctx, templBuffer)
+		err = var_27.Render(ctx, templBuffer)
 		if err != nil {
 			return err
 		}
@@ -660,18 +989,18 @@ func Home(state AppState, paging Paging, videos []videostore.Video) templ.Compon
 			defer templ.ReleaseBuffer(templBuffer)
 		}
 		ctx = templ.InitializeContext(ctx)
-		var_24 := templ.GetChildren(ctx)
-		if var_24 == nil {
-			var_24 = templ.NopComponent
+		var_38 := templ.GetChildren(ctx)
+		if var_38 == nil {
+			var_38 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		var_25 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+		var_39 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 			templBuffer, templIsBuffer := w.(*bytes.Buffer)
 			if !templIsBuffer {
 				templBuffer = templ.GetBuffer()
 				defer templ.ReleaseBuffer(templBuffer)
 			}
-			var_26 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+			var_40 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 				templBuffer, templIsBuffer := w.(*bytes.Buffer)
 				if !templIsBuffer {
 					templBuffer = templ.GetBuffer()
@@ -694,7 +1023,7 @@ func Home(state AppState, paging Paging, videos []videostore.Video) templ.Compon
 				}
 				return err
 			})
-			err = app(state).Render(templ.WithChildren(ctx, var_26), templBuffer)
+			err = app(state).Render(templ.WithChildren(ctx, var_40), templBuffer)
 			if err != nil {
 				return err
 			}
@@ -703,7 +1032,7 @@ func Home(state AppState, paging Paging, videos []videostore.Video) templ.Compon
 			}
 			return err
 		})
-		err = page("Home", "The creamiest selfhosted tubesite", "/img/banner.jpg").Render(templ.WithChildren(ctx, var_25), templBuffer)
+		err = page(state, "Home", "The creamiest selfhosted tubesite", "/img/banner.jpg").Render(templ.WithChildren(ctx, var_39), templBuffer)
 		if err != nil {
 			return err
 		}
@@ -722,18 +1051,18 @@ func Search(state AppState, paging Paging, videos []videostore.Video) templ.Comp
 			defer templ.ReleaseBuffer(templBuffer)
 		}
 		ctx = templ.InitializeContext(ctx)
-		var_27 := templ.GetChildren(ctx)
-		if var_27 == nil {
-			var_27 = templ.NopComponent
+		var_41 := templ.GetChildren(ctx)
+		if var_41 == nil {
+			var_41 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		var_28 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+		var_42 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 			templBuffer, templIsBuffer := w.(*bytes.Buffer)
 			if !templIsBuffer {
 				templBuffer = templ.GetBuffer()
 				defer templ.ReleaseBuffer(templBuffer)
 			}
-			var_29 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+			var_43 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 				templBuffer, templIsBuffer := w.(*bytes.Buffer)
 				if !templIsBuffer {
 					templBuffer = templ.GetBuffer()
@@ -756,7 +1085,7 @@ func Search(state AppState, paging Paging, videos []videostore.Video) templ.Comp
 				}
 				return err
 			})
-			err = app(state).Render(templ.WithChildren(ctx, var_29), templBuffer)
+			err = app(state).Render(templ.WithChildren(ctx, var_43), templBuffer)
 			if err != nil {
 				return err
 			}
@@ -765,7 +1094,7 @@ func Search(state AppState, paging Paging, videos []videostore.Video) templ.Comp
 			}
 			return err
 		})
-		err = page("Search: "+state.SearchText, fmt.Sprintf("Page %v of %v", paging.CurrentPage, paging.Pages), "/img/banner.jpg").Render(templ.WithChildren(ctx, var_28), templBuffer)
+		err = page(state, "Search: "+state.SearchText, fmt.Sprintf("Page %v of %v", paging.CurrentPage, paging.Pages), "/img/banner.jpg").Render(templ.WithChildren(ctx, var_42), templBuffer)
 		if err != nil {
 			return err
 		}
@@ -784,24 +1113,24 @@ func UploadForm(state AppState, videoFormState VideoFormState) templ.Component {
 			defer templ.ReleaseBuffer(templBuffer)
 		}
 		ctx = templ.InitializeContext(ctx)
-		var_30 := templ.GetChildren(ctx)
-		if var_30 == nil {
-			var_30 = templ.NopComponent
+		var_44 := templ.GetChildren(ctx)
+		if var_44 == nil {
+			var_44 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		var_31 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+		var_45 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 			templBuffer, templIsBuffer := w.(*bytes.Buffer)
 			if !templIsBuffer {
 				templBuffer = templ.GetBuffer()
 				defer templ.ReleaseBuffer(templBuffer)
 			}
-			var_32 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+			var_46 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 				templBuffer, templIsBuffer := w.(*bytes.Buffer)
 				if !templIsBuffer {
 					templBuffer = templ.GetBuffer()
 					defer templ.ReleaseBuffer(templBuffer)
 				}
-				_, err = templBuffer.WriteString("<div class=\"upload ui text container\"><form method=\"POST\" class=\"ui form\" enctype=\"multipart/form-data\">")
+				_, err = templBuffer.WriteString("<div class=\"upload ui text container\"><form method=\"POST\" class=\"ui form\" enctype=\"multipart/form-data\" cv-resumable-upload=\"/api/uploads\">")
 				if err != nil {
 					return err
 				}
@@ -813,8 +1142,8 @@ func UploadForm(state AppState, videoFormState VideoFormState) templ.Component {
 				if err != nil {
 					return err
 				}
-				var_33 := `Title`
-				_, err = templBuffer.WriteString(var_33)
+				var_47 := `Title`
+				_, err = templBuffer.WriteString(var_47)
 				if err != nil {
 					return err
 				}
@@ -830,12 +1159,12 @@ func UploadForm(state AppState, videoFormState VideoFormState) templ.Component {
 				if err != nil {
 					return err
 				}
-				var_34 := `Tags (separated by comma)`
-				_, err = templBuffer.WriteString(var_34)
+				var_48 := `Tags (separated by comma)`
+				_, err = templBuffer.WriteString(var_48)
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString("</label><input type=\"text\" name=\"tags\" placeholder=\"educational, computer science, wizardry\" value=\"")
+				_, err = templBuffer.WriteString("</label><input type=\"text\" name=\"tags\" placeholder=\"educational, computer science, wizardry\" cv-tag-suggest=\"/api/tags/suggest\" value=\"")
 				if err != nil {
 					return err
 				}
@@ -847,8 +1176,8 @@ func UploadForm(state AppState, videoFormState VideoFormState) templ.Component {
 				if err != nil {
 					return err
 				}
-				var_35 := `Description`
-				_, err = templBuffer.WriteString(var_35)
+				var_49 := `Description`
+				_, err = templBuffer.WriteString(var_49)
 				if err != nil {
 					return err
 				}
@@ -856,21 +1185,29 @@ func UploadForm(state AppState, videoFormState VideoFormState) templ.Component {
 				if err != nil {
 					return err
 				}
-				var var_36 string = videoFormState.Description
-				_, err = templBuffer.WriteString(templ.EscapeString(var_36))
+				var var_50 string = videoFormState.Description
+				_, err = templBuffer.WriteString(templ.EscapeString(var_50))
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString("</textarea></div><div class=\"field\"><label>")
+				_, err = templBuffer.WriteString("</textarea></div>")
 				if err != nil {
 					return err
 				}
-				var_37 := `File`
-				_, err = templBuffer.WriteString(var_37)
+				err = visibilityField(videoFormState.Visibility).Render(ctx, templBuffer)
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString("</label><input type=\"file\" name=\"file\" required cv-filename-default-to=\"#txtTitle\"></div>")
+				_, err = templBuffer.WriteString("<div class=\"field\"><label>")
+				if err != nil {
+					return err
+				}
+				var_51 := `File`
+				_, err = templBuffer.WriteString(var_51)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</label><input type=\"file\" name=\"file\" required cv-filename-default-to=\"#txtTitle\"></div><div class=\"ui small indicating progress\" cv-upload-progress hidden><div class=\"bar\"></div></div>")
 				if err != nil {
 					return err
 				}
@@ -879,8 +1216,8 @@ func UploadForm(state AppState, videoFormState VideoFormState) templ.Component {
 					if err != nil {
 						return err
 					}
-					var_38 := `Video upload failed`
-					_, err = templBuffer.WriteString(var_38)
+					var_52 := `Video upload failed`
+					_, err = templBuffer.WriteString(var_52)
 					if err != nil {
 						return err
 					}
@@ -888,8 +1225,8 @@ func UploadForm(state AppState, videoFormState VideoFormState) templ.Component {
 					if err != nil {
 						return err
 					}
-					var var_39 string = videoFormState.Error
-					_, err = templBuffer.WriteString(templ.EscapeString(var_39))
+					var var_53 string = videoFormState.Error
+					_, err = templBuffer.WriteString(templ.EscapeString(var_53))
 					if err != nil {
 						return err
 					}
@@ -902,8 +1239,8 @@ func UploadForm(state AppState, videoFormState VideoFormState) templ.Component {
 				if err != nil {
 					return err
 				}
-				var_40 := `Upload`
-				_, err = templBuffer.WriteString(var_40)
+				var_54 := `Upload`
+				_, err = templBuffer.WriteString(var_54)
 				if err != nil {
 					return err
 				}
@@ -916,7 +1253,7 @@ func UploadForm(state AppState, videoFormState VideoFormState) templ.Component {
 				}
 				return err
 			})
-			err = app(state).Render(templ.WithChildren(ctx, var_32), templBuffer)
+			err = app(state).Render(templ.WithChildren(ctx, var_46), templBuffer)
 			if err != nil {
 				return err
 			}
@@ -925,7 +1262,7 @@ func UploadForm(state AppState, videoFormState VideoFormState) templ.Component {
 			}
 			return err
 		})
-		err = page("Upload", "Contribute to the creamiest selfhosted tubesite", "/img/banner.jpg").Render(templ.WithChildren(ctx, var_31), templBuffer)
+		err = page(state, "Upload", "Contribute to the creamiest selfhosted tubesite", "/img/banner.jpg").Render(templ.WithChildren(ctx, var_45), templBuffer)
 		if err != nil {
 			return err
 		}
@@ -944,18 +1281,18 @@ func EditForm(state AppState, videoFormState VideoFormState, video videostore.Vi
 			defer templ.ReleaseBuffer(templBuffer)
 		}
 		ctx = templ.InitializeContext(ctx)
-		var_41 := templ.GetChildren(ctx)
-		if var_41 == nil {
-			var_41 = templ.NopComponent
+		var_55 := templ.GetChildren(ctx)
+		if var_55 == nil {
+			var_55 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		var_42 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+		var_56 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 			templBuffer, templIsBuffer := w.(*bytes.Buffer)
 			if !templIsBuffer {
 				templBuffer = templ.GetBuffer()
 				defer templ.ReleaseBuffer(templBuffer)
 			}
-			var_43 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+			var_57 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 				templBuffer, templIsBuffer := w.(*bytes.Buffer)
 				if !templIsBuffer {
 					templBuffer = templ.GetBuffer()
@@ -973,8 +1310,8 @@ func EditForm(state AppState, videoFormState VideoFormState, video videostore.Vi
 				if err != nil {
 					return err
 				}
-				var_44 := `Title`
-				_, err = templBuffer.WriteString(var_44)
+				var_58 := `Title`
+				_, err = templBuffer.WriteString(var_58)
 				if err != nil {
 					return err
 				}
@@ -990,12 +1327,12 @@ func EditForm(state AppState, videoFormState VideoFormState, video videostore.Vi
 				if err != nil {
 					return err
 				}
-				var_45 := `Tags (separated by comma)`
-				_, err = templBuffer.WriteString(var_45)
+				var_59 := `Tags (separated by comma)`
+				_, err = templBuffer.WriteString(var_59)
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString("</label><input type=\"text\" name=\"tags\" placeholder=\"educational, computer science, wizardry\" value=\"")
+				_, err = templBuffer.WriteString("</label><input type=\"text\" name=\"tags\" placeholder=\"educational, computer science, wizardry\" cv-tag-suggest=\"/api/tags/suggest\" value=\"")
 				if err != nil {
 					return err
 				}
@@ -1007,8 +1344,8 @@ func EditForm(state AppState, videoFormState VideoFormState, video videostore.Vi
 				if err != nil {
 					return err
 				}
-				var_46 := `Description`
-				_, err = templBuffer.WriteString(var_46)
+				var_60 := `Description`
+				_, err = templBuffer.WriteString(var_60)
 				if err != nil {
 					return err
 				}
@@ -1016,8 +1353,8 @@ func EditForm(state AppState, videoFormState VideoFormState, video videostore.Vi
 				if err != nil {
 					return err
 				}
-				var var_47 string = videoFormState.Description
-				_, err = templBuffer.WriteString(templ.EscapeString(var_47))
+				var var_61 string = videoFormState.Description
+				_, err = templBuffer.WriteString(templ.EscapeString(var_61))
 				if err != nil {
 					return err
 				}
@@ -1025,13 +1362,47 @@ func EditForm(state AppState, videoFormState VideoFormState, video videostore.Vi
 				if err != nil {
 					return err
 				}
+				err = visibilityField(videoFormState.Visibility).Render(ctx, templBuffer)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("<div class=\"two fields\"><div class=\"field\"><label>")
+				if err != nil {
+					return err
+				}
+				var_62 := `Custom Thumbnail (optional)`
+				_, err = templBuffer.WriteString(var_62)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</label><input type=\"file\" name=\"thumbnail\" accept=\"image/jpeg,image/png,image/gif\"></div><div class=\"field\"><label>")
+				if err != nil {
+					return err
+				}
+				var_63 := `...or Thumbnail Timestamp (optional)`
+				_, err = templBuffer.WriteString(var_63)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</label><input type=\"text\" name=\"thumbnail_timestamp\" placeholder=\"1:23\" value=\"")
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString(templ.EscapeString(videoFormState.ThumbnailTimestamp))
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("\"></div></div>")
+				if err != nil {
+					return err
+				}
 				if videoFormState.Error != "" {
 					_, err = templBuffer.WriteString("<div class=\"ui visible negative message\"><div class=\"header\">")
 					if err != nil {
 						return err
 					}
-					var_48 := `Video edit failed`
-					_, err = templBuffer.WriteString(var_48)
+					var_64 := `Video edit failed`
+					_, err = templBuffer.WriteString(var_64)
 					if err != nil {
 						return err
 					}
@@ -1039,8 +1410,8 @@ func EditForm(state AppState, videoFormState VideoFormState, video videostore.Vi
 					if err != nil {
 						return err
 					}
-					var var_49 string = videoFormState.Error
-					_, err = templBuffer.WriteString(templ.EscapeString(var_49))
+					var var_65 string = videoFormState.Error
+					_, err = templBuffer.WriteString(templ.EscapeString(var_65))
 					if err != nil {
 						return err
 					}
@@ -1053,8 +1424,8 @@ func EditForm(state AppState, videoFormState VideoFormState, video videostore.Vi
 				if err != nil {
 					return err
 				}
-				var_50 := `Save`
-				_, err = templBuffer.WriteString(var_50)
+				var_66 := `Save`
+				_, err = templBuffer.WriteString(var_66)
 				if err != nil {
 					return err
 				}
@@ -1067,7 +1438,7 @@ func EditForm(state AppState, videoFormState VideoFormState, video videostore.Vi
 				}
 				return err
 			})
-			err = app(state).Render(templ.WithChildren(ctx, var_43), templBuffer)
+			err = app(state).Render(templ.WithChildren(ctx, var_57), templBuffer)
 			if err != nil {
 				return err
 			}
@@ -1076,7 +1447,7 @@ func EditForm(state AppState, videoFormState VideoFormState, video videostore.Vi
 			}
 			return err
 		})
-		err = page(fmt.Sprintf("Edit %v", video.Title), video.Description, state.PUG(video.Thumbnail)).Render(templ.WithChildren(ctx, var_42), templBuffer)
+		err = page(state, fmt.Sprintf("Edit %v", video.Title), video.Description, state.PUG(video.Thumbnail)).Render(templ.WithChildren(ctx, var_56), templBuffer)
 		if err != nil {
 			return err
 		}
@@ -1087,7 +1458,7 @@ func EditForm(state AppState, videoFormState VideoFormState, video videostore.Vi
 	})
 }
 
-func DeleteForm(state AppState, videoFormState VideoFormState, video videostore.Video) templ.Component {
+func Share(state AppState, video videostore.Video, shareFormState ShareFormState) templ.Component {
 	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 		templBuffer, templIsBuffer := w.(*bytes.Buffer)
 		if !templIsBuffer {
@@ -1095,24 +1466,88 @@ func DeleteForm(state AppState, videoFormState VideoFormState, video videostore.
 			defer templ.ReleaseBuffer(templBuffer)
 		}
 		ctx = templ.InitializeContext(ctx)
-		var_51 := templ.GetChildren(ctx)
-		if var_51 == nil {
-			var_51 = templ.NopComponent
+		var_67 := templ.GetChildren(ctx)
+		if var_67 == nil {
+			var_67 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		var_52 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+		var_68 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 			templBuffer, templIsBuffer := w.(*bytes.Buffer)
 			if !templIsBuffer {
 				templBuffer = templ.GetBuffer()
 				defer templ.ReleaseBuffer(templBuffer)
 			}
-			var_53 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+			var_69 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 				templBuffer, templIsBuffer := w.(*bytes.Buffer)
 				if !templIsBuffer {
 					templBuffer = templ.GetBuffer()
 					defer templ.ReleaseBuffer(templBuffer)
 				}
-				_, err = templBuffer.WriteString("<div class=\"upload ui text container\"><form method=\"POST\" class=\"ui form\" enctype=\"multipart/form-data\">")
+				_, err = templBuffer.WriteString("<div class=\"upload ui text container\">")
+				if err != nil {
+					return err
+				}
+				if shareFormState.Link != "" {
+					_, err = templBuffer.WriteString("<div class=\"ui visible positive message\"><div class=\"header\">")
+					if err != nil {
+						return err
+					}
+					var_70 := `Link created`
+					_, err = templBuffer.WriteString(var_70)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</div><p>")
+					if err != nil {
+						return err
+					}
+					var_71 := `Anyone with it can watch `
+					_, err = templBuffer.WriteString(var_71)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("<strong>")
+					if err != nil {
+						return err
+					}
+					var var_72 string = video.Title
+					_, err = templBuffer.WriteString(templ.EscapeString(var_72))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</strong> ")
+					if err != nil {
+						return err
+					}
+					var_73 := `until `
+					_, err = templBuffer.WriteString(var_73)
+					if err != nil {
+						return err
+					}
+					var var_74 string = shareFormState.Expires
+					_, err = templBuffer.WriteString(templ.EscapeString(var_74))
+					if err != nil {
+						return err
+					}
+					var_75 := `:`
+					_, err = templBuffer.WriteString(var_75)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</p><div class=\"ui fluid input\"><input data-e2e=\"Share Link\" type=\"text\" value=\"")
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString(templ.EscapeString(shareFormState.Link))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\" readonly></div></div>")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("<form method=\"POST\" class=\"ui form\">")
 				if err != nil {
 					return err
 				}
@@ -1120,44 +1555,102 @@ func DeleteForm(state AppState, videoFormState VideoFormState, video videostore.
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString("<p>")
+				_, err = templBuffer.WriteString("<div class=\"ui field\"><label>")
 				if err != nil {
 					return err
 				}
-				var_54 := `Are you sure you want to delete `
-				_, err = templBuffer.WriteString(var_54)
+				var_76 := `Link works for`
+				_, err = templBuffer.WriteString(var_76)
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString("<strong>")
+				_, err = templBuffer.WriteString("</label><select class=\"ui dropdown\" name=\"ttl\"><option value=\"1h\"")
 				if err != nil {
 					return err
 				}
-				var var_55 string = video.Title
-				_, err = templBuffer.WriteString(templ.EscapeString(var_55))
+				if shareFormState.TTL == "1h" {
+					_, err = templBuffer.WriteString(" selected")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString(">")
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString("</strong>")
+				var_77 := `1 hour`
+				_, err = templBuffer.WriteString(var_77)
 				if err != nil {
 					return err
 				}
-				var_56 := `?`
-				_, err = templBuffer.WriteString(var_56)
+				_, err = templBuffer.WriteString("</option><option value=\"24h\"")
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString("</p>")
+				if shareFormState.TTL == "24h" {
+					_, err = templBuffer.WriteString(" selected")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString(">")
 				if err != nil {
 					return err
 				}
-				if videoFormState.Error != "" {
+				var_78 := `1 day`
+				_, err = templBuffer.WriteString(var_78)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</option><option value=\"168h\"")
+				if err != nil {
+					return err
+				}
+				if shareFormState.TTL == "168h" {
+					_, err = templBuffer.WriteString(" selected")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString(">")
+				if err != nil {
+					return err
+				}
+				var_79 := `7 days`
+				_, err = templBuffer.WriteString(var_79)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</option><option value=\"720h\"")
+				if err != nil {
+					return err
+				}
+				if shareFormState.TTL == "720h" {
+					_, err = templBuffer.WriteString(" selected")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString(">")
+				if err != nil {
+					return err
+				}
+				var_80 := `30 days`
+				_, err = templBuffer.WriteString(var_80)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</option></select></div>")
+				if err != nil {
+					return err
+				}
+				if shareFormState.Error != "" {
 					_, err = templBuffer.WriteString("<div class=\"ui visible negative message\"><div class=\"header\">")
 					if err != nil {
 						return err
 					}
-					var_57 := `Video delete failed`
-					_, err = templBuffer.WriteString(var_57)
+					var_81 := `Creating the link failed`
+					_, err = templBuffer.WriteString(var_81)
 					if err != nil {
 						return err
 					}
@@ -1165,8 +1658,8 @@ func DeleteForm(state AppState, videoFormState VideoFormState, video videostore.
 					if err != nil {
 						return err
 					}
-					var var_58 string = videoFormState.Error
-					_, err = templBuffer.WriteString(templ.EscapeString(var_58))
+					var var_82 string = shareFormState.Error
+					_, err = templBuffer.WriteString(templ.EscapeString(var_82))
 					if err != nil {
 						return err
 					}
@@ -1175,12 +1668,12 @@ func DeleteForm(state AppState, videoFormState VideoFormState, video videostore.
 						return err
 					}
 				}
-				_, err = templBuffer.WriteString("<button type=\"submit\" class=\"ui submit negative button\">")
+				_, err = templBuffer.WriteString("<button type=\"submit\" class=\"ui submit button\">")
 				if err != nil {
 					return err
 				}
-				var_59 := `Delete`
-				_, err = templBuffer.WriteString(var_59)
+				var_83 := `Create link`
+				_, err = templBuffer.WriteString(var_83)
 				if err != nil {
 					return err
 				}
@@ -1193,7 +1686,7 @@ func DeleteForm(state AppState, videoFormState VideoFormState, video videostore.
 				}
 				return err
 			})
-			err = app(state).Render(templ.WithChildren(ctx, var_53), templBuffer)
+			err = app(state).Render(templ.WithChildren(ctx, var_69), templBuffer)
 			if err != nil {
 				return err
 			}
@@ -1202,7 +1695,7 @@ func DeleteForm(state AppState, videoFormState VideoFormState, video videostore.
 			}
 			return err
 		})
-		err = page(fmt.Sprintf("Delete %v", video.Title), video.Description, state.PUG(video.Thumbnail)).Render(templ.WithChildren(ctx, var_52), templBuffer)
+		err = page(state, fmt.Sprintf("Share %v", video.Title), video.Description, state.PUG(video.Thumbnail)).Render(templ.WithChildren(ctx, var_68), templBuffer)
 		if err != nil {
 			return err
 		}
@@ -1213,7 +1706,7 @@ func DeleteForm(state AppState, videoFormState VideoFormState, video videostore.
 	})
 }
 
-func Watch(state AppState, video videostore.Video) templ.Component {
+func DeleteForm(state AppState, videoFormState VideoFormState, video videostore.Video) templ.Component {
 	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 		templBuffer, templIsBuffer := w.(*bytes.Buffer)
 		if !templIsBuffer {
@@ -1221,171 +1714,96 @@ func Watch(state AppState, video videostore.Video) templ.Component {
 			defer templ.ReleaseBuffer(templBuffer)
 		}
 		ctx = templ.InitializeContext(ctx)
-		var_60 := templ.GetChildren(ctx)
-		if var_60 == nil {
-			var_60 = templ.NopComponent
+		var_84 := templ.GetChildren(ctx)
+		if var_84 == nil {
+			var_84 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		var_61 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+		var_85 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 			templBuffer, templIsBuffer := w.(*bytes.Buffer)
 			if !templIsBuffer {
 				templBuffer = templ.GetBuffer()
 				defer templ.ReleaseBuffer(templBuffer)
 			}
-			var_62 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+			var_86 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 				templBuffer, templIsBuffer := w.(*bytes.Buffer)
 				if !templIsBuffer {
 					templBuffer = templ.GetBuffer()
 					defer templ.ReleaseBuffer(templBuffer)
 				}
-				_, err = templBuffer.WriteString("<div class=\"watch\"><div class=\"ui vertical segment\"><div class=\"ui center aligned fluid video container\"><video src=\"")
-				if err != nil {
-					return err
-				}
-				_, err = templBuffer.WriteString(templ.EscapeString(state.PUG(video.Source)))
-				if err != nil {
-					return err
-				}
-				_, err = templBuffer.WriteString("\" controls autoplay></video></div></div><div class=\"ui vertical segment\"><span data-e2e=\"Video Title\" class=\"header\">")
-				if err != nil {
-					return err
-				}
-				var var_63 string = video.Title
-				_, err = templBuffer.WriteString(templ.EscapeString(var_63))
-				if err != nil {
-					return err
-				}
-				_, err = templBuffer.WriteString("</span><p data-e2e=\"Video Description\" class=\"description\">")
+				_, err = templBuffer.WriteString("<div class=\"upload ui text container\"><form method=\"POST\" class=\"ui form\" enctype=\"multipart/form-data\">")
 				if err != nil {
 					return err
 				}
-				var var_64 string = video.Description
-				_, err = templBuffer.WriteString(templ.EscapeString(var_64))
+				err = xsrf(state).Render(ctx, templBuffer)
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString("</p><div class=\"ui right floated buttons\"><a class=\"ui basic inverted icon download button\" download=\"")
+				_, err = templBuffer.WriteString("<p>")
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString(templ.EscapeString(video.OriginalFileName))
+				var_87 := `Are you sure you want to delete `
+				_, err = templBuffer.WriteString(var_87)
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString("\" href=\"")
+				_, err = templBuffer.WriteString("<strong>")
 				if err != nil {
 					return err
 				}
-				var var_65 templ.SafeURL = templ.SafeURL(state.PUG(video.Source))
-				_, err = templBuffer.WriteString(templ.EscapeString(string(var_65)))
+				var var_88 string = video.Title
+				_, err = templBuffer.WriteString(templ.EscapeString(var_88))
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString("\"><i class=\"download icon\"></i> ")
+				_, err = templBuffer.WriteString("</strong>")
 				if err != nil {
 					return err
 				}
-				var_66 := `Download`
-				_, err = templBuffer.WriteString(var_66)
+				var_89 := `?`
+				_, err = templBuffer.WriteString(var_89)
 				if err != nil {
 					return err
 				}
-				_, err = templBuffer.WriteString("</a>")
+				_, err = templBuffer.WriteString("</p>")
 				if err != nil {
 					return err
 				}
-				if !state.ReadOnly {
-					_, err = templBuffer.WriteString("<a cv-confirm=\"#formDelete\" class=\"ui basic red icon delete button\" href=\"")
-					if err != nil {
-						return err
-					}
-					var var_67 templ.SafeURL = videoDeleteURL(video)
-					_, err = templBuffer.WriteString(templ.EscapeString(string(var_67)))
-					if err != nil {
-						return err
-					}
-					_, err = templBuffer.WriteString("\"><i class=\"trash icon\"></i> ")
-					if err != nil {
-						return err
-					}
-					var_68 := `Delete`
-					_, err = templBuffer.WriteString(var_68)
-					if err != nil {
-						return err
-					}
-					_, err = templBuffer.WriteString("</a> <form id=\"formDelete\" method=\"POST\" action=\"")
-					if err != nil {
-						return err
-					}
-					_, err = templBuffer.WriteString(templ.EscapeString(fmt.Sprintf("/delete/%v", video.ID)))
-					if err != nil {
-						return err
-					}
-					_, err = templBuffer.WriteString("\">")
-					if err != nil {
-						return err
-					}
-					err = xsrf(state).Render(ctx, templBuffer)
-					if err != nil {
-						return err
-					}
-					_, err = templBuffer.WriteString("</form> <a class=\"ui basic yellow icon edit button\" href=\"")
+				if videoFormState.Error != "" {
+					_, err = templBuffer.WriteString("<div class=\"ui visible negative message\"><div class=\"header\">")
 					if err != nil {
 						return err
 					}
-					var var_69 templ.SafeURL = videoEditURL(video)
-					_, err = templBuffer.WriteString(templ.EscapeString(string(var_69)))
+					var_90 := `Video delete failed`
+					_, err = templBuffer.WriteString(var_90)
 					if err != nil {
 						return err
 					}
-					_, err = templBuffer.WriteString("\"><i class=\"edit icon\"></i> ")
+					_, err = templBuffer.WriteString("</div><p>")
 					if err != nil {
 						return err
 					}
-					var_70 := `Edit`
-					_, err = templBuffer.WriteString(var_70)
+					var var_91 string = videoFormState.Error
+					_, err = templBuffer.WriteString(templ.EscapeString(var_91))
 					if err != nil {
 						return err
 					}
-					_, err = templBuffer.WriteString("</a>")
+					_, err = templBuffer.WriteString("</p></div>")
 					if err != nil {
 						return err
 					}
 				}
-				_, err = templBuffer.WriteString("</div><div data-e2e=\"Video Tags\" class=\"tags\">")
+				_, err = templBuffer.WriteString("<button type=\"submit\" class=\"ui submit negative button\">")
 				if err != nil {
 					return err
 				}
-				for _, tag := range video.Tags {
-					_, err = templBuffer.WriteString("<a class=\"ui label\" href=\"")
-					if err != nil {
-						return err
-					}
-					var var_71 templ.SafeURL = tagSearchURL(tag)
-					_, err = templBuffer.WriteString(templ.EscapeString(string(var_71)))
-					if err != nil {
-						return err
-					}
-					_, err = templBuffer.WriteString("\">")
-					if err != nil {
-						return err
-					}
-					var var_72 string = tag
-					_, err = templBuffer.WriteString(templ.EscapeString(var_72))
-					if err != nil {
-						return err
-					}
-					_, err = templBuffer.WriteString("</a>")
-					if err != nil {
-						return err
-					}
-					var_73 := `&nbsp;`
-					_, err = templBuffer.WriteString(var_73)
-					if err != nil {
-						return err
-					}
+				var_92 := `Delete`
+				_, err = templBuffer.WriteString(var_92)
+				if err != nil {
+					return err
 				}
-				_, err = templBuffer.WriteString("</div></div></div>")
+				_, err = templBuffer.WriteString("</button></form></div>")
 				if err != nil {
 					return err
 				}
@@ -1394,7 +1812,7 @@ func Watch(state AppState, video videostore.Video) templ.Component {
 				}
 				return err
 			})
-			err = app(state).Render(templ.WithChildren(ctx, var_62), templBuffer)
+			err = app(state).Render(templ.WithChildren(ctx, var_86), templBuffer)
 			if err != nil {
 				return err
 			}
@@ -1403,7 +1821,7 @@ func Watch(state AppState, video videostore.Video) templ.Component {
 			}
 			return err
 		})
-		err = page(video.Title, video.Description, state.PUG(video.Thumbnail)).Render(templ.WithChildren(ctx, var_61), templBuffer)
+		err = page(state, fmt.Sprintf("Delete %v", video.Title), video.Description, state.PUG(video.Thumbnail)).Render(templ.WithChildren(ctx, var_85), templBuffer)
 		if err != nil {
 			return err
 		}
@@ -1414,7 +1832,7 @@ func Watch(state AppState, video videostore.Video) templ.Component {
 	})
 }
 
-func ErrorPage(state AppState, message string) templ.Component {
+func Watch(state AppState, video videostore.Video, owner videostore.User) templ.Component {
 	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 		templBuffer, templIsBuffer := w.(*bytes.Buffer)
 		if !templIsBuffer {
@@ -1422,29 +1840,1080 @@ func ErrorPage(state AppState, message string) templ.Component {
 			defer templ.ReleaseBuffer(templBuffer)
 		}
 		ctx = templ.InitializeContext(ctx)
-		var_74 := templ.GetChildren(ctx)
-		if var_74 == nil {
-			var_74 = templ.NopComponent
+		var_93 := templ.GetChildren(ctx)
+		if var_93 == nil {
+			var_93 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		var_75 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+		var_94 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 			templBuffer, templIsBuffer := w.(*bytes.Buffer)
 			if !templIsBuffer {
 				templBuffer = templ.GetBuffer()
 				defer templ.ReleaseBuffer(templBuffer)
 			}
-			var_76 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+			var_95 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
 				templBuffer, templIsBuffer := w.(*bytes.Buffer)
 				if !templIsBuffer {
 					templBuffer = templ.GetBuffer()
 					defer templ.ReleaseBuffer(templBuffer)
 				}
-				_, err = templBuffer.WriteString("<div class=\"ui visible negative message\"><div class=\"header\">")
+				_, err = templBuffer.WriteString("<div class=\"watch\"><div class=\"ui vertical segment\"><div class=\"ui center aligned fluid video container\"><video id=\"watchVideo\" controls autoplay>")
 				if err != nil {
 					return err
 				}
-				var_77 := `Something broke`
-				_, err = templBuffer.WriteString(var_77)
+				if video.HLSPlaylist != "" {
+					_, err = templBuffer.WriteString("<source src=\"")
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString(templ.EscapeString(state.PUG(video.HLSPlaylist)))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\" type=\"application/vnd.apple.mpegurl\">")
+					if err != nil {
+						return err
+					}
+				}
+				if video.Fallback != "" {
+					_, err = templBuffer.WriteString("<source src=\"")
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString(templ.EscapeString(state.PUG(video.Fallback)))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\" type=\"video/mp4\">")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("<source src=\"")
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString(templ.EscapeString(state.PUG(video.Source)))
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("\">")
+				if err != nil {
+					return err
+				}
+				if video.Sprites != "" {
+					_, err = templBuffer.WriteString("<track kind=\"metadata\" label=\"Scrubbing Previews\" src=\"")
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString(templ.EscapeString(state.PUG(video.Sprites)))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\" default>")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("</video>")
+				if err != nil {
+					return err
+				}
+				if video.Sprites != "" {
+					_, err = templBuffer.WriteString("<div class=\"scrub-bar\" cv-scrub-bar=\"#watchVideo\"><div class=\"scrub-progress\" cv-scrub-progress></div><div class=\"scrub-preview\" cv-scrub-preview hidden></div></div>")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("</div></div><div class=\"ui vertical segment\"><span data-e2e=\"Video Title\" class=\"header\">")
+				if err != nil {
+					return err
+				}
+				var var_96 string = video.Title
+				_, err = templBuffer.WriteString(templ.EscapeString(var_96))
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</span>")
+				if err != nil {
+					return err
+				}
+				if video.Visibility == videostore.VisibilityUnlisted {
+					_, err = templBuffer.WriteString("<span data-e2e=\"Video Visibility\" class=\"ui basic label\">")
+					if err != nil {
+						return err
+					}
+					var_97 := `Unlisted`
+					_, err = templBuffer.WriteString(var_97)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</span>")
+					if err != nil {
+						return err
+					}
+				} else if video.Visibility == videostore.VisibilityPrivate {
+					_, err = templBuffer.WriteString("<span data-e2e=\"Video Visibility\" class=\"ui basic label\">")
+					if err != nil {
+						return err
+					}
+					var_98 := `Private`
+					_, err = templBuffer.WriteString(var_98)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</span>")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("<p data-e2e=\"Video Description\" class=\"description\">")
+				if err != nil {
+					return err
+				}
+				var var_99 string = video.Description
+				_, err = templBuffer.WriteString(templ.EscapeString(var_99))
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</p>")
+				if err != nil {
+					return err
+				}
+				if metadata := videoMetadata(video); metadata != "" {
+					_, err = templBuffer.WriteString("<p data-e2e=\"Video Metadata\" class=\"metadata\">")
+					if err != nil {
+						return err
+					}
+					var var_100 string = metadata
+					_, err = templBuffer.WriteString(templ.EscapeString(var_100))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</p>")
+					if err != nil {
+						return err
+					}
+				}
+				if owner.Exists() {
+					_, err = templBuffer.WriteString("<p data-e2e=\"Video Owner\" class=\"metadata\">")
+					if err != nil {
+						return err
+					}
+					var_101 := `Uploaded by `
+					_, err = templBuffer.WriteString(var_101)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("<a href=\"")
+					if err != nil {
+						return err
+					}
+					var var_102 templ.SafeURL = ownerSearchURL(owner)
+					_, err = templBuffer.WriteString(templ.EscapeString(string(var_102)))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\">")
+					if err != nil {
+						return err
+					}
+					var var_103 string = owner.Username
+					_, err = templBuffer.WriteString(templ.EscapeString(var_103))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</a></p>")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("<div class=\"ui right floated buttons\"><a class=\"ui basic inverted icon download button\" download=\"")
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString(templ.EscapeString(video.OriginalFileName))
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("\" href=\"")
+				if err != nil {
+					return err
+				}
+				var var_104 templ.SafeURL = templ.SafeURL(state.PUG(video.Source))
+				_, err = templBuffer.WriteString(templ.EscapeString(string(var_104)))
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("\"><i class=\"download icon\"></i> ")
+				if err != nil {
+					return err
+				}
+				var_105 := `Download`
+				_, err = templBuffer.WriteString(var_105)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</a>")
+				if err != nil {
+					return err
+				}
+				if state.CanDelete(video) {
+					_, err = templBuffer.WriteString("<a cv-confirm=\"#formDelete\" class=\"ui basic red icon delete button\" href=\"")
+					if err != nil {
+						return err
+					}
+					var var_106 templ.SafeURL = videoDeleteURL(video)
+					_, err = templBuffer.WriteString(templ.EscapeString(string(var_106)))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\"><i class=\"trash icon\"></i> ")
+					if err != nil {
+						return err
+					}
+					var_107 := `Delete`
+					_, err = templBuffer.WriteString(var_107)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</a> <form id=\"formDelete\" method=\"POST\" action=\"")
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString(templ.EscapeString(fmt.Sprintf("/delete/%v", video.ID)))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\">")
+					if err != nil {
+						return err
+					}
+					err = xsrf(state).Render(ctx, templBuffer)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</form>")
+					if err != nil {
+						return err
+					}
+				}
+				if state.CanEdit(video) && video.Visibility != videostore.VisibilityPublic {
+					_, err = templBuffer.WriteString("<a class=\"ui basic inverted icon share button\" href=\"")
+					if err != nil {
+						return err
+					}
+					var var_108 templ.SafeURL = videoShareURL(video)
+					_, err = templBuffer.WriteString(templ.EscapeString(string(var_108)))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\"><i class=\"share icon\"></i> ")
+					if err != nil {
+						return err
+					}
+					var_109 := `Share`
+					_, err = templBuffer.WriteString(var_109)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</a>")
+					if err != nil {
+						return err
+					}
+				}
+				if state.CanEdit(video) {
+					_, err = templBuffer.WriteString("<a class=\"ui basic yellow icon edit button\" href=\"")
+					if err != nil {
+						return err
+					}
+					var var_110 templ.SafeURL = videoEditURL(video)
+					_, err = templBuffer.WriteString(templ.EscapeString(string(var_110)))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\"><i class=\"edit icon\"></i> ")
+					if err != nil {
+						return err
+					}
+					var_111 := `Edit`
+					_, err = templBuffer.WriteString(var_111)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</a>")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("</div><div data-e2e=\"Video Tags\" class=\"tags\">")
+				if err != nil {
+					return err
+				}
+				for _, tag := range video.Tags {
+					_, err = templBuffer.WriteString("<a class=\"ui label\" href=\"")
+					if err != nil {
+						return err
+					}
+					var var_112 templ.SafeURL = tagSearchURL(tag)
+					_, err = templBuffer.WriteString(templ.EscapeString(string(var_112)))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\">")
+					if err != nil {
+						return err
+					}
+					var var_113 string = tag
+					_, err = templBuffer.WriteString(templ.EscapeString(var_113))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</a>")
+					if err != nil {
+						return err
+					}
+					var_114 := `&nbsp;`
+					_, err = templBuffer.WriteString(var_114)
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("</div></div></div>")
+				if err != nil {
+					return err
+				}
+				if !templIsBuffer {
+					_, err = io.Copy(w, templBuffer)
+				}
+				return err
+			})
+			err = app(state).Render(templ.WithChildren(ctx, var_95), templBuffer)
+			if err != nil {
+				return err
+			}
+			if !templIsBuffer {
+				_, err = io.Copy(w, templBuffer)
+			}
+			return err
+		})
+		err = page(state, video.Title, video.Description, state.PUG(video.Thumbnail)).Render(templ.WithChildren(ctx, var_94), templBuffer)
+		if err != nil {
+			return err
+		}
+		if !templIsBuffer {
+			_, err = templBuffer.WriteTo(w)
+		}
+		return err
+	})
+}
+
+func Tags(state AppState, tags []videostore.TagCount, tagFormState TagFormState) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+		templBuffer, templIsBuffer := w.(*bytes.Buffer)
+		if !templIsBuffer {
+			templBuffer = templ.GetBuffer()
+			defer templ.ReleaseBuffer(templBuffer)
+		}
+		ctx = templ.InitializeContext(ctx)
+		var_115 := templ.GetChildren(ctx)
+		if var_115 == nil {
+			var_115 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		var_116 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+			templBuffer, templIsBuffer := w.(*bytes.Buffer)
+			if !templIsBuffer {
+				templBuffer = templ.GetBuffer()
+				defer templ.ReleaseBuffer(templBuffer)
+			}
+			var_117 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+				templBuffer, templIsBuffer := w.(*bytes.Buffer)
+				if !templIsBuffer {
+					templBuffer = templ.GetBuffer()
+					defer templ.ReleaseBuffer(templBuffer)
+				}
+				_, err = templBuffer.WriteString("<div data-e2e=\"Tag Cloud\" class=\"tag-cloud\">")
+				if err != nil {
+					return err
+				}
+				for _, tag := range tags {
+					var var_118 = []any{classes("ui label", tagCloudClass(tag.Count, tags[0].Count))}
+					err = templ.RenderCSSItems(ctx, templBuffer, var_118...)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("<a class=\"")
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString(templ.EscapeString(templ.CSSClasses(var_118).String()))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\" href=\"")
+					if err != nil {
+						return err
+					}
+					var var_119 templ.SafeURL = tagSearchURL(tag.Tag)
+					_, err = templBuffer.WriteString(templ.EscapeString(string(var_119)))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\">")
+					if err != nil {
+						return err
+					}
+					var var_120 string = tag.Tag
+					_, err = templBuffer.WriteString(templ.EscapeString(var_120))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("<span class=\"detail\">")
+					if err != nil {
+						return err
+					}
+					var var_121 string = fmt.Sprintf("%v", tag.Count)
+					_, err = templBuffer.WriteString(templ.EscapeString(var_121))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</span></a>")
+					if err != nil {
+						return err
+					}
+				}
+				if len(tags) == 0 {
+					_, err = templBuffer.WriteString("<p>")
+					if err != nil {
+						return err
+					}
+					var_122 := `No tags yet`
+					_, err = templBuffer.WriteString(var_122)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</p>")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("</div> ")
+				if err != nil {
+					return err
+				}
+				if state.CanEditTags() {
+					_, err = templBuffer.WriteString("<div class=\"upload ui text container\"><form method=\"POST\" action=\"/tags\" class=\"ui form\">")
+					if err != nil {
+						return err
+					}
+					err = xsrf(state).Render(ctx, templBuffer)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("<div class=\"ui field\"><label>")
+					if err != nil {
+						return err
+					}
+					var_123 := `Rename or merge tags (separated by comma)`
+					_, err = templBuffer.WriteString(var_123)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</label><input type=\"text\" name=\"from\" placeholder=\"kitty, kitten\" value=\"")
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString(templ.EscapeString(tagFormState.From))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\" required></div><div class=\"ui field\"><label>")
+					if err != nil {
+						return err
+					}
+					var_124 := `Into`
+					_, err = templBuffer.WriteString(var_124)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</label><input type=\"text\" name=\"into\" placeholder=\"cat\" value=\"")
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString(templ.EscapeString(tagFormState.Into))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\" required></div>")
+					if err != nil {
+						return err
+					}
+					if tagFormState.Error != "" {
+						_, err = templBuffer.WriteString("<div class=\"ui visible negative message\"><div class=\"header\">")
+						if err != nil {
+							return err
+						}
+						var_125 := `Tag merge failed`
+						_, err = templBuffer.WriteString(var_125)
+						if err != nil {
+							return err
+						}
+						_, err = templBuffer.WriteString("</div><p>")
+						if err != nil {
+							return err
+						}
+						var var_126 string = tagFormState.Error
+						_, err = templBuffer.WriteString(templ.EscapeString(var_126))
+						if err != nil {
+							return err
+						}
+						_, err = templBuffer.WriteString("</p></div>")
+						if err != nil {
+							return err
+						}
+					}
+					_, err = templBuffer.WriteString("<button type=\"submit\" class=\"ui submit button\">")
+					if err != nil {
+						return err
+					}
+					var_127 := `Merge`
+					_, err = templBuffer.WriteString(var_127)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</button></form></div>")
+					if err != nil {
+						return err
+					}
+				}
+				if !templIsBuffer {
+					_, err = io.Copy(w, templBuffer)
+				}
+				return err
+			})
+			err = app(state).Render(templ.WithChildren(ctx, var_117), templBuffer)
+			if err != nil {
+				return err
+			}
+			if !templIsBuffer {
+				_, err = io.Copy(w, templBuffer)
+			}
+			return err
+		})
+		err = page(state, "Tags", fmt.Sprintf("%v %v", len(tags), plural(len(tags), "tag", "tags")), "/img/banner.jpg").Render(templ.WithChildren(ctx, var_116), templBuffer)
+		if err != nil {
+			return err
+		}
+		if !templIsBuffer {
+			_, err = templBuffer.WriteTo(w)
+		}
+		return err
+	})
+}
+
+func LoginForm(state AppState, loginFormState LoginFormState) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+		templBuffer, templIsBuffer := w.(*bytes.Buffer)
+		if !templIsBuffer {
+			templBuffer = templ.GetBuffer()
+			defer templ.ReleaseBuffer(templBuffer)
+		}
+		ctx = templ.InitializeContext(ctx)
+		var_128 := templ.GetChildren(ctx)
+		if var_128 == nil {
+			var_128 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		var_129 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+			templBuffer, templIsBuffer := w.(*bytes.Buffer)
+			if !templIsBuffer {
+				templBuffer = templ.GetBuffer()
+				defer templ.ReleaseBuffer(templBuffer)
+			}
+			var_130 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+				templBuffer, templIsBuffer := w.(*bytes.Buffer)
+				if !templIsBuffer {
+					templBuffer = templ.GetBuffer()
+					defer templ.ReleaseBuffer(templBuffer)
+				}
+				_, err = templBuffer.WriteString("<div class=\"upload ui text container\"><form method=\"POST\" action=\"/login\" class=\"ui form\">")
+				if err != nil {
+					return err
+				}
+				err = xsrf(state).Render(ctx, templBuffer)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("<input type=\"hidden\" name=\"next\" value=\"")
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString(templ.EscapeString(loginFormState.Next))
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("\"><div class=\"ui field\"><label>")
+				if err != nil {
+					return err
+				}
+				var_131 := `Username`
+				_, err = templBuffer.WriteString(var_131)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</label><input type=\"text\" name=\"username\" autocomplete=\"username\" value=\"")
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString(templ.EscapeString(loginFormState.Username))
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("\" required></div><div class=\"ui field\"><label>")
+				if err != nil {
+					return err
+				}
+				var_132 := `Password`
+				_, err = templBuffer.WriteString(var_132)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</label><input type=\"password\" name=\"password\" autocomplete=\"current-password\" required></div>")
+				if err != nil {
+					return err
+				}
+				if loginFormState.Error != "" {
+					_, err = templBuffer.WriteString("<div class=\"ui visible negative message\"><div class=\"header\">")
+					if err != nil {
+						return err
+					}
+					var_133 := `Login failed`
+					_, err = templBuffer.WriteString(var_133)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</div><p>")
+					if err != nil {
+						return err
+					}
+					var var_134 string = loginFormState.Error
+					_, err = templBuffer.WriteString(templ.EscapeString(var_134))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</p></div>")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("<button type=\"submit\" class=\"ui submit button\">")
+				if err != nil {
+					return err
+				}
+				var_135 := `Log in`
+				_, err = templBuffer.WriteString(var_135)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</button>")
+				if err != nil {
+					return err
+				}
+				if loginFormState.SSO {
+					_, err = templBuffer.WriteString("<a class=\"ui basic inverted button\" href=\"")
+					if err != nil {
+						return err
+					}
+					var var_136 templ.SafeURL = templ.SafeURL("/login/oidc?" + url.Values{"next": {loginFormState.Next}}.Encode())
+					_, err = templBuffer.WriteString(templ.EscapeString(string(var_136)))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\">")
+					if err != nil {
+						return err
+					}
+					var_137 := `Log in with SSO`
+					_, err = templBuffer.WriteString(var_137)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</a>")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("</form></div>")
+				if err != nil {
+					return err
+				}
+				if !templIsBuffer {
+					_, err = io.Copy(w, templBuffer)
+				}
+				return err
+			})
+			err = app(state).Render(templ.WithChildren(ctx, var_130), templBuffer)
+			if err != nil {
+				return err
+			}
+			if !templIsBuffer {
+				_, err = io.Copy(w, templBuffer)
+			}
+			return err
+		})
+		err = page(state, "Log in", "Log in to make changes", "/img/banner.jpg").Render(templ.WithChildren(ctx, var_129), templBuffer)
+		if err != nil {
+			return err
+		}
+		if !templIsBuffer {
+			_, err = templBuffer.WriteTo(w)
+		}
+		return err
+	})
+}
+
+func Tokens(state AppState, tokens []videostore.APIToken, tokenFormState TokenFormState) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+		templBuffer, templIsBuffer := w.(*bytes.Buffer)
+		if !templIsBuffer {
+			templBuffer = templ.GetBuffer()
+			defer templ.ReleaseBuffer(templBuffer)
+		}
+		ctx = templ.InitializeContext(ctx)
+		var_138 := templ.GetChildren(ctx)
+		if var_138 == nil {
+			var_138 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		var_139 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+			templBuffer, templIsBuffer := w.(*bytes.Buffer)
+			if !templIsBuffer {
+				templBuffer = templ.GetBuffer()
+				defer templ.ReleaseBuffer(templBuffer)
+			}
+			var_140 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+				templBuffer, templIsBuffer := w.(*bytes.Buffer)
+				if !templIsBuffer {
+					templBuffer = templ.GetBuffer()
+					defer templ.ReleaseBuffer(templBuffer)
+				}
+				_, err = templBuffer.WriteString("<div class=\"upload ui text container\">")
+				if err != nil {
+					return err
+				}
+				if tokenFormState.Secret != "" {
+					_, err = templBuffer.WriteString("<div class=\"ui visible positive message\"><div class=\"header\">")
+					if err != nil {
+						return err
+					}
+					var_141 := `Token created`
+					_, err = templBuffer.WriteString(var_141)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</div><p>")
+					if err != nil {
+						return err
+					}
+					var_142 := `Copy it now, it won't be shown again:`
+					_, err = templBuffer.WriteString(var_142)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</p><code class=\"api-token\">")
+					if err != nil {
+						return err
+					}
+					var var_143 string = tokenFormState.Secret
+					_, err = templBuffer.WriteString(templ.EscapeString(var_143))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</code></div>")
+					if err != nil {
+						return err
+					}
+				}
+				if len(tokens) > 0 {
+					_, err = templBuffer.WriteString("<table data-e2e=\"API Tokens\" class=\"ui inverted table\"><thead><tr><th>")
+					if err != nil {
+						return err
+					}
+					var_144 := `Name`
+					_, err = templBuffer.WriteString(var_144)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</th><th>")
+					if err != nil {
+						return err
+					}
+					var_145 := `Scopes`
+					_, err = templBuffer.WriteString(var_145)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</th><th>")
+					if err != nil {
+						return err
+					}
+					var_146 := `Created`
+					_, err = templBuffer.WriteString(var_146)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</th><th></th></tr></thead><tbody>")
+					if err != nil {
+						return err
+					}
+					for _, token := range tokens {
+						_, err = templBuffer.WriteString("<tr><td>")
+						if err != nil {
+							return err
+						}
+						var var_147 string = token.Name
+						_, err = templBuffer.WriteString(templ.EscapeString(var_147))
+						if err != nil {
+							return err
+						}
+						_, err = templBuffer.WriteString("</td><td>")
+						if err != nil {
+							return err
+						}
+						var var_148 string = strings.Join(token.Scopes, ", ")
+						_, err = templBuffer.WriteString(templ.EscapeString(var_148))
+						if err != nil {
+							return err
+						}
+						_, err = templBuffer.WriteString("</td><td>")
+						if err != nil {
+							return err
+						}
+						var var_149 string = token.TimeCreated
+						_, err = templBuffer.WriteString(templ.EscapeString(var_149))
+						if err != nil {
+							return err
+						}
+						_, err = templBuffer.WriteString("</td><td><form method=\"POST\" action=\"")
+						if err != nil {
+							return err
+						}
+						_, err = templBuffer.WriteString(templ.EscapeString(fmt.Sprintf("/tokens/%v/revoke", token.ID)))
+						if err != nil {
+							return err
+						}
+						_, err = templBuffer.WriteString("\">")
+						if err != nil {
+							return err
+						}
+						err = xsrf(state).Render(ctx, templBuffer)
+						if err != nil {
+							return err
+						}
+						_, err = templBuffer.WriteString("<button type=\"submit\" class=\"ui basic red compact button\">")
+						if err != nil {
+							return err
+						}
+						var_150 := `Revoke`
+						_, err = templBuffer.WriteString(var_150)
+						if err != nil {
+							return err
+						}
+						_, err = templBuffer.WriteString("</button></form></td></tr>")
+						if err != nil {
+							return err
+						}
+					}
+					_, err = templBuffer.WriteString("</tbody></table>")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("<form method=\"POST\" action=\"/tokens\" class=\"ui form\">")
+				if err != nil {
+					return err
+				}
+				err = xsrf(state).Render(ctx, templBuffer)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("<div class=\"ui field\"><label>")
+				if err != nil {
+					return err
+				}
+				var_151 := `Name`
+				_, err = templBuffer.WriteString(var_151)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</label><input type=\"text\" name=\"name\" placeholder=\"ingest script\" value=\"")
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString(templ.EscapeString(tokenFormState.Name))
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("\" required></div><div class=\"ui field\"><label>")
+				if err != nil {
+					return err
+				}
+				var_152 := `Scopes, limited to what your role allows`
+				_, err = templBuffer.WriteString(var_152)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</label>")
+				if err != nil {
+					return err
+				}
+				for _, scope := range videostore.Scopes {
+					_, err = templBuffer.WriteString("<div class=\"ui checkbox\"><input type=\"checkbox\" class=\"hidden\" id=\"")
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString(templ.EscapeString("scope-" + scope))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\" name=\"scopes\" value=\"")
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString(templ.EscapeString(scope))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\"")
+					if err != nil {
+						return err
+					}
+					if contains(tokenFormState.Scopes, scope) {
+						_, err = templBuffer.WriteString(" checked")
+						if err != nil {
+							return err
+						}
+					}
+					_, err = templBuffer.WriteString("><label for=\"")
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString(templ.EscapeString("scope-" + scope))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("\">")
+					if err != nil {
+						return err
+					}
+					var var_153 string = scope
+					_, err = templBuffer.WriteString(templ.EscapeString(var_153))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</label></div>")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("</div>")
+				if err != nil {
+					return err
+				}
+				if tokenFormState.Error != "" {
+					_, err = templBuffer.WriteString("<div class=\"ui visible negative message\"><div class=\"header\">")
+					if err != nil {
+						return err
+					}
+					var_154 := `Creating the token failed`
+					_, err = templBuffer.WriteString(var_154)
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</div><p>")
+					if err != nil {
+						return err
+					}
+					var var_155 string = tokenFormState.Error
+					_, err = templBuffer.WriteString(templ.EscapeString(var_155))
+					if err != nil {
+						return err
+					}
+					_, err = templBuffer.WriteString("</p></div>")
+					if err != nil {
+						return err
+					}
+				}
+				_, err = templBuffer.WriteString("<button type=\"submit\" class=\"ui submit button\">")
+				if err != nil {
+					return err
+				}
+				var_156 := `Create`
+				_, err = templBuffer.WriteString(var_156)
+				if err != nil {
+					return err
+				}
+				_, err = templBuffer.WriteString("</button></form></div>")
+				if err != nil {
+					return err
+				}
+				if !templIsBuffer {
+					_, err = io.Copy(w, templBuffer)
+				}
+				return err
+			})
+			err = app(state).Render(templ.WithChildren(ctx, var_140), templBuffer)
+			if err != nil {
+				return err
+			}
+			if !templIsBuffer {
+				_, err = io.Copy(w, templBuffer)
+			}
+			return err
+		})
+		err = page(state, "API tokens", fmt.Sprintf("%v %v", len(tokens), plural(len(tokens), "token", "tokens")), "/img/banner.jpg").Render(templ.WithChildren(ctx, var_139), templBuffer)
+		if err != nil {
+			return err
+		}
+		if !templIsBuffer {
+			_, err = templBuffer.WriteTo(w)
+		}
+		return err
+	})
+}
+
+func ErrorPage(state AppState, message string) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+		templBuffer, templIsBuffer := w.(*bytes.Buffer)
+		if !templIsBuffer {
+			templBuffer = templ.GetBuffer()
+			defer templ.ReleaseBuffer(templBuffer)
+		}
+		ctx = templ.InitializeContext(ctx)
+		var_157 := templ.GetChildren(ctx)
+		if var_157 == nil {
+			var_157 = templ.NopComponent
+		}
+		ctx = templ.ClearChildren(ctx)
+		var_158 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+			templBuffer, templIsBuffer := w.(*bytes.Buffer)
+			if !templIsBuffer {
+				templBuffer = templ.GetBuffer()
+				defer templ.ReleaseBuffer(templBuffer)
+			}
+			var_159 := templ.ComponentFunc(func(ctx context.Context, w io.Writer) (err error) {
+				templBuffer, templIsBuffer := w.(*bytes.Buffer)
+				if !templIsBuffer {
+					templBuffer = templ.GetBuffer()
+					defer templ.ReleaseBuffer(templBuffer)
+				}
+				_, err = templBuffer.WriteString("<div class=\"ui visible negative message\"><div class=\"header\">")
+				if err != nil {
+					return err
+				}
+				var_160 := `Something broke`
+				_, err = templBuffer.WriteString(var_160)
 				if err != nil {
 					return err
 				}
@@ -1452,8 +2921,8 @@ func ErrorPage(state AppState, message string) templ.Component {
 				if err != nil {
 					return err
 				}
-				var var_78 string = message
-				_, err = templBuffer.WriteString(templ.EscapeString(var_78))
+				var var_161 string = message
+				_, err = templBuffer.WriteString(templ.EscapeString(var_161))
 				if err != nil {
 					return err
 				}
@@ -1466,7 +2935,7 @@ func ErrorPage(state AppState, message string) templ.Component {
 				}
 				return err
 			})
-			err = app(state).Render(templ.WithChildren(ctx, var_76), templBuffer)
+			err = app(state).Render(templ.WithChildren(ctx, var_159), templBuffer)
 			if err != nil {
 				return err
 			}
@@ -1475,7 +2944,7 @@ func ErrorPage(state AppState, message string) templ.Component {
 			}
 			return err
 		})
-		err = page("Error", "", "/img/banner.jpg").Render(templ.WithChildren(ctx, var_75), templBuffer)
+		err = page(state, "Error", "", "/img/banner.jpg").Render(templ.WithChildren(ctx, var_158), templBuffer)
 		if err != nil {
 			return err
 		}
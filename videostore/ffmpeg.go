@@ -0,0 +1,50 @@
+package videostore
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/pkg/errors"
+)
+
+// downloadToTemporaryFile copies a video out of fs and into a new
+// temporary directory, for tools that can't work from a pipe
+// (some MOV files, or anything that needs to seek).
+// The caller is responsible for removing tempDir.
+func downloadToTemporaryFile(video Video, fs files.FileSystem, prefix string) (tempDir string, videoPath string, err error) {
+	// create a temporary directory to store our junk
+	tempDir, err = ioutil.TempDir("", prefix+strconv.Itoa(int(video.ID)))
+	if err != nil {
+		return "", "", errors.Wrap(err, "failed to make tempdir")
+	}
+
+	// create the file in our temporary directory
+	videoPath = path.Join(tempDir, path.Base(video.Source))
+	temporaryVideoStream, err := os.Create(videoPath)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", "", errors.Wrap(err, "failed to create temporary video file")
+	}
+	defer temporaryVideoStream.Close()
+
+	// open the existing (and possibly remote) video file
+	realVideoStream, err := fs.Open(video.Source)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", "", errors.Wrap(err, "failed to open real video file")
+	}
+	defer realVideoStream.Close()
+
+	// download it to our temporary directory
+	_, err = io.Copy(temporaryVideoStream, realVideoStream)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", "", errors.Wrap(err, "failed to download video to temporary path")
+	}
+
+	return tempDir, videoPath, nil
+}
@@ -0,0 +1,74 @@
+package videostore
+
+import (
+	"errors"
+	"time"
+)
+
+const JobStatusPending = "pending"
+const JobStatusRunning = "running"
+const JobStatusDone = "done"
+const JobStatusFailed = "failed"
+
+const JobKindCustomThumbnail = "custom_thumbnail"
+const JobKindPreview = "preview"
+const JobKindProbe = "probe"
+const JobKindSprites = "sprites"
+const JobKindThumbnail = "thumbnail"
+const JobKindTranscode = "transcode"
+
+// Job is a unit of background work, like generating
+// a thumbnail, performed against a single video
+type Job struct {
+	ID          uint      `json:"id"`
+	Kind        string    `json:"kind"`
+	VideoID     uint      `json:"video_id"`
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts" sql:",notnull"`
+	MaxAttempts int       `json:"max_attempts"`
+	LastError   string    `json:"last_error"`
+	RunAfter    time.Time `json:"run_after"`
+	TimeCreated string    `json:"time_created"`
+	TimeUpdated string    `json:"time_updated"`
+}
+
+func (job Job) Exists() bool {
+	return job.ID > 0
+}
+
+// JobFilter narrows down listed jobs,
+// empty fields are ignored
+type JobFilter struct {
+	Kind    string
+	Status  string
+	VideoID uint
+}
+
+func (filter JobFilter) Matches(job Job) bool {
+	if filter.Kind != "" && filter.Kind != job.Kind {
+		return false
+	}
+	if filter.Status != "" && filter.Status != job.Status {
+		return false
+	}
+	if filter.VideoID != 0 && filter.VideoID != job.VideoID {
+		return false
+	}
+	return true
+}
+
+type JobRepo interface {
+	Save(job Job) (Job, error)
+	FindById(id uint) (Job, error)
+	// All lists matching jobs, newest first
+	All(filter JobFilter, limit uint, offset uint) ([]Job, error)
+	// Claim marks the oldest pending job that is due by now as running,
+	// counts the attempt, and returns it.
+	// ErrorNoJobs is returned if nothing is ready to run.
+	Claim(now time.Time) (Job, error)
+	// Requeue returns jobs left running by a previous process to pending
+	Requeue() error
+}
+
+var ErrorJobNotFound = errors.New("job not found")
+var ErrorNoJobs = errors.New("no jobs ready")
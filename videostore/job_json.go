@@ -0,0 +1,187 @@
+package videostore
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+)
+
+// dummyJobRetention is how long done and failed jobs are kept,
+// the whole file is rewritten on every change so it must stay small
+const dummyJobRetention = 7 * 24 * time.Hour
+
+// dummyJobRepo stores jobs to a local JSON file
+// beside the dummyVideoRepo's dummy.json
+type dummyJobRepo struct {
+	fs     files.FileSystem
+	jobs   []Job // ordered by ID
+	nextID uint
+	lock   sync.Mutex
+}
+
+func NewDummyJobRepo(fs files.FileSystem) *dummyJobRepo {
+	var jobs []Job
+
+	storedDatabase, err := fs.Open("jobs.json")
+	if err == nil {
+		defer storedDatabase.Close()
+		err = json.NewDecoder(storedDatabase).Decode(&jobs)
+	}
+
+	if err != nil {
+		jobs = make([]Job, 0)
+	}
+
+	nextID := uint(1)
+	if len(jobs) > 0 {
+		nextID = jobs[len(jobs)-1].ID + 1
+	}
+
+	return &dummyJobRepo{
+		fs:     fs,
+		jobs:   jobs,
+		nextID: nextID,
+	}
+}
+
+// index finds the position of the job with id, or -1
+func (repo *dummyJobRepo) index(id uint) int {
+	i := sort.Search(len(repo.jobs), func(i int) bool {
+		return repo.jobs[i].ID >= id
+	})
+	if i < len(repo.jobs) && repo.jobs[i].ID == id {
+		return i
+	}
+	return -1
+}
+
+// prune forgets done and failed jobs last updated before cutoff
+func (repo *dummyJobRepo) prune(cutoff time.Time) {
+	kept := repo.jobs[:0]
+	for _, job := range repo.jobs {
+		if job.Status == JobStatusDone || job.Status == JobStatusFailed {
+			updated, err := time.Parse(time.RFC3339, job.TimeUpdated)
+			if err == nil && updated.Before(cutoff) {
+				continue
+			}
+		}
+		kept = append(kept, job)
+	}
+	repo.jobs = kept
+}
+
+func (repo *dummyJobRepo) dumpToDisk() error {
+	jobJSON, err := json.Marshal(&repo.jobs)
+	if err != nil {
+		return err
+	}
+	return files.PipeTo(repo.fs, "jobs.json", bytes.NewReader(jobJSON))
+}
+
+func (repo *dummyJobRepo) Save(job Job) (Job, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	now := time.Now()
+	job.TimeUpdated = now.Format(time.RFC3339)
+
+	if !job.Exists() {
+		job.ID = repo.nextID
+		repo.nextID++
+		job.TimeCreated = job.TimeUpdated
+		repo.prune(now.Add(-dummyJobRetention))
+		repo.jobs = append(repo.jobs, job)
+		return job, repo.dumpToDisk()
+	}
+
+	i := repo.index(job.ID)
+	if i == -1 {
+		return Job{}, ErrorJobNotFound
+	}
+
+	repo.jobs[i] = job
+	return job, repo.dumpToDisk()
+}
+
+func (repo *dummyJobRepo) FindById(id uint) (Job, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	i := repo.index(id)
+	if i == -1 {
+		return Job{}, ErrorJobNotFound
+	}
+
+	return repo.jobs[i], nil
+}
+
+func (repo *dummyJobRepo) All(filter JobFilter, limit uint, offset uint) ([]Job, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	jobs := make([]Job, 0)
+	for i := len(repo.jobs) - 1; i >= 0; i-- {
+		if !filter.Matches(repo.jobs[i]) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if uint(len(jobs)) >= limit {
+			break
+		}
+		jobs = append(jobs, repo.jobs[i])
+	}
+
+	return jobs, nil
+}
+
+func (repo *dummyJobRepo) Claim(now time.Time) (Job, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	claimed := -1
+	for i, job := range repo.jobs {
+		if job.Status != JobStatusPending || job.RunAfter.After(now) {
+			continue
+		}
+		if claimed == -1 || job.RunAfter.Before(repo.jobs[claimed].RunAfter) {
+			claimed = i
+		}
+	}
+
+	if claimed == -1 {
+		return Job{}, ErrorNoJobs
+	}
+
+	job := repo.jobs[claimed]
+	job.Status = JobStatusRunning
+	job.Attempts++
+	job.TimeUpdated = now.Format(time.RFC3339)
+	repo.jobs[claimed] = job
+
+	return job, repo.dumpToDisk()
+}
+
+func (repo *dummyJobRepo) Requeue() error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	changed := false
+	for i, job := range repo.jobs {
+		if job.Status == JobStatusRunning {
+			repo.jobs[i].Status = JobStatusPending
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return repo.dumpToDisk()
+}
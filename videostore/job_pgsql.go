@@ -0,0 +1,100 @@
+package videostore
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+// postgresJobRepo stores jobs to a Postgres DB
+type postgresJobRepo struct {
+	db pg.DB
+}
+
+// NewPostgresJobRepo expects an up-to-date schema, see MigratePostgresUp
+func NewPostgresJobRepo(db pg.DB) *postgresJobRepo {
+	return &postgresJobRepo{
+		db,
+	}
+}
+
+func (repo *postgresJobRepo) Save(job Job) (Job, error) {
+	var err error
+
+	job.TimeUpdated = time.Now().Format(time.RFC3339)
+	if job.Exists() {
+		err = repo.db.Update(&job)
+	} else {
+		job.TimeCreated = job.TimeUpdated
+		err = repo.db.Insert(&job)
+	}
+
+	return job, err
+}
+
+func (repo *postgresJobRepo) FindById(id uint) (Job, error) {
+	job := Job{
+		ID: id,
+	}
+
+	err := repo.db.Select(&job)
+
+	if err == pg.ErrNoRows {
+		return job, ErrorJobNotFound
+	}
+
+	return job, err
+}
+
+func (repo *postgresJobRepo) All(filter JobFilter, limit uint, offset uint) ([]Job, error) {
+	var jobs []Job
+
+	query := repo.db.Model(&jobs)
+
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.VideoID != 0 {
+		query = query.Where("video_id = ?", filter.VideoID)
+	}
+
+	err := query.Order("id DESC").Limit(int(limit)).Offset(int(offset)).Select()
+
+	return jobs, err
+}
+
+func (repo *postgresJobRepo) Claim(now time.Time) (Job, error) {
+	var job Job
+
+	// SKIP LOCKED lets several workers claim jobs without stepping on each other
+	_, err := repo.db.QueryOne(&job, `
+		UPDATE jobs
+		SET status = ?, attempts = attempts + 1, time_updated = ?
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = ? AND run_after <= ?
+			ORDER BY run_after, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`, JobStatusRunning, now.Format(time.RFC3339), JobStatusPending, now)
+
+	if err == pg.ErrNoRows {
+		return job, ErrorNoJobs
+	}
+
+	return job, err
+}
+
+func (repo *postgresJobRepo) Requeue() error {
+	_, err := repo.db.Model((*Job)(nil)).
+		Set("status = ?", JobStatusPending).
+		Where("status = ?", JobStatusRunning).
+		Update()
+
+	return err
+}
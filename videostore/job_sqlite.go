@@ -0,0 +1,180 @@
+package videostore
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+)
+
+// sqliteJobRepo stores jobs to an embedded SQLite DB
+type sqliteJobRepo struct {
+	db *sql.DB
+}
+
+const sqliteJobSchema = `
+CREATE TABLE IF NOT EXISTS jobs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL,
+	video_id INTEGER NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	max_attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	run_after TEXT NOT NULL,
+	time_created TEXT NOT NULL DEFAULT '',
+	time_updated TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS jobs_status_run_after ON jobs (status, run_after);
+`
+
+const sqliteJobColumns = `id, kind, video_id, status, attempts, max_attempts,
+	last_error, run_after, time_created, time_updated`
+
+func NewSQLiteJobRepo(db *sql.DB) *sqliteJobRepo {
+	if _, err := db.Exec(sqliteJobSchema); err != nil {
+		log.Fatalf("failed to create table: %+v", err)
+	}
+
+	return &sqliteJobRepo{
+		db,
+	}
+}
+
+func scanSQLiteJob(row sqliteScanner) (Job, error) {
+	var job Job
+	var runAfter string
+
+	err := row.Scan(
+		&job.ID,
+		&job.Kind,
+		&job.VideoID,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.LastError,
+		&runAfter,
+		&job.TimeCreated,
+		&job.TimeUpdated,
+	)
+	job.RunAfter = parseSQLiteTime(runAfter)
+
+	return job, err
+}
+
+func (repo *sqliteJobRepo) Save(job Job) (Job, error) {
+	job.TimeUpdated = time.Now().Format(time.RFC3339)
+
+	if job.Exists() {
+		result, err := repo.db.Exec(`UPDATE jobs SET
+			kind = ?, video_id = ?, status = ?, attempts = ?, max_attempts = ?,
+			last_error = ?, run_after = ?, time_updated = ?
+			WHERE id = ?`,
+			job.Kind, job.VideoID, job.Status, job.Attempts, job.MaxAttempts,
+			job.LastError, formatSQLiteTime(job.RunAfter), job.TimeUpdated, job.ID,
+		)
+		if err != nil {
+			return job, err
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			return job, ErrorJobNotFound
+		}
+		return job, nil
+	}
+
+	job.TimeCreated = job.TimeUpdated
+	result, err := repo.db.Exec(`INSERT INTO jobs (
+		kind, video_id, status, attempts, max_attempts,
+		last_error, run_after, time_created, time_updated
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.Kind, job.VideoID, job.Status, job.Attempts, job.MaxAttempts,
+		job.LastError, formatSQLiteTime(job.RunAfter), job.TimeCreated, job.TimeUpdated,
+	)
+	if err != nil {
+		return job, err
+	}
+
+	id, err := result.LastInsertId()
+	job.ID = uint(id)
+
+	return job, err
+}
+
+func (repo *sqliteJobRepo) FindById(id uint) (Job, error) {
+	job, err := scanSQLiteJob(repo.db.QueryRow("SELECT "+sqliteJobColumns+" FROM jobs WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return Job{ID: id}, ErrorJobNotFound
+	}
+
+	return job, err
+}
+
+func (repo *sqliteJobRepo) All(filter JobFilter, limit uint, offset uint) ([]Job, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.Kind != "" {
+		conditions = append(conditions, "kind = ?")
+		args = append(args, filter.Kind)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.VideoID != 0 {
+		conditions = append(conditions, "video_id = ?")
+		args = append(args, filter.VideoID)
+	}
+
+	query := "SELECT " + sqliteJobColumns + " FROM jobs"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]Job, 0)
+	for rows.Next() {
+		job, err := scanSQLiteJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+func (repo *sqliteJobRepo) Claim(now time.Time) (Job, error) {
+	// a single statement is atomic, and there is only ever one writer
+	job, err := scanSQLiteJob(repo.db.QueryRow(`
+		UPDATE jobs
+		SET status = ?, attempts = attempts + 1, time_updated = ?
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE status = ? AND run_after <= ?
+			ORDER BY run_after, id
+			LIMIT 1
+		)
+		RETURNING `+sqliteJobColumns,
+		JobStatusRunning, now.Format(time.RFC3339), JobStatusPending, formatSQLiteTime(now),
+	))
+
+	if err == sql.ErrNoRows {
+		return job, ErrorNoJobs
+	}
+
+	return job, err
+}
+
+func (repo *sqliteJobRepo) Requeue() error {
+	_, err := repo.db.Exec("UPDATE jobs SET status = ? WHERE status = ?", JobStatusPending, JobStatusRunning)
+
+	return err
+}
@@ -0,0 +1,61 @@
+package videostore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDummyJobRepoPrunesFinishedJobs(t *testing.T) {
+	root := "test-dummy-job-prune"
+	defer os.RemoveAll(root)
+
+	repo := NewDummyJobRepo(files.LocalFileSystem(root))
+
+	old, err := repo.Save(Job{Kind: JobKindProbe, Status: JobStatusDone})
+	assert.Nil(t, err)
+	pending, err := repo.Save(Job{Kind: JobKindThumbnail, Status: JobStatusPending})
+	assert.Nil(t, err)
+	recent, err := repo.Save(Job{Kind: JobKindPreview, Status: JobStatusFailed})
+	assert.Nil(t, err)
+	repo.jobs[repo.index(old.ID)].TimeUpdated = time.Now().Add(-dummyJobRetention - time.Hour).Format(time.RFC3339)
+	repo.jobs[repo.index(pending.ID)].TimeUpdated = repo.jobs[repo.index(old.ID)].TimeUpdated
+
+	added, err := repo.Save(Job{Kind: JobKindSprites, Status: JobStatusPending})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(4), added.ID, "IDs aren't reused")
+
+	_, err = repo.FindById(old.ID)
+	assert.Equal(t, ErrorJobNotFound, err)
+	for _, job := range []Job{pending, recent, added} {
+		found, err := repo.FindById(job.ID)
+		assert.Nil(t, err)
+		assert.Equal(t, job.Kind, found.Kind)
+	}
+
+	reloaded := NewDummyJobRepo(files.LocalFileSystem(root))
+	jobs, err := reloaded.All(JobFilter{}, 10, 0)
+	assert.Nil(t, err)
+	assert.Len(t, jobs, 3)
+	added, err = reloaded.Save(Job{Kind: JobKindProbe, Status: JobStatusPending})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(5), added.ID)
+}
+
+func TestDummyJobRepoRequeueOnlyWritesChanges(t *testing.T) {
+	root := "test-dummy-job-requeue"
+	defer os.RemoveAll(root)
+
+	repo := NewDummyJobRepo(files.LocalFileSystem(root))
+	_, err := repo.Save(Job{Kind: JobKindProbe, Status: JobStatusDone})
+	assert.Nil(t, err)
+
+	assert.Nil(t, os.Remove(filepath.Join(root, "jobs.json")))
+	assert.Nil(t, repo.Requeue())
+	_, err = os.Stat(filepath.Join(root, "jobs.json"))
+	assert.True(t, os.IsNotExist(err), "nothing was running, so nothing is written")
+}
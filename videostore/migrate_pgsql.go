@@ -0,0 +1,366 @@
+package videostore
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+// Migration is one numbered step of the Postgres schema.
+// Versions must never be renumbered or edited once released,
+// add a new migration instead.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes whether a migration has been applied
+type MigrationStatus struct {
+	Migration
+	Applied     bool
+	TimeApplied string
+}
+
+var ErrorNoMigrations = errors.New("no migrations applied")
+
+// migrationLockID keeps concurrent instances from
+// applying the same migration twice
+const migrationLockID = 0x637265616d79
+
+// the first migrations match what CreateTable and the old
+// ADD COLUMN list used to produce, so existing databases
+// can be brought under migration without changes
+var postgresMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create videos",
+		Up: `CREATE TABLE IF NOT EXISTS videos (
+			id bigserial,
+			title text,
+			description text,
+			thumbnail text,
+			source text,
+			original_file_name text,
+			time_created text,
+			time_updated text,
+			tags jsonb,
+			PRIMARY KEY (id)
+		)`,
+		Down: `DROP TABLE videos`,
+	},
+	{
+		Version: 2,
+		Name:    "create jobs",
+		Up: `CREATE TABLE IF NOT EXISTS jobs (
+			id bigserial,
+			kind text,
+			video_id bigint,
+			status text,
+			attempts bigint NOT NULL,
+			max_attempts bigint,
+			last_error text,
+			run_after timestamptz,
+			time_created text,
+			time_updated text,
+			PRIMARY KEY (id)
+		)`,
+		Down: `DROP TABLE jobs`,
+	},
+	{
+		Version: 3,
+		Name:    "add video media info",
+		Up: `ALTER TABLE videos
+			ADD COLUMN IF NOT EXISTS duration double precision,
+			ADD COLUMN IF NOT EXISTS width bigint,
+			ADD COLUMN IF NOT EXISTS height bigint,
+			ADD COLUMN IF NOT EXISTS video_codec text,
+			ADD COLUMN IF NOT EXISTS audio_codec text,
+			ADD COLUMN IF NOT EXISTS bitrate bigint,
+			ADD COLUMN IF NOT EXISTS size bigint`,
+		Down: `ALTER TABLE videos
+			DROP COLUMN IF EXISTS duration,
+			DROP COLUMN IF EXISTS width,
+			DROP COLUMN IF EXISTS height,
+			DROP COLUMN IF EXISTS video_codec,
+			DROP COLUMN IF EXISTS audio_codec,
+			DROP COLUMN IF EXISTS bitrate,
+			DROP COLUMN IF EXISTS size`,
+	},
+	{
+		Version: 4,
+		Name:    "add video hls renditions",
+		Up: `ALTER TABLE videos
+			ADD COLUMN IF NOT EXISTS hls_playlist text,
+			ADD COLUMN IF NOT EXISTS renditions jsonb`,
+		Down: `ALTER TABLE videos
+			DROP COLUMN IF EXISTS hls_playlist,
+			DROP COLUMN IF EXISTS renditions`,
+	},
+	{
+		Version: 5,
+		Name:    "add video sprites",
+		Up:      `ALTER TABLE videos ADD COLUMN IF NOT EXISTS sprites text`,
+		Down:    `ALTER TABLE videos DROP COLUMN IF EXISTS sprites`,
+	},
+	{
+		Version: 6,
+		Name:    "add video preview",
+		Up:      `ALTER TABLE videos ADD COLUMN IF NOT EXISTS preview text`,
+		Down:    `ALTER TABLE videos DROP COLUMN IF EXISTS preview`,
+	},
+	{
+		Version: 7,
+		Name:    "add video full-text search",
+		// weights line up with the dummy repo's search index:
+		// title A, tags B, description C
+		Up: `ALTER TABLE videos ADD COLUMN search tsvector GENERATED ALWAYS AS (
+				setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+				setweight(jsonb_to_tsvector('simple', coalesce(tags, '[]'::jsonb), '["string"]'), 'B') ||
+				setweight(to_tsvector('simple', coalesce(description, '')), 'C')
+			) STORED;
+			CREATE INDEX videos_search ON videos USING GIN (search)`,
+		Down: `DROP INDEX IF EXISTS videos_search;
+			ALTER TABLE videos DROP COLUMN IF EXISTS search`,
+	},
+	{
+		Version: 8,
+		Name:    "create tag aliases table",
+		Up: `CREATE TABLE tag_aliases (
+				alias text PRIMARY KEY,
+				tag text NOT NULL
+			)`,
+		Down: `DROP TABLE IF EXISTS tag_aliases`,
+	},
+	{
+		Version: 9,
+		Name:    "create users",
+		Up: `CREATE TABLE users (
+				id bigserial PRIMARY KEY,
+				username text NOT NULL UNIQUE,
+				password_hash text NOT NULL DEFAULT '',
+				time_created text,
+				time_updated text
+			)`,
+		Down: `DROP TABLE IF EXISTS users`,
+	},
+	{
+		Version: 10,
+		Name:    "add user roles",
+		// anyone who could log in before roles could do everything
+		Up: `ALTER TABLE users ADD COLUMN role text NOT NULL DEFAULT 'admin';
+			ALTER TABLE users ALTER COLUMN role SET DEFAULT 'viewer'`,
+		Down: `ALTER TABLE users DROP COLUMN IF EXISTS role`,
+	},
+	{
+		Version: 11,
+		Name:    "create api tokens",
+		Up: `CREATE TABLE api_tokens (
+				id bigserial PRIMARY KEY,
+				user_id bigint NOT NULL,
+				name text NOT NULL DEFAULT '',
+				token_hash text NOT NULL UNIQUE,
+				scopes jsonb,
+				time_created text
+			);
+			CREATE INDEX api_tokens_user_id ON api_tokens (user_id)`,
+		Down: `DROP TABLE IF EXISTS api_tokens`,
+	},
+	{
+		Version: 12,
+		Name:    "add video owners",
+		Up: `ALTER TABLE videos ADD COLUMN owner_id bigint;
+			CREATE INDEX videos_owner_id ON videos (owner_id)`,
+		Down: `DROP INDEX IF EXISTS videos_owner_id;
+			ALTER TABLE videos DROP COLUMN IF EXISTS owner_id`,
+	},
+	{
+		Version: 13,
+		Name:    "add video visibility",
+		Up:      `ALTER TABLE videos ADD COLUMN visibility text NOT NULL DEFAULT 'public'`,
+		Down:    `ALTER TABLE videos DROP COLUMN IF EXISTS visibility`,
+	},
+	{
+		Version: 14,
+		Name:    "add video hls fallback",
+		Up:      `ALTER TABLE videos ADD COLUMN IF NOT EXISTS fallback text`,
+		Down:    `ALTER TABLE videos DROP COLUMN IF EXISTS fallback`,
+	},
+	{
+		Version: 15,
+		Name:    "add user oidc identities",
+		Up: `ALTER TABLE users ADD COLUMN oidc_issuer text;
+			ALTER TABLE users ADD COLUMN oidc_subject text;
+			CREATE UNIQUE INDEX users_oidc ON users (oidc_issuer, oidc_subject)`,
+		Down: `DROP INDEX IF EXISTS users_oidc;
+			ALTER TABLE users DROP COLUMN IF EXISTS oidc_subject;
+			ALTER TABLE users DROP COLUMN IF EXISTS oidc_issuer`,
+	},
+	{
+		Version: 16,
+		Name:    "add video custom thumbnails",
+		Up: `ALTER TABLE videos ADD COLUMN custom_thumbnail boolean NOT NULL DEFAULT false;
+			ALTER TABLE videos ADD COLUMN thumbnail_timestamp double precision`,
+		Down: `ALTER TABLE videos DROP COLUMN IF EXISTS thumbnail_timestamp;
+			ALTER TABLE videos DROP COLUMN IF EXISTS custom_thumbnail`,
+	},
+}
+
+type appliedMigration struct {
+	tableName struct{} `sql:"migrations"`
+
+	Version     int
+	Name        string
+	TimeApplied string
+}
+
+func createMigrationsTable(db pg.DB) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		time_applied text NOT NULL
+	)`)
+	return err
+}
+
+// migrationStatus lines up known migrations with applied ones
+func migrationStatus(migrations []Migration, applied []appliedMigration) []MigrationStatus {
+	appliedByVersion := make(map[int]appliedMigration, len(applied))
+	for _, migration := range applied {
+		appliedByVersion[migration.Version] = migration
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		statuses[i].Migration = migration
+		if found, ok := appliedByVersion[migration.Version]; ok {
+			statuses[i].Applied = true
+			statuses[i].TimeApplied = found.TimeApplied
+		}
+	}
+
+	return statuses
+}
+
+// PostgresMigrationStatus lists every known migration and whether it has been applied
+func PostgresMigrationStatus(db pg.DB) ([]MigrationStatus, error) {
+	if err := createMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	var applied []appliedMigration
+	if err := db.Model(&applied).Select(); err != nil {
+		return nil, err
+	}
+
+	return migrationStatus(postgresMigrations, applied), nil
+}
+
+// PendingPostgresMigrations counts migrations that haven't been applied yet
+func PendingPostgresMigrations(db pg.DB) (int, error) {
+	statuses, err := PostgresMigrationStatus(db)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, status := range statuses {
+		if !status.Applied {
+			pending++
+		}
+	}
+
+	return pending, nil
+}
+
+// MigratePostgresUp applies every pending migration in order,
+// each in its own transaction, and returns the ones it applied
+func MigratePostgresUp(db pg.DB) ([]Migration, error) {
+	if err := createMigrationsTable(db); err != nil {
+		return nil, err
+	}
+
+	applied := []Migration{}
+	for _, migration := range postgresMigrations {
+		migration := migration
+		ran := false
+
+		err := db.RunInTransaction(func(tx *pg.Tx) error {
+			if _, err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID); err != nil {
+				return err
+			}
+
+			count, err := tx.Model((*appliedMigration)(nil)).Where("version = ?", migration.Version).Count()
+			if err != nil || count > 0 {
+				return err
+			}
+
+			if _, err := tx.Exec(migration.Up); err != nil {
+				return err
+			}
+
+			ran = true
+			return tx.Insert(&appliedMigration{
+				Version:     migration.Version,
+				Name:        migration.Name,
+				TimeApplied: time.Now().Format(time.RFC3339),
+			})
+		})
+		if err != nil {
+			return applied, fmt.Errorf("failed to apply migration %v (%v): %v", migration.Version, migration.Name, err)
+		}
+
+		if ran {
+			applied = append(applied, migration)
+		}
+	}
+
+	return applied, nil
+}
+
+// MigratePostgresDown reverts the most recently applied migration.
+// ErrorNoMigrations is returned if there is nothing to revert.
+func MigratePostgresDown(db pg.DB) (Migration, error) {
+	if err := createMigrationsTable(db); err != nil {
+		return Migration{}, err
+	}
+
+	var reverted Migration
+	err := db.RunInTransaction(func(tx *pg.Tx) error {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID); err != nil {
+			return err
+		}
+
+		var latest appliedMigration
+		err := tx.Model(&latest).Order("version DESC").Limit(1).Select()
+		if err == pg.ErrNoRows {
+			return ErrorNoMigrations
+		}
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for _, migration := range postgresMigrations {
+			if migration.Version == latest.Version {
+				reverted = migration
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("migration %v (%v) is unknown to this version", latest.Version, latest.Name)
+		}
+
+		if _, err := tx.Exec(reverted.Down); err != nil {
+			return err
+		}
+
+		_, err = tx.Model((*appliedMigration)(nil)).Where("version = ?", reverted.Version).Delete()
+		return err
+	})
+
+	return reverted, err
+}
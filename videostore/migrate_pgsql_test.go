@@ -0,0 +1,55 @@
+package videostore
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-pg/pg/orm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresMigrationsAreNumberedInOrder(t *testing.T) {
+	for i, migration := range postgresMigrations {
+		assert.Equal(t, i+1, migration.Version)
+		assert.NotEmpty(t, migration.Name)
+		assert.NotEmpty(t, migration.Up)
+		assert.NotEmpty(t, migration.Down)
+	}
+}
+
+func TestPostgresMigrationsCoverModels(t *testing.T) {
+	var allUp strings.Builder
+	for _, migration := range postgresMigrations {
+		allUp.WriteString(migration.Up)
+		allUp.WriteString("\n")
+	}
+
+	// adding a field without a migration breaks existing deployments
+	for _, model := range []interface{}{Video{}, Job{}, TagAlias{}, User{}, APIToken{}} {
+		table := orm.GetTable(reflect.TypeOf(model))
+		for _, field := range table.Fields {
+			assert.Contains(t, allUp.String(), strings.Trim(string(field.Column), `"`)+" ", "%v.%v has no migration", table.TypeName, field.GoName)
+		}
+	}
+}
+
+func TestMigrationStatus(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "one"},
+		{Version: 2, Name: "two"},
+		{Version: 3, Name: "three"},
+	}
+
+	statuses := migrationStatus(migrations, []appliedMigration{
+		{Version: 1, Name: "one", TimeApplied: "2018-12-25T00:00:00Z"},
+		{Version: 3, Name: "three", TimeApplied: "2018-12-26T00:00:00Z"},
+	})
+
+	assert.Len(t, statuses, 3)
+	assert.True(t, statuses[0].Applied)
+	assert.Equal(t, "2018-12-25T00:00:00Z", statuses[0].TimeApplied)
+	assert.False(t, statuses[1].Applied)
+	assert.Equal(t, "two", statuses[1].Name)
+	assert.True(t, statuses[2].Applied)
+}
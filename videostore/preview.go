@@ -0,0 +1,87 @@
+package videostore
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/pkg/errors"
+)
+
+const previewFile = "preview.mp4"
+const previewLength = 3.0 // seconds
+
+// previewStart skips past intros and title cards,
+// without running off the end of short videos
+func previewStart(duration float64) float64 {
+	start := duration * 0.2
+	if start+previewLength > duration {
+		start = duration - previewLength
+	}
+	if start < 0 {
+		start = 0
+	}
+	return start
+}
+
+func previewArgs(input string, output string, duration float64) []string {
+	return []string{
+		"-v", "error",
+		"-ss", fmt.Sprintf("%.3f", previewStart(duration)),
+		"-i", input,
+		"-t", fmt.Sprintf("%.3f", previewLength),
+		"-an",
+		"-vf", "scale=320:-2",
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-crf", "32",
+		"-pix_fmt", "yuv420p",
+		"-movflags", "+faststart",
+		"-y", output,
+	}
+}
+
+// GeneratePreview produces a short, silent, low-bitrate clip
+// of the video, shown when hovering over it in a list
+func GeneratePreview(video Video, repo VideoRepo, fs files.FileSystem) (Video, error) {
+	tempDir, temporaryVideoPath, err := downloadToTemporaryFile(video, fs, "eventual-preview-")
+	if err != nil {
+		return video, err
+	}
+	defer os.RemoveAll(tempDir) // clean up
+
+	source, err := probeLocalFile(Video{}, temporaryVideoPath)
+	if err != nil {
+		return video, err
+	}
+
+	temporaryPreviewPath := filepath.Join(tempDir, previewFile)
+	cmdOutput, err := exec.Command("ffmpeg", previewArgs(temporaryVideoPath, temporaryPreviewPath, source.Duration)...).CombinedOutput()
+	if err != nil {
+		return video, errors.Wrapf(err, "failed to run ffmpeg: %s", cmdOutput)
+	}
+
+	temporaryPreviewStream, err := os.Open(temporaryPreviewPath)
+	if err != nil {
+		return video, errors.Wrap(err, "failed to open created preview")
+	}
+	defer temporaryPreviewStream.Close()
+
+	finalPreviewPath := path.Join(path.Dir(video.Source), previewFile)
+	err = files.PipeTo(fs, finalPreviewPath, temporaryPreviewStream)
+	if err != nil {
+		return video, errors.Wrap(err, "failed to upload preview")
+	}
+
+	video, err = updateVideo(repo, video.ID, func(video *Video) {
+		video.Preview = finalPreviewPath
+	})
+	if err != nil {
+		return video, errors.Wrap(err, "failed to save video preview")
+	}
+
+	return video, nil
+}
@@ -0,0 +1,16 @@
+package videostore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_previewStart(t *testing.T) {
+	assert.Equal(t, 20.0, previewStart(100))
+	// short videos start early enough to fill the preview
+	assert.Equal(t, 0.5, previewStart(3.5))
+	// and videos shorter than the preview start at the beginning
+	assert.Equal(t, 0.0, previewStart(2))
+	assert.Equal(t, 0.0, previewStart(0))
+}
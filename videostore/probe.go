@@ -0,0 +1,150 @@
+package videostore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/pkg/errors"
+)
+
+// probeResult is the subset of `ffprobe -print_format json` output we care about
+type probeResult struct {
+	Streams []struct {
+		CodecType string `json:"codec_type"`
+		CodecName string `json:"codec_name"`
+		Width     int    `json:"width"`
+		Height    int    `json:"height"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// applyProbeOutput copies media information from raw ffprobe JSON onto video
+func applyProbeOutput(video Video, output []byte) (Video, error) {
+	var result probeResult
+	if err := json.Unmarshal(output, &result); err != nil {
+		return video, errors.Wrap(err, "failed to parse ffprobe output")
+	}
+
+	if result.Format.Duration != "" {
+		duration, err := strconv.ParseFloat(result.Format.Duration, 64)
+		if err != nil {
+			return video, errors.Wrap(err, "failed to parse duration")
+		}
+		video.Duration = duration
+	}
+
+	if result.Format.BitRate != "" {
+		bitrate, err := strconv.ParseInt(result.Format.BitRate, 10, 64)
+		if err != nil {
+			return video, errors.Wrap(err, "failed to parse bitrate")
+		}
+		video.Bitrate = bitrate
+	}
+
+	for _, stream := range result.Streams {
+		switch stream.CodecType {
+		case "video":
+			if video.VideoCodec != "" {
+				continue
+			}
+			video.VideoCodec = stream.CodecName
+			video.Width = stream.Width
+			video.Height = stream.Height
+		case "audio":
+			if video.AudioCodec != "" {
+				continue
+			}
+			video.AudioCodec = stream.CodecName
+		}
+	}
+
+	return video, nil
+}
+
+func probeArgs(input string) []string {
+	return []string{"-v", "error", "-print_format", "json", "-show_format", "-show_streams", input}
+}
+
+// ProbeVideo reads duration, resolution and codec information
+// from the video file using ffprobe and saves it to the repo
+func ProbeVideo(video Video, repo VideoRepo, fs files.FileSystem) (Video, error) {
+	stat, err := fs.Stat(video.Source)
+	if err != nil {
+		return video, errors.Wrap(err, "failed to stat video")
+	}
+
+	videoStream, err := fs.Open(video.Source)
+	if err != nil {
+		return video, errors.Wrap(err, "failed to open video")
+	}
+	defer videoStream.Close()
+
+	probed := video
+	probed.VideoCodec = ""
+	probed.AudioCodec = ""
+
+	var output bytes.Buffer
+	cmd := exec.Command("ffprobe", probeArgs("-")...)
+	cmd.Stdin = videoStream
+	cmd.Stdout = &output
+
+	err = cmd.Run()
+	if err == nil {
+		probed, err = applyProbeOutput(probed, output.Bytes())
+	}
+
+	// some formats cannot be probed when being piped,
+	// or are missing a duration until the whole file can be seen (some MOV files)
+	if err != nil || probed.Duration == 0 {
+		var tempFileError error
+		probed, tempFileError = probeVideoUsingTemporaryFile(video, fs)
+		if tempFileError != nil {
+			return video, fmt.Errorf("failed to probe video using any method.\npipe: %+v\ntemp: %+v", err, tempFileError)
+		}
+	}
+
+	video, err = updateVideo(repo, video.ID, func(video *Video) {
+		video.Duration = probed.Duration
+		video.Width = probed.Width
+		video.Height = probed.Height
+		video.VideoCodec = probed.VideoCodec
+		video.AudioCodec = probed.AudioCodec
+		video.Bitrate = probed.Bitrate
+		video.Size = stat.Size()
+	})
+	if err != nil {
+		return video, errors.Wrap(err, "failed to save video metadata")
+	}
+
+	return video, nil
+}
+
+func probeVideoUsingTemporaryFile(video Video, fs files.FileSystem) (Video, error) {
+	tempDir, temporaryVideoPath, err := downloadToTemporaryFile(video, fs, "eventual-probe-")
+	if err != nil {
+		return video, err
+	}
+	defer os.RemoveAll(tempDir) // clean up
+
+	video.VideoCodec = ""
+	video.AudioCodec = ""
+	return probeLocalFile(video, temporaryVideoPath)
+}
+
+// probeLocalFile applies media information from a file on disk to video
+func probeLocalFile(video Video, localPath string) (Video, error) {
+	output, err := exec.Command("ffprobe", probeArgs(localPath)...).Output()
+	if err != nil {
+		return video, errors.Wrap(err, "failed to run ffprobe")
+	}
+
+	return applyProbeOutput(video, output)
+}
@@ -0,0 +1,40 @@
+package videostore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_applyProbeOutput(t *testing.T) {
+	output := []byte(`{
+		"streams": [
+			{"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080},
+			{"codec_type": "audio", "codec_name": "aac"},
+			{"codec_type": "video", "codec_name": "mjpeg", "width": 320, "height": 180}
+		],
+		"format": {
+			"duration": "62.500000",
+			"bit_rate": "4500000"
+		}
+	}`)
+
+	video, err := applyProbeOutput(Video{ID: 1}, output)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), video.ID)
+	assert.Equal(t, 62.5, video.Duration)
+	assert.Equal(t, int64(4500000), video.Bitrate)
+	assert.Equal(t, "h264", video.VideoCodec)
+	assert.Equal(t, "aac", video.AudioCodec)
+	assert.Equal(t, 1920, video.Width)
+	assert.Equal(t, 1080, video.Height)
+
+	// silent videos and missing bitrates are fine
+	video, err = applyProbeOutput(Video{}, []byte(`{"streams": [{"codec_type": "video", "codec_name": "vp9", "width": 640, "height": 360}], "format": {"duration": "1.0"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "", video.AudioCodec)
+	assert.Equal(t, int64(0), video.Bitrate)
+
+	_, err = applyProbeOutput(Video{}, []byte(`not json`))
+	assert.NotNil(t, err)
+}
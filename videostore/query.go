@@ -0,0 +1,78 @@
+package videostore
+
+import "strings"
+
+const QueryAnd = "and"
+const QueryOr = "or"
+const QueryNot = "not"
+const QueryTerm = "term"
+
+// QueryFieldAny matches like VideoFilter.Any,
+// text in the title or an exact tag
+const QueryFieldAny = ""
+const QueryFieldTitle = "title"
+const QueryFieldTag = "tag"
+
+// QueryNode is one node of a parsed search query, like
+// `cats -dogs (funny | cute)`.
+// And, Or and Not combine their Children,
+// Term matches Value against Field.
+type QueryNode struct {
+	Op       string
+	Field    string
+	Value    string
+	Children []QueryNode
+}
+
+func (node QueryNode) String() string {
+	switch node.Op {
+	case QueryTerm:
+		if node.Field == QueryFieldAny {
+			return node.Value
+		}
+		return node.Field + ":" + node.Value
+	case QueryNot:
+		return "-" + node.Children[0].String()
+	}
+
+	parts := make([]string, len(node.Children))
+	for i, child := range node.Children {
+		parts[i] = child.String()
+	}
+	separator := " "
+	if node.Op == QueryOr {
+		separator = " | "
+	}
+	return "(" + strings.Join(parts, separator) + ")"
+}
+
+// videoMatchesQuery evaluates node in-memory,
+// terms behave like the text filters of the dummy repo
+func videoMatchesQuery(video Video, node QueryNode) bool {
+	switch node.Op {
+	case QueryAnd:
+		for _, child := range node.Children {
+			if !videoMatchesQuery(video, child) {
+				return false
+			}
+		}
+		return true
+	case QueryOr:
+		for _, child := range node.Children {
+			if videoMatchesQuery(video, child) {
+				return true
+			}
+		}
+		return false
+	case QueryNot:
+		return !videoMatchesQuery(video, node.Children[0])
+	}
+
+	switch node.Field {
+	case QueryFieldTitle:
+		return titleContains(video.Title, node.Value)
+	case QueryFieldTag:
+		return videoHasAllTags(video, []string{node.Value})
+	}
+	return titleContains(video.Title, node.Value) || videoHasAllTags(video, []string{node.Value})
+}
@@ -0,0 +1,135 @@
+package videostore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/stretchr/testify/assert"
+)
+
+func testTerm(field string, value string) QueryNode {
+	return QueryNode{Op: QueryTerm, Field: field, Value: value}
+}
+
+func testNot(child QueryNode) QueryNode {
+	return QueryNode{Op: QueryNot, Children: []QueryNode{child}}
+}
+
+// `cats -dogs (funny | cute)`
+var testQuery = QueryNode{Op: QueryAnd, Children: []QueryNode{
+	testTerm(QueryFieldAny, "cats"),
+	testNot(testTerm(QueryFieldAny, "dogs")),
+	{Op: QueryOr, Children: []QueryNode{
+		testTerm(QueryFieldTag, "funny"),
+		testTerm(QueryFieldTitle, "cute"),
+	}},
+}}
+
+var testQueryVideos = []Video{
+	{Title: "cats being cute"},
+	{Title: "more cats", Tags: []string{"funny"}},
+	{Title: "cats vs dogs", Tags: []string{"funny"}},
+	{Title: "cats", Tags: []string{"funny", "dogs"}},
+	{Title: "funny cats"},
+	{Title: "cute", Tags: []string{"cats"}},
+}
+
+var testQueryMatches = []string{"cats being cute", "more cats", "cute"}
+
+// title matching ignores case in every repo
+var testQueryUpper = testTerm(QueryFieldTitle, "CATS")
+
+var testQueryUpperMatches = []string{"cats being cute", "more cats", "cats vs dogs", "cats", "funny cats"}
+
+func queryTitles(t *testing.T, repo VideoRepo, filter VideoFilter) []string {
+	videos, err := repo.All(filter, 10, 0)
+	assert.Nil(t, err)
+
+	count, err := repo.Count(filter)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(len(videos)), count)
+
+	titles := []string{}
+	for _, video := range videos {
+		titles = append(titles, video.Title)
+	}
+	return titles
+}
+
+func TestQueryNodeString(t *testing.T) {
+	assert.Equal(t, "(cats -dogs (tag:funny | title:cute))", testQuery.String())
+}
+
+func TestDummyVideoRepoQuery(t *testing.T) {
+	root := "test-dummy-query"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := NewDummyVideoRepo(fs)
+	for _, video := range testQueryVideos {
+		_, err := repo.Save(video)
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, testQueryMatches, queryTitles(t, repo, VideoFilter{Query: &testQuery}))
+	assert.Equal(t, testQueryUpperMatches, queryTitles(t, repo, VideoFilter{Query: &testQueryUpper}))
+}
+
+func TestSQLiteVideoRepoQuery(t *testing.T) {
+	root := "test-sqlite-query"
+	assert.Nil(t, os.MkdirAll(root, os.ModePerm))
+	defer os.RemoveAll(root)
+
+	db, err := OpenSQLite(filepath.Join(root, "videos.sqlite"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	repo := NewSQLiteVideoRepo(db)
+	for _, video := range testQueryVideos {
+		_, err := repo.Save(video)
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, testQueryMatches, queryTitles(t, repo, VideoFilter{Query: &testQuery}))
+	assert.Equal(t, testQueryUpperMatches, queryTitles(t, repo, VideoFilter{Query: &testQueryUpper}))
+}
+
+func TestPostgresQueryCondition(t *testing.T) {
+	condition, params := postgresQueryCondition(testQuery)
+	tag, _ := postgresHasAllTags([]string{"tag"})
+
+	assert.Equal(
+		t,
+		`((LOWER(COALESCE(title, '')) LIKE LOWER(?) OR `+tag+`) AND `+
+			`NOT (LOWER(COALESCE(title, '')) LIKE LOWER(?) OR `+tag+`) AND `+
+			`((`+tag+`) OR (LOWER(COALESCE(title, '')) LIKE LOWER(?))))`,
+		condition,
+	)
+	assert.Len(t, params, 9)
+	assert.Equal(t, "%cats%", params[0])
+	assert.Equal(t, "cats", params[1])
+	assert.Equal(t, "cats/%", params[2])
+	assert.Equal(t, "%cute%", params[8])
+
+	// wildcards are matched literally
+	_, params = postgresQueryCondition(testTerm(QueryFieldTitle, "100%_"))
+	assert.Equal(t, []interface{}{`%100\%\_%`}, params)
+}
+
+func TestPostgresHasAllTags(t *testing.T) {
+	condition, params := postgresHasAllTags([]string{"animals", "100%"})
+
+	assert.Equal(
+		t,
+		`(COALESCE(tags, '[]'::jsonb) \? ? OR EXISTS (`+
+			`SELECT 1 FROM jsonb_array_elements_text(CASE jsonb_typeof(tags) WHEN 'array' THEN tags ELSE '[]'::jsonb END) AS nested `+
+			`WHERE nested LIKE ?)) AND `+
+			`(COALESCE(tags, '[]'::jsonb) \? ? OR EXISTS (`+
+			`SELECT 1 FROM jsonb_array_elements_text(CASE jsonb_typeof(tags) WHEN 'array' THEN tags ELSE '[]'::jsonb END) AS nested `+
+			`WHERE nested LIKE ?))`,
+		condition,
+	)
+	assert.Equal(t, []interface{}{"animals", "animals/%", "100%", `100\%/%`}, params)
+}
@@ -0,0 +1,41 @@
+package videostore
+
+import (
+	"path"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/pkg/errors"
+)
+
+// VideoFiles lists the video and everything generated from it.
+// HLS renditions are listed as their whole directory.
+func VideoFiles(video Video) []string {
+	candidates := []string{video.Source, video.Thumbnail, video.Preview}
+	if video.Sprites != "" {
+		candidates = append(candidates, video.Sprites, path.Join(path.Dir(video.Sprites), spriteSheetFile))
+	}
+	if video.HLSPlaylist != "" {
+		candidates = append(candidates, path.Dir(video.HLSPlaylist))
+	}
+
+	paths := []string{}
+	for _, p := range candidates {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// RemoveVideoFiles removes the video and everything generated from it.
+// Every file is attempted, the first error is returned.
+func RemoveVideoFiles(video Video, fs files.FileSystem) error {
+	var firstErr error
+	for _, p := range VideoFiles(video) {
+		if err := files.RemoveAll(fs, p); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "failed to remove %v", p)
+		}
+	}
+
+	return firstErr
+}
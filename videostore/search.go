@@ -0,0 +1,212 @@
+package videostore
+
+import (
+	"html"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// how much a term counts towards relevance depending on where it was found
+const (
+	searchWeightTitle       = 3
+	searchWeightTags        = 2
+	searchWeightDescription = 1
+)
+
+// snippetRadius is roughly how many characters of context
+// are kept on each side of the first match
+const snippetRadius = 60
+
+// tokenize splits text into lowercase words,
+// the same way for indexing and for querying
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// searchIndex is an inverted index of video text,
+// mapping each token to the videos containing it and how relevant it is to them
+type searchIndex struct {
+	lock     sync.RWMutex
+	postings map[string]map[uint]float64
+	tokens   map[uint][]string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[uint]float64),
+		tokens:   make(map[uint][]string),
+	}
+}
+
+func (index *searchIndex) removeLocked(id uint) {
+	for _, token := range index.tokens[id] {
+		delete(index.postings[token], id)
+		if len(index.postings[token]) == 0 {
+			delete(index.postings, token)
+		}
+	}
+	delete(index.tokens, id)
+}
+
+// add indexes video, replacing whatever was indexed for it before
+func (index *searchIndex) add(video Video) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	index.removeLocked(video.ID)
+	if !video.Exists() {
+		return
+	}
+
+	weights := make(map[string]float64)
+	for _, token := range tokenize(video.Title) {
+		weights[token] += searchWeightTitle
+	}
+	for _, token := range tokenize(strings.Join(video.Tags, " ")) {
+		weights[token] += searchWeightTags
+	}
+	for _, token := range tokenize(video.Description) {
+		weights[token] += searchWeightDescription
+	}
+
+	tokens := make([]string, 0, len(weights))
+	for token, weight := range weights {
+		if index.postings[token] == nil {
+			index.postings[token] = make(map[uint]float64)
+		}
+		index.postings[token][video.ID] = weight
+		tokens = append(tokens, token)
+	}
+	index.tokens[video.ID] = tokens
+}
+
+func (index *searchIndex) remove(id uint) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	index.removeLocked(id)
+}
+
+// search scores the videos containing every token of text
+func (index *searchIndex) search(text string) map[uint]float64 {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+
+	scores := make(map[uint]float64)
+
+	tokens := tokenize(text)
+	for i, token := range tokens {
+		postings := index.postings[token]
+		if i == 0 {
+			for id, weight := range postings {
+				scores[id] = weight
+			}
+			continue
+		}
+
+		for id := range scores {
+			weight, ok := postings[id]
+			if !ok {
+				delete(scores, id)
+				continue
+			}
+			scores[id] += weight
+		}
+	}
+
+	return scores
+}
+
+// Snippet picks the part of the video's description (or title) that best matches text,
+// with each matching word wrapped in <mark></mark>.
+// Everything else is HTML-escaped, so the snippet is safe to render as-is.
+func Snippet(video Video, text string) string {
+	wanted := make(map[string]bool)
+	for _, token := range tokenize(text) {
+		wanted[token] = true
+	}
+	if len(wanted) == 0 {
+		return ""
+	}
+
+	source := video.Description
+	if !containsToken(source, wanted) {
+		source = video.Title
+		if !containsToken(source, wanted) {
+			return ""
+		}
+	}
+
+	// find each word along with where it sits in source
+	type word struct {
+		start, end int
+		match      bool
+	}
+	var words []word
+	start := -1
+	for i, r := range source + " " {
+		isWordRune := unicode.IsLetter(r) || unicode.IsNumber(r)
+		if isWordRune && start == -1 {
+			start = i
+		} else if !isWordRune && start != -1 {
+			words = append(words, word{start, i, wanted[strings.ToLower(source[start:i])]})
+			start = -1
+		}
+	}
+
+	first := 0
+	for i, w := range words {
+		if w.match {
+			first = i
+			break
+		}
+	}
+
+	from := 0
+	prefix := ""
+	if words[first].start > snippetRadius {
+		for i := first; i >= 0 && words[first].start-words[i].start <= snippetRadius; i-- {
+			from = words[i].start
+		}
+		prefix = "…"
+	}
+
+	to := len(source)
+	suffix := ""
+	if len(source)-words[first].end > snippetRadius {
+		for i := first; i < len(words) && words[i].end-words[first].end <= snippetRadius; i++ {
+			to = words[i].end
+		}
+		suffix = "…"
+	}
+
+	var snippet strings.Builder
+	snippet.WriteString(prefix)
+	position := from
+	for _, w := range words {
+		if !w.match || w.start < from || w.end > to {
+			continue
+		}
+		snippet.WriteString(html.EscapeString(source[position:w.start]))
+		snippet.WriteString("<mark>")
+		snippet.WriteString(html.EscapeString(source[w.start:w.end]))
+		snippet.WriteString("</mark>")
+		position = w.end
+	}
+	snippet.WriteString(html.EscapeString(source[position:to]))
+	snippet.WriteString(suffix)
+
+	return snippet.String()
+}
+
+func containsToken(text string, wanted map[string]bool) bool {
+	for _, token := range tokenize(text) {
+		if wanted[token] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package videostore
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"doggo", "zoomies", "2", "café"}, tokenize("Doggo-Zoomies #2: CAFÉ!"))
+	assert.Empty(t, tokenize(" ?! "))
+}
+
+func TestSearchIndex(t *testing.T) {
+	index := newSearchIndex()
+	index.add(Video{ID: 1, Title: "good dog", Description: "a very good boy"})
+	index.add(Video{ID: 2, Title: "cat", Tags: []string{"good"}})
+	index.add(Video{ID: 3, Title: "bird", Description: "not a dog"})
+
+	scores := index.search("GOOD")
+	assert.Len(t, scores, 2)
+	// title and description beat tags
+	assert.Greater(t, scores[1], scores[2])
+
+	assert.Equal(t, map[uint]float64{1: searchWeightTitle*2 + searchWeightDescription}, index.search("good dog"))
+	assert.Empty(t, index.search("good bird"))
+
+	// re-adding replaces old tokens
+	index.add(Video{ID: 1, Title: "renamed"})
+	assert.Len(t, index.search("dog"), 1)
+	assert.Len(t, index.search("renamed"), 1)
+
+	index.remove(1)
+	assert.Empty(t, index.search("renamed"))
+}
+
+func TestSnippet(t *testing.T) {
+	video := Video{Title: "Dog <3", Description: "a very good <b>boy</b>"}
+
+	assert.Equal(t, "a very <mark>good</mark> &lt;b&gt;<mark>boy</mark>&lt;/b&gt;", Snippet(video, "good BOY"))
+	assert.Equal(t, "<mark>Dog</mark> &lt;3", Snippet(video, "dog"))
+	assert.Equal(t, "", Snippet(video, "cat"))
+	assert.Equal(t, "", Snippet(video, "!!"))
+
+	long := Video{Description: strings.Repeat("filler ", 30) + "needle" + strings.Repeat(" filler", 30)}
+	snippet := Snippet(long, "needle")
+	assert.True(t, strings.HasPrefix(snippet, "…filler"), snippet)
+	assert.True(t, strings.HasSuffix(snippet, "filler…"), snippet)
+	assert.Contains(t, snippet, " <mark>needle</mark> ")
+	assert.Less(t, len(snippet), len(long.Description))
+}
+
+func TestDummyVideoRepoSearch(t *testing.T) {
+	root := "test-dummy-search"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := NewDummyVideoRepo(fs)
+	first, err := repo.Save(Video{Title: "ok", Description: "good dog"})
+	assert.Nil(t, err)
+	second, err := repo.Save(Video{Title: "good dog", Tags: []string{"dog"}, Height: 1080})
+	assert.Nil(t, err)
+	third, err := repo.Save(Video{Title: "cat"})
+	assert.Nil(t, err)
+
+	filter := VideoFilter{Text: "dog good", SortField: SortFieldRelevance, SortDirection: SortDirectionDescending}
+	videos, err := repo.All(filter, 10, 0)
+	assert.Nil(t, err)
+	assert.Len(t, videos, 2)
+	assert.Equal(t, second.ID, videos[0].ID)
+	assert.Equal(t, first.ID, videos[1].ID)
+
+	// without search terms, relevance falls back to newest first
+	videos, err = repo.All(VideoFilter{SortField: SortFieldRelevance, SortDirection: SortDirectionAscending}, 10, 0)
+	assert.Nil(t, err)
+	assert.Len(t, videos, 3)
+	assert.Equal(t, third.ID, videos[0].ID)
+	assert.Equal(t, first.ID, videos[2].ID)
+
+	count, err := repo.Count(filter)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), count)
+
+	// full-text search narrows down other filters
+	count, err = repo.Count(VideoFilter{Text: "dog", MinHeight: 720})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), count)
+
+	assert.Nil(t, repo.Delete(second))
+	count, err = repo.Count(filter)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), count)
+
+	// the index is rebuilt on load
+	reloaded := NewDummyVideoRepo(fs)
+	count, err = reloaded.Count(VideoFilter{Text: "good"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), count)
+}
@@ -0,0 +1,128 @@
+package videostore
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/pkg/errors"
+)
+
+const spriteSheetFile = "sprites.jpg"
+const spriteTrackFile = "sprites.vtt"
+
+const spriteColumns = 10
+const spriteMaxTiles = 100
+const spriteTileWidth = 160
+const spriteTileHeight = 90
+
+// spriteLayout decides how many seconds apart each tile is,
+// and how many tiles there are, for a video of the given length
+func spriteLayout(duration float64) (interval int, tiles int) {
+	interval = int(math.Ceil(duration / spriteMaxTiles))
+	if interval < 1 {
+		interval = 1
+	}
+	tiles = int(math.Ceil(duration / float64(interval)))
+	if tiles < 1 {
+		tiles = 1
+	}
+	return interval, tiles
+}
+
+func formatVTTTimestamp(seconds float64) string {
+	millis := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", millis/3600000, (millis/60000)%60, (millis/1000)%60, millis%1000)
+}
+
+// buildSpriteTrack maps each stretch of the video to its tile in the sprite sheet
+func buildSpriteTrack(sheet string, duration float64, interval int, tiles int) string {
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n")
+	for i := 0; i < tiles; i++ {
+		start := float64(i * interval)
+		end := math.Min(float64((i+1)*interval), duration)
+		if end <= start {
+			end = start + float64(interval)
+		}
+		x := (i % spriteColumns) * spriteTileWidth
+		y := (i / spriteColumns) * spriteTileHeight
+
+		sb.WriteString(fmt.Sprintf(
+			"\n%v --> %v\n%v#xywh=%v,%v,%v,%v\n",
+			formatVTTTimestamp(start),
+			formatVTTTimestamp(end),
+			sheet,
+			x, y, spriteTileWidth, spriteTileHeight,
+		))
+	}
+	return sb.String()
+}
+
+// GenerateSprites produces a tiled sprite sheet of frames from the video
+// and a WebVTT track pointing into it, used for scrubbing previews
+func GenerateSprites(video Video, repo VideoRepo, fs files.FileSystem) (Video, error) {
+	tempDir, temporaryVideoPath, err := downloadToTemporaryFile(video, fs, "eventual-sprites-")
+	if err != nil {
+		return video, err
+	}
+	defer os.RemoveAll(tempDir) // clean up
+
+	source, err := probeLocalFile(Video{}, temporaryVideoPath)
+	if err != nil {
+		return video, err
+	}
+	if source.Duration <= 0 {
+		return video, errors.New("video has no duration")
+	}
+
+	interval, tiles := spriteLayout(source.Duration)
+	rows := (tiles + spriteColumns - 1) / spriteColumns
+	temporarySheetPath := filepath.Join(tempDir, spriteSheetFile)
+
+	filter := fmt.Sprintf(
+		"fps=1/%v,scale=%v:%v:force_original_aspect_ratio=decrease,pad=%v:%v:(ow-iw)/2:(oh-ih)/2,tile=%vx%v",
+		interval,
+		spriteTileWidth, spriteTileHeight,
+		spriteTileWidth, spriteTileHeight,
+		spriteColumns, rows,
+	)
+	cmdOutput, err := exec.Command("ffmpeg", "-v", "error", "-i", temporaryVideoPath, "-vf", filter, "-frames:v", "1", "-q:v", "5", temporarySheetPath).CombinedOutput()
+	if err != nil {
+		return video, errors.Wrapf(err, "failed to run ffmpeg: %s", cmdOutput)
+	}
+
+	temporarySheetStream, err := os.Open(temporarySheetPath)
+	if err != nil {
+		return video, errors.Wrap(err, "failed to open created sprite sheet")
+	}
+	defer temporarySheetStream.Close()
+
+	videoDir := path.Dir(video.Source)
+	err = files.PipeTo(fs, path.Join(videoDir, spriteSheetFile), temporarySheetStream)
+	if err != nil {
+		return video, errors.Wrap(err, "failed to upload sprite sheet")
+	}
+
+	// the sheet is referenced relative to the track,
+	// so both can be served from wherever the video is
+	trackPath := path.Join(videoDir, spriteTrackFile)
+	err = files.PipeTo(fs, trackPath, strings.NewReader(buildSpriteTrack(spriteSheetFile, source.Duration, interval, tiles)))
+	if err != nil {
+		return video, errors.Wrap(err, "failed to upload sprite track")
+	}
+
+	video, err = updateVideo(repo, video.ID, func(video *Video) {
+		video.Sprites = trackPath
+	})
+	if err != nil {
+		return video, errors.Wrap(err, "failed to save video sprites")
+	}
+
+	return video, nil
+}
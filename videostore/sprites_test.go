@@ -0,0 +1,36 @@
+package videostore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_spriteLayout(t *testing.T) {
+	interval, tiles := spriteLayout(4.4)
+	assert.Equal(t, 1, interval)
+	assert.Equal(t, 5, tiles)
+
+	interval, tiles = spriteLayout(3600)
+	assert.Equal(t, 36, interval)
+	assert.Equal(t, 100, tiles)
+
+	interval, tiles = spriteLayout(0)
+	assert.Equal(t, 1, interval)
+	assert.Equal(t, 1, tiles)
+}
+
+func Test_formatVTTTimestamp(t *testing.T) {
+	assert.Equal(t, "00:00:00.000", formatVTTTimestamp(0))
+	assert.Equal(t, "00:01:02.500", formatVTTTimestamp(62.5))
+	assert.Equal(t, "01:00:01.000", formatVTTTimestamp(3601))
+}
+
+func Test_buildSpriteTrack(t *testing.T) {
+	track := buildSpriteTrack("sprites.jpg", 12.5, 1, 13)
+	assert.Contains(t, track, "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\nsprites.jpg#xywh=0,0,160,90\n")
+	// wraps onto the next row after 10 tiles
+	assert.Contains(t, track, "\n00:00:10.000 --> 00:00:11.000\nsprites.jpg#xywh=0,90,160,90\n")
+	// the last cue ends with the video
+	assert.Contains(t, track, "\n00:00:12.000 --> 00:00:12.500\nsprites.jpg#xywh=320,90,160,90\n")
+}
@@ -0,0 +1,70 @@
+package videostore
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
+	// registers the "sqlite" driver
+	_ "modernc.org/sqlite"
+)
+
+// sqliteTimeFormat sorts the same as text and as time,
+// so due jobs can be found with a plain comparison
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// OpenSQLite opens (or creates) the SQLite database at path
+func OpenSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open sqlite db")
+	}
+
+	// sqlite allows a single writer, let database/sql queue them up
+	// instead of handing out SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "failed to open sqlite db")
+	}
+
+	return db, nil
+}
+
+func formatSQLiteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+func parseSQLiteTime(value string) time.Time {
+	t, _ := time.Parse(sqliteTimeFormat, value)
+	return t
+}
+
+// sqliteAddColumn brings tables created by older versions up to date,
+// CREATE TABLE IF NOT EXISTS leaves them as they were
+func sqliteAddColumn(db *sql.DB, table string, column string, definition string) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
+}
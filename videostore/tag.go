@@ -0,0 +1,79 @@
+package videostore
+
+import (
+	"errors"
+	"sort"
+)
+
+// TagCount is a tag along with how many videos have it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count uint   `json:"count"`
+}
+
+type TagRepo interface {
+	// Tags lists every tag on videos matching filter, most used first
+	Tags(filter VideoFilter) ([]TagCount, error)
+	// SuggestTags lists up to limit tags on videos matching filter
+	// that start with prefix, ignoring case, most used first
+	SuggestTags(filter VideoFilter, prefix string, limit uint) ([]TagCount, error)
+	// MergeTags replaces each of from with into on every video, all at once,
+	// and returns how many videos changed. Renaming a tag is merging it
+	// into a new one.
+	MergeTags(from []string, into string) (uint, error)
+}
+
+var ErrorTagInvalid = errors.New("tag can't be empty")
+
+// mergeTags replaces each of from in tags with into,
+// keeping the order of tags and dropping any duplicates this creates
+func mergeTags(tags []string, from []string, into string) ([]string, bool) {
+	merging := make(map[string]bool, len(from))
+	for _, tag := range from {
+		merging[tag] = true
+	}
+
+	changed := false
+	seen := make(map[string]bool, len(tags))
+	merged := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if merging[tag] && tag != into {
+			tag = into
+			changed = true
+		}
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		merged = append(merged, tag)
+	}
+
+	return merged, changed
+}
+
+// validateMerge checks from and into can be merged.
+// ok is false when there's nothing to do.
+func validateMerge(from []string, into string) (ok bool, err error) {
+	if into == "" {
+		return false, ErrorTagInvalid
+	}
+	for _, tag := range from {
+		if tag == "" {
+			return false, ErrorTagInvalid
+		}
+		if tag != into {
+			ok = true
+		}
+	}
+	return ok, nil
+}
+
+// sortTagCounts puts the most used tags first, then sorts alphabetically
+func sortTagCounts(tags []TagCount) {
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+}
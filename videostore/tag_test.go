@@ -0,0 +1,139 @@
+package videostore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeTags(t *testing.T) {
+	tags, changed := mergeTags([]string{"cat", "kitty", "dog", "kitten"}, []string{"kitty", "kitten"}, "cat")
+	assert.True(t, changed)
+	assert.Equal(t, []string{"cat", "dog"}, tags)
+
+	tags, changed = mergeTags([]string{"dog", "kitty"}, []string{"kitty"}, "cat")
+	assert.True(t, changed)
+	assert.Equal(t, []string{"dog", "cat"}, tags)
+
+	_, changed = mergeTags([]string{"dog"}, []string{"kitty"}, "cat")
+	assert.False(t, changed)
+
+	_, changed = mergeTags([]string{"cat"}, []string{"cat"}, "cat")
+	assert.False(t, changed)
+}
+
+// testTagRepo runs the same checks against every repo
+func testTagRepo(t *testing.T, repo VideoRepo) {
+	for _, video := range []Video{
+		{Title: "one", Tags: []string{"cat", "funny"}},
+		{Title: "two", Tags: []string{"kitty", "cat"}},
+		{Title: "three", Tags: []string{"kitten"}},
+		{Title: "four", Tags: []string{"dog", "funny"}},
+	} {
+		video.Source = "/" + video.Title + ".mp4"
+		_, err := repo.Save(video)
+		assert.Nil(t, err)
+	}
+
+	tags, err := repo.Tags(VideoFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, []TagCount{
+		{"cat", 2},
+		{"funny", 2},
+		{"dog", 1},
+		{"kitten", 1},
+		{"kitty", 1},
+	}, tags)
+
+	suggestions, err := repo.SuggestTags(VideoFilter{}, "K", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []TagCount{{"kitten", 1}, {"kitty", 1}}, suggestions)
+
+	suggestions, err = repo.SuggestTags(VideoFilter{}, "", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []TagCount{{"cat", 2}}, suggestions)
+
+	suggestions, err = repo.SuggestTags(VideoFilter{}, "%", 10)
+	assert.Nil(t, err)
+	assert.Empty(t, suggestions)
+
+	changed, err := repo.MergeTags([]string{"kitty", "kitten"}, "cat")
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), changed)
+
+	tags, err = repo.Tags(VideoFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, []TagCount{
+		{"cat", 3},
+		{"funny", 2},
+		{"dog", 1},
+	}, tags)
+
+	videos, err := repo.All(VideoFilter{Tags: []string{"cat"}, SortField: SortFieldTitle, SortDirection: SortDirectionAscending}, 10, 0)
+	assert.Nil(t, err)
+	assert.Len(t, videos, 3)
+	assert.Equal(t, "three", videos[1].Title)
+	assert.Equal(t, []string{"cat"}, videos[2].Tags)
+	assert.Equal(t, []string{"cat"}, videos[1].Tags)
+
+	// renaming is merging into a new tag
+	changed, err = repo.MergeTags([]string{"dog"}, "doggo")
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), changed)
+	count, err := repo.Count(VideoFilter{Tags: []string{"doggo"}})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), count)
+
+	changed, err = repo.MergeTags([]string{"missing"}, "doggo")
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), changed)
+
+	_, err = repo.MergeTags([]string{"dog"}, "")
+	assert.Equal(t, ErrorTagInvalid, err)
+
+	// tags only used on private videos are hidden from everyone else
+	_, err = repo.Save(Video{Title: "five", Source: "/five.mp4", Tags: []string{"secret", "cat"}, OwnerID: 7, Visibility: VisibilityPrivate})
+	assert.Nil(t, err)
+
+	listed := VideoFilter{Listed: true, ViewerID: 8}
+	tags, err = repo.Tags(listed)
+	assert.Nil(t, err)
+	assert.Equal(t, []TagCount{
+		{"cat", 3},
+		{"funny", 2},
+		{"doggo", 1},
+	}, tags)
+	suggestions, err = repo.SuggestTags(listed, "s", 10)
+	assert.Nil(t, err)
+	assert.Empty(t, suggestions)
+
+	owner := VideoFilter{Listed: true, ViewerID: 7}
+	suggestions, err = repo.SuggestTags(owner, "s", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []TagCount{{"secret", 1}}, suggestions)
+	tags, err = repo.Tags(owner)
+	assert.Nil(t, err)
+	assert.Equal(t, TagCount{"cat", 4}, tags[0])
+}
+
+func TestDummyVideoRepoTags(t *testing.T) {
+	root := "test-dummy-tags"
+	defer os.RemoveAll(root)
+
+	testTagRepo(t, NewDummyVideoRepo(files.LocalFileSystem(root)))
+}
+
+func TestSQLiteVideoRepoTags(t *testing.T) {
+	root := "test-sqlite-tags"
+	assert.Nil(t, os.MkdirAll(root, os.ModePerm))
+	defer os.RemoveAll(root)
+
+	db, err := OpenSQLite(filepath.Join(root, "videos.sqlite"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	testTagRepo(t, NewSQLiteVideoRepo(db))
+}
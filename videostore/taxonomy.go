@@ -0,0 +1,112 @@
+package videostore
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// TagSeparator nests tags, a video tagged animals/cats
+// also matches searches for animals
+const TagSeparator = "/"
+
+// TagAlias rewrites Alias to Tag whenever a video is saved
+type TagAlias struct {
+	Alias string `json:"alias" sql:",pk"`
+	Tag   string `json:"tag" sql:",notnull"`
+}
+
+type TagTaxonomyRepo interface {
+	// TagAliases lists every alias, sorted by alias
+	TagAliases() ([]TagAlias, error)
+	// SetTagAlias saves alias as another name for tag,
+	// replacing any previous alias of the same name.
+	// Existing videos are left as-is, see MergeTags.
+	SetTagAlias(alias string, tag string) (TagAlias, error)
+	DeleteTagAlias(alias string) error
+}
+
+var ErrorTagAliasNotFound = errors.New("tag alias not found")
+var ErrorTagAliasCycle = errors.New("tag alias would point to itself")
+
+// cleanTag trims spaces around a tag and every level of it,
+// dropping empty levels like the one in animals//cats
+func cleanTag(tag string) string {
+	parts := strings.Split(tag, TagSeparator)
+	levels := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			levels = append(levels, part)
+		}
+	}
+	return strings.Join(levels, TagSeparator)
+}
+
+// normalizeTags cleans tags and swaps aliases for what they point to,
+// keeping the order of tags and dropping empty and duplicate tags
+func normalizeTags(tags []string, aliases map[string]string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = cleanTag(tag)
+		if aliased, ok := aliases[tag]; ok {
+			tag = aliased
+		}
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// tagMatches checks if videoTag is tag, or nested somewhere below it
+func tagMatches(videoTag string, tag string) bool {
+	return videoTag == tag || strings.HasPrefix(videoTag, tag+TagSeparator)
+}
+
+// tagAliasMap indexes aliases by alias
+func tagAliasMap(aliases []TagAlias) map[string]string {
+	aliasMap := make(map[string]string, len(aliases))
+	for _, alias := range aliases {
+		aliasMap[alias.Alias] = alias.Tag
+	}
+	return aliasMap
+}
+
+// resolveTagAlias cleans alias and tag, follows tag through existing
+// aliases so they never chain, and lists the existing aliases that
+// pointed to alias and now need to point to tag instead
+func resolveTagAlias(aliases map[string]string, alias string, tag string) (TagAlias, []string, error) {
+	resolved := TagAlias{
+		Alias: cleanTag(alias),
+		Tag:   cleanTag(tag),
+	}
+	if resolved.Alias == "" || resolved.Tag == "" {
+		return resolved, nil, ErrorTagInvalid
+	}
+	if aliased, ok := aliases[resolved.Tag]; ok {
+		resolved.Tag = aliased
+	}
+	if resolved.Alias == resolved.Tag {
+		return resolved, nil, ErrorTagAliasCycle
+	}
+
+	var repointed []string
+	for existing, existingTag := range aliases {
+		if existingTag == resolved.Alias {
+			repointed = append(repointed, existing)
+		}
+	}
+	sort.Strings(repointed)
+
+	return resolved, repointed, nil
+}
+
+func sortTagAliases(aliases []TagAlias) {
+	sort.Slice(aliases, func(i, j int) bool {
+		return aliases[i].Alias < aliases[j].Alias
+	})
+}
@@ -0,0 +1,133 @@
+package videostore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	aliases := map[string]string{"kitty": "animals/cats"}
+
+	assert.Equal(
+		t,
+		[]string{"animals/cats", "funny", "a/b"},
+		normalizeTags([]string{"kitty", " animals / cats ", "funny", "", "/a//b/"}, aliases),
+	)
+}
+
+func TestTagMatches(t *testing.T) {
+	assert.True(t, tagMatches("animals", "animals"))
+	assert.True(t, tagMatches("animals/cats", "animals"))
+	assert.True(t, tagMatches("animals/cats/tabby", "animals/cats"))
+	assert.False(t, tagMatches("animalsfoo", "animals"))
+	assert.False(t, tagMatches("animals", "animals/cats"))
+}
+
+func TestResolveTagAlias(t *testing.T) {
+	aliases := map[string]string{
+		"kitty":  "cats",
+		"kitten": "cats",
+	}
+
+	resolved, repointed, err := resolveTagAlias(aliases, "pussycat", "kitty")
+	assert.Nil(t, err)
+	assert.Equal(t, TagAlias{"pussycat", "cats"}, resolved)
+	assert.Empty(t, repointed)
+
+	resolved, repointed, err = resolveTagAlias(aliases, "cats", "animals/cats")
+	assert.Nil(t, err)
+	assert.Equal(t, TagAlias{"cats", "animals/cats"}, resolved)
+	assert.Equal(t, []string{"kitten", "kitty"}, repointed)
+
+	_, _, err = resolveTagAlias(aliases, "cats", "kitty")
+	assert.Equal(t, ErrorTagAliasCycle, err)
+
+	_, _, err = resolveTagAlias(aliases, " / ", "cats")
+	assert.Equal(t, ErrorTagInvalid, err)
+}
+
+// testTaxonomyRepo runs the same checks against every repo
+func testTaxonomyRepo(t *testing.T, repo VideoRepo) {
+	_, err := repo.SetTagAlias("kitty", "cats")
+	assert.Nil(t, err)
+	alias, err := repo.SetTagAlias("kitten", "kitty")
+	assert.Nil(t, err)
+	assert.Equal(t, TagAlias{"kitten", "cats"}, alias)
+
+	// moving cats moves everything pointing at it
+	_, err = repo.SetTagAlias("cats", "animals/cats")
+	assert.Nil(t, err)
+
+	_, err = repo.SetTagAlias("animals/cats", "kitty")
+	assert.Equal(t, ErrorTagAliasCycle, err)
+
+	aliases, err := repo.TagAliases()
+	assert.Nil(t, err)
+	assert.Equal(t, []TagAlias{
+		{"cats", "animals/cats"},
+		{"kitten", "animals/cats"},
+		{"kitty", "animals/cats"},
+	}, aliases)
+
+	video, err := repo.Save(Video{Title: "cat", Tags: []string{"kitty", "funny", "kitten"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"animals/cats", "funny"}, video.Tags)
+	video, err = repo.FindById(video.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"animals/cats", "funny"}, video.Tags)
+
+	_, err = repo.Save(Video{Title: "dog", Tags: []string{"animals / dogs"}})
+	assert.Nil(t, err)
+	_, err = repo.Save(Video{Title: "lizard", Tags: []string{"animalsish"}})
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"cat", "dog"}, queryTitles(t, repo, VideoFilter{
+		Tags:          []string{"animals"},
+		SortField:     SortFieldTitle,
+		SortDirection: SortDirectionAscending,
+	}))
+	assert.Equal(t, []string{"cat"}, queryTitles(t, repo, VideoFilter{Tags: []string{"animals/cats", "funny"}}))
+	assert.Equal(t, []string{"dog"}, queryTitles(t, repo, VideoFilter{Any: "animals/dogs"}))
+	assert.Empty(t, queryTitles(t, repo, VideoFilter{Tags: []string{"animals/cat"}}))
+
+	query := QueryNode{Op: QueryAnd, Children: []QueryNode{
+		testTerm(QueryFieldTag, "animals"),
+		testNot(testTerm(QueryFieldTag, "animals/cats")),
+	}}
+	assert.Equal(t, []string{"dog"}, queryTitles(t, repo, VideoFilter{Query: &query}))
+
+	assert.Nil(t, repo.DeleteTagAlias("kitten"))
+	assert.Equal(t, ErrorTagAliasNotFound, repo.DeleteTagAlias("kitten"))
+	video, err = repo.Save(Video{Title: "kitten", Tags: []string{"kitten"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"kitten"}, video.Tags)
+}
+
+func TestDummyVideoRepoTaxonomy(t *testing.T) {
+	root := "test-dummy-taxonomy"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	testTaxonomyRepo(t, NewDummyVideoRepo(fs))
+
+	// aliases survive a restart
+	aliases, err := NewDummyVideoRepo(fs).TagAliases()
+	assert.Nil(t, err)
+	assert.Len(t, aliases, 2)
+}
+
+func TestSQLiteVideoRepoTaxonomy(t *testing.T) {
+	root := "test-sqlite-taxonomy"
+	assert.Nil(t, os.MkdirAll(root, os.ModePerm))
+	defer os.RemoveAll(root)
+
+	db, err := OpenSQLite(filepath.Join(root, "videos.sqlite"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	testTaxonomyRepo(t, NewSQLiteVideoRepo(db))
+}
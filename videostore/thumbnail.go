@@ -1,19 +1,33 @@
 package videostore
 
 import (
+	"bytes"
 	"fmt"
+	"image"
+	_ "image/gif" // decoders for uploaded thumbnails
+	"image/jpeg"
+	_ "image/png"
 	"io"
-	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
-	"strconv"
 
 	"github.com/AlbinoDrought/creamy-videos/files"
 	"github.com/pkg/errors"
 )
 
+const maxThumbnailDimension = 8192
+
+var ErrorThumbnailInvalid = errors.New("thumbnail is not a supported image")
+var ErrorThumbnailTimestampInvalid = errors.New("thumbnail timestamp is outside of the video")
+
+// GenerateThumbnail picks a representative frame as the thumbnail,
+// unless someone already chose one
 func GenerateThumbnail(video Video, repo VideoRepo, fs files.FileSystem) (Video, error) {
+	if video.CustomThumbnail {
+		return video, nil
+	}
+
 	thumbnailPath := path.Join(path.Dir(video.Source), "thumbnail.jpg")
 
 	videoStream, err := fs.Open(video.Source)
@@ -33,6 +47,11 @@ func GenerateThumbnail(video Video, repo VideoRepo, fs files.FileSystem) (Video,
 	cmd.Stdout = createdThumbnailStream
 
 	err = cmd.Run()
+	// finish with this stream before the fallback writes the same file,
+	// some filesystems only write on close
+	if closeErr := createdThumbnailStream.Close(); err == nil {
+		err = closeErr
+	}
 	if err == nil {
 		video.Thumbnail = thumbnailPath
 	} else {
@@ -48,8 +67,13 @@ func GenerateThumbnail(video Video, repo VideoRepo, fs files.FileSystem) (Video,
 		// with this method :)
 	}
 
-	_, err = repo.Save(video)
+	return saveThumbnail(video, repo, video.Thumbnail)
+}
 
+func saveThumbnail(video Video, repo VideoRepo, thumbnailPath string) (Video, error) {
+	video, err := updateVideo(repo, video.ID, func(video *Video) {
+		video.Thumbnail = thumbnailPath
+	})
 	if err != nil {
 		return video, errors.Wrap(err, "failed to save video thumbnail to disk")
 	}
@@ -57,38 +81,120 @@ func GenerateThumbnail(video Video, repo VideoRepo, fs files.FileSystem) (Video,
 	return video, nil
 }
 
-func generateThumbnailUsingTemporaryFile(video Video, fs files.FileSystem) (Video, error) {
-	// create a temporary directory to store our junk
-	tempDir, err := ioutil.TempDir("", "eventual-thumbnail-"+strconv.Itoa(int(video.ID)))
+// saveCustomThumbnail is saveThumbnail, but also remembers
+// where the thumbnail came from so it isn't replaced
+func saveCustomThumbnail(video Video, repo VideoRepo, thumbnailPath string, timestamp *float64) (Video, error) {
+	video, err := updateVideo(repo, video.ID, func(video *Video) {
+		video.Thumbnail = thumbnailPath
+		video.CustomThumbnail = true
+		video.ThumbnailTimestamp = timestamp
+	})
 	if err != nil {
-		return video, errors.Wrap(err, "failed to make tempdir for thumbnail generation")
+		return video, errors.Wrap(err, "failed to save video thumbnail to disk")
 	}
-	defer os.RemoveAll(tempDir) // clean up
 
-	// create the file in our temporary directory
-	temporaryVideoPath := path.Join(tempDir, path.Base(video.Source))
-	temporaryVideoStream, err := os.Create(temporaryVideoPath)
+	return video, nil
+}
+
+// SetThumbnailFromImage replaces the thumbnail with an uploaded image.
+// Anything the image package can decode is accepted, it is always stored as a JPEG.
+func SetThumbnailFromImage(video Video, repo VideoRepo, fs files.FileSystem, upload io.Reader) (Video, error) {
+	// check the dimensions before decoding the whole thing,
+	// a tiny file can claim to be enormous
+	var header bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(upload, &header))
 	if err != nil {
-		return video, errors.Wrap(err, "failed to create temporary video file")
+		return video, ErrorThumbnailInvalid
+	}
+	if config.Width > maxThumbnailDimension || config.Height > maxThumbnailDimension {
+		return video, ErrorThumbnailInvalid
 	}
-	defer temporaryVideoStream.Close()
 
-	// open the existing (and possibly remote) video file
-	realVideoStream, err := fs.Open(video.Source)
+	img, _, err := image.Decode(io.MultiReader(&header, upload))
 	if err != nil {
-		return video, errors.Wrap(err, "failed to open real video file")
+		return video, ErrorThumbnailInvalid
 	}
-	defer realVideoStream.Close()
 
-	// download it to our temporary directory
-	_, err = io.Copy(temporaryVideoStream, realVideoStream)
+	thumbnailPath := path.Join(path.Dir(video.Source), "thumbnail.jpg")
+	thumbnailStream, err := fs.Create(thumbnailPath)
 	if err != nil {
-		return video, errors.Wrap(err, "failed to download video to temporary path")
+		return video, errors.Wrap(err, "failed to create thumbnail")
+	}
+	defer thumbnailStream.Close()
+
+	if err := jpeg.Encode(thumbnailStream, img, &jpeg.Options{Quality: 90}); err != nil {
+		return video, errors.Wrap(err, "failed to encode thumbnail")
+	}
+	if err := thumbnailStream.Close(); err != nil {
+		return video, errors.Wrap(err, "failed to save thumbnail")
+	}
+
+	return saveCustomThumbnail(video, repo, thumbnailPath, nil)
+}
+
+func validThumbnailTimestamp(video Video, seconds float64) bool {
+	return seconds >= 0 && (video.Duration <= 0 || seconds <= video.Duration)
+}
+
+// RequestThumbnailAt marks the frame the given number of seconds into
+// the video as its thumbnail. The frame itself is taken later by
+// GenerateThumbnailAt, queue JobKindCustomThumbnail to do so.
+func RequestThumbnailAt(video Video, repo VideoRepo, seconds float64) (Video, error) {
+	if !validThumbnailTimestamp(video, seconds) {
+		return video, ErrorThumbnailTimestampInvalid
 	}
 
-	// close both of our streams, the file has been downloaded
-	realVideoStream.Close()
-	temporaryVideoStream.Close()
+	return updateVideo(repo, video.ID, func(video *Video) {
+		video.CustomThumbnail = true
+		video.ThumbnailTimestamp = &seconds
+	})
+}
+
+// GenerateThumbnailAt replaces the thumbnail with the frame
+// the given number of seconds into the video
+func GenerateThumbnailAt(video Video, repo VideoRepo, fs files.FileSystem, seconds float64) (Video, error) {
+	if !validThumbnailTimestamp(video, seconds) {
+		return video, ErrorThumbnailTimestampInvalid
+	}
+
+	tempDir, temporaryVideoPath, err := downloadToTemporaryFile(video, fs, "eventual-thumbnail-")
+	if err != nil {
+		return video, err
+	}
+	defer os.RemoveAll(tempDir) // clean up
+
+	temporaryThumbnailPath := path.Join(tempDir, "thumbnail.jpg")
+
+	cmd := exec.Command("ffmpeg", "-ss", fmt.Sprintf("%.3f", seconds), "-i", temporaryVideoPath, "-vf", "scale=640:-1", "-frames:v", "1", "-f", "singlejpeg", temporaryThumbnailPath)
+	if err := cmd.Run(); err != nil {
+		return video, errors.Wrap(err, "failed to run ffmpeg")
+	}
+
+	// seeking past the end leaves us with nothing
+	if stat, err := os.Stat(temporaryThumbnailPath); err != nil || stat.Size() == 0 {
+		return video, ErrorThumbnailTimestampInvalid
+	}
+
+	temporaryThumbnailStream, err := os.Open(temporaryThumbnailPath)
+	if err != nil {
+		return video, errors.Wrap(err, "failed to open created temporary thumbnail")
+	}
+	defer temporaryThumbnailStream.Close()
+
+	thumbnailPath := path.Join(path.Dir(video.Source), "thumbnail.jpg")
+	if err := files.PipeTo(fs, thumbnailPath, temporaryThumbnailStream); err != nil {
+		return video, errors.Wrap(err, "failed to upload temporary thumbnail")
+	}
+
+	return saveCustomThumbnail(video, repo, thumbnailPath, &seconds)
+}
+
+func generateThumbnailUsingTemporaryFile(video Video, fs files.FileSystem) (Video, error) {
+	tempDir, temporaryVideoPath, err := downloadToTemporaryFile(video, fs, "eventual-thumbnail-")
+	if err != nil {
+		return video, err
+	}
+	defer os.RemoveAll(tempDir) // clean up
 
 	temporaryThumbnailPath := path.Join(tempDir, "thumbnail.jpg")
 
@@ -0,0 +1,72 @@
+package videostore
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetThumbnailFromImage(t *testing.T) {
+	root := "test-custom-thumbnail"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := NewDummyVideoRepo(fs)
+	video, err := repo.Save(Video{Title: "doggo", Source: "1/video.mp4"})
+	assert.Nil(t, err)
+	assert.Nil(t, fs.MkdirAll("1", os.ModePerm))
+
+	img := image.NewRGBA(image.Rect(0, 0, 32, 18))
+	img.Set(0, 0, color.White)
+	var upload bytes.Buffer
+	assert.Nil(t, png.Encode(&upload, img))
+
+	video, err = SetThumbnailFromImage(video, repo, fs, &upload)
+	assert.Nil(t, err)
+	assert.Equal(t, "1/thumbnail.jpg", video.Thumbnail)
+
+	saved, err := repo.FindById(video.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "1/thumbnail.jpg", saved.Thumbnail)
+
+	// stored as a JPEG regardless of what was uploaded
+	thumbnail, err := fs.Open(saved.Thumbnail)
+	assert.Nil(t, err)
+	defer thumbnail.Close()
+	config, err := jpeg.DecodeConfig(thumbnail)
+	assert.Nil(t, err)
+	assert.Equal(t, 32, config.Width)
+	assert.Equal(t, 18, config.Height)
+
+	_, err = SetThumbnailFromImage(video, repo, fs, strings.NewReader("definitely not an image"))
+	assert.Equal(t, ErrorThumbnailInvalid, err)
+}
+
+func TestGenerateThumbnailKeepsCustomThumbnail(t *testing.T) {
+	root := "test-keep-custom-thumbnail"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := NewDummyVideoRepo(fs)
+	video, err := repo.Save(Video{Title: "doggo", Source: "1/video.mp4", Duration: 10})
+	assert.Nil(t, err)
+
+	_, err = RequestThumbnailAt(video, repo, 11)
+	assert.Equal(t, ErrorThumbnailTimestampInvalid, err)
+	video, err = RequestThumbnailAt(video, repo, 3)
+	assert.Nil(t, err)
+	assert.True(t, video.CustomThumbnail)
+
+	// the source doesn't exist, so this would fail if it tried
+	video, err = GenerateThumbnail(video, repo, fs)
+	assert.Nil(t, err)
+	assert.Equal(t, "", video.Thumbnail)
+}
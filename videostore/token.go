@@ -0,0 +1,127 @@
+package videostore
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// apiTokenPrefix makes tokens easy to spot in scripts and leaks
+const apiTokenPrefix = "cv_"
+
+// scopes limit what an API token can do,
+// on top of what its user's role allows
+const ScopeRead = "read"
+const ScopeUpload = "upload"
+const ScopeEdit = "edit"
+const ScopeDelete = "delete"
+
+var Scopes = []string{
+	ScopeRead,
+	ScopeUpload,
+	ScopeEdit,
+	ScopeDelete,
+}
+
+// permissionScopes lines up role permissions with the scope they need
+var permissionScopes = map[string]string{
+	PermissionUpload:    ScopeUpload,
+	PermissionEditAny:   ScopeEdit,
+	PermissionDeleteAny: ScopeDelete,
+}
+
+// APIToken lets scripts act as a user without their password.
+// Only a hash of the token is kept, it's shown once when created.
+type APIToken struct {
+	tableName struct{} `sql:"api_tokens"`
+
+	ID          uint     `json:"id"`
+	UserID      uint     `json:"user_id" sql:",notnull"`
+	Name        string   `json:"name" sql:",notnull"`
+	TokenHash   string   `json:"-" sql:",unique,notnull"`
+	Scopes      []string `json:"scopes"`
+	TimeCreated string   `json:"time_created"`
+}
+
+func (token APIToken) Exists() bool {
+	return token.ID > 0
+}
+
+func (token APIToken) HasScope(scope string) bool {
+	for _, granted := range token.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether token has the scope permission needs
+func (token APIToken) Allows(permission string) bool {
+	return token.HasScope(permissionScopes[permission])
+}
+
+type APITokenRepo interface {
+	// Save creates or updates token,
+	// use NewAPIToken to make one
+	Save(token APIToken) (APIToken, error)
+	FindById(id uint) (APIToken, error)
+	FindByHash(hash string) (APIToken, error)
+	// ForUser lists the tokens of a user, oldest first
+	ForUser(userID uint) ([]APIToken, error)
+	Delete(token APIToken) error
+}
+
+var ErrorAPITokenNotFound = errors.New("api token not found")
+var ErrorScopeInvalid = errors.New("scopes must be some of read, upload, edit or delete")
+
+// NewAPIToken makes a token for user, returning it along with
+// the secret to hand out. The secret can't be recovered later.
+func NewAPIToken(user User, name string, scopes []string) (APIToken, string, error) {
+	token := APIToken{
+		UserID: user.ID,
+		Name:   strings.TrimSpace(name),
+		Scopes: []string{},
+	}
+
+	for _, scope := range scopes {
+		if !validScope(scope) {
+			return token, "", ErrorScopeInvalid
+		}
+		if !token.HasScope(scope) {
+			token.Scopes = append(token.Scopes, scope)
+		}
+	}
+	if len(token.Scopes) == 0 {
+		return token, "", ErrorScopeInvalid
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return token, "", err
+	}
+	secret := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(random)
+	token.TokenHash = HashAPIToken(secret)
+
+	return token, secret, nil
+}
+
+// HashAPIToken is what gets stored for secret.
+// Tokens are random enough that a fast hash is fine,
+// and it lets them be looked up directly.
+func HashAPIToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func validScope(scope string) bool {
+	for _, known := range Scopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package videostore
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+)
+
+// dummyStoredAPIToken keeps the hash that APIToken hides from JSON
+type dummyStoredAPIToken struct {
+	APIToken
+	TokenHash string `json:"token_hash"`
+}
+
+// dummyAPITokenRepo stores tokens to a local JSON file
+// beside the dummyUserRepo's users.json
+type dummyAPITokenRepo struct {
+	fs     files.FileSystem
+	tokens []APIToken
+	lock   sync.Mutex
+}
+
+func NewDummyAPITokenRepo(fs files.FileSystem) *dummyAPITokenRepo {
+	var stored []dummyStoredAPIToken
+
+	storedDatabase, err := fs.Open("api-tokens.json")
+	if err == nil {
+		defer storedDatabase.Close()
+		err = json.NewDecoder(storedDatabase).Decode(&stored)
+	}
+
+	tokens := make([]APIToken, len(stored))
+	for i, storedToken := range stored {
+		tokens[i] = storedToken.APIToken
+		tokens[i].TokenHash = storedToken.TokenHash
+	}
+
+	return &dummyAPITokenRepo{
+		fs:     fs,
+		tokens: tokens,
+	}
+}
+
+func (repo *dummyAPITokenRepo) dumpToDisk() error {
+	stored := make([]dummyStoredAPIToken, len(repo.tokens))
+	for i, token := range repo.tokens {
+		stored[i] = dummyStoredAPIToken{token, token.TokenHash}
+	}
+
+	tokenJSON, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+	return files.PipeTo(repo.fs, "api-tokens.json", bytes.NewReader(tokenJSON))
+}
+
+func (repo *dummyAPITokenRepo) find(match func(token APIToken) bool) (APIToken, error) {
+	for _, token := range repo.tokens {
+		if token.Exists() && match(token) {
+			return token, nil
+		}
+	}
+	return APIToken{}, ErrorAPITokenNotFound
+}
+
+func (repo *dummyAPITokenRepo) Save(token APIToken) (APIToken, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	if !token.Exists() {
+		token.ID = uint(len(repo.tokens)) + 1
+		token.TimeCreated = time.Now().Format(time.RFC3339)
+		repo.tokens = append(repo.tokens, token)
+		return token, repo.dumpToDisk()
+	}
+
+	if len(repo.tokens) < int(token.ID) || !repo.tokens[token.ID-1].Exists() {
+		return APIToken{}, ErrorAPITokenNotFound
+	}
+
+	repo.tokens[token.ID-1] = token
+	return token, repo.dumpToDisk()
+}
+
+func (repo *dummyAPITokenRepo) FindById(id uint) (APIToken, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	return repo.find(func(token APIToken) bool {
+		return token.ID == id
+	})
+}
+
+func (repo *dummyAPITokenRepo) FindByHash(hash string) (APIToken, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	return repo.find(func(token APIToken) bool {
+		return token.TokenHash == hash
+	})
+}
+
+func (repo *dummyAPITokenRepo) ForUser(userID uint) ([]APIToken, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	tokens := make([]APIToken, 0)
+	for _, token := range repo.tokens {
+		if token.Exists() && token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens, nil
+}
+
+func (repo *dummyAPITokenRepo) Delete(token APIToken) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	if token.ID == 0 || len(repo.tokens) < int(token.ID) || !repo.tokens[token.ID-1].Exists() {
+		return ErrorAPITokenNotFound
+	}
+
+	// soft delete, like the user repo, so IDs are never reused
+	repo.tokens[token.ID-1] = APIToken{}
+	return repo.dumpToDisk()
+}
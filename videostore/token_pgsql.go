@@ -0,0 +1,78 @@
+package videostore
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+// postgresAPITokenRepo stores tokens to a Postgres DB
+type postgresAPITokenRepo struct {
+	db pg.DB
+}
+
+// NewPostgresAPITokenRepo expects an up-to-date schema, see MigratePostgresUp
+func NewPostgresAPITokenRepo(db pg.DB) *postgresAPITokenRepo {
+	return &postgresAPITokenRepo{
+		db,
+	}
+}
+
+func (repo *postgresAPITokenRepo) Save(token APIToken) (APIToken, error) {
+	var err error
+
+	if token.Exists() {
+		err = repo.db.Update(&token)
+		if err == pg.ErrNoRows {
+			return token, ErrorAPITokenNotFound
+		}
+	} else {
+		token.TimeCreated = time.Now().Format(time.RFC3339)
+		err = repo.db.Insert(&token)
+	}
+
+	return token, err
+}
+
+func (repo *postgresAPITokenRepo) FindById(id uint) (APIToken, error) {
+	token := APIToken{
+		ID: id,
+	}
+
+	err := repo.db.Select(&token)
+
+	if err == pg.ErrNoRows {
+		return token, ErrorAPITokenNotFound
+	}
+
+	return token, err
+}
+
+func (repo *postgresAPITokenRepo) FindByHash(hash string) (APIToken, error) {
+	var token APIToken
+
+	err := repo.db.Model(&token).Where("token_hash = ?", hash).Select()
+
+	if err == pg.ErrNoRows {
+		return token, ErrorAPITokenNotFound
+	}
+
+	return token, err
+}
+
+func (repo *postgresAPITokenRepo) ForUser(userID uint) ([]APIToken, error) {
+	tokens := make([]APIToken, 0)
+	err := repo.db.Model(&tokens).Where("user_id = ?", userID).Order("id").Select()
+	return tokens, err
+}
+
+func (repo *postgresAPITokenRepo) Delete(token APIToken) error {
+	result, err := repo.db.Model(&token).WherePK().Delete()
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrorAPITokenNotFound
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package videostore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// sqliteAPITokenRepo stores tokens to an embedded SQLite DB
+type sqliteAPITokenRepo struct {
+	db *sql.DB
+}
+
+const sqliteAPITokenSchema = `
+CREATE TABLE IF NOT EXISTS api_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name TEXT NOT NULL DEFAULT '',
+	token_hash TEXT NOT NULL UNIQUE,
+	scopes TEXT NOT NULL DEFAULT '[]',
+	time_created TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS api_tokens_user_id ON api_tokens (user_id);
+`
+
+const sqliteAPITokenColumns = `id, user_id, name, token_hash, scopes, time_created`
+
+func NewSQLiteAPITokenRepo(db *sql.DB) *sqliteAPITokenRepo {
+	if _, err := db.Exec(sqliteAPITokenSchema); err != nil {
+		log.Fatalf("failed to create table: %+v", err)
+	}
+
+	return &sqliteAPITokenRepo{
+		db,
+	}
+}
+
+func scanSQLiteAPIToken(row sqliteScanner) (APIToken, error) {
+	var token APIToken
+	var scopes string
+
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.TokenHash,
+		&scopes,
+		&token.TimeCreated,
+	)
+	if err != nil {
+		return token, err
+	}
+
+	if err := json.Unmarshal([]byte(scopes), &token.Scopes); err != nil {
+		return token, errors.Wrap(err, "failed to decode scopes")
+	}
+
+	return token, nil
+}
+
+func (repo *sqliteAPITokenRepo) Save(token APIToken) (APIToken, error) {
+	scopes, err := json.Marshal(token.Scopes)
+	if err != nil {
+		return token, errors.Wrap(err, "failed to encode scopes")
+	}
+	if token.Scopes == nil {
+		scopes = []byte("[]")
+	}
+
+	if token.Exists() {
+		result, err := repo.db.Exec(
+			"UPDATE api_tokens SET user_id = ?, name = ?, token_hash = ?, scopes = ? WHERE id = ?",
+			token.UserID, token.Name, token.TokenHash, string(scopes), token.ID,
+		)
+		if err != nil {
+			return token, err
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			return token, ErrorAPITokenNotFound
+		}
+		return token, nil
+	}
+
+	token.TimeCreated = time.Now().Format(time.RFC3339)
+	result, err := repo.db.Exec(
+		"INSERT INTO api_tokens (user_id, name, token_hash, scopes, time_created) VALUES (?, ?, ?, ?, ?)",
+		token.UserID, token.Name, token.TokenHash, string(scopes), token.TimeCreated,
+	)
+	if err != nil {
+		return token, err
+	}
+
+	id, err := result.LastInsertId()
+	token.ID = uint(id)
+
+	return token, err
+}
+
+func (repo *sqliteAPITokenRepo) FindById(id uint) (APIToken, error) {
+	token, err := scanSQLiteAPIToken(repo.db.QueryRow("SELECT "+sqliteAPITokenColumns+" FROM api_tokens WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return APIToken{ID: id}, ErrorAPITokenNotFound
+	}
+
+	return token, err
+}
+
+func (repo *sqliteAPITokenRepo) FindByHash(hash string) (APIToken, error) {
+	token, err := scanSQLiteAPIToken(repo.db.QueryRow("SELECT "+sqliteAPITokenColumns+" FROM api_tokens WHERE token_hash = ?", hash))
+	if err == sql.ErrNoRows {
+		return APIToken{}, ErrorAPITokenNotFound
+	}
+
+	return token, err
+}
+
+func (repo *sqliteAPITokenRepo) ForUser(userID uint) ([]APIToken, error) {
+	rows, err := repo.db.Query("SELECT "+sqliteAPITokenColumns+" FROM api_tokens WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]APIToken, 0)
+	for rows.Next() {
+		token, err := scanSQLiteAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (repo *sqliteAPITokenRepo) Delete(token APIToken) error {
+	result, err := repo.db.Exec("DELETE FROM api_tokens WHERE id = ?", token.ID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrorAPITokenNotFound
+	}
+	return nil
+}
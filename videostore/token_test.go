@@ -0,0 +1,110 @@
+package videostore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIToken(t *testing.T) {
+	user := User{ID: 1}
+
+	token, secret, err := NewAPIToken(user, " script ", []string{ScopeUpload, ScopeRead, ScopeUpload})
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(secret, apiTokenPrefix))
+	assert.Equal(t, "script", token.Name)
+	assert.Equal(t, user.ID, token.UserID)
+	assert.Equal(t, []string{ScopeUpload, ScopeRead}, token.Scopes)
+	assert.Equal(t, HashAPIToken(secret), token.TokenHash)
+	assert.NotContains(t, token.TokenHash, secret)
+
+	assert.True(t, token.Allows(PermissionUpload))
+	assert.False(t, token.Allows(PermissionEditAny))
+	assert.False(t, token.Allows(PermissionDeleteAny))
+
+	_, other, err := NewAPIToken(user, "script", []string{ScopeUpload})
+	assert.Nil(t, err)
+	assert.NotEqual(t, secret, other)
+
+	_, _, err = NewAPIToken(user, "script", []string{})
+	assert.Equal(t, ErrorScopeInvalid, err)
+	_, _, err = NewAPIToken(user, "script", []string{"admin"})
+	assert.Equal(t, ErrorScopeInvalid, err)
+}
+
+// testAPITokenRepo runs the same checks against every repo
+func testAPITokenRepo(t *testing.T, repo APITokenRepo) {
+	first, secret, err := NewAPIToken(User{ID: 1}, "first", []string{ScopeRead})
+	assert.Nil(t, err)
+	first, err = repo.Save(first)
+	assert.Nil(t, err)
+	assert.True(t, first.Exists())
+	assert.NotEmpty(t, first.TimeCreated)
+
+	second, _, err := NewAPIToken(User{ID: 1}, "second", []string{ScopeUpload, ScopeEdit})
+	assert.Nil(t, err)
+	second, err = repo.Save(second)
+	assert.Nil(t, err)
+
+	other, _, err := NewAPIToken(User{ID: 2}, "other", []string{ScopeDelete})
+	assert.Nil(t, err)
+	_, err = repo.Save(other)
+	assert.Nil(t, err)
+
+	found, err := repo.FindByHash(HashAPIToken(secret))
+	assert.Nil(t, err)
+	assert.Equal(t, first.ID, found.ID)
+	assert.Equal(t, []string{ScopeRead}, found.Scopes)
+
+	_, err = repo.FindByHash(HashAPIToken("cv_guess"))
+	assert.Equal(t, ErrorAPITokenNotFound, err)
+
+	found, err = repo.FindById(second.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "second", found.Name)
+	assert.Equal(t, []string{ScopeUpload, ScopeEdit}, found.Scopes)
+
+	tokens, err := repo.ForUser(1)
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 2)
+	assert.Equal(t, "first", tokens[0].Name)
+
+	assert.Nil(t, repo.Delete(first))
+	assert.Equal(t, ErrorAPITokenNotFound, repo.Delete(first))
+	_, err = repo.FindByHash(HashAPIToken(secret))
+	assert.Equal(t, ErrorAPITokenNotFound, err)
+
+	tokens, err = repo.ForUser(1)
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+}
+
+func TestDummyAPITokenRepo(t *testing.T) {
+	root := "test-dummy-api-tokens"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	testAPITokenRepo(t, NewDummyAPITokenRepo(fs))
+
+	// hashes survive a restart
+	tokens, err := NewDummyAPITokenRepo(fs).ForUser(1)
+	assert.Nil(t, err)
+	assert.Len(t, tokens, 1)
+	assert.NotEmpty(t, tokens[0].TokenHash)
+}
+
+func TestSQLiteAPITokenRepo(t *testing.T) {
+	root := "test-sqlite-api-tokens"
+	assert.Nil(t, os.MkdirAll(root, os.ModePerm))
+	defer os.RemoveAll(root)
+
+	db, err := OpenSQLite(filepath.Join(root, "videos.sqlite"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	testAPITokenRepo(t, NewSQLiteAPITokenRepo(db))
+}
@@ -0,0 +1,220 @@
+package videostore
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/pkg/errors"
+)
+
+// Rendition is a single HLS quality level
+type Rendition struct {
+	Height       int
+	VideoBitrate int // kbit/s
+	AudioBitrate int // kbit/s
+}
+
+// HLSRenditions are produced by TranscodeVideo, smallest first.
+// Renditions taller than the source are skipped.
+var HLSRenditions = []Rendition{
+	{Height: 360, VideoBitrate: 800, AudioBitrate: 96},
+	{Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+	{Height: 1080, VideoBitrate: 5000, AudioBitrate: 192},
+}
+
+const hlsDirectory = "hls"
+const hlsMasterPlaylist = "master.m3u8"
+const hlsRenditionPlaylist = "index.m3u8"
+const hlsFallbackFile = "fallback.mp4"
+
+// hlsFallbackHeight is the tallest fallback made,
+// it's a single file so it can't adapt to slow connections
+const hlsFallbackHeight = 720
+
+func (rendition Rendition) name() string {
+	return fmt.Sprintf("%vp", rendition.Height)
+}
+
+// renditionsFor picks the renditions worth making for a source of the given height.
+// At least one rendition is always picked, so odd sources still get
+// something H.264 to play.
+func renditionsFor(sourceHeight int, available []Rendition) []Rendition {
+	var picked []Rendition
+	for _, rendition := range available {
+		if sourceHeight <= 0 || rendition.Height <= sourceHeight {
+			picked = append(picked, rendition)
+		}
+	}
+	if len(picked) == 0 && len(available) > 0 {
+		picked = available[:1]
+	}
+	return picked
+}
+
+// fallbackFor picks the rendition to also encode as a plain MP4,
+// the tallest one no taller than hlsFallbackHeight
+func fallbackFor(renditions []Rendition) Rendition {
+	fallback := renditions[0]
+	for _, rendition := range renditions {
+		if rendition.Height <= hlsFallbackHeight {
+			fallback = rendition
+		}
+	}
+	return fallback
+}
+
+// buildMasterPlaylist links each rendition's playlist together
+func buildMasterPlaylist(renditions []Rendition, sourceWidth int, sourceHeight int) string {
+	var sb strings.Builder
+	sb.WriteString("#EXTM3U\n")
+	sb.WriteString("#EXT-X-VERSION:3\n")
+	for _, rendition := range renditions {
+		bandwidth := (rendition.VideoBitrate + rendition.AudioBitrate) * 1000
+		sb.WriteString(fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%v", bandwidth))
+		if sourceWidth > 0 && sourceHeight > 0 {
+			// matches ffmpeg's scale=-2:height
+			width := (sourceWidth*rendition.Height/sourceHeight + 1) / 2 * 2
+			sb.WriteString(fmt.Sprintf(",RESOLUTION=%vx%v", width, rendition.Height))
+		}
+		sb.WriteString("\n")
+		sb.WriteString(path.Join(rendition.name(), hlsRenditionPlaylist) + "\n")
+	}
+	return sb.String()
+}
+
+// encodeArgs are shared by the HLS renditions and the fallback
+func encodeArgs(input string, rendition Rendition) []string {
+	return []string{
+		"-v", "error",
+		"-i", input,
+		"-map", "0:v:0",
+		"-map", "0:a:0?",
+		"-vf", fmt.Sprintf("scale=-2:%v", rendition.Height),
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-profile:v", "main",
+		"-pix_fmt", "yuv420p",
+		"-b:v", fmt.Sprintf("%vk", rendition.VideoBitrate),
+		"-maxrate", fmt.Sprintf("%vk", rendition.VideoBitrate*3/2),
+		"-bufsize", fmt.Sprintf("%vk", rendition.VideoBitrate*2),
+		"-c:a", "aac",
+		"-ac", "2",
+		"-b:a", fmt.Sprintf("%vk", rendition.AudioBitrate),
+	}
+}
+
+func transcodeArgs(input string, rendition Rendition, outputDir string) []string {
+	return append(encodeArgs(input, rendition),
+		"-f", "hls",
+		"-hls_time", "6",
+		"-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(outputDir, "segment%03d.ts"),
+		filepath.Join(outputDir, hlsRenditionPlaylist),
+	)
+}
+
+// fallbackArgs make an MP4 that starts playing before it has fully loaded
+func fallbackArgs(input string, rendition Rendition, output string) []string {
+	return append(encodeArgs(input, rendition),
+		"-movflags", "+faststart",
+		"-f", "mp4",
+		output,
+	)
+}
+
+// TranscodeVideo produces H.264 HLS renditions of the video,
+// and an MP4 fallback for browsers without HLS support,
+// beside the original file and saves them to the repo
+func TranscodeVideo(video Video, repo VideoRepo, fs files.FileSystem) (Video, error) {
+	// transcoding needs random access and several passes,
+	// so always work from a local copy
+	tempDir, temporaryVideoPath, err := downloadToTemporaryFile(video, fs, "eventual-transcode-")
+	if err != nil {
+		return video, err
+	}
+	defer os.RemoveAll(tempDir) // clean up
+
+	// the probe job may not have finished yet, check the source ourselves
+	source, err := probeLocalFile(Video{}, temporaryVideoPath)
+	if err != nil {
+		return video, err
+	}
+
+	renditions := renditionsFor(source.Height, HLSRenditions)
+	temporaryHLSDir := filepath.Join(tempDir, hlsDirectory)
+	for _, rendition := range renditions {
+		outputDir := filepath.Join(temporaryHLSDir, rendition.name())
+		if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+			return video, errors.Wrap(err, "failed to make rendition tempdir")
+		}
+
+		cmdOutput, err := exec.Command("ffmpeg", transcodeArgs(temporaryVideoPath, rendition, outputDir)...).CombinedOutput()
+		if err != nil {
+			return video, errors.Wrapf(err, "failed to transcode %v: %s", rendition.name(), cmdOutput)
+		}
+	}
+
+	fallback := fallbackFor(renditions)
+	cmdOutput, err := exec.Command("ffmpeg", fallbackArgs(temporaryVideoPath, fallback, filepath.Join(temporaryHLSDir, hlsFallbackFile))...).CombinedOutput()
+	if err != nil {
+		return video, errors.Wrapf(err, "failed to transcode %v fallback: %s", fallback.name(), cmdOutput)
+	}
+
+	err = os.WriteFile(filepath.Join(temporaryHLSDir, hlsMasterPlaylist), []byte(buildMasterPlaylist(renditions, source.Width, source.Height)), 0644)
+	if err != nil {
+		return video, errors.Wrap(err, "failed to write master playlist")
+	}
+
+	// replace any previous renditions
+	finalHLSDir := path.Join(path.Dir(video.Source), hlsDirectory)
+	if err := files.RemoveAll(fs, finalHLSDir); err != nil {
+		return video, errors.Wrap(err, "failed to remove old renditions")
+	}
+
+	// upload everything beside the video
+	err = filepath.Walk(temporaryHLSDir, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(temporaryHLSDir, localPath)
+		if err != nil {
+			return err
+		}
+		finalPath := path.Join(finalHLSDir, filepath.ToSlash(relativePath))
+
+		if info.IsDir() {
+			return fs.MkdirAll(finalPath, os.ModePerm)
+		}
+
+		localFile, err := os.Open(localPath)
+		if err != nil {
+			return err
+		}
+		defer localFile.Close()
+
+		return files.PipeTo(fs, finalPath, localFile)
+	})
+	if err != nil {
+		return video, errors.Wrap(err, "failed to upload renditions")
+	}
+
+	video, err = updateVideo(repo, video.ID, func(video *Video) {
+		video.HLSPlaylist = path.Join(finalHLSDir, hlsMasterPlaylist)
+		video.Fallback = path.Join(finalHLSDir, hlsFallbackFile)
+		video.Renditions = make([]int, len(renditions))
+		for i, rendition := range renditions {
+			video.Renditions[i] = rendition.Height
+		}
+	})
+	if err != nil {
+		return video, errors.Wrap(err, "failed to save video renditions")
+	}
+
+	return video, nil
+}
@@ -0,0 +1,48 @@
+package videostore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_renditionsFor(t *testing.T) {
+	heights := func(renditions []Rendition) []int {
+		result := []int{}
+		for _, rendition := range renditions {
+			result = append(result, rendition.Height)
+		}
+		return result
+	}
+
+	assert.Equal(t, []int{360, 720, 1080}, heights(renditionsFor(2160, HLSRenditions)))
+	assert.Equal(t, []int{360, 720}, heights(renditionsFor(720, HLSRenditions)))
+	// tiny sources still get something playable
+	assert.Equal(t, []int{360}, heights(renditionsFor(240, HLSRenditions)))
+	// unknown heights get everything
+	assert.Equal(t, []int{360, 720, 1080}, heights(renditionsFor(0, HLSRenditions)))
+}
+
+func Test_fallbackFor(t *testing.T) {
+	assert.Equal(t, 720, fallbackFor(HLSRenditions).Height)
+	assert.Equal(t, 360, fallbackFor(HLSRenditions[:1]).Height)
+	// nothing small enough, the smallest will do
+	assert.Equal(t, 1080, fallbackFor(HLSRenditions[2:]).Height)
+}
+
+func Test_buildMasterPlaylist(t *testing.T) {
+	playlist := buildMasterPlaylist(HLSRenditions[:2], 1920, 1080)
+	assert.Equal(t, "#EXTM3U\n"+
+		"#EXT-X-VERSION:3\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=896000,RESOLUTION=640x360\n"+
+		"360p/index.m3u8\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=2928000,RESOLUTION=1280x720\n"+
+		"720p/index.m3u8\n", playlist)
+
+	// resolution is left out when we don't know the source dimensions
+	playlist = buildMasterPlaylist(HLSRenditions[:1], 0, 0)
+	assert.Equal(t, "#EXTM3U\n"+
+		"#EXT-X-VERSION:3\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=896000\n"+
+		"360p/index.m3u8\n", playlist)
+}
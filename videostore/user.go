@@ -0,0 +1,188 @@
+package videostore
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+// roles from least to most trusted,
+// each can do everything the one before it can
+const RoleViewer = "viewer"
+const RoleUploader = "uploader"
+const RoleEditor = "editor"
+const RoleAdmin = "admin"
+
+var Roles = []string{
+	RoleViewer,
+	RoleUploader,
+	RoleEditor,
+	RoleAdmin,
+}
+
+// PermissionUpload allows adding videos,
+// and changing the ones you added
+const PermissionUpload = "upload"
+
+// PermissionEditAny allows changing anyone's videos and tags
+const PermissionEditAny = "edit_any"
+
+// PermissionDeleteAny allows deleting anyone's videos
+const PermissionDeleteAny = "delete_any"
+
+var rolePermissions = map[string][]string{
+	RoleViewer:   {},
+	RoleUploader: {PermissionUpload},
+	RoleEditor:   {PermissionUpload, PermissionEditAny},
+	RoleAdmin:    {PermissionUpload, PermissionEditAny, PermissionDeleteAny},
+}
+
+// User is someone who can log in to make changes
+type User struct {
+	ID           uint   `json:"id"`
+	Username     string `json:"username" sql:",unique,notnull"`
+	PasswordHash string `json:"-" sql:",notnull"`
+	Role         string `json:"role" sql:",notnull"`
+	// OIDCIssuer and OIDCSubject identify someone who logs in with an
+	// OpenID Connect provider. Unlike usernames, subjects never change.
+	OIDCIssuer  string `json:"oidc_issuer,omitempty" sql:"oidc_issuer"`
+	OIDCSubject string `json:"oidc_subject,omitempty" sql:"oidc_subject"`
+	TimeCreated string `json:"time_created"`
+	TimeUpdated string `json:"time_updated"`
+}
+
+func (user User) Exists() bool {
+	return user.ID > 0
+}
+
+// SetPassword hashes password for storage, it is never kept as-is
+func (user *User) SetPassword(password string) error {
+	if len(password) < minPasswordLength {
+		return ErrorPasswordTooShort
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.PasswordHash = string(hash)
+	return nil
+}
+
+// CheckPassword compares password against the stored hash.
+// Users without a password can't log in.
+func (user User) CheckPassword(password string) bool {
+	if user.PasswordHash == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+}
+
+// Can reports whether user's role grants permission.
+// Users without a known role can't do anything.
+func (user User) Can(permission string) bool {
+	for _, granted := range rolePermissions[user.Role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Owns reports whether user added video
+func (user User) Owns(video Video) bool {
+	return user.Exists() && video.OwnerID == user.ID
+}
+
+// CanView reports whether user may watch video,
+// the zero User is anyone logged out
+func (user User) CanView(video Video) bool {
+	return video.Visibility != VisibilityPrivate || user.Owns(video) || user.Can(PermissionEditAny)
+}
+
+// CanEdit reports whether user may change video
+func (user User) CanEdit(video Video) bool {
+	return user.Can(PermissionEditAny) || (user.Can(PermissionUpload) && user.Owns(video))
+}
+
+// CanDelete reports whether user may delete video
+func (user User) CanDelete(video Video) bool {
+	return user.Can(PermissionDeleteAny)
+}
+
+type UserRepo interface {
+	// Save creates or updates user,
+	// ErrorUsernameTaken is returned if someone else has the same username
+	Save(user User) (User, error)
+	FindById(id uint) (User, error)
+	FindByUsername(username string) (User, error)
+	// FindByOIDC finds whoever logs in as subject at issuer,
+	// an empty subject matches no one
+	FindByOIDC(issuer string, subject string) (User, error)
+	// All lists every user by username
+	All() ([]User, error)
+	Delete(user User) error
+}
+
+// UserImporter is a UserRepo that can take users from another repo,
+// keeping their IDs so videos still point at their uploader
+type UserImporter interface {
+	UserRepo
+	// Import inserts user under its ID, keeping its timestamps
+	Import(user User) (User, error)
+}
+
+var ErrorUserNotFound = errors.New("user not found")
+var ErrorUsernameTaken = errors.New("username is taken")
+var ErrorUsernameInvalid = errors.New("username can't be empty or contain spaces")
+var ErrorPasswordTooShort = errors.New("password must be at least 8 characters")
+var ErrorRoleInvalid = errors.New("role must be one of viewer, uploader, editor or admin")
+
+// ValidRole reports whether role is one of Roles
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// validateRole gives new users the least trusted role
+// if they don't have one yet
+func validateRole(user *User) error {
+	if user.Role == "" {
+		user.Role = RoleViewer
+	}
+	if !ValidRole(user.Role) {
+		return ErrorRoleInvalid
+	}
+	return nil
+}
+
+func validateUsername(username string) error {
+	if username == "" || strings.IndexFunc(username, unicode.IsSpace) != -1 {
+		return ErrorUsernameInvalid
+	}
+	return nil
+}
+
+// checkUsernameAvailable makes sure username isn't used by anyone besides user
+func checkUsernameAvailable(repo UserRepo, user User) error {
+	if err := validateUsername(user.Username); err != nil {
+		return err
+	}
+
+	existing, err := repo.FindByUsername(user.Username)
+	if err == ErrorUserNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.ID != user.ID {
+		return ErrorUsernameTaken
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package videostore
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+)
+
+// dummyStoredUser keeps the password hash that User hides from JSON
+type dummyStoredUser struct {
+	User
+	PasswordHash string `json:"password_hash"`
+}
+
+// dummyUserRepo stores users to a local JSON file
+// beside the dummyVideoRepo's dummy.json
+type dummyUserRepo struct {
+	fs    files.FileSystem
+	users []User
+	lock  sync.Mutex
+}
+
+func NewDummyUserRepo(fs files.FileSystem) *dummyUserRepo {
+	var stored []dummyStoredUser
+
+	storedDatabase, err := fs.Open("users.json")
+	if err == nil {
+		defer storedDatabase.Close()
+		err = json.NewDecoder(storedDatabase).Decode(&stored)
+	}
+
+	users := make([]User, len(stored))
+	for i, storedUser := range stored {
+		users[i] = storedUser.User
+		users[i].PasswordHash = storedUser.PasswordHash
+		// anyone who could log in before roles could do everything
+		if users[i].Exists() && users[i].Role == "" {
+			users[i].Role = RoleAdmin
+		}
+	}
+
+	return &dummyUserRepo{
+		fs:    fs,
+		users: users,
+	}
+}
+
+func (repo *dummyUserRepo) dumpToDisk() error {
+	stored := make([]dummyStoredUser, len(repo.users))
+	for i, user := range repo.users {
+		stored[i] = dummyStoredUser{user, user.PasswordHash}
+	}
+
+	userJSON, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+	return files.PipeTo(repo.fs, "users.json", bytes.NewReader(userJSON))
+}
+
+func (repo *dummyUserRepo) find(match func(user User) bool) (User, error) {
+	for _, user := range repo.users {
+		if user.Exists() && match(user) {
+			return user, nil
+		}
+	}
+	return User{}, ErrorUserNotFound
+}
+
+func (repo *dummyUserRepo) Save(user User) (User, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	if err := validateUsername(user.Username); err != nil {
+		return user, err
+	}
+	if err := validateRole(&user); err != nil {
+		return user, err
+	}
+	existing, err := repo.find(func(existing User) bool {
+		return existing.Username == user.Username
+	})
+	if err == nil && existing.ID != user.ID {
+		return user, ErrorUsernameTaken
+	}
+
+	user.TimeUpdated = time.Now().Format(time.RFC3339)
+
+	if !user.Exists() {
+		user.ID = uint(len(repo.users)) + 1
+		user.TimeCreated = user.TimeUpdated
+		repo.users = append(repo.users, user)
+		return user, repo.dumpToDisk()
+	}
+
+	if len(repo.users) < int(user.ID) || !repo.users[user.ID-1].Exists() {
+		return User{}, ErrorUserNotFound
+	}
+
+	repo.users[user.ID-1] = user
+	return user, repo.dumpToDisk()
+}
+
+func (repo *dummyUserRepo) FindById(id uint) (User, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	return repo.find(func(user User) bool {
+		return user.ID == id
+	})
+}
+
+func (repo *dummyUserRepo) FindByUsername(username string) (User, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	return repo.find(func(user User) bool {
+		return user.Username == username
+	})
+}
+
+func (repo *dummyUserRepo) FindByOIDC(issuer string, subject string) (User, error) {
+	if subject == "" {
+		return User{}, ErrorUserNotFound
+	}
+
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	return repo.find(func(user User) bool {
+		return user.OIDCIssuer == issuer && user.OIDCSubject == subject
+	})
+}
+
+func (repo *dummyUserRepo) All() ([]User, error) {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	users := make([]User, 0, len(repo.users))
+	for _, user := range repo.users {
+		if user.Exists() {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	return users, nil
+}
+
+func (repo *dummyUserRepo) Delete(user User) error {
+	repo.lock.Lock()
+	defer repo.lock.Unlock()
+
+	if user.ID == 0 || len(repo.users) < int(user.ID) || !repo.users[user.ID-1].Exists() {
+		return ErrorUserNotFound
+	}
+
+	// soft delete, like the video repo, so IDs are never reused
+	repo.users[user.ID-1] = User{}
+	return repo.dumpToDisk()
+}
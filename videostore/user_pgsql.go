@@ -0,0 +1,127 @@
+package videostore
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+// postgresUserRepo stores users to a Postgres DB
+type postgresUserRepo struct {
+	db pg.DB
+}
+
+// NewPostgresUserRepo expects an up-to-date schema, see MigratePostgresUp
+func NewPostgresUserRepo(db pg.DB) *postgresUserRepo {
+	return &postgresUserRepo{
+		db,
+	}
+}
+
+func (repo *postgresUserRepo) Save(user User) (User, error) {
+	// the unique index still has the final say if two saves race
+	if err := checkUsernameAvailable(repo, user); err != nil {
+		return user, err
+	}
+	if err := validateRole(&user); err != nil {
+		return user, err
+	}
+
+	var err error
+
+	user.TimeUpdated = time.Now().Format(time.RFC3339)
+	if user.Exists() {
+		err = repo.db.Update(&user)
+		if err == pg.ErrNoRows {
+			return user, ErrorUserNotFound
+		}
+	} else {
+		user.TimeCreated = user.TimeUpdated
+		err = repo.db.Insert(&user)
+	}
+
+	return user, err
+}
+
+func (repo *postgresUserRepo) Import(user User) (User, error) {
+	if err := checkUsernameAvailable(repo, user); err != nil {
+		return user, err
+	}
+	if err := validateRole(&user); err != nil {
+		return user, err
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	if user.TimeCreated == "" {
+		user.TimeCreated = now
+	}
+	if user.TimeUpdated == "" {
+		user.TimeUpdated = now
+	}
+	if err := repo.db.Insert(&user); err != nil {
+		return user, err
+	}
+
+	// the sequence doesn't know about IDs picked by hand
+	_, err := repo.db.Exec(`SELECT setval(pg_get_serial_sequence('users', 'id'), (SELECT MAX(id) FROM users))`)
+	return user, err
+}
+
+func (repo *postgresUserRepo) FindById(id uint) (User, error) {
+	user := User{
+		ID: id,
+	}
+
+	err := repo.db.Select(&user)
+
+	if err == pg.ErrNoRows {
+		return user, ErrorUserNotFound
+	}
+
+	return user, err
+}
+
+func (repo *postgresUserRepo) FindByUsername(username string) (User, error) {
+	var user User
+
+	err := repo.db.Model(&user).Where("username = ?", username).Select()
+
+	if err == pg.ErrNoRows {
+		return user, ErrorUserNotFound
+	}
+
+	return user, err
+}
+
+func (repo *postgresUserRepo) FindByOIDC(issuer string, subject string) (User, error) {
+	if subject == "" {
+		return User{}, ErrorUserNotFound
+	}
+
+	var user User
+
+	err := repo.db.Model(&user).Where("oidc_issuer = ? AND oidc_subject = ?", issuer, subject).Select()
+
+	if err == pg.ErrNoRows {
+		return user, ErrorUserNotFound
+	}
+
+	return user, err
+}
+
+func (repo *postgresUserRepo) All() ([]User, error) {
+	users := make([]User, 0)
+	err := repo.db.Model(&users).Order("username").Select()
+	return users, err
+}
+
+func (repo *postgresUserRepo) Delete(user User) error {
+	result, err := repo.db.Model(&user).WherePK().Delete()
+	if err != nil {
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return ErrorUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package videostore
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// sqliteUserRepo stores users to an embedded SQLite DB
+type sqliteUserRepo struct {
+	db *sql.DB
+}
+
+const sqliteUserSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL DEFAULT '',
+	role TEXT NOT NULL DEFAULT 'viewer',
+	oidc_issuer TEXT NOT NULL DEFAULT '',
+	oidc_subject TEXT NOT NULL DEFAULT '',
+	time_created TEXT NOT NULL DEFAULT '',
+	time_updated TEXT NOT NULL DEFAULT ''
+);
+`
+
+const sqliteUserColumns = `id, username, password_hash, role, oidc_issuer, oidc_subject, time_created, time_updated`
+
+func NewSQLiteUserRepo(db *sql.DB) *sqliteUserRepo {
+	if _, err := db.Exec(sqliteUserSchema); err != nil {
+		log.Fatalf("failed to create table: %+v", err)
+	}
+
+	// anyone who could log in before roles could do everything
+	if err := sqliteAddColumn(db, "users", "role", "TEXT NOT NULL DEFAULT 'admin'"); err != nil {
+		log.Fatalf("failed to add user roles: %+v", err)
+	}
+	if err := sqliteAddColumn(db, "users", "oidc_issuer", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		log.Fatalf("failed to add user oidc issuers: %+v", err)
+	}
+	if err := sqliteAddColumn(db, "users", "oidc_subject", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		log.Fatalf("failed to add user oidc subjects: %+v", err)
+	}
+	if _, err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS users_oidc ON users (oidc_issuer, oidc_subject) WHERE oidc_subject != ''"); err != nil {
+		log.Fatalf("failed to index user oidc identities: %+v", err)
+	}
+
+	return &sqliteUserRepo{
+		db,
+	}
+}
+
+func scanSQLiteUser(row sqliteScanner) (User, error) {
+	var user User
+
+	err := row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.PasswordHash,
+		&user.Role,
+		&user.OIDCIssuer,
+		&user.OIDCSubject,
+		&user.TimeCreated,
+		&user.TimeUpdated,
+	)
+
+	return user, err
+}
+
+func (repo *sqliteUserRepo) Save(user User) (User, error) {
+	// the unique index still has the final say if two saves race
+	if err := checkUsernameAvailable(repo, user); err != nil {
+		return user, err
+	}
+	if err := validateRole(&user); err != nil {
+		return user, err
+	}
+
+	user.TimeUpdated = time.Now().Format(time.RFC3339)
+
+	if user.Exists() {
+		result, err := repo.db.Exec(
+			"UPDATE users SET username = ?, password_hash = ?, role = ?, oidc_issuer = ?, oidc_subject = ?, time_updated = ? WHERE id = ?",
+			user.Username, user.PasswordHash, user.Role, user.OIDCIssuer, user.OIDCSubject, user.TimeUpdated, user.ID,
+		)
+		if err != nil {
+			return user, err
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			return user, ErrorUserNotFound
+		}
+		return user, nil
+	}
+
+	user.TimeCreated = user.TimeUpdated
+	result, err := repo.db.Exec(
+		"INSERT INTO users (username, password_hash, role, oidc_issuer, oidc_subject, time_created, time_updated) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		user.Username, user.PasswordHash, user.Role, user.OIDCIssuer, user.OIDCSubject, user.TimeCreated, user.TimeUpdated,
+	)
+	if err != nil {
+		return user, err
+	}
+
+	id, err := result.LastInsertId()
+	user.ID = uint(id)
+
+	return user, err
+}
+
+func (repo *sqliteUserRepo) Import(user User) (User, error) {
+	if err := checkUsernameAvailable(repo, user); err != nil {
+		return user, err
+	}
+	if err := validateRole(&user); err != nil {
+		return user, err
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	if user.TimeCreated == "" {
+		user.TimeCreated = now
+	}
+	if user.TimeUpdated == "" {
+		user.TimeUpdated = now
+	}
+	_, err := repo.db.Exec(
+		"INSERT INTO users (id, username, password_hash, role, oidc_issuer, oidc_subject, time_created, time_updated) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		user.ID, user.Username, user.PasswordHash, user.Role, user.OIDCIssuer, user.OIDCSubject, user.TimeCreated, user.TimeUpdated,
+	)
+	return user, err
+}
+
+func (repo *sqliteUserRepo) FindById(id uint) (User, error) {
+	user, err := scanSQLiteUser(repo.db.QueryRow("SELECT "+sqliteUserColumns+" FROM users WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return User{ID: id}, ErrorUserNotFound
+	}
+
+	return user, err
+}
+
+func (repo *sqliteUserRepo) FindByUsername(username string) (User, error) {
+	user, err := scanSQLiteUser(repo.db.QueryRow("SELECT "+sqliteUserColumns+" FROM users WHERE username = ?", username))
+	if err == sql.ErrNoRows {
+		return User{}, ErrorUserNotFound
+	}
+
+	return user, err
+}
+
+func (repo *sqliteUserRepo) FindByOIDC(issuer string, subject string) (User, error) {
+	if subject == "" {
+		return User{}, ErrorUserNotFound
+	}
+
+	user, err := scanSQLiteUser(repo.db.QueryRow("SELECT "+sqliteUserColumns+" FROM users WHERE oidc_issuer = ? AND oidc_subject = ?", issuer, subject))
+	if err == sql.ErrNoRows {
+		return User{}, ErrorUserNotFound
+	}
+
+	return user, err
+}
+
+func (repo *sqliteUserRepo) All() ([]User, error) {
+	rows, err := repo.db.Query("SELECT " + sqliteUserColumns + " FROM users ORDER BY username")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]User, 0)
+	for rows.Next() {
+		user, err := scanSQLiteUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (repo *sqliteUserRepo) Delete(user User) error {
+	result, err := repo.db.Exec("DELETE FROM users WHERE id = ?", user.ID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrorUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package videostore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserPassword(t *testing.T) {
+	user := User{Username: "alice"}
+	assert.False(t, user.CheckPassword(""))
+
+	assert.Equal(t, ErrorPasswordTooShort, user.SetPassword("short"))
+	assert.Nil(t, user.SetPassword("correct horse"))
+	assert.NotContains(t, user.PasswordHash, "correct horse")
+	assert.True(t, user.CheckPassword("correct horse"))
+	assert.False(t, user.CheckPassword("battery staple"))
+}
+
+func TestUserPermissions(t *testing.T) {
+	viewer := User{ID: 1, Role: RoleViewer}
+	uploader := User{ID: 2, Role: RoleUploader}
+	editor := User{ID: 3, Role: RoleEditor}
+	admin := User{ID: 4, Role: RoleAdmin}
+	nobody := User{ID: 5}
+
+	own := Video{ID: 1, OwnerID: uploader.ID}
+	other := Video{ID: 2, OwnerID: admin.ID}
+	anonymous := Video{ID: 3}
+
+	assert.False(t, viewer.Can(PermissionUpload))
+	assert.False(t, viewer.CanEdit(anonymous))
+	assert.False(t, nobody.Can(PermissionUpload))
+
+	assert.True(t, uploader.Can(PermissionUpload))
+	assert.True(t, uploader.CanEdit(own))
+	assert.False(t, uploader.CanEdit(other))
+	assert.False(t, uploader.CanEdit(anonymous))
+	assert.False(t, uploader.CanDelete(own))
+
+	assert.True(t, editor.CanEdit(other))
+	assert.True(t, editor.CanEdit(anonymous))
+	assert.False(t, editor.CanDelete(other))
+
+	assert.True(t, admin.CanEdit(own))
+	assert.True(t, admin.CanDelete(own))
+	assert.True(t, admin.CanDelete(anonymous))
+
+	// logged out users own nothing, even videos nobody uploaded
+	assert.False(t, User{Role: RoleUploader}.CanEdit(anonymous))
+
+	private := Video{ID: 4, OwnerID: uploader.ID, Visibility: VisibilityPrivate}
+	unlisted := Video{ID: 5, OwnerID: uploader.ID, Visibility: VisibilityUnlisted}
+	assert.True(t, User{}.CanView(own))
+	assert.True(t, User{}.CanView(unlisted))
+	assert.False(t, User{}.CanView(private))
+	assert.False(t, viewer.CanView(private))
+	assert.True(t, uploader.CanView(private))
+	assert.True(t, editor.CanView(private))
+}
+
+// testUserRepo runs the same checks against every repo
+func testUserRepo(t *testing.T, repo UserRepo) {
+	bob, err := repo.Save(User{Username: "bob", PasswordHash: "hash"})
+	assert.Nil(t, err)
+	assert.True(t, bob.Exists())
+	assert.Equal(t, RoleViewer, bob.Role)
+	alice, err := repo.Save(User{Username: "alice", Role: RoleEditor})
+	assert.Nil(t, err)
+
+	_, err = repo.Save(User{Username: "bob"})
+	assert.Equal(t, ErrorUsernameTaken, err)
+	_, err = repo.Save(User{Username: "bob smith"})
+	assert.Equal(t, ErrorUsernameInvalid, err)
+	_, err = repo.Save(User{Username: "dave", Role: "owner"})
+	assert.Equal(t, ErrorRoleInvalid, err)
+
+	found, err := repo.FindByUsername("bob")
+	assert.Nil(t, err)
+	assert.Equal(t, bob.ID, found.ID)
+	assert.Equal(t, "hash", found.PasswordHash)
+
+	alice.PasswordHash = "other"
+	_, err = repo.Save(alice)
+	assert.Nil(t, err)
+	found, err = repo.FindById(alice.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "other", found.PasswordHash)
+	assert.Equal(t, RoleEditor, found.Role)
+
+	users, err := repo.All()
+	assert.Nil(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "alice", users[0].Username)
+
+	assert.Nil(t, repo.Delete(bob))
+	assert.Equal(t, ErrorUserNotFound, repo.Delete(bob))
+	_, err = repo.FindById(bob.ID)
+	assert.Equal(t, ErrorUserNotFound, err)
+	_, err = repo.FindByUsername("bob")
+	assert.Equal(t, ErrorUserNotFound, err)
+
+	// the name is free again
+	carol, err := repo.Save(User{Username: "bob"})
+	assert.Nil(t, err)
+	assert.NotEqual(t, bob.ID, carol.ID)
+
+	// OIDC logins are found by issuer and subject, not username
+	oscar, err := repo.Save(User{Username: "oscar", OIDCIssuer: "https://sso.example", OIDCSubject: "1234"})
+	assert.Nil(t, err)
+	found, err = repo.FindByOIDC("https://sso.example", "1234")
+	assert.Nil(t, err)
+	assert.Equal(t, oscar.ID, found.ID)
+	_, err = repo.FindByOIDC("https://other.example", "1234")
+	assert.Equal(t, ErrorUserNotFound, err)
+	_, err = repo.FindByOIDC("", "")
+	assert.Equal(t, ErrorUserNotFound, err)
+}
+
+func TestDummyUserRepo(t *testing.T) {
+	root := "test-dummy-users"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	testUserRepo(t, NewDummyUserRepo(fs))
+
+	// password hashes survive a restart
+	user, err := NewDummyUserRepo(fs).FindByUsername("alice")
+	assert.Nil(t, err)
+	assert.Equal(t, "other", user.PasswordHash)
+}
+
+func TestSQLiteUserRepo(t *testing.T) {
+	root := "test-sqlite-users"
+	assert.Nil(t, os.MkdirAll(root, os.ModePerm))
+	defer os.RemoveAll(root)
+
+	db, err := OpenSQLite(filepath.Join(root, "videos.sqlite"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	testUserRepo(t, NewSQLiteUserRepo(db))
+}
@@ -10,11 +10,23 @@ const SortDirectionDescending = "desc"
 const SortFieldTitle = "title"
 const SortFieldTimeCreated = "time_created"
 const SortFieldTimeUpdated = "time_updated"
+const SortFieldDuration = "duration"
+const SortFieldHeight = "height"
+const SortFieldSize = "size"
+
+// SortFieldRelevance orders by how well videos match VideoFilter.Text,
+// use SortDirectionDescending for best matches first.
+// Without Text, every video is equally relevant.
+const SortFieldRelevance = "relevance"
 
 var SortFields = []string{
 	SortFieldTitle,
 	SortFieldTimeCreated,
 	SortFieldTimeUpdated,
+	SortFieldDuration,
+	SortFieldHeight,
+	SortFieldSize,
+	SortFieldRelevance,
 }
 
 // VideoFilter represents a "filter" used for
@@ -26,6 +38,28 @@ type VideoFilter struct {
 	Tags  []string
 	Any   string
 
+	// full-text search across title, description and tags,
+	// every word must match regardless of the filters above
+	Text string
+
+	// parsed boolean query, also required to match
+	// regardless of the filters above
+	Query *QueryNode
+
+	// media filters, zero means unbounded
+	MinDuration float64
+	MaxDuration float64
+	MinHeight   int
+
+	// only videos uploaded by this user, zero means anyone
+	OwnerID uint
+
+	// Listed hides unlisted and private videos, except ones
+	// uploaded by ViewerID, and videos still being uploaded.
+	// Leave it off to find every video.
+	Listed   bool
+	ViewerID uint
+
 	SortDirection string
 	SortField     string
 }
@@ -35,7 +69,19 @@ func (filter VideoFilter) Sort() bool {
 }
 
 func (filter VideoFilter) Empty() bool {
-	return len(filter.Title)+len(filter.Tags)+len(filter.Any) == 0
+	return !filter.hasText() && !filter.hasSearch() && filter.Query == nil && !filter.hasMedia() && filter.OwnerID == 0 && !filter.Listed
+}
+
+func (filter VideoFilter) hasText() bool {
+	return len(filter.Title)+len(filter.Tags)+len(filter.Any) > 0
+}
+
+func (filter VideoFilter) hasSearch() bool {
+	return len(tokenize(filter.Text)) > 0
+}
+
+func (filter VideoFilter) hasMedia() bool {
+	return filter.MinDuration > 0 || filter.MaxDuration > 0 || filter.MinHeight > 0
 }
 
 func (filter VideoFilter) ValidSortDirection() bool {
@@ -62,6 +108,43 @@ type Video struct {
 	TimeCreated      string   `json:"time_created"`
 	TimeUpdated      string   `json:"time_updated"`
 	Tags             []string `json:"tags"`
+
+	// User.ID of whoever uploaded it, zero if nobody was logged in
+	OwnerID uint `json:"owner_id,omitempty"`
+
+	// who can find and watch it, one of Visibilities
+	Visibility string `json:"visibility,omitempty" sql:",notnull"`
+
+	// media info, filled in by ProbeVideo
+	Duration   float64 `json:"duration,omitempty"` // seconds
+	Width      int     `json:"width,omitempty"`
+	Height     int     `json:"height,omitempty"`
+	VideoCodec string  `json:"video_codec,omitempty"`
+	AudioCodec string  `json:"audio_codec,omitempty"`
+	Bitrate    int64   `json:"bitrate,omitempty"` // bits per second
+	Size       int64   `json:"size,omitempty"`    // bytes
+
+	// HLS renditions, filled in by TranscodeVideo
+	HLSPlaylist string `json:"hls_playlist,omitempty"`
+	Renditions  []int  `json:"renditions,omitempty"` // heights, like 720
+	Fallback    string `json:"fallback,omitempty"`   // H.264 MP4 for browsers without HLS
+
+	// WebVTT track of scrubbing previews, filled in by GenerateSprites
+	Sprites string `json:"sprites,omitempty"`
+
+	// short animated clip shown on hover, filled in by GeneratePreview
+	Preview string `json:"preview,omitempty"`
+
+	// set once someone picks the thumbnail,
+	// GenerateThumbnail leaves it alone afterwards
+	CustomThumbnail bool `json:"custom_thumbnail,omitempty" sql:",notnull"`
+	// seconds into the video the custom thumbnail is taken from,
+	// nil if it was uploaded as an image
+	ThumbnailTimestamp *float64 `json:"thumbnail_timestamp,omitempty"`
+
+	// highlighted match when listing with VideoFilter.Text, see Snippet.
+	// Not stored.
+	Snippet string `json:"snippet,omitempty" sql:"-"`
 }
 
 func (video Video) Exists() bool {
@@ -74,6 +157,28 @@ type VideoRepo interface {
 	All(filter VideoFilter, limit uint, offset uint) ([]Video, error)
 	Count(filter VideoFilter) (uint, error)
 	Delete(video Video) error
+	TagRepo
+	TagTaxonomyRepo
+}
+
+// VideoImporter is a VideoRepo that can take videos from another repo,
+// keeping their IDs so stored media paths stay valid
+type VideoImporter interface {
+	VideoRepo
+	// Import inserts video under its ID, keeping its timestamps
+	Import(video Video) (Video, error)
 }
 
 var ErrorVideoNotFound = errors.New("video not found")
+
+// updateVideo applies update to the latest copy of video id and saves it.
+// Processing jobs use it to record their results, since other jobs
+// may have updated the video while they were busy.
+func updateVideo(repo VideoRepo, id uint, update func(video *Video)) (Video, error) {
+	video, err := repo.FindById(id)
+	if err != nil {
+		return video, err
+	}
+	update(&video)
+	return repo.Save(video)
+}
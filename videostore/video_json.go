@@ -17,6 +17,8 @@ type dummyVideoRepo struct {
 	VideoRepo
 	fs        files.FileSystem
 	videos    []Video
+	aliases   map[string]string
+	index     *searchIndex
 	id        uint
 	idLock    sync.Mutex
 	videoLock sync.Mutex
@@ -39,10 +41,28 @@ func NewDummyVideoRepo(fs files.FileSystem) *dummyVideoRepo {
 		videos = make([]Video, 0)
 	}
 
+	var aliases []TagAlias
+	storedAliases, err := fs.Open("tag-aliases.json")
+	if err == nil {
+		defer storedAliases.Close()
+		json.NewDecoder(storedAliases).Decode(&aliases)
+	}
+
+	index := newSearchIndex()
+	for i := range videos {
+		// saved before visibility existed
+		if videos[i].Visibility == "" {
+			videos[i].Visibility = VisibilityPublic
+		}
+		index.add(videos[i])
+	}
+
 	return &dummyVideoRepo{
-		fs:     fs,
-		videos: videos,
-		id:     uint(len(videos)),
+		fs:      fs,
+		videos:  videos,
+		aliases: tagAliasMap(aliases),
+		index:   index,
+		id:      uint(len(videos)),
 	}
 }
 
@@ -58,16 +78,33 @@ func (repo *dummyVideoRepo) dumpToDisk() {
 	files.PipeTo(repo.fs, "dummy.json", bytes.NewReader(videoJSON))
 }
 
+func (repo *dummyVideoRepo) dumpAliasesToDisk() error {
+	aliases := make([]TagAlias, 0, len(repo.aliases))
+	for alias, tag := range repo.aliases {
+		aliases = append(aliases, TagAlias{alias, tag})
+	}
+	sortTagAliases(aliases)
+
+	aliasJSON, _ := json.Marshal(&aliases)
+	return files.PipeTo(repo.fs, "tag-aliases.json", bytes.NewReader(aliasJSON))
+}
+
 func (repo *dummyVideoRepo) Save(video Video) (Video, error) {
 	repo.videoLock.Lock()
 	defer repo.videoLock.Unlock()
 
+	video.Tags = normalizeTags(video.Tags, repo.aliases)
+	if err := validateVisibility(&video); err != nil {
+		return video, err
+	}
+
 	if !video.Exists() {
 		// create
 		video.ID = repo.makeID()
 		repo.videos = append(repo.videos, video)
 		video.TimeCreated = time.Now().Format(time.RFC3339)
 		video.TimeUpdated = time.Now().Format(time.RFC3339)
+		repo.index.add(video)
 
 		return video, nil
 	}
@@ -78,6 +115,7 @@ func (repo *dummyVideoRepo) Save(video Video) (Video, error) {
 
 	video.TimeUpdated = time.Now().Format(time.RFC3339)
 	repo.videos[video.ID-1] = video
+	repo.index.add(video)
 	repo.dumpToDisk()
 
 	return video, nil
@@ -92,13 +130,14 @@ func (repo *dummyVideoRepo) Delete(video Video) error {
 	index := video.ID - 1
 	// soft delete
 	repo.videos[index] = Video{}
+	repo.index.remove(video.ID)
 	repo.dumpToDisk()
 
 	return nil
 }
 
 func (repo *dummyVideoRepo) FindById(video uint) (Video, error) {
-	if len(repo.videos) < int(video) {
+	if video == 0 || len(repo.videos) < int(video) {
 		return Video{}, ErrorVideoNotFound
 	}
 
@@ -135,7 +174,7 @@ func videoHasAllTags(video Video, tags []string) bool {
 	for _, tag := range tags {
 		hasTag := false
 		for _, videoTag := range video.Tags {
-			if videoTag == tag {
+			if tagMatches(videoTag, tag) {
 				hasTag = true
 				break
 			}
@@ -149,9 +188,86 @@ func videoHasAllTags(video Video, tags []string) bool {
 	return true
 }
 
+// videoMatchesText checks the text filters,
+// any one of which is enough for a match
+// titleContains matches text in title regardless of case,
+// like the LIKE filters of the database repos
+func titleContains(title string, text string) bool {
+	return strings.Contains(strings.ToLower(title), strings.ToLower(text))
+}
+
+func videoMatchesText(video Video, filter VideoFilter) bool {
+	if len(filter.Title) > 0 && titleContains(video.Title, filter.Title) {
+		return true
+	}
+
+	if len(filter.Tags) > 0 && videoHasAllTags(video, filter.Tags) {
+		return true
+	}
+
+	if len(filter.Any) > 0 {
+		if titleContains(video.Title, filter.Any) || videoHasAllTags(video, []string{filter.Any}) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// videoMatchesMedia checks the media filters,
+// all of which must match
+func videoMatchesMedia(video Video, filter VideoFilter) bool {
+	if filter.MinDuration > 0 && video.Duration < filter.MinDuration {
+		return false
+	}
+
+	if filter.MaxDuration > 0 && video.Duration > filter.MaxDuration {
+		return false
+	}
+
+	if filter.MinHeight > 0 && video.Height < filter.MinHeight {
+		return false
+	}
+
+	return true
+}
+
+// videoMatchesFilter checks every filter, scores holds
+// the full-text search results if filter.Text is set
+func videoMatchesFilter(video Video, filter VideoFilter, scores map[uint]float64) bool {
+	if filter.hasText() && !videoMatchesText(video, filter) {
+		return false
+	}
+
+	if filter.hasSearch() {
+		if _, ok := scores[video.ID]; !ok {
+			return false
+		}
+	}
+
+	if filter.Query != nil && !videoMatchesQuery(video, *filter.Query) {
+		return false
+	}
+
+	if filter.OwnerID != 0 && video.OwnerID != filter.OwnerID {
+		return false
+	}
+
+	if filter.Listed && !videoListedFor(video, filter.ViewerID) {
+		return false
+	}
+
+	return videoMatchesMedia(video, filter)
+}
+
 func (repo *dummyVideoRepo) All(filter VideoFilter, limit uint, offset uint) ([]Video, error) {
 	var videos []Video
 
+	var scores map[uint]float64
+	if filter.hasSearch() {
+		scores = repo.index.search(filter.Text)
+	}
+
 	if filter.Empty() {
 		videos = repo.videos
 	} else {
@@ -159,21 +275,8 @@ func (repo *dummyVideoRepo) All(filter VideoFilter, limit uint, offset uint) ([]
 		// a very inefficient filter
 		// accepting PRs ;)
 		for _, video := range repo.videos {
-			if len(filter.Title) > 0 && strings.Contains(video.Title, filter.Title) {
+			if videoMatchesFilter(video, filter, scores) {
 				videos = append(videos, video)
-				continue
-			}
-
-			if len(filter.Tags) > 0 && videoHasAllTags(video, filter.Tags) {
-				videos = append(videos, video)
-				continue
-			}
-
-			if len(filter.Any) > 0 {
-				if strings.Contains(video.Title, filter.Any) || videoHasAllTags(video, []string{filter.Any}) {
-					videos = append(videos, video)
-					continue
-				}
 			}
 		}
 	}
@@ -207,6 +310,26 @@ func (repo *dummyVideoRepo) All(filter VideoFilter, limit uint, offset uint) ([]
 
 				return iTime.Before(jTime)
 			}
+		} else if filter.SortField == SortFieldDuration {
+			sortFunction = func(i, j int) bool {
+				return existingVideos[i].Duration < existingVideos[j].Duration
+			}
+		} else if filter.SortField == SortFieldHeight {
+			sortFunction = func(i, j int) bool {
+				return existingVideos[i].Height < existingVideos[j].Height
+			}
+		} else if filter.SortField == SortFieldSize {
+			sortFunction = func(i, j int) bool {
+				return existingVideos[i].Size < existingVideos[j].Size
+			}
+		} else if filter.SortField == SortFieldRelevance {
+			sortFunction = func(i, j int) bool {
+				if scores[existingVideos[i].ID] == scores[existingVideos[j].ID] {
+					// ties, or nothing to rank by: show the newest first
+					return (existingVideos[i].ID > existingVideos[j].ID) != (filter.SortDirection == SortDirectionDescending)
+				}
+				return scores[existingVideos[i].ID] < scores[existingVideos[j].ID]
+			}
 		} else {
 			return []Video{}, fmt.Errorf("unsupported sort field %v", filter.SortField)
 		}
@@ -229,33 +352,145 @@ func (repo *dummyVideoRepo) All(filter VideoFilter, limit uint, offset uint) ([]
 func (repo *dummyVideoRepo) Count(filter VideoFilter) (uint, error) {
 	count := uint(0)
 
+	var scores map[uint]float64
+	if filter.hasSearch() {
+		scores = repo.index.search(filter.Text)
+	}
+
 	for _, video := range repo.videos {
 		if !video.Exists() {
 			continue
 		}
 
-		if filter.Empty() {
+		if filter.Empty() || videoMatchesFilter(video, filter, scores) {
 			count++
-			continue
 		}
+	}
 
-		if len(filter.Title) > 0 && strings.Contains(video.Title, filter.Title) {
-			count++
+	return count, nil
+}
+
+func (repo *dummyVideoRepo) Tags(filter VideoFilter) ([]TagCount, error) {
+	var scores map[uint]float64
+	if filter.hasSearch() {
+		scores = repo.index.search(filter.Text)
+	}
+
+	counts := make(map[string]uint)
+	for _, video := range repo.videos {
+		if !video.Exists() || (!filter.Empty() && !videoMatchesFilter(video, filter, scores)) {
 			continue
 		}
+		seen := make(map[string]bool, len(video.Tags))
+		for _, tag := range video.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				counts[tag]++
+			}
+		}
+	}
 
-		if len(filter.Tags) > 0 && videoHasAllTags(video, filter.Tags) {
-			count++
-			continue
+	tags := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tags = append(tags, TagCount{tag, count})
+	}
+	sortTagCounts(tags)
+
+	return tags, nil
+}
+
+func (repo *dummyVideoRepo) SuggestTags(filter VideoFilter, prefix string, limit uint) ([]TagCount, error) {
+	tags, err := repo.Tags(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix = strings.ToLower(prefix)
+	suggestions := make([]TagCount, 0, limit)
+	for _, tag := range tags {
+		if uint(len(suggestions)) >= limit {
+			break
 		}
+		if strings.HasPrefix(strings.ToLower(tag.Tag), prefix) {
+			suggestions = append(suggestions, tag)
+		}
+	}
 
-		if len(filter.Any) > 0 {
-			if strings.Contains(video.Title, filter.Any) || videoHasAllTags(video, []string{filter.Any}) {
-				count++
-				continue
-			}
+	return suggestions, nil
+}
+
+func (repo *dummyVideoRepo) MergeTags(from []string, into string) (uint, error) {
+	ok, err := validateMerge(from, into)
+	if !ok {
+		return 0, err
+	}
+
+	repo.videoLock.Lock()
+	defer repo.videoLock.Unlock()
+
+	changed := uint(0)
+	now := time.Now().Format(time.RFC3339)
+	for i, video := range repo.videos {
+		if !video.Exists() {
+			continue
 		}
+		tags, ok := mergeTags(video.Tags, from, into)
+		if !ok {
+			continue
+		}
+		video.Tags = tags
+		video.TimeUpdated = now
+		repo.videos[i] = video
+		repo.index.add(video)
+		changed++
 	}
 
-	return count, nil
+	if changed > 0 {
+		repo.dumpToDisk()
+	}
+
+	return changed, nil
+}
+
+func (repo *dummyVideoRepo) TagAliases() ([]TagAlias, error) {
+	repo.videoLock.Lock()
+	defer repo.videoLock.Unlock()
+
+	aliases := make([]TagAlias, 0, len(repo.aliases))
+	for alias, tag := range repo.aliases {
+		aliases = append(aliases, TagAlias{alias, tag})
+	}
+	sortTagAliases(aliases)
+
+	return aliases, nil
+}
+
+func (repo *dummyVideoRepo) SetTagAlias(alias string, tag string) (TagAlias, error) {
+	repo.videoLock.Lock()
+	defer repo.videoLock.Unlock()
+
+	resolved, repointed, err := resolveTagAlias(repo.aliases, alias, tag)
+	if err != nil {
+		return resolved, err
+	}
+
+	repo.aliases[resolved.Alias] = resolved.Tag
+	for _, existing := range repointed {
+		repo.aliases[existing] = resolved.Tag
+	}
+
+	return resolved, repo.dumpAliasesToDisk()
+}
+
+func (repo *dummyVideoRepo) DeleteTagAlias(alias string) error {
+	repo.videoLock.Lock()
+	defer repo.videoLock.Unlock()
+
+	alias = cleanTag(alias)
+	if _, ok := repo.aliases[alias]; !ok {
+		return ErrorTagAliasNotFound
+	}
+	delete(repo.aliases, alias)
+
+	return repo.dumpAliasesToDisk()
 }
@@ -2,7 +2,7 @@ package videostore
 
 import (
 	"fmt"
-	"log"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -16,14 +16,8 @@ type postgresVideoRepo struct {
 	db pg.DB
 }
 
+// NewPostgresVideoRepo expects an up-to-date schema, see MigratePostgresUp
 func NewPostgresVideoRepo(db pg.DB) *postgresVideoRepo {
-	err := db.CreateTable((*Video)(nil), &orm.CreateTableOptions{
-		IfNotExists: true,
-	})
-	if err != nil {
-		log.Fatalf("failed to create table: %+v", err)
-	}
-
 	return &postgresVideoRepo{
 		db,
 	}
@@ -43,28 +37,118 @@ func (repo *postgresVideoRepo) FindById(id uint) (Video, error) {
 	return video, err
 }
 
-func (repo *postgresVideoRepo) All(filter VideoFilter, limit uint, offset uint) ([]Video, error) {
-	var videos []Video
+// postgresHasAllTags matches videos tagged with every one of tags,
+// or with a tag nested below it like animals/cats for animals
+func postgresHasAllTags(tags []string) (string, []interface{}) {
+	conditions := make([]string, len(tags))
+	params := make([]interface{}, 0, 2*len(tags))
+	for i, tag := range tags {
+		conditions[i] = `(COALESCE(tags, '[]'::jsonb) \? ? OR EXISTS (` +
+			`SELECT 1 FROM jsonb_array_elements_text(CASE jsonb_typeof(tags) WHEN 'array' THEN tags ELSE '[]'::jsonb END) AS nested ` +
+			`WHERE nested LIKE ?))`
+		params = append(params, tag, escapeLike(tag+TagSeparator)+"%")
+	}
+	return strings.Join(conditions, " AND "), params
+}
 
-	query := repo.db.Model(&videos)
+// postgresQueryCondition compiles node into a WHERE condition,
+// terms behave like the text filters in applyVideoFilter
+func postgresQueryCondition(node QueryNode) (string, []interface{}) {
+	switch node.Op {
+	case QueryAnd, QueryOr:
+		conditions := make([]string, len(node.Children))
+		var params []interface{}
+		for i, child := range node.Children {
+			condition, childParams := postgresQueryCondition(child)
+			conditions[i] = condition
+			params = append(params, childParams...)
+		}
+		return "(" + strings.Join(conditions, " "+strings.ToUpper(node.Op)+" ") + ")", params
+	case QueryNot:
+		condition, params := postgresQueryCondition(node.Children[0])
+		return "NOT " + condition, params
+	}
 
-	if !filter.Empty() {
-		query = query.Apply(func(q *orm.Query) (*orm.Query, error) {
-			if len(filter.Title) > 0 {
-				q = q.Where("LOWER(title) LIKE LOWER(?)", "%"+filter.Title+"%")
-			}
+	title := "LOWER(COALESCE(title, '')) LIKE LOWER(?)"
+	tag, tagParams := postgresHasAllTags([]string{node.Value})
 
-			if len(filter.Tags) > 0 {
-				q = q.Where("tags \\?& ?", pg.Array(filter.Tags))
-			}
+	switch node.Field {
+	case QueryFieldTitle:
+		return "(" + title + ")", []interface{}{"%" + escapeLike(node.Value) + "%"}
+	case QueryFieldTag:
+		return "(" + tag + ")", tagParams
+	}
+	return "(" + title + " OR " + tag + ")", append([]interface{}{"%" + escapeLike(node.Value) + "%"}, tagParams...)
+}
 
-			if len(filter.Any) > 0 {
-				q = q.WhereOr("LOWER(title) LIKE LOWER(?)", "%"+filter.Any+"%")
-				q = q.WhereOr("tags \\?& ?", pg.Array([]string{filter.Any}))
+func applyVideoFilter(filter VideoFilter) func(q *orm.Query) (*orm.Query, error) {
+	return func(q *orm.Query) (*orm.Query, error) {
+		if filter.hasText() {
+			q = q.WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+				if len(filter.Title) > 0 {
+					q = q.Where("LOWER(title) LIKE LOWER(?)", "%"+escapeLike(filter.Title)+"%")
+				}
+
+				if len(filter.Tags) > 0 {
+					condition, params := postgresHasAllTags(filter.Tags)
+					q = q.Where(condition, params...)
+				}
+
+				if len(filter.Any) > 0 {
+					q = q.WhereOr("LOWER(title) LIKE LOWER(?)", "%"+escapeLike(filter.Any)+"%")
+					condition, params := postgresHasAllTags([]string{filter.Any})
+					q = q.WhereOr(condition, params...)
+				}
+
+				return q, nil
+			})
+		}
+
+		if filter.hasSearch() {
+			q = q.Where("search @@ plainto_tsquery('simple', ?)", filter.Text)
+		}
+
+		if filter.Query != nil {
+			condition, params := postgresQueryCondition(*filter.Query)
+			q = q.Where(condition, params...)
+		}
+
+		if filter.MinDuration > 0 {
+			q = q.Where("duration >= ?", filter.MinDuration)
+		}
+
+		if filter.MaxDuration > 0 {
+			q = q.Where("duration <= ?", filter.MaxDuration)
+		}
+
+		if filter.MinHeight > 0 {
+			q = q.Where("height >= ?", filter.MinHeight)
+		}
+
+		if filter.OwnerID != 0 {
+			q = q.Where("owner_id = ?", filter.OwnerID)
+		}
+
+		if filter.Listed {
+			q = q.Where("source IS NOT NULL AND source <> ''")
+			if filter.ViewerID != 0 {
+				q = q.Where("(visibility = ? OR owner_id = ?)", VisibilityPublic, filter.ViewerID)
+			} else {
+				q = q.Where("visibility = ?", VisibilityPublic)
 			}
+		}
 
-			return q, nil
-		})
+		return q, nil
+	}
+}
+
+func (repo *postgresVideoRepo) All(filter VideoFilter, limit uint, offset uint) ([]Video, error) {
+	var videos []Video
+
+	query := repo.db.Model(&videos)
+
+	if !filter.Empty() {
+		query = query.Apply(applyVideoFilter(filter))
 	}
 
 	if filter.Sort() {
@@ -77,6 +161,15 @@ func (repo *postgresVideoRepo) All(filter VideoFilter, limit uint, offset uint)
 				return nil, fmt.Errorf("invalid sort direction %v", filter.SortDirection)
 			}
 
+			if filter.SortField == SortFieldRelevance {
+				if !filter.hasSearch() {
+					// nothing to rank by, show the newest first
+					return q.Order("id DESC"), nil
+				}
+				q = q.OrderExpr("ts_rank(search, plainto_tsquery('simple', ?)) "+filter.SortDirection, filter.Text)
+				return q.Order("id DESC"), nil
+			}
+
 			q = q.Order(fmt.Sprintf("%v %v", filter.SortField, filter.SortDirection))
 
 			return q, nil
@@ -96,22 +189,7 @@ func (repo *postgresVideoRepo) Count(filter VideoFilter) (uint, error) {
 	query := repo.db.Model(&Video{})
 
 	if !filter.Empty() {
-		query = query.Apply(func(q *orm.Query) (*orm.Query, error) {
-			if len(filter.Title) > 0 {
-				q = q.Where("LOWER(title) LIKE LOWER(?)", "%"+filter.Title+"%")
-			}
-
-			if len(filter.Tags) > 0 {
-				q = q.Where("tags \\?& ?", pg.Array(filter.Tags))
-			}
-
-			if len(filter.Any) > 0 {
-				q = q.WhereOr("LOWER(title) LIKE LOWER(?)", "%"+filter.Any+"%")
-				q = q.WhereOr("tags \\?& ?", pg.Array([]string{filter.Any}))
-			}
-
-			return q, nil
-		})
+		query = query.Apply(applyVideoFilter(filter))
 	}
 
 	count, err := query.Count()
@@ -121,8 +199,23 @@ func (repo *postgresVideoRepo) Count(filter VideoFilter) (uint, error) {
 	return uint(count), nil
 }
 
+func (repo *postgresVideoRepo) tagAliasMap() (map[string]string, error) {
+	aliases, err := repo.TagAliases()
+	if err != nil {
+		return nil, err
+	}
+	return tagAliasMap(aliases), nil
+}
+
 func (repo *postgresVideoRepo) Save(video Video) (Video, error) {
-	var err error
+	aliases, err := repo.tagAliasMap()
+	if err != nil {
+		return video, errors.Wrap(err, "failed to load tag aliases")
+	}
+	video.Tags = normalizeTags(video.Tags, aliases)
+	if err := validateVisibility(&video); err != nil {
+		return video, err
+	}
 
 	if video.Exists() {
 		video.TimeUpdated = time.Now().Format(time.RFC3339)
@@ -136,6 +229,32 @@ func (repo *postgresVideoRepo) Save(video Video) (Video, error) {
 	return video, err
 }
 
+func (repo *postgresVideoRepo) Import(video Video) (Video, error) {
+	aliases, err := repo.tagAliasMap()
+	if err != nil {
+		return video, errors.Wrap(err, "failed to load tag aliases")
+	}
+	video.Tags = normalizeTags(video.Tags, aliases)
+	if err := validateVisibility(&video); err != nil {
+		return video, err
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	if video.TimeCreated == "" {
+		video.TimeCreated = now
+	}
+	if video.TimeUpdated == "" {
+		video.TimeUpdated = now
+	}
+	if err := repo.db.Insert(&video); err != nil {
+		return video, err
+	}
+
+	// the sequence doesn't know about IDs picked by hand
+	_, err = repo.db.Exec(`SELECT setval(pg_get_serial_sequence('videos', 'id'), (SELECT MAX(id) FROM videos))`)
+	return video, err
+}
+
 func (repo *postgresVideoRepo) Delete(video Video) error {
 	err := repo.db.Delete(&video)
 	if err != nil {
@@ -144,3 +263,129 @@ func (repo *postgresVideoRepo) Delete(video Video) error {
 
 	return nil
 }
+
+// tagCounts counts the tags of videos matching filter
+func (repo *postgresVideoRepo) tagCounts(filter VideoFilter) *orm.Query {
+	return repo.db.Model((*Video)(nil)).
+		ColumnExpr("tag, COUNT(DISTINCT id) AS count").
+		TableExpr(`jsonb_array_elements_text(CASE jsonb_typeof(tags) WHEN 'array' THEN tags ELSE '[]'::jsonb END) AS tag`).
+		Apply(applyVideoFilter(filter)).
+		Group("tag").
+		OrderExpr("count DESC, tag")
+}
+
+func (repo *postgresVideoRepo) Tags(filter VideoFilter) ([]TagCount, error) {
+	tags := make([]TagCount, 0)
+	err := repo.tagCounts(filter).Select(&tags)
+	return tags, err
+}
+
+func (repo *postgresVideoRepo) SuggestTags(filter VideoFilter, prefix string, limit uint) ([]TagCount, error) {
+	tags := make([]TagCount, 0)
+	err := repo.tagCounts(filter).
+		Where("tag ILIKE ?", escapeLike(prefix)+"%").
+		Limit(int(limit)).
+		Select(&tags)
+	return tags, err
+}
+
+func (repo *postgresVideoRepo) MergeTags(from []string, into string) (uint, error) {
+	ok, err := validateMerge(from, into)
+	if !ok {
+		return 0, err
+	}
+
+	changed := uint(0)
+	err = repo.db.RunInTransaction(func(tx *pg.Tx) error {
+		var videos []Video
+		err := tx.Model(&videos).
+			Where("tags \\?| ?", pg.Array(from)).
+			For("UPDATE").
+			Select()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		for _, video := range videos {
+			tags, ok := mergeTags(video.Tags, from, into)
+			if !ok {
+				continue
+			}
+			video.Tags = tags
+			video.TimeUpdated = now
+			if _, err := tx.Model(&video).Column("tags", "time_updated").WherePK().Update(); err != nil {
+				return err
+			}
+			changed++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to merge tags")
+	}
+
+	return changed, nil
+}
+
+func (repo *postgresVideoRepo) TagAliases() ([]TagAlias, error) {
+	aliases := make([]TagAlias, 0)
+	err := repo.db.Model(&aliases).Order("alias").Select()
+	return aliases, err
+}
+
+func (repo *postgresVideoRepo) SetTagAlias(alias string, tag string) (TagAlias, error) {
+	var resolved TagAlias
+	err := repo.db.RunInTransaction(func(tx *pg.Tx) error {
+		// aliases are resolved against each other, so only one change at a time
+		if _, err := tx.Exec("LOCK TABLE tag_aliases IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+			return err
+		}
+
+		var aliases []TagAlias
+		if err := tx.Model(&aliases).Select(); err != nil {
+			return err
+		}
+
+		var err error
+		resolved, _, err = resolveTagAlias(tagAliasMap(aliases), alias, tag)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Model(&resolved).
+			OnConflict("(alias) DO UPDATE").
+			Set("tag = EXCLUDED.tag").
+			Insert()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Model(&TagAlias{}).
+			Set("tag = ?", resolved.Tag).
+			Where("tag = ?", resolved.Alias).
+			Update()
+		return err
+	})
+	if err == ErrorTagInvalid || err == ErrorTagAliasCycle {
+		return resolved, err
+	}
+	if err != nil {
+		return resolved, errors.Wrap(err, "failed to save tag alias")
+	}
+
+	return resolved, nil
+}
+
+func (repo *postgresVideoRepo) DeleteTagAlias(alias string) error {
+	result, err := repo.db.Model(&TagAlias{Alias: cleanTag(alias)}).WherePK().Delete()
+	if err != nil {
+		return errors.Wrap(err, "failed to delete tag alias")
+	}
+	if result.RowsAffected() == 0 {
+		return ErrorTagAliasNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,782 @@
+package videostore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// sqliteVideoRepo stores models to an embedded SQLite DB
+type sqliteVideoRepo struct {
+	db *sql.DB
+}
+
+// tags live in their own table so they can be indexed,
+// position keeps them in the order they were given
+const sqliteVideoSchema = `
+CREATE TABLE IF NOT EXISTS videos (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	thumbnail TEXT NOT NULL DEFAULT '',
+	source TEXT NOT NULL DEFAULT '',
+	original_file_name TEXT NOT NULL DEFAULT '',
+	time_created TEXT NOT NULL DEFAULT '',
+	time_updated TEXT NOT NULL DEFAULT '',
+	duration REAL NOT NULL DEFAULT 0,
+	width INTEGER NOT NULL DEFAULT 0,
+	height INTEGER NOT NULL DEFAULT 0,
+	video_codec TEXT NOT NULL DEFAULT '',
+	audio_codec TEXT NOT NULL DEFAULT '',
+	bitrate INTEGER NOT NULL DEFAULT 0,
+	size INTEGER NOT NULL DEFAULT 0,
+	hls_playlist TEXT NOT NULL DEFAULT '',
+	renditions TEXT NOT NULL DEFAULT '[]',
+	sprites TEXT NOT NULL DEFAULT '',
+	preview TEXT NOT NULL DEFAULT '',
+	owner_id INTEGER NOT NULL DEFAULT 0,
+	visibility TEXT NOT NULL DEFAULT 'public',
+	fallback TEXT NOT NULL DEFAULT '',
+	custom_thumbnail INTEGER NOT NULL DEFAULT 0,
+	thumbnail_timestamp REAL
+);
+CREATE INDEX IF NOT EXISTS videos_title ON videos (title COLLATE NOCASE);
+CREATE INDEX IF NOT EXISTS videos_time_created ON videos (time_created);
+CREATE TABLE IF NOT EXISTS video_tags (
+	video_id INTEGER NOT NULL REFERENCES videos (id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	tag TEXT NOT NULL,
+	PRIMARY KEY (video_id, position)
+);
+CREATE INDEX IF NOT EXISTS video_tags_tag ON video_tags (tag, video_id);
+CREATE VIRTUAL TABLE IF NOT EXISTS videos_search USING fts5 (title, tags, description);
+CREATE TABLE IF NOT EXISTS tag_aliases (
+	alias TEXT PRIMARY KEY,
+	tag TEXT NOT NULL
+);
+`
+
+// sqliteSearchRank weighs videos_search columns
+// the same as the dummy repo's search index
+const sqliteSearchRank = "bm25(videos_search, 3.0, 2.0, 1.0)"
+
+// sqliteSearchBackfill indexes videos saved before search existed
+const sqliteSearchBackfill = `
+INSERT INTO videos_search (rowid, title, tags, description)
+SELECT id, title, COALESCE((SELECT group_concat(tag, ' ') FROM video_tags WHERE video_id = videos.id), ''), description
+FROM videos
+WHERE id NOT IN (SELECT rowid FROM videos_search)
+`
+
+const sqliteVideoColumns = `id, title, description, thumbnail, source, original_file_name,
+	time_created, time_updated, duration, width, height, video_codec, audio_codec,
+	bitrate, size, hls_playlist, renditions, sprites, preview, owner_id, visibility, fallback,
+	custom_thumbnail, thumbnail_timestamp`
+
+func NewSQLiteVideoRepo(db *sql.DB) *sqliteVideoRepo {
+	if _, err := db.Exec(sqliteVideoSchema); err != nil {
+		log.Fatalf("failed to create table: %+v", err)
+	}
+
+	if err := sqliteAddColumn(db, "videos", "owner_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		log.Fatalf("failed to add video owners: %+v", err)
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS videos_owner_id ON videos (owner_id)"); err != nil {
+		log.Fatalf("failed to index video owners: %+v", err)
+	}
+	if err := sqliteAddColumn(db, "videos", "visibility", "TEXT NOT NULL DEFAULT 'public'"); err != nil {
+		log.Fatalf("failed to add video visibility: %+v", err)
+	}
+	if err := sqliteAddColumn(db, "videos", "fallback", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		log.Fatalf("failed to add video hls fallback: %+v", err)
+	}
+	if err := sqliteAddColumn(db, "videos", "custom_thumbnail", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		log.Fatalf("failed to add video custom thumbnails: %+v", err)
+	}
+	if err := sqliteAddColumn(db, "videos", "thumbnail_timestamp", "REAL"); err != nil {
+		log.Fatalf("failed to add video thumbnail timestamps: %+v", err)
+	}
+
+	if _, err := db.Exec(sqliteSearchBackfill); err != nil {
+		log.Fatalf("failed to index videos for search: %+v", err)
+	}
+
+	return &sqliteVideoRepo{
+		db,
+	}
+}
+
+type sqliteScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSQLiteVideo(row sqliteScanner) (Video, error) {
+	var video Video
+	var renditions string
+	var thumbnailTimestamp sql.NullFloat64
+
+	err := row.Scan(
+		&video.ID,
+		&video.Title,
+		&video.Description,
+		&video.Thumbnail,
+		&video.Source,
+		&video.OriginalFileName,
+		&video.TimeCreated,
+		&video.TimeUpdated,
+		&video.Duration,
+		&video.Width,
+		&video.Height,
+		&video.VideoCodec,
+		&video.AudioCodec,
+		&video.Bitrate,
+		&video.Size,
+		&video.HLSPlaylist,
+		&renditions,
+		&video.Sprites,
+		&video.Preview,
+		&video.OwnerID,
+		&video.Visibility,
+		&video.Fallback,
+		&video.CustomThumbnail,
+		&thumbnailTimestamp,
+	)
+	if err != nil {
+		return video, err
+	}
+
+	if thumbnailTimestamp.Valid {
+		video.ThumbnailTimestamp = &thumbnailTimestamp.Float64
+	}
+
+	if err := json.Unmarshal([]byte(renditions), &video.Renditions); err != nil {
+		return video, errors.Wrap(err, "failed to decode renditions")
+	}
+
+	return video, nil
+}
+
+// loadTags fills in the tags of each video
+func (repo *sqliteVideoRepo) loadTags(videos []Video) error {
+	if len(videos) == 0 {
+		return nil
+	}
+
+	byID := make(map[uint]*Video, len(videos))
+	placeholders := make([]string, len(videos))
+	args := make([]interface{}, len(videos))
+	for i := range videos {
+		byID[videos[i].ID] = &videos[i]
+		placeholders[i] = "?"
+		args[i] = videos[i].ID
+	}
+
+	rows, err := repo.db.Query(
+		"SELECT video_id, tag FROM video_tags WHERE video_id IN ("+strings.Join(placeholders, ", ")+") ORDER BY video_id, position",
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var videoID uint
+		var tag string
+		if err := rows.Scan(&videoID, &tag); err != nil {
+			return err
+		}
+		video := byID[videoID]
+		video.Tags = append(video.Tags, tag)
+	}
+
+	return rows.Err()
+}
+
+func (repo *sqliteVideoRepo) FindById(id uint) (Video, error) {
+	video, err := scanSQLiteVideo(repo.db.QueryRow("SELECT "+sqliteVideoColumns+" FROM videos WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return Video{ID: id}, ErrorVideoNotFound
+	}
+	if err != nil {
+		return video, err
+	}
+
+	videos := []Video{video}
+	err = repo.loadTags(videos)
+
+	return videos[0], err
+}
+
+// escapeLike makes user input safe to use in a LIKE pattern
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// sqliteHasAllTags matches videos tagged with every one of tags,
+// or with a tag nested below it like animals/cats for animals.
+// Nested tags sort between "animals/" and "animals0",
+// '0' being the character after the separator.
+func sqliteHasAllTags(tags []string) (string, []interface{}) {
+	unique := make(map[string]bool, len(tags))
+	conditions := make([]string, 0, len(tags))
+	args := make([]interface{}, 0, 3*len(tags))
+	for _, tag := range tags {
+		if unique[tag] {
+			continue
+		}
+		unique[tag] = true
+		conditions = append(conditions, "id IN (SELECT video_id FROM video_tags WHERE tag = ? OR (tag >= ? AND tag < ?))")
+		args = append(args, tag, tag+TagSeparator, tag+string(TagSeparator[0]+1))
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// sqliteSearchQuery quotes every word of text so none of them
+// are taken as FTS5 syntax, they are then all required to match
+func sqliteSearchQuery(text string) string {
+	tokens := tokenize(text)
+	for i, token := range tokens {
+		tokens[i] = `"` + token + `"`
+	}
+	return strings.Join(tokens, " ")
+}
+
+// sqliteQueryCondition compiles node into a WHERE condition,
+// terms behave like the text filters in sqliteVideoFilter
+func sqliteQueryCondition(node QueryNode) (string, []interface{}) {
+	switch node.Op {
+	case QueryAnd, QueryOr:
+		conditions := make([]string, len(node.Children))
+		var args []interface{}
+		for i, child := range node.Children {
+			condition, childArgs := sqliteQueryCondition(child)
+			conditions[i] = condition
+			args = append(args, childArgs...)
+		}
+		return "(" + strings.Join(conditions, " "+strings.ToUpper(node.Op)+" ") + ")", args
+	case QueryNot:
+		condition, args := sqliteQueryCondition(node.Children[0])
+		return "NOT " + condition, args
+	}
+
+	title := `title LIKE ? ESCAPE '\'`
+	titleArg := "%" + escapeLike(node.Value) + "%"
+	tag, tagArgs := sqliteHasAllTags([]string{node.Value})
+
+	switch node.Field {
+	case QueryFieldTitle:
+		return "(" + title + ")", []interface{}{titleArg}
+	case QueryFieldTag:
+		return "(" + tag + ")", tagArgs
+	}
+	return "(" + title + " OR " + tag + ")", append([]interface{}{titleArg}, tagArgs...)
+}
+
+// sqliteVideoFilter builds the WHERE clause for filter,
+// following the same rules as the other repos:
+// any one text filter is enough, all media filters must match
+func sqliteVideoFilter(filter VideoFilter) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.hasText() {
+		var textConditions []string
+
+		if len(filter.Title) > 0 {
+			textConditions = append(textConditions, `title LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+escapeLike(filter.Title)+"%")
+		}
+
+		if len(filter.Tags) > 0 {
+			condition, tagArgs := sqliteHasAllTags(filter.Tags)
+			textConditions = append(textConditions, condition)
+			args = append(args, tagArgs...)
+		}
+
+		if len(filter.Any) > 0 {
+			textConditions = append(textConditions, `title LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+escapeLike(filter.Any)+"%")
+
+			condition, tagArgs := sqliteHasAllTags([]string{filter.Any})
+			textConditions = append(textConditions, condition)
+			args = append(args, tagArgs...)
+		}
+
+		conditions = append(conditions, "("+strings.Join(textConditions, " OR ")+")")
+	}
+
+	if filter.hasSearch() {
+		conditions = append(conditions, "id IN (SELECT rowid FROM videos_search WHERE videos_search MATCH ?)")
+		args = append(args, sqliteSearchQuery(filter.Text))
+	}
+
+	if filter.Query != nil {
+		condition, queryArgs := sqliteQueryCondition(*filter.Query)
+		conditions = append(conditions, condition)
+		args = append(args, queryArgs...)
+	}
+
+	if filter.MinDuration > 0 {
+		conditions = append(conditions, "duration >= ?")
+		args = append(args, filter.MinDuration)
+	}
+
+	if filter.MaxDuration > 0 {
+		conditions = append(conditions, "duration <= ?")
+		args = append(args, filter.MaxDuration)
+	}
+
+	if filter.MinHeight > 0 {
+		conditions = append(conditions, "height >= ?")
+		args = append(args, filter.MinHeight)
+	}
+
+	if filter.OwnerID != 0 {
+		conditions = append(conditions, "owner_id = ?")
+		args = append(args, filter.OwnerID)
+	}
+
+	if filter.Listed {
+		conditions = append(conditions, "source <> ''")
+		if filter.ViewerID != 0 {
+			conditions = append(conditions, "(visibility = ? OR owner_id = ?)")
+			args = append(args, VisibilityPublic, filter.ViewerID)
+		} else {
+			conditions = append(conditions, "visibility = ?")
+			args = append(args, VisibilityPublic)
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (repo *sqliteVideoRepo) All(filter VideoFilter, limit uint, offset uint) ([]Video, error) {
+	query := "SELECT " + sqliteVideoColumns + " FROM videos"
+
+	where, args := sqliteVideoFilter(filter)
+	query += where
+
+	if filter.Sort() {
+		if !filter.ValidSortField() {
+			return nil, fmt.Errorf("invalid sort field %v", filter.SortField)
+		}
+
+		if !filter.ValidSortDirection() {
+			return nil, fmt.Errorf("invalid sort direction %v", filter.SortDirection)
+		}
+
+		order := fmt.Sprintf("%v %v, id", filter.SortField, filter.SortDirection)
+		if filter.SortField == SortFieldTitle {
+			order = fmt.Sprintf("title COLLATE NOCASE %v, id", filter.SortDirection)
+		}
+		if filter.SortField == SortFieldRelevance {
+			// nothing to rank by, show the newest first
+			order = "id DESC"
+			if filter.hasSearch() {
+				// bm25 is lower for better matches, flip it to line up with the other repos
+				order = fmt.Sprintf("(SELECT -%v FROM videos_search WHERE videos_search MATCH ? AND rowid = videos.id) %v, id DESC", sqliteSearchRank, filter.SortDirection)
+				args = append(args, sqliteSearchQuery(filter.Text))
+			}
+		}
+		query += " ORDER BY " + order
+	} else {
+		query += " ORDER BY id"
+	}
+
+	query += " LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	videos := make([]Video, 0)
+	for rows.Next() {
+		video, err := scanSQLiteVideo(rows)
+		if err != nil {
+			return nil, err
+		}
+		videos = append(videos, video)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	err = repo.loadTags(videos)
+
+	return videos, err
+}
+
+func (repo *sqliteVideoRepo) Count(filter VideoFilter) (uint, error) {
+	where, args := sqliteVideoFilter(filter)
+
+	var count uint
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM videos"+where, args...).Scan(&count)
+
+	return count, err
+}
+
+// writeSQLiteTags replaces the stored tags of video,
+// and reindexes it for search to match
+func writeSQLiteTags(tx *sql.Tx, video Video) error {
+	if _, err := tx.Exec("DELETE FROM video_tags WHERE video_id = ?", video.ID); err != nil {
+		return errors.Wrap(err, "failed to save tags")
+	}
+
+	for position, tag := range video.Tags {
+		if _, err := tx.Exec("INSERT INTO video_tags (video_id, position, tag) VALUES (?, ?, ?)", video.ID, position, tag); err != nil {
+			return errors.Wrap(err, "failed to save tags")
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM videos_search WHERE rowid = ?", video.ID); err != nil {
+		return errors.Wrap(err, "failed to index video for search")
+	}
+	_, err := tx.Exec(
+		"INSERT INTO videos_search (rowid, title, tags, description) VALUES (?, ?, ?, ?)",
+		video.ID, video.Title, strings.Join(video.Tags, " "), video.Description,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to index video for search")
+	}
+
+	return nil
+}
+
+func (repo *sqliteVideoRepo) Save(video Video) (Video, error) {
+	return repo.save(video, false)
+}
+
+func (repo *sqliteVideoRepo) Import(video Video) (Video, error) {
+	return repo.save(video, true)
+}
+
+// save updates video if it exists, otherwise it's inserted.
+// Imported videos are always inserted, keeping their ID and timestamps.
+func (repo *sqliteVideoRepo) save(video Video, imported bool) (Video, error) {
+	if err := validateVisibility(&video); err != nil {
+		return video, err
+	}
+
+	renditions, err := json.Marshal(video.Renditions)
+	if err != nil {
+		return video, errors.Wrap(err, "failed to encode renditions")
+	}
+	if video.Renditions == nil {
+		renditions = []byte("[]")
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return video, err
+	}
+	defer tx.Rollback()
+
+	aliases, err := sqliteTagAliases(tx)
+	if err != nil {
+		return video, errors.Wrap(err, "failed to load tag aliases")
+	}
+	video.Tags = normalizeTags(video.Tags, tagAliasMap(aliases))
+
+	now := time.Now().Format(time.RFC3339)
+	if !imported || video.TimeUpdated == "" {
+		video.TimeUpdated = now
+	}
+
+	values := []interface{}{
+		video.Title,
+		video.Description,
+		video.Thumbnail,
+		video.Source,
+		video.OriginalFileName,
+		video.TimeUpdated,
+		video.Duration,
+		video.Width,
+		video.Height,
+		video.VideoCodec,
+		video.AudioCodec,
+		video.Bitrate,
+		video.Size,
+		video.HLSPlaylist,
+		string(renditions),
+		video.Sprites,
+		video.Preview,
+		video.OwnerID,
+		video.Visibility,
+		video.Fallback,
+		video.CustomThumbnail,
+		video.ThumbnailTimestamp,
+	}
+
+	if video.Exists() && !imported {
+		result, err := tx.Exec(`UPDATE videos SET
+			title = ?, description = ?, thumbnail = ?, source = ?, original_file_name = ?,
+			time_updated = ?, duration = ?, width = ?, height = ?, video_codec = ?, audio_codec = ?,
+			bitrate = ?, size = ?, hls_playlist = ?, renditions = ?, sprites = ?, preview = ?,
+			owner_id = ?, visibility = ?, fallback = ?, custom_thumbnail = ?, thumbnail_timestamp = ?
+			WHERE id = ?`, append(values, video.ID)...)
+		if err != nil {
+			return video, err
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			return video, ErrorVideoNotFound
+		}
+
+	} else {
+		if !imported || video.TimeCreated == "" {
+			video.TimeCreated = now
+		}
+		// a NULL id picks the next one
+		var insertID interface{}
+		if imported && video.Exists() {
+			insertID = video.ID
+		}
+		result, err := tx.Exec(`INSERT INTO videos (
+			title, description, thumbnail, source, original_file_name,
+			time_updated, duration, width, height, video_codec, audio_codec,
+			bitrate, size, hls_playlist, renditions, sprites, preview, owner_id, visibility, fallback,
+			custom_thumbnail, thumbnail_timestamp, time_created, id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, append(values, video.TimeCreated, insertID)...)
+		if err != nil {
+			return video, err
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return video, err
+		}
+		video.ID = uint(id)
+	}
+
+	if err := writeSQLiteTags(tx, video); err != nil {
+		return video, err
+	}
+
+	return video, tx.Commit()
+}
+
+func (repo *sqliteVideoRepo) Delete(video Video) error {
+	_, err := repo.db.Exec("DELETE FROM videos WHERE id = ?", video.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete from db")
+	}
+
+	_, err = repo.db.Exec("DELETE FROM videos_search WHERE rowid = ?", video.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to remove from search index")
+	}
+
+	return nil
+}
+
+func (repo *sqliteVideoRepo) queryTagCounts(query string, args ...interface{}) ([]TagCount, error) {
+	rows, err := repo.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]TagCount, 0)
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// sqliteTagFilter limits tag counts to videos matching filter
+func sqliteTagFilter(filter VideoFilter) (string, []interface{}) {
+	where, args := sqliteVideoFilter(filter)
+	if where == "" {
+		return "1 = 1", nil
+	}
+	return "video_id IN (SELECT id FROM videos" + where + ")", args
+}
+
+func (repo *sqliteVideoRepo) Tags(filter VideoFilter) ([]TagCount, error) {
+	condition, args := sqliteTagFilter(filter)
+	return repo.queryTagCounts(
+		"SELECT tag, COUNT(DISTINCT video_id) AS count FROM video_tags WHERE "+condition+" GROUP BY tag ORDER BY count DESC, tag",
+		args...,
+	)
+}
+
+func (repo *sqliteVideoRepo) SuggestTags(filter VideoFilter, prefix string, limit uint) ([]TagCount, error) {
+	condition, args := sqliteTagFilter(filter)
+	return repo.queryTagCounts(
+		`SELECT tag, COUNT(DISTINCT video_id) AS count FROM video_tags WHERE tag LIKE ? ESCAPE '\' AND `+condition+` GROUP BY tag ORDER BY count DESC, tag LIMIT ?`,
+		append(append([]interface{}{escapeLike(prefix) + "%"}, args...), limit)...,
+	)
+}
+
+func (repo *sqliteVideoRepo) MergeTags(from []string, into string) (uint, error) {
+	ok, err := validateMerge(from, into)
+	if !ok {
+		return 0, err
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	placeholders := make([]string, len(from))
+	args := make([]interface{}, len(from))
+	for i, tag := range from {
+		placeholders[i] = "?"
+		args[i] = tag
+	}
+
+	rows, err := tx.Query(
+		"SELECT "+sqliteVideoColumns+" FROM videos WHERE id IN (SELECT video_id FROM video_tags WHERE tag IN ("+strings.Join(placeholders, ", ")+"))",
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var videos []Video
+	for rows.Next() {
+		video, err := scanSQLiteVideo(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		videos = append(videos, video)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	changed := uint(0)
+	now := time.Now().Format(time.RFC3339)
+	for _, video := range videos {
+		tagRows, err := tx.Query("SELECT tag FROM video_tags WHERE video_id = ? ORDER BY position", video.ID)
+		if err != nil {
+			return 0, err
+		}
+		for tagRows.Next() {
+			var tag string
+			if err := tagRows.Scan(&tag); err != nil {
+				tagRows.Close()
+				return 0, err
+			}
+			video.Tags = append(video.Tags, tag)
+		}
+		tagRows.Close()
+		if err := tagRows.Err(); err != nil {
+			return 0, err
+		}
+
+		tags, ok := mergeTags(video.Tags, from, into)
+		if !ok {
+			continue
+		}
+		video.Tags = tags
+
+		if _, err := tx.Exec("UPDATE videos SET time_updated = ? WHERE id = ?", now, video.ID); err != nil {
+			return 0, err
+		}
+		if err := writeSQLiteTags(tx, video); err != nil {
+			return 0, err
+		}
+		changed++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "failed to merge tags")
+	}
+
+	return changed, nil
+}
+
+type sqliteQueryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func sqliteTagAliases(db sqliteQueryer) ([]TagAlias, error) {
+	rows, err := db.Query("SELECT alias, tag FROM tag_aliases ORDER BY alias")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := make([]TagAlias, 0)
+	for rows.Next() {
+		var alias TagAlias
+		if err := rows.Scan(&alias.Alias, &alias.Tag); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, rows.Err()
+}
+
+func (repo *sqliteVideoRepo) TagAliases() ([]TagAlias, error) {
+	return sqliteTagAliases(repo.db)
+}
+
+func (repo *sqliteVideoRepo) SetTagAlias(alias string, tag string) (TagAlias, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return TagAlias{}, err
+	}
+	defer tx.Rollback()
+
+	aliases, err := sqliteTagAliases(tx)
+	if err != nil {
+		return TagAlias{}, err
+	}
+
+	resolved, _, err := resolveTagAlias(tagAliasMap(aliases), alias, tag)
+	if err != nil {
+		return resolved, err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO tag_aliases (alias, tag) VALUES (?, ?) ON CONFLICT (alias) DO UPDATE SET tag = excluded.tag",
+		resolved.Alias,
+		resolved.Tag,
+	)
+	if err != nil {
+		return resolved, err
+	}
+
+	if _, err := tx.Exec("UPDATE tag_aliases SET tag = ? WHERE tag = ?", resolved.Tag, resolved.Alias); err != nil {
+		return resolved, err
+	}
+
+	return resolved, tx.Commit()
+}
+
+func (repo *sqliteVideoRepo) DeleteTagAlias(alias string) error {
+	result, err := repo.db.Exec("DELETE FROM tag_aliases WHERE alias = ?", cleanTag(alias))
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrorTagAliasNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,308 @@
+package videostore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteVideoRepo(t *testing.T) {
+	root := "test-sqlite-videos"
+	assert.Nil(t, os.MkdirAll(root, os.ModePerm))
+	defer os.RemoveAll(root)
+
+	db, err := OpenSQLite(filepath.Join(root, "videos.sqlite"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	repo := NewSQLiteVideoRepo(db)
+
+	doggo, err := repo.Save(Video{
+		Title:      "Doggo Zoomies",
+		Tags:       []string{"dog", "funny"},
+		Duration:   30,
+		Height:     720,
+		Renditions: []int{360, 720},
+		Fallback:   "1/hls/fallback.mp4",
+		OwnerID:    7,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), doggo.ID)
+	assert.NotEmpty(t, doggo.TimeCreated)
+
+	cat, err := repo.Save(Video{Title: "cat 100%", Tags: []string{"cat", "funny"}, Duration: 300, Height: 1080})
+	assert.Nil(t, err)
+
+	_, err = repo.Save(Video{Title: "bird"})
+	assert.Nil(t, err)
+
+	found, err := repo.FindById(doggo.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "Doggo Zoomies", found.Title)
+	assert.Equal(t, []string{"dog", "funny"}, found.Tags)
+	assert.Equal(t, []int{360, 720}, found.Renditions)
+	assert.Equal(t, "1/hls/fallback.mp4", found.Fallback)
+	assert.Equal(t, uint(7), found.OwnerID)
+	assert.False(t, found.CustomThumbnail)
+	assert.Nil(t, found.ThumbnailTimestamp)
+
+	found, err = RequestThumbnailAt(found, repo, 12.5)
+	assert.Nil(t, err)
+	found, err = repo.FindById(doggo.ID)
+	assert.Nil(t, err)
+	assert.True(t, found.CustomThumbnail)
+	if assert.NotNil(t, found.ThumbnailTimestamp) {
+		assert.Equal(t, 12.5, *found.ThumbnailTimestamp)
+	}
+
+	_, err = repo.FindById(69)
+	assert.Equal(t, ErrorVideoNotFound, err)
+
+	titles := func(filter VideoFilter) []string {
+		videos, err := repo.All(filter, 10, 0)
+		assert.Nil(t, err)
+		titles := []string{}
+		for _, video := range videos {
+			titles = append(titles, video.Title)
+		}
+
+		count, err := repo.Count(filter)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(len(videos)), count)
+
+		return titles
+	}
+
+	assert.Equal(t, []string{"Doggo Zoomies", "cat 100%", "bird"}, titles(VideoFilter{}))
+	assert.Equal(t, []string{"Doggo Zoomies"}, titles(VideoFilter{Title: "doggo"}))
+	assert.Equal(t, []string{"cat 100%"}, titles(VideoFilter{Title: "100%"}))
+	assert.Equal(t, []string{}, titles(VideoFilter{Title: "1_0"}))
+	assert.Equal(t, []string{"Doggo Zoomies", "cat 100%"}, titles(VideoFilter{Tags: []string{"funny"}}))
+	assert.Equal(t, []string{"cat 100%"}, titles(VideoFilter{Tags: []string{"funny", "cat", "cat"}}))
+	assert.Equal(t, []string{"bird"}, titles(VideoFilter{Any: "IR"}))
+	assert.Equal(t, []string{"Doggo Zoomies"}, titles(VideoFilter{Any: "dog"}))
+	// text filters are ORed
+	assert.Equal(t, []string{"Doggo Zoomies", "bird"}, titles(VideoFilter{Title: "bird", Tags: []string{"dog"}}))
+	// media filters are ANDed
+	assert.Equal(t, []string{"cat 100%"}, titles(VideoFilter{Tags: []string{"funny"}, MinDuration: 60}))
+	assert.Equal(t, []string{"Doggo Zoomies"}, titles(VideoFilter{MinDuration: 1, MaxDuration: 60, MinHeight: 720}))
+	assert.Equal(t, []string{"Doggo Zoomies"}, titles(VideoFilter{OwnerID: 7}))
+	assert.Equal(t, []string{}, titles(VideoFilter{Tags: []string{"cat"}, OwnerID: 7}))
+
+	assert.Equal(t, []string{"bird", "cat 100%", "Doggo Zoomies"}, titles(VideoFilter{SortField: SortFieldTitle, SortDirection: SortDirectionAscending}))
+	assert.Equal(t, []string{"cat 100%", "Doggo Zoomies", "bird"}, titles(VideoFilter{SortField: SortFieldHeight, SortDirection: SortDirectionDescending}))
+
+	_, err = repo.All(VideoFilter{SortField: "id; DROP TABLE videos", SortDirection: SortDirectionAscending}, 10, 0)
+	assert.NotNil(t, err)
+
+	paged, err := repo.All(VideoFilter{}, 1, 1)
+	assert.Nil(t, err)
+	assert.Len(t, paged, 1)
+	assert.Equal(t, cat.ID, paged[0].ID)
+
+	// tags are replaced on update
+	cat.Tags = []string{"kitty"}
+	_, err = repo.Save(cat)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Doggo Zoomies"}, titles(VideoFilter{Tags: []string{"funny"}}))
+	assert.Equal(t, []string{"cat 100%"}, titles(VideoFilter{Tags: []string{"kitty"}}))
+
+	assert.Nil(t, repo.Delete(cat))
+	_, err = repo.FindById(cat.ID)
+	assert.Equal(t, ErrorVideoNotFound, err)
+	assert.Equal(t, []string{}, titles(VideoFilter{Tags: []string{"kitty"}}))
+
+	_, err = repo.Save(cat)
+	assert.Equal(t, ErrorVideoNotFound, err)
+}
+
+func TestSQLiteAddColumn(t *testing.T) {
+	root := "test-sqlite-add-column"
+	assert.Nil(t, os.MkdirAll(root, os.ModePerm))
+	defer os.RemoveAll(root)
+
+	db, err := OpenSQLite(filepath.Join(root, "videos.sqlite"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	// users created before roles existed
+	_, err = db.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, username TEXT NOT NULL UNIQUE, password_hash TEXT NOT NULL DEFAULT '', time_created TEXT NOT NULL DEFAULT '', time_updated TEXT NOT NULL DEFAULT '')")
+	assert.Nil(t, err)
+	_, err = db.Exec("INSERT INTO users (username) VALUES ('alice')")
+	assert.Nil(t, err)
+
+	repo := NewSQLiteUserRepo(db)
+	alice, err := repo.FindByUsername("alice")
+	assert.Nil(t, err)
+	assert.Equal(t, RoleAdmin, alice.Role)
+
+	// running it again changes nothing
+	assert.Nil(t, sqliteAddColumn(db, "users", "role", "TEXT NOT NULL DEFAULT 'admin'"))
+
+	bob, err := repo.Save(User{Username: "bob"})
+	assert.Nil(t, err)
+	assert.Equal(t, RoleViewer, bob.Role)
+}
+
+func TestSQLiteJobRepo(t *testing.T) {
+	root := "test-sqlite-jobs"
+	assert.Nil(t, os.MkdirAll(root, os.ModePerm))
+	defer os.RemoveAll(root)
+
+	db, err := OpenSQLite(filepath.Join(root, "jobs.sqlite"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	repo := NewSQLiteJobRepo(db)
+	now := time.Now()
+
+	later, err := repo.Save(Job{Kind: JobKindSprites, VideoID: 1, Status: JobStatusPending, RunAfter: now.Add(time.Hour)})
+	assert.Nil(t, err)
+	due, err := repo.Save(Job{Kind: JobKindProbe, VideoID: 1, Status: JobStatusPending, RunAfter: now.Add(-time.Minute)})
+	assert.Nil(t, err)
+
+	claimed, err := repo.Claim(now)
+	assert.Nil(t, err)
+	assert.Equal(t, due.ID, claimed.ID)
+	assert.Equal(t, JobStatusRunning, claimed.Status)
+	assert.Equal(t, 1, claimed.Attempts)
+
+	_, err = repo.Claim(now)
+	assert.Equal(t, ErrorNoJobs, err)
+
+	assert.Nil(t, repo.Requeue())
+	found, err := repo.FindById(due.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, JobStatusPending, found.Status)
+
+	claimed, err = repo.Claim(now.Add(2 * time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, due.ID, claimed.ID)
+	assert.Equal(t, 2, claimed.Attempts)
+
+	jobs, err := repo.All(JobFilter{VideoID: 1}, 10, 0)
+	assert.Nil(t, err)
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, due.ID, jobs[0].ID)
+	assert.Equal(t, later.ID, jobs[1].ID)
+	assert.True(t, jobs[1].RunAfter.Equal(later.RunAfter))
+
+	jobs, err = repo.All(JobFilter{Kind: JobKindSprites}, 10, 0)
+	assert.Nil(t, err)
+	assert.Len(t, jobs, 1)
+
+	_, err = repo.FindById(69)
+	assert.Equal(t, ErrorJobNotFound, err)
+}
+
+func TestSQLiteVideoRepoSearch(t *testing.T) {
+	root := "test-sqlite-search"
+	assert.Nil(t, os.MkdirAll(root, os.ModePerm))
+	defer os.RemoveAll(root)
+
+	db, err := OpenSQLite(filepath.Join(root, "videos.sqlite"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	repo := NewSQLiteVideoRepo(db)
+
+	first, err := repo.Save(Video{Title: "ok", Description: "good dog"})
+	assert.Nil(t, err)
+	second, err := repo.Save(Video{Title: "good dog", Tags: []string{"dog"}, Height: 1080})
+	assert.Nil(t, err)
+	third, err := repo.Save(Video{Title: "cat", Description: `"quoted" OR NOT`})
+	assert.Nil(t, err)
+
+	filter := VideoFilter{Text: "DOG good", SortField: SortFieldRelevance, SortDirection: SortDirectionDescending}
+	videos, err := repo.All(filter, 10, 0)
+	assert.Nil(t, err)
+	assert.Len(t, videos, 2)
+	assert.Equal(t, second.ID, videos[0].ID)
+	assert.Equal(t, first.ID, videos[1].ID)
+
+	// without search terms, relevance falls back to newest first
+	videos, err = repo.All(VideoFilter{SortField: SortFieldRelevance, SortDirection: SortDirectionAscending}, 10, 0)
+	assert.Nil(t, err)
+	assert.Len(t, videos, 3)
+	assert.Equal(t, third.ID, videos[0].ID)
+	assert.Equal(t, first.ID, videos[2].ID)
+
+	count, err := repo.Count(VideoFilter{Text: "dog", MinHeight: 720})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), count)
+
+	// search syntax is taken literally
+	count, err = repo.Count(VideoFilter{Text: `"quoted" OR`})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), count)
+
+	// edits are reindexed
+	second.Title = "renamed"
+	second.Tags = nil
+	_, err = repo.Save(second)
+	assert.Nil(t, err)
+	count, err = repo.Count(VideoFilter{Text: "dog"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), count)
+
+	assert.Nil(t, repo.Delete(first))
+	count, err = repo.Count(VideoFilter{Text: "dog"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), count)
+
+	// videos saved before the search index existed get indexed on startup
+	_, err = db.Exec("DELETE FROM videos_search")
+	assert.Nil(t, err)
+	repo = NewSQLiteVideoRepo(db)
+	count, err = repo.Count(VideoFilter{Text: "renamed"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), count)
+}
+
+func TestSQLiteVideoRepoImport(t *testing.T) {
+	root := "test-sqlite-import"
+	assert.Nil(t, os.MkdirAll(root, os.ModePerm))
+	defer os.RemoveAll(root)
+
+	db, err := OpenSQLite(filepath.Join(root, "videos.sqlite"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	repo := NewSQLiteVideoRepo(db)
+
+	imported, err := repo.Import(Video{ID: 5, Title: "old dog", Tags: []string{"dog"}, TimeCreated: "2019-01-02T03:04:05Z"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(5), imported.ID)
+
+	found, err := repo.FindById(5)
+	assert.Nil(t, err)
+	assert.Equal(t, "old dog", found.Title)
+	assert.Equal(t, "2019-01-02T03:04:05Z", found.TimeCreated)
+	count, err := repo.Count(VideoFilter{Text: "dog"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), count)
+
+	// new videos carry on after imported ones
+	saved, err := repo.Save(Video{Title: "new dog"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(6), saved.ID)
+
+	_, err = repo.Import(Video{ID: 5, Title: "duplicate"})
+	assert.NotNil(t, err)
+
+	users := NewSQLiteUserRepo(db)
+	user, err := users.Import(User{ID: 3, Username: "ursula", Role: RoleUploader})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(3), user.ID)
+	foundUser, err := users.FindByUsername("ursula")
+	assert.Nil(t, err)
+	assert.Equal(t, uint(3), foundUser.ID)
+	user, err = users.Save(User{Username: "victor"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(4), user.ID)
+	_, err = users.Import(User{ID: 9, Username: "victor"})
+	assert.Equal(t, ErrorUsernameTaken, err)
+}
@@ -2,8 +2,10 @@ package videostore
 
 import (
 	"encoding/json"
+	"os"
 	"testing"
 
+	"github.com/AlbinoDrought/creamy-videos/files"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -126,6 +128,61 @@ func TestVideoFilter_Empty(t *testing.T) {
 	}
 }
 
+func TestDummyVideoRepoOwnerFilter(t *testing.T) {
+	root := "test-dummy-owner-filter"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := NewDummyVideoRepo(fs)
+	mine, err := repo.Save(Video{Title: "mine", Tags: []string{"dog"}, OwnerID: 7})
+	assert.Nil(t, err)
+	_, err = repo.Save(Video{Title: "theirs", Tags: []string{"dog"}, OwnerID: 8})
+	assert.Nil(t, err)
+	_, err = repo.Save(Video{Title: "nobody's", Tags: []string{"dog"}})
+	assert.Nil(t, err)
+
+	assert.False(t, VideoFilter{OwnerID: 7}.Empty())
+
+	videos, err := repo.All(VideoFilter{OwnerID: 7}, 10, 0)
+	assert.Nil(t, err)
+	assert.Len(t, videos, 1)
+	assert.Equal(t, mine.ID, videos[0].ID)
+
+	count, err := repo.Count(VideoFilter{Tags: []string{"dog"}, OwnerID: 7})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), count)
+
+	count, err = repo.Count(VideoFilter{Tags: []string{"cat"}, OwnerID: 7})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), count)
+}
+
+func Test_updateVideo(t *testing.T) {
+	root := "test-update-video"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := NewDummyVideoRepo(fs)
+	video, err := repo.Save(Video{Title: "doggo"})
+	assert.Nil(t, err)
+
+	// another job finished first
+	_, err = updateVideo(repo, video.ID, func(video *Video) {
+		video.Thumbnail = "1/thumbnail.jpg"
+	})
+	assert.Nil(t, err)
+
+	updated, err := updateVideo(repo, video.ID, func(video *Video) {
+		video.Duration = 30
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "1/thumbnail.jpg", updated.Thumbnail)
+	assert.Equal(t, float64(30), updated.Duration)
+
+	_, err = updateVideo(repo, 69, func(video *Video) {})
+	assert.Equal(t, ErrorVideoNotFound, err)
+}
+
 func TestVideo_Exists(t *testing.T) {
 	type fields struct {
 		ID               uint
@@ -184,3 +241,24 @@ func TestVideo_Exists(t *testing.T) {
 		})
 	}
 }
+
+func TestVideoFiles(t *testing.T) {
+	assert.Equal(t, []string{"/1/foo.mp4"}, VideoFiles(Video{Source: "/1/foo.mp4"}))
+
+	video := Video{
+		Source:      "/1/foo.mp4",
+		Thumbnail:   "/1/thumbnail.jpg",
+		Preview:     "/1/preview.mp4",
+		Sprites:     "/1/sprites.vtt",
+		HLSPlaylist: "/1/hls/master.m3u8",
+		Fallback:    "/1/hls/fallback.mp4",
+	}
+	assert.Equal(t, []string{
+		"/1/foo.mp4",
+		"/1/thumbnail.jpg",
+		"/1/preview.mp4",
+		"/1/sprites.vtt",
+		"/1/" + spriteSheetFile,
+		"/1/hls",
+	}, VideoFiles(video))
+}
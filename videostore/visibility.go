@@ -0,0 +1,53 @@
+package videostore
+
+import "errors"
+
+// VisibilityPublic videos are listed for everyone
+const VisibilityPublic = "public"
+
+// VisibilityUnlisted videos can be watched by anyone with the link,
+// but aren't listed, searchable or in the sitemap
+const VisibilityUnlisted = "unlisted"
+
+// VisibilityPrivate videos can only be watched by their owner,
+// and anyone who may edit any video
+const VisibilityPrivate = "private"
+
+var Visibilities = []string{
+	VisibilityPublic,
+	VisibilityUnlisted,
+	VisibilityPrivate,
+}
+
+var ErrorVisibilityInvalid = errors.New("visibility must be one of public, unlisted or private")
+
+// ValidVisibility reports whether visibility is one of Visibilities
+func ValidVisibility(visibility string) bool {
+	for _, v := range Visibilities {
+		if v == visibility {
+			return true
+		}
+	}
+	return false
+}
+
+// validateVisibility makes videos public
+// if they don't say otherwise
+func validateVisibility(video *Video) error {
+	if video.Visibility == "" {
+		video.Visibility = VisibilityPublic
+	}
+	if !ValidVisibility(video.Visibility) {
+		return ErrorVisibilityInvalid
+	}
+	return nil
+}
+
+// videoListedFor reports whether video shows up in
+// listings for viewerID, see VideoFilter.Listed
+func videoListedFor(video Video, viewerID uint) bool {
+	if video.Source == "" {
+		return false
+	}
+	return video.Visibility == VisibilityPublic || (viewerID != 0 && video.OwnerID == viewerID)
+}
@@ -0,0 +1,79 @@
+package videostore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/stretchr/testify/assert"
+)
+
+// testVideoVisibility runs the same checks against every repo
+func testVideoVisibility(t *testing.T, repo VideoRepo) {
+	public, err := repo.Save(Video{Title: "public", Source: "/public.mp4", OwnerID: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, VisibilityPublic, public.Visibility)
+
+	_, err = repo.Save(Video{Title: "unlisted", Source: "/unlisted.mp4", OwnerID: 1, Visibility: VisibilityUnlisted})
+	assert.Nil(t, err)
+	private, err := repo.Save(Video{Title: "private", Source: "/private.mp4", OwnerID: 2, Visibility: VisibilityPrivate})
+	assert.Nil(t, err)
+
+	_, err = repo.Save(Video{Title: "secret", Visibility: "secret"})
+	assert.Equal(t, ErrorVisibilityInvalid, err)
+
+	found, err := repo.FindById(private.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, VisibilityPrivate, found.Visibility)
+
+	titles := func(filter VideoFilter) []string {
+		filter.SortField = SortFieldTitle
+		filter.SortDirection = SortDirectionAscending
+		videos, err := repo.All(filter, 10, 0)
+		assert.Nil(t, err)
+		titles := []string{}
+		for _, video := range videos {
+			titles = append(titles, video.Title)
+		}
+
+		count, err := repo.Count(filter)
+		assert.Nil(t, err)
+		assert.Equal(t, uint(len(videos)), count)
+
+		return titles
+	}
+
+	assert.Equal(t, []string{"private", "public", "unlisted"}, titles(VideoFilter{}))
+	assert.Equal(t, []string{"public"}, titles(VideoFilter{Listed: true}))
+	assert.Equal(t, []string{"public", "unlisted"}, titles(VideoFilter{Listed: true, ViewerID: 1}))
+	assert.Equal(t, []string{"private", "public"}, titles(VideoFilter{Listed: true, ViewerID: 2}))
+	assert.Equal(t, []string{}, titles(VideoFilter{Listed: true, OwnerID: 2}))
+	assert.Equal(t, []string{"private"}, titles(VideoFilter{Listed: true, ViewerID: 2, OwnerID: 2}))
+
+	// videos still being uploaded aren't listed, even to their owner
+	_, err = repo.Save(Video{Title: "uploading", OwnerID: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"public", "unlisted"}, titles(VideoFilter{Listed: true, ViewerID: 1}))
+	assert.Equal(t, []string{"private", "public", "unlisted", "uploading"}, titles(VideoFilter{}))
+}
+
+func TestDummyVideoVisibility(t *testing.T) {
+	root := "test-dummy-visibility"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	testVideoVisibility(t, NewDummyVideoRepo(fs))
+}
+
+func TestSQLiteVideoVisibility(t *testing.T) {
+	root := "test-sqlite-visibility"
+	assert.Nil(t, os.MkdirAll(root, os.ModePerm))
+	defer os.RemoveAll(root)
+
+	db, err := OpenSQLite(filepath.Join(root, "videos.sqlite"))
+	assert.Nil(t, err)
+	defer db.Close()
+
+	testVideoVisibility(t, NewSQLiteVideoRepo(db))
+}
@@ -9,9 +9,9 @@ import (
 	"path"
 	"runtime/debug"
 	"strconv"
-	"strings"
 
 	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/jobs"
 	"github.com/AlbinoDrought/creamy-videos/ui2/tmpl"
 	"github.com/AlbinoDrought/creamy-videos/videostore"
 	"github.com/gorilla/mux"
@@ -30,7 +30,20 @@ type CreamyVideosAPI interface {
 
 	ShowVideo(w http.ResponseWriter, r *http.Request)
 	EditVideo(w http.ResponseWriter, r *http.Request)
+	EditThumbnail(w http.ResponseWriter, r *http.Request)
 	DeleteVideo(w http.ResponseWriter, r *http.Request)
+
+	ListJobs(w http.ResponseWriter, r *http.Request)
+	ShowJob(w http.ResponseWriter, r *http.Request)
+
+	ListTags(w http.ResponseWriter, r *http.Request)
+	SuggestTags(w http.ResponseWriter, r *http.Request)
+	RenameTag(w http.ResponseWriter, r *http.Request)
+	MergeTags(w http.ResponseWriter, r *http.Request)
+
+	ListTagAliases(w http.ResponseWriter, r *http.Request)
+	SetTagAlias(w http.ResponseWriter, r *http.Request)
+	DeleteTagAlias(w http.ResponseWriter, r *http.Request)
 }
 
 func writeJSON(w http.ResponseWriter, thing any) {
@@ -42,6 +55,7 @@ type api struct {
 	PublicURL tmpl.PublicURLGenerator
 	FS        files.FileSystem
 	Repo      videostore.VideoRepo
+	Jobs      *jobs.Queue
 }
 
 func (a *api) transformVideo(video videostore.Video) videostore.Video {
@@ -49,6 +63,18 @@ func (a *api) transformVideo(video videostore.Video) videostore.Video {
 	if len(video.Thumbnail) > 0 {
 		video.Thumbnail = a.PublicURL(video.Thumbnail)
 	}
+	if len(video.Preview) > 0 {
+		video.Preview = a.PublicURL(video.Preview)
+	}
+	if len(video.Sprites) > 0 {
+		video.Sprites = a.PublicURL(video.Sprites)
+	}
+	if len(video.HLSPlaylist) > 0 {
+		video.HLSPlaylist = a.PublicURL(video.HLSPlaylist)
+	}
+	if len(video.Fallback) > 0 {
+		video.Fallback = a.PublicURL(video.Fallback)
+	}
 	return video
 }
 
@@ -67,7 +93,12 @@ func (a *api) ListVideos(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
-	filter := videoFilterFromDict(r.URL.Query())
+	filter, err := videoFilterFromDict(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	filter = listedFor(r, filter)
 
 	videos, err := a.Repo.All(filter, uint(limit), uint(offset))
 	if err != nil {
@@ -79,6 +110,9 @@ func (a *api) ListVideos(w http.ResponseWriter, r *http.Request) {
 	transformedVideos := make([]videostore.Video, len(videos))
 	for i, video := range videos {
 		transformedVideos[i] = a.transformVideo(video)
+		if filter.Text != "" {
+			transformedVideos[i].Snippet = videostore.Snippet(video, filter.Text)
+		}
 	}
 
 	writeJSON(w, transformedVideos)
@@ -94,15 +128,7 @@ func (a *api) UploadVideo(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.MultipartForm.RemoveAll()
 
-	// convert "foo, bar" and "foo,bar" into
-	// ["foo", "bar"]
-	tags := strings.Split(r.FormValue("tags"), ",")
-	for i, tag := range tags {
-		tags[i] = strings.Trim(tag, " ")
-	}
-	if len(tags) == 1 && tags[0] == "" {
-		tags = []string{}
-	}
+	tags := splitTags(r.FormValue("tags"))
 
 	file, header, err := r.FormFile("file")
 	if err != nil {
@@ -117,9 +143,18 @@ func (a *api) UploadVideo(w http.ResponseWriter, r *http.Request) {
 		Description:      r.FormValue("description"),
 		OriginalFileName: header.Filename,
 		Tags:             tags,
+		Visibility:       r.FormValue("visibility"),
+	}
+	if user, ok := CurrentUser(r); ok {
+		video.OwnerID = user.ID
 	}
 
 	video, err = a.Repo.Save(video)
+	if err == videostore.ErrorVisibilityInvalid {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
 
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -150,12 +185,7 @@ func (a *api) UploadVideo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	go func() {
-		_, err := videostore.GenerateThumbnail(video, a.Repo, a.FS)
-		if err != nil {
-			log.Printf("failed to make thumbnail: %+v", err)
-		}
-	}()
+	enqueueVideoProcessing(a.Jobs, video)
 
 	go debug.FreeOSMemory() // hack to request our memory back :'(
 
@@ -176,7 +206,7 @@ func (a *api) ShowVideo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	video, err := a.Repo.FindById(uint(id))
-	if err == videostore.ErrorVideoNotFound {
+	if err == videostore.ErrorVideoNotFound || (err == nil && !canView(r, video)) {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
@@ -218,8 +248,16 @@ func (a *api) EditVideo(w http.ResponseWriter, r *http.Request) {
 	video.Title = postedVideo.Title
 	video.Description = postedVideo.Description
 	video.Tags = postedVideo.Tags
+	// older clients don't know about visibility, leave it be
+	if postedVideo.Visibility != "" {
+		video.Visibility = postedVideo.Visibility
+	}
 
 	video, err = a.Repo.Save(video)
+	if err == videostore.ErrorVisibilityInvalid {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -230,6 +268,54 @@ func (a *api) EditVideo(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, a.transformVideo(video))
 }
 
+func (a *api) EditThumbnail(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	vars := mux.Vars(r)
+	rawID := vars["id"]
+	id, err := strconv.Atoi(rawID)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	video, err := a.Repo.FindById(uint(id))
+	if err == videostore.ErrorVideoNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error while retrieving video: %+v", err)
+		return
+	}
+
+	err = r.ParseMultipartForm(maxMultipartFormSize)
+	if err != nil && err != http.ErrNotMultipart {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+
+	video, changed, err := applyCustomThumbnail(r, video, a.Repo, a.FS, a.Jobs)
+	if customThumbnailClientError(err) || (err == nil && !changed) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error updating thumbnail: %+v", err)
+		return
+	}
+
+	writeJSON(w, a.transformVideo(video))
+}
+
 func (a *api) DeleteVideo(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
@@ -262,34 +348,25 @@ func (a *api) DeleteVideo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = a.FS.Stat(video.Source)
-	if !a.FS.IsNotExist(err) {
-		// video exists, attempt to delete
-		err = a.FS.Remove(video.Source)
-		if err != nil {
-			log.Print(errors.Wrap(err, "failed to remove video from disk"))
-		}
-	}
-
-	_, err = a.FS.Stat(video.Thumbnail)
-	if !a.FS.IsNotExist(err) {
-		// thumbnail exists, attempt to delete
-		err = a.FS.Remove(video.Thumbnail)
-		if err != nil {
-			log.Print(errors.Wrap(err, "failed to remove thumbnail from disk"))
-		}
+	if err := videostore.RemoveVideoFiles(video, a.FS); err != nil {
+		log.Print(errors.Wrap(err, "failed to remove video from disk"))
 	}
 
 	writeJSON(w, a.transformVideo(video))
 }
 
-func newAPI(PublicURL tmpl.PublicURLGenerator, FS files.FileSystem, Repo videostore.VideoRepo) CreamyVideosAPI {
-	return &api{PublicURL, FS, Repo}
+func newAPI(PublicURL tmpl.PublicURLGenerator, FS files.FileSystem, Repo videostore.VideoRepo, Jobs *jobs.Queue) CreamyVideosAPI {
+	return &api{PublicURL, FS, Repo, Jobs}
 }
 
-func NewWriteableAPI(PublicURL tmpl.PublicURLGenerator, FS files.FileSystem, Repo videostore.VideoRepo) http.Handler {
-	api := newAPI(PublicURL, FS, Repo)
+// NewWriteableAPI lets anyone read videos and tags, but only logged in users
+// make the changes their role allows. Jobs are only shown to editors.
+// Scripts can log in with API tokens.
+func NewWriteableAPI(PublicURL tmpl.PublicURLGenerator, FS files.FileSystem, Repo videostore.VideoRepo, Jobs *jobs.Queue, Auth *Auth) http.Handler {
+	api := newAPI(PublicURL, FS, Repo, Jobs)
+	authz := authorizer{Repo, denyAPI}
 	r := mux.NewRouter()
+	r.Use(Auth.TokenMiddleware)
 
 	r.HandleFunc(
 		"/api/video",
@@ -303,24 +380,101 @@ func NewWriteableAPI(PublicURL tmpl.PublicURLGenerator, FS files.FileSystem, Rep
 
 	r.HandleFunc(
 		"/api/video/{id:[0-9]+}",
-		api.EditVideo,
+		authz.RequireVideo(videostore.ScopeEdit, videostore.User.CanEdit, api.EditVideo),
 	).Methods("POST")
 
 	r.HandleFunc(
 		"/api/video/{id:[0-9]+}",
-		api.DeleteVideo,
+		authz.RequireVideo(videostore.ScopeDelete, videostore.User.CanDelete, api.DeleteVideo),
+	).Methods("DELETE")
+
+	r.HandleFunc(
+		"/api/video/{id:[0-9]+}/thumbnail",
+		authz.RequireVideo(videostore.ScopeEdit, videostore.User.CanEdit, api.EditThumbnail),
+	).Methods("POST")
+
+	r.HandleFunc(
+		"/api/jobs",
+		authz.Require(videostore.PermissionEditAny, authz.RequireScope(videostore.ScopeRead, api.ListJobs)),
+	).Methods("GET")
+
+	r.HandleFunc(
+		"/api/jobs/{id:[0-9]+}",
+		authz.Require(videostore.PermissionEditAny, authz.RequireScope(videostore.ScopeRead, api.ShowJob)),
+	).Methods("GET")
+
+	r.HandleFunc(
+		"/api/tags",
+		api.ListTags,
+	).Methods("GET")
+
+	r.HandleFunc(
+		"/api/tags/suggest",
+		api.SuggestTags,
+	).Methods("GET")
+
+	r.HandleFunc(
+		"/api/tags/rename",
+		authz.Require(videostore.PermissionEditAny, api.RenameTag),
+	).Methods("POST")
+
+	r.HandleFunc(
+		"/api/tags/merge",
+		authz.Require(videostore.PermissionEditAny, api.MergeTags),
+	).Methods("POST")
+
+	r.HandleFunc(
+		"/api/tags/aliases",
+		api.ListTagAliases,
+	).Methods("GET")
+
+	r.HandleFunc(
+		"/api/tags/aliases",
+		authz.Require(videostore.PermissionEditAny, api.SetTagAlias),
+	).Methods("POST")
+
+	r.HandleFunc(
+		"/api/tags/aliases/{alias:.+}",
+		authz.Require(videostore.PermissionEditAny, api.DeleteTagAlias),
 	).Methods("DELETE")
 
 	r.HandleFunc(
 		"/api/upload",
-		api.UploadVideo,
+		authz.Require(videostore.PermissionUpload, api.UploadVideo),
 	)
 
+	uploads := newTusUploads(FS, Repo, Jobs)
+
+	r.HandleFunc(
+		"/api/uploads",
+		uploads.Options,
+	).Methods("OPTIONS")
+
+	r.HandleFunc(
+		"/api/uploads",
+		authz.Require(videostore.PermissionUpload, uploads.Create),
+	).Methods("POST")
+
+	r.HandleFunc(
+		"/api/uploads/{uploadID:[0-9a-f]{32}}",
+		authz.Require(videostore.PermissionUpload, uploads.Head),
+	).Methods("HEAD")
+
+	r.HandleFunc(
+		"/api/uploads/{uploadID:[0-9a-f]{32}}",
+		authz.Require(videostore.PermissionUpload, uploads.Patch),
+	).Methods("PATCH")
+
+	r.HandleFunc(
+		"/api/uploads/{uploadID:[0-9a-f]{32}}",
+		authz.Require(videostore.PermissionUpload, uploads.Terminate),
+	).Methods("DELETE")
+
 	return r
 }
 
-func NewReadOnlyAPI(PublicURL tmpl.PublicURLGenerator, FS files.FileSystem, Repo videostore.VideoRepo) http.Handler {
-	api := newAPI(PublicURL, FS, Repo)
+func NewReadOnlyAPI(PublicURL tmpl.PublicURLGenerator, FS files.FileSystem, Repo videostore.VideoRepo, Jobs *jobs.Queue) http.Handler {
+	api := newAPI(PublicURL, FS, Repo, Jobs)
 	r := mux.NewRouter()
 
 	r.HandleFunc(
@@ -333,5 +487,20 @@ func NewReadOnlyAPI(PublicURL tmpl.PublicURLGenerator, FS files.FileSystem, Repo
 		api.ShowVideo,
 	).Methods("GET")
 
+	r.HandleFunc(
+		"/api/tags",
+		api.ListTags,
+	).Methods("GET")
+
+	r.HandleFunc(
+		"/api/tags/suggest",
+		api.SuggestTags,
+	).Methods("GET")
+
+	r.HandleFunc(
+		"/api/tags/aliases",
+		api.ListTagAliases,
+	).Methods("GET")
+
 	return r
 }
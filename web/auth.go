@@ -0,0 +1,398 @@
+package web
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+)
+
+const sessionCookieName = "creamy_session"
+
+type contextKey string
+
+const userContextKey = contextKey("user")
+const apiTokenContextKey = contextKey("api token")
+
+// Auth knows who is making a request.
+// Sessions are kept client-side in a signed cookie,
+// so nothing needs to be stored to log in.
+// Scripts can use API tokens instead, see TokenMiddleware.
+type Auth struct {
+	Users         videostore.UserRepo
+	Tokens        videostore.APITokenRepo
+	SessionKey    []byte
+	SessionTTL    time.Duration
+	SecureCookies bool
+
+	// ProxyHeader names a header holding the username,
+	// like X-Forwarded-User. It's only trusted on requests
+	// from ProxyCIDRs, since anyone else could send it too.
+	ProxyHeader string
+	ProxyCIDRs  []*net.IPNet
+
+	// OIDC logs users in through an SSO provider, if set
+	OIDC *OIDC
+
+	// ExternalRole is given to users the first time
+	// they're seen through ProxyHeader or OIDC
+	ExternalRole string
+}
+
+func NewAuth(users videostore.UserRepo, tokens videostore.APITokenRepo, sessionKey []byte, sessionTTL time.Duration, secureCookies bool) *Auth {
+	return &Auth{
+		Users:         users,
+		Tokens:        tokens,
+		SessionKey:    sessionKey,
+		SessionTTL:    sessionTTL,
+		SecureCookies: secureCookies,
+	}
+}
+
+// sessionMAC signs payload for user.
+// The password hash is mixed in so changing it logs out every session.
+func (a *Auth) sessionMAC(payload string, user videostore.User) []byte {
+	mac := hmac.New(sha256.New, a.SessionKey)
+	mac.Write([]byte(payload))
+	mac.Write([]byte{0})
+	mac.Write([]byte(user.PasswordHash))
+	return mac.Sum(nil)
+}
+
+// sessionValue looks like base64(id|expiry).base64(mac)
+func (a *Auth) sessionValue(user videostore.User, expires time.Time) string {
+	payload := fmt.Sprintf("%v|%v", user.ID, expires.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(a.sessionMAC(payload, user))
+}
+
+func (a *Auth) userFromSession(value string, now time.Time) (videostore.User, bool) {
+	encodedPayload, encodedMAC, ok := strings.Cut(value, ".")
+	if !ok {
+		return videostore.User{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return videostore.User{}, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return videostore.User{}, false
+	}
+
+	rawID, rawExpires, ok := strings.Cut(string(payload), "|")
+	if !ok {
+		return videostore.User{}, false
+	}
+	id, err := strconv.ParseUint(rawID, 10, 64)
+	if err != nil {
+		return videostore.User{}, false
+	}
+	expires, err := strconv.ParseInt(rawExpires, 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return videostore.User{}, false
+	}
+
+	user, err := a.Users.FindById(uint(id))
+	if err != nil {
+		return videostore.User{}, false
+	}
+	if !hmac.Equal(mac, a.sessionMAC(string(payload), user)) {
+		return videostore.User{}, false
+	}
+
+	return user, true
+}
+
+// externalUser finds the local user for someone who logged in
+// elsewhere, adding them if they're new.
+// They have no password, so they can only log in the same way again.
+func (a *Auth) externalUser(username string) (videostore.User, error) {
+	user, err := a.Users.FindByUsername(username)
+	if err != videostore.ErrorUserNotFound {
+		return user, err
+	}
+
+	user, err = a.Users.Save(videostore.User{Username: username, Role: a.ExternalRole})
+	if err == videostore.ErrorUsernameTaken {
+		// someone else's request added them first
+		return a.Users.FindByUsername(username)
+	}
+	return user, err
+}
+
+// oidcUser finds the local user for an OIDC identity, adding them if
+// they're new. They're matched by issuer and subject, never by username,
+// since the provider may let people pick their own. Users added before
+// identities were kept are linked on their next login, unless they
+// have a password.
+func (a *Auth) oidcUser(identity oidcIdentity) (videostore.User, error) {
+	user, err := a.Users.FindByOIDC(identity.Issuer, identity.Subject)
+	if err != videostore.ErrorUserNotFound {
+		return user, err
+	}
+
+	user, err = a.Users.FindByUsername(identity.Username)
+	if err == videostore.ErrorUserNotFound {
+		user = videostore.User{Username: identity.Username, Role: a.ExternalRole}
+	} else if err != nil {
+		return user, err
+	} else if user.PasswordHash != "" || user.OIDCSubject != "" {
+		return videostore.User{}, errOIDCUsernameTaken
+	}
+
+	user.OIDCIssuer = identity.Issuer
+	user.OIDCSubject = identity.Subject
+	saved, err := a.Users.Save(user)
+	if err == videostore.ErrorUsernameTaken {
+		// someone else's request added them first
+		return a.Users.FindByOIDC(identity.Issuer, identity.Subject)
+	}
+	return saved, err
+}
+
+func (a *Auth) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, cidr := range a.ProxyCIDRs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Auth) userFromProxy(r *http.Request) (videostore.User, bool) {
+	if a.ProxyHeader == "" {
+		return videostore.User{}, false
+	}
+
+	username := strings.TrimSpace(r.Header.Get(a.ProxyHeader))
+	if username == "" || !a.fromTrustedProxy(r) {
+		return videostore.User{}, false
+	}
+
+	user, err := a.externalUser(username)
+	if err != nil {
+		log.Printf("failed to find proxy user %v: %+v", username, err)
+		return videostore.User{}, false
+	}
+	return user, true
+}
+
+// Login starts a session for user
+func (a *Auth) Login(w http.ResponseWriter, user videostore.User) {
+	expires := time.Now().Add(a.SessionTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    a.sessionValue(user, expires),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   a.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Logout ends the session of whoever made the request
+func (a *Auth) Logout(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   a.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Middleware remembers who is logged in for the rest of the request,
+// see CurrentUser. A trusted proxy's say wins over any session.
+func (a *Auth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, ok := a.userFromProxy(r); ok {
+			r = r.WithContext(context.WithValue(r.Context(), userContextKey, user))
+		} else if cookie, err := r.Cookie(sessionCookieName); err == nil {
+			if user, ok := a.userFromSession(cookie.Value, time.Now()); ok {
+				r = r.WithContext(context.WithValue(r.Context(), userContextKey, user))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// userFromToken finds the owner of an API token
+func (a *Auth) userFromToken(secret string) (videostore.User, videostore.APIToken, bool) {
+	token, err := a.Tokens.FindByHash(videostore.HashAPIToken(secret))
+	if err != nil {
+		return videostore.User{}, token, false
+	}
+
+	user, err := a.Users.FindById(token.UserID)
+	if err != nil {
+		return videostore.User{}, token, false
+	}
+
+	return user, token, true
+}
+
+// TokenMiddleware is Middleware, but also accepts
+// API tokens as "Authorization: Bearer <token>".
+// Bad tokens are turned away instead of being treated as logged out,
+// so scripts notice.
+func (a *Auth) TokenMiddleware(next http.Handler) http.Handler {
+	return a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, secret, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, token, ok := a.userFromToken(strings.TrimSpace(secret))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		ctx = context.WithValue(ctx, apiTokenContextKey, token)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}))
+}
+
+// CurrentUser is whoever is logged in, if anyone
+func CurrentUser(r *http.Request) (videostore.User, bool) {
+	user, ok := r.Context().Value(userContextKey).(videostore.User)
+	return user, ok
+}
+
+// currentAPIToken is the token the request was made with, if any
+func currentAPIToken(r *http.Request) (videostore.APIToken, bool) {
+	token, ok := r.Context().Value(apiTokenContextKey).(videostore.APIToken)
+	return token, ok
+}
+
+// tokenHasScope is true unless the request was made
+// with an API token lacking scope
+func tokenHasScope(r *http.Request, scope string) bool {
+	token, ok := currentAPIToken(r)
+	return !ok || token.HasScope(scope)
+}
+
+// oidcLogin remembers an OIDC login in progress,
+// from leaving for the provider until coming back
+type oidcLogin struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Next     string `json:"next"`
+}
+
+func newOIDCLogin(next string) (oidcLogin, error) {
+	login := oidcLogin{Next: safeRedirect(next)}
+
+	var err error
+	if login.State, err = randomOIDCValue(); err != nil {
+		return login, err
+	}
+	if login.Nonce, err = randomOIDCValue(); err != nil {
+		return login, err
+	}
+	login.Verifier, err = randomOIDCValue()
+	return login, err
+}
+
+func (a *Auth) oidcLoginMAC(payload string) []byte {
+	mac := hmac.New(sha256.New, a.SessionKey)
+	mac.Write([]byte("oidc"))
+	mac.Write([]byte{0})
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// startOIDCLogin keeps login in a short-lived signed cookie
+func (a *Auth) startOIDCLogin(w http.ResponseWriter, login oidcLogin) error {
+	data, err := json.Marshal(login)
+	if err != nil {
+		return err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    payload + "." + base64.RawURLEncoding.EncodeToString(a.oidcLoginMAC(payload)),
+		Path:     "/login/oidc",
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		HttpOnly: true,
+		Secure:   a.SecureCookies,
+		// the provider sends people back with a top-level GET
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// finishOIDCLogin returns the login matching state,
+// each one can only be finished once
+func (a *Auth) finishOIDCLogin(w http.ResponseWriter, r *http.Request, state string) (oidcLogin, bool) {
+	var login oidcLogin
+
+	cookie, err := r.Cookie(oidcStateCookieName)
+	if err != nil {
+		return login, false
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    "",
+		Path:     "/login/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   a.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	payload, encodedMAC, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return login, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil || !hmac.Equal(mac, a.oidcLoginMAC(payload)) {
+		return login, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(data, &login) != nil {
+		return login, false
+	}
+
+	if login.State == "" || subtle.ConstantTimeCompare([]byte(login.State), []byte(state)) != 1 {
+		return login, false
+	}
+	return login, true
+}
+
+// safeRedirect only allows redirecting to paths on this site
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, `/\`) {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,296 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/jobs"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/stretchr/testify/assert"
+)
+
+// testAuth makes an Auth with one admin, alice,
+// and a session cookie that logs in as her
+func testAuth(t *testing.T, fs files.FileSystem) (*Auth, *http.Cookie) {
+	auth := NewAuth(videostore.NewDummyUserRepo(fs), videostore.NewDummyAPITokenRepo(fs), []byte("session key"), time.Hour, false)
+	_, cookie := testUser(t, auth, "alice", videostore.RoleAdmin)
+	return auth, cookie
+}
+
+// testUser adds another user to auth,
+// and a session cookie that logs in as them
+func testUser(t *testing.T, auth *Auth, username string, role string) (videostore.User, *http.Cookie) {
+	user := videostore.User{Username: username, Role: role}
+	assert.Nil(t, user.SetPassword("correct horse"))
+	user, err := auth.Users.Save(user)
+	assert.Nil(t, err)
+
+	rec := httptest.NewRecorder()
+	auth.Login(rec, user)
+
+	return user, rec.Result().Cookies()[0]
+}
+
+// asUser sends cookie with every request to handler
+func asUser(handler http.Handler, cookie *http.Cookie) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.AddCookie(cookie)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func TestAuthSession(t *testing.T) {
+	root := "test-auth-session"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	auth, cookie := testAuth(t, fs)
+	assert.True(t, cookie.HttpOnly)
+
+	user, ok := auth.userFromSession(cookie.Value, time.Now())
+	assert.True(t, ok)
+	assert.Equal(t, "alice", user.Username)
+
+	_, ok = auth.userFromSession(cookie.Value, time.Now().Add(2*time.Hour))
+	assert.False(t, ok, "expired")
+
+	_, ok = auth.userFromSession(cookie.Value+"x", time.Now())
+	assert.False(t, ok, "tampered")
+
+	_, ok = auth.userFromSession(auth.sessionValue(videostore.User{ID: 1}, time.Now().Add(time.Hour)), time.Now())
+	assert.False(t, ok, "signed without the password hash")
+
+	// changing the password logs out every session
+	assert.Nil(t, user.SetPassword("battery staple"))
+	_, err := auth.Users.Save(user)
+	assert.Nil(t, err)
+	_, ok = auth.userFromSession(cookie.Value, time.Now())
+	assert.False(t, ok, "password changed")
+}
+
+func TestAPIRequiresUser(t *testing.T) {
+	root := "test-api-requires-user"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, cookie := testAuth(t, fs)
+	handler := NewWriteableAPI(func(s string) string { return s }, fs, repo, queue, auth)
+
+	_, err := repo.Save(videostore.Video{Title: "doggo"})
+	assert.Nil(t, err)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/video/1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("DELETE", "/api/video/1", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	asUser(handler, cookie).ServeHTTP(rec, httptest.NewRequest("DELETE", "/api/video/1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestUILogin(t *testing.T) {
+	root := "test-ui-login"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, _ := testAuth(t, fs)
+	u := &cUI2{XSRFKey: []byte("xsrf key"), Auth: auth}
+	handler := NewWriteableCUI2(func(s string) string { return s }, func(s string) string { return s }, "", fs, repo, queue, u.XSRFKey, auth)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/upload", nil))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "/login?next=%2Fupload", rec.Header().Get("Location"))
+
+	login := func(password string, next string) *httptest.ResponseRecorder {
+		form := url.Values{
+			"_xsrf":    {u.baseAppState(httptest.NewRequest("GET", "/login", nil)).XSRFToken()},
+			"username": {"alice"},
+			"password": {password},
+			"next":     {next},
+		}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec = login("wrong password", "/upload")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Empty(t, rec.Result().Cookies())
+
+	rec = login("correct horse", "//evil.example.com")
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "/", rec.Header().Get("Location"))
+
+	rec = login("correct horse", "/upload")
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "/upload", rec.Header().Get("Location"))
+	cookies := rec.Result().Cookies()
+	assert.Len(t, cookies, 1)
+
+	rec = httptest.NewRecorder()
+	asUser(handler, cookies[0]).ServeHTTP(rec, httptest.NewRequest("GET", "/upload", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Log out alice")
+}
+
+func Test_safeRedirect(t *testing.T) {
+	assert.Equal(t, "/watch/1?a=b", safeRedirect("/watch/1?a=b"))
+	assert.Equal(t, "/", safeRedirect(""))
+	assert.Equal(t, "/", safeRedirect("https://evil.example.com"))
+	assert.Equal(t, "/", safeRedirect("//evil.example.com"))
+	assert.Equal(t, "/", safeRedirect(`/\evil.example.com`))
+}
+
+// testXSRFToken makes an XSRF token for whoever cookie logs in as
+func testXSRFToken(u *cUI2, cookie *http.Cookie) string {
+	var token string
+	asUser(u.Auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = u.baseAppState(r).XSRFToken()
+	})), cookie).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	return token
+}
+
+// testToken saves an API token for user
+func testToken(t *testing.T, auth *Auth, user videostore.User, scopes ...string) string {
+	token, secret, err := videostore.NewAPIToken(user, "test", scopes)
+	assert.Nil(t, err)
+	_, err = auth.Tokens.Save(token)
+	assert.Nil(t, err)
+	return secret
+}
+
+func TestAPITokens(t *testing.T) {
+	root := "test-api-tokens"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, _ := testAuth(t, fs)
+	admin, err := auth.Users.FindByUsername("alice")
+	assert.Nil(t, err)
+	uploader, _ := testUser(t, auth, "ursula", videostore.RoleUploader)
+	handler := NewWriteableAPI(func(s string) string { return s }, fs, repo, queue, auth)
+
+	_, err = repo.Save(videostore.Video{Title: "doggo"})
+	assert.Nil(t, err)
+
+	request := func(secret string, method string, target string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+secret)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("cv_guess", "GET", "/api/video", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "bad tokens aren't treated as logged out")
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+
+	readOnly := testToken(t, auth, admin, videostore.ScopeRead)
+	assert.Equal(t, http.StatusOK, request(readOnly, "GET", "/api/video", "").Code)
+	assert.Equal(t, http.StatusForbidden, request(readOnly, "POST", "/api/video/1", `{"title":"edited"}`).Code)
+	assert.Equal(t, http.StatusForbidden, request(readOnly, "POST", "/api/tags/rename", `{"from":"a","to":"b"}`).Code)
+
+	// reading private videos needs the read scope too
+	_, err = repo.Save(videostore.Video{Title: "secret", Source: "/2/video.mp4", OwnerID: admin.ID, Visibility: videostore.VisibilityPrivate})
+	assert.Nil(t, err)
+	media := auth.TokenMiddleware(NewVisibleMediaHandler(repo, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("served " + r.URL.Path))
+	})))
+	fetch := func(secret string, target string) int {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("Authorization", "Bearer "+secret)
+		rec := httptest.NewRecorder()
+		media.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	uploadOnly := testToken(t, auth, admin, videostore.ScopeUpload)
+	assert.NotContains(t, request(uploadOnly, "GET", "/api/video", "").Body.String(), "secret")
+	assert.Equal(t, http.StatusNotFound, request(uploadOnly, "GET", "/api/video/2", "").Code)
+	assert.Equal(t, http.StatusNotFound, fetch(uploadOnly, "/2/video.mp4"))
+	assert.Equal(t, http.StatusForbidden, request(uploadOnly, "GET", "/api/jobs", "").Code)
+	assert.Contains(t, request(readOnly, "GET", "/api/video", "").Body.String(), "secret")
+	assert.Equal(t, http.StatusOK, request(readOnly, "GET", "/api/video/2", "").Code)
+	assert.Equal(t, http.StatusOK, fetch(readOnly, "/2/video.mp4"))
+
+	editor := testToken(t, auth, admin, videostore.ScopeEdit)
+	assert.Equal(t, http.StatusOK, request(editor, "POST", "/api/video/1", `{"title":"edited"}`).Code)
+	assert.Equal(t, http.StatusForbidden, request(editor, "DELETE", "/api/video/1", "").Code)
+
+	// scopes can't go past the user's role
+	uploaderDelete := testToken(t, auth, uploader, videostore.ScopeDelete)
+	assert.Equal(t, http.StatusForbidden, request(uploaderDelete, "DELETE", "/api/video/1", "").Code)
+
+	deleter := testToken(t, auth, admin, videostore.ScopeDelete)
+	assert.Equal(t, http.StatusOK, request(deleter, "DELETE", "/api/video/1", "").Code)
+
+	// revoked tokens stop working
+	found, err := auth.Tokens.FindByHash(videostore.HashAPIToken(deleter))
+	assert.Nil(t, err)
+	assert.Nil(t, auth.Tokens.Delete(found))
+	assert.Equal(t, http.StatusUnauthorized, request(deleter, "GET", "/api/video", "").Code)
+}
+
+func TestUITokens(t *testing.T) {
+	root := "test-ui-tokens"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, cookie := testAuth(t, fs)
+	_, otherCookie := testUser(t, auth, "ursula", videostore.RoleUploader)
+	u := &cUI2{XSRFKey: []byte("xsrf key"), Auth: auth}
+	handler := NewWriteableCUI2(func(s string) string { return s }, func(s string) string { return s }, "", fs, repo, queue, u.XSRFKey, auth)
+
+	post := func(cookie *http.Cookie, target string, form url.Values) *httptest.ResponseRecorder {
+		form.Set("_xsrf", testXSRFToken(u, cookie))
+		req := httptest.NewRequest("POST", target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		asUser(handler, cookie).ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/tokens", nil))
+	assert.Equal(t, http.StatusFound, rec.Code)
+
+	rec = post(cookie, "/tokens", url.Values{"name": {"ingest"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Pick at least one scope")
+
+	rec = post(cookie, "/tokens", url.Values{"name": {"ingest"}, "scopes": {"read", "upload"}})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), "cv_")
+	assert.Contains(t, rec.Body.String(), "read, upload")
+
+	rec = httptest.NewRecorder()
+	asUser(handler, otherCookie).ServeHTTP(rec, httptest.NewRequest("GET", "/tokens", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), `data-e2e="API Tokens"`)
+
+	assert.Equal(t, http.StatusNotFound, post(otherCookie, "/tokens/1/revoke", url.Values{}).Code)
+	assert.Equal(t, http.StatusFound, post(cookie, "/tokens/1/revoke", url.Values{}).Code)
+
+	_, err := auth.Tokens.FindById(1)
+	assert.Equal(t, videostore.ErrorAPITokenNotFound, err)
+}
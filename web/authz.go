@@ -0,0 +1,144 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/gorilla/mux"
+)
+
+var errForbidden = errors.New("forbidden")
+
+// authorizer decides who may do what, see videostore.User.Can.
+// The API and UI share the rules and only differ in how they say no.
+type authorizer struct {
+	Repo videostore.VideoRepo
+
+	// Deny is called with http.StatusUnauthorized for anyone
+	// not logged in, http.StatusNotFound for videos they can't
+	// see, or http.StatusForbidden for anyone else
+	Deny func(w http.ResponseWriter, r *http.Request, statusCode int)
+}
+
+// Require lets through users whose role grants permission,
+// API tokens also need the matching scope
+func (a authorizer) Require(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := CurrentUser(r)
+		if !ok {
+			a.Deny(w, r, http.StatusUnauthorized)
+			return
+		}
+		token, usingToken := currentAPIToken(r)
+		if !user.Can(permission) || (usingToken && !token.Allows(permission)) {
+			a.Deny(w, r, http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequireVideo lets through users allowed to act on the video
+// in the {id} route var, like videostore.User.CanEdit.
+// API tokens also need scope. Videos the user can't see are
+// reported missing, like Watch does, and other missing videos
+// are left for next to report.
+func (a authorizer) RequireVideo(scope string, allowed func(user videostore.User, video videostore.Video) bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := CurrentUser(r)
+		if !ok {
+			a.Deny(w, r, http.StatusUnauthorized)
+			return
+		}
+		if !tokenHasScope(r, scope) {
+			a.Deny(w, r, http.StatusForbidden)
+			return
+		}
+
+		if id, err := strconv.Atoi(mux.Vars(r)["id"]); err == nil {
+			if video, err := a.Repo.FindById(uint(id)); err == nil {
+				if !canView(r, video) {
+					a.Deny(w, r, http.StatusNotFound)
+					return
+				}
+				if !allowed(user, video) {
+					a.Deny(w, r, http.StatusForbidden)
+					return
+				}
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// RequireScope lets through requests not made with an API token,
+// or made with one that has scope
+func (a authorizer) RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !tokenHasScope(r, scope) {
+			a.Deny(w, r, http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequireUser lets through anyone logged in
+func (a authorizer) RequireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := CurrentUser(r); !ok {
+			a.Deny(w, r, http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// currentViewer is who r reads videos as. API tokens without
+// the read scope only see what someone logged out would.
+func currentViewer(r *http.Request) (videostore.User, bool) {
+	if !tokenHasScope(r, videostore.ScopeRead) {
+		return videostore.User{}, false
+	}
+	return CurrentUser(r)
+}
+
+// listedFor hides videos from filter's results that r shouldn't find,
+// see videostore.VideoFilter.Listed
+func listedFor(r *http.Request, filter videostore.VideoFilter) videostore.VideoFilter {
+	filter.Listed = true
+	if user, ok := currentViewer(r); ok {
+		filter.ViewerID = user.ID
+	}
+	return filter
+}
+
+// canView reports whether r may watch video,
+// anyone else is told it doesn't exist
+func canView(r *http.Request, video videostore.Video) bool {
+	user, _ := currentViewer(r)
+	return user.CanView(video)
+}
+
+// denyAPI only sends the status code
+func denyAPI(w http.ResponseWriter, r *http.Request, statusCode int) {
+	w.WriteHeader(statusCode)
+}
+
+// deny sends anyone not logged in to the login page,
+// they come back here afterwards
+func (u *cUI2) deny(w http.ResponseWriter, r *http.Request, statusCode int) {
+	if statusCode == http.StatusUnauthorized {
+		http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusFound)
+		return
+	}
+	if statusCode == http.StatusNotFound {
+		u.WriteErrorPage(w, r, statusCode, videostore.ErrorVideoNotFound, "video not found")
+		return
+	}
+	u.WriteErrorPage(w, r, statusCode, errForbidden, "You aren't allowed to do that")
+}
@@ -0,0 +1,308 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/jobs"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIRoles(t *testing.T) {
+	root := "test-api-roles"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, admin := testAuth(t, fs)
+	_, viewer := testUser(t, auth, "victor", videostore.RoleViewer)
+	uploaderUser, uploader := testUser(t, auth, "ursula", videostore.RoleUploader)
+	_, editor := testUser(t, auth, "edna", videostore.RoleEditor)
+	handler := NewWriteableAPI(func(s string) string { return s }, fs, repo, queue, auth)
+
+	request := func(cookie *http.Cookie, method string, target string, body string) int {
+		rec := httptest.NewRecorder()
+		asUser(handler, cookie).ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec.Code
+	}
+
+	upload := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("title", "doggo")
+		file, _ := form.CreateFormFile("file", "doggo.mp4")
+		file.Write([]byte("not really a video"))
+		form.Close()
+
+		req := httptest.NewRequest("POST", "/api/upload", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		rec := httptest.NewRecorder()
+		asUser(handler, cookie).ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusForbidden, upload(viewer).Code)
+	assert.Equal(t, http.StatusCreated, upload(uploader).Code)
+
+	own, err := repo.FindById(1)
+	assert.Nil(t, err)
+	assert.Equal(t, uploaderUser.ID, own.OwnerID)
+
+	other, err := repo.Save(videostore.Video{Title: "kitty"})
+	assert.Nil(t, err)
+
+	edit := `{"title":"edited"}`
+	assert.Equal(t, http.StatusForbidden, request(viewer, "POST", "/api/video/1", edit))
+	assert.Equal(t, http.StatusOK, request(uploader, "POST", "/api/video/1", edit))
+	assert.Equal(t, http.StatusForbidden, request(uploader, "POST", "/api/video/2", edit))
+	assert.Equal(t, http.StatusOK, request(editor, "POST", "/api/video/2", edit))
+	// missing videos are still reported as missing
+	assert.Equal(t, http.StatusNotFound, request(editor, "POST", "/api/video/69", edit))
+
+	// jobs show every video's files and errors, so they're for editors only
+	anonymous := httptest.NewRecorder()
+	handler.ServeHTTP(anonymous, httptest.NewRequest("GET", "/api/jobs", nil))
+	assert.Equal(t, http.StatusUnauthorized, anonymous.Code)
+	assert.Equal(t, http.StatusForbidden, request(uploader, "GET", "/api/jobs", ""))
+	assert.Equal(t, http.StatusForbidden, request(uploader, "GET", "/api/jobs/1", ""))
+	assert.Equal(t, http.StatusOK, request(editor, "GET", "/api/jobs", ""))
+
+	assert.Equal(t, http.StatusForbidden, request(uploader, "POST", "/api/tags/rename", `{"from":"a","to":"b"}`))
+	assert.Equal(t, http.StatusOK, request(editor, "POST", "/api/tags/rename", `{"from":"a","to":"b"}`))
+
+	assert.Equal(t, http.StatusForbidden, request(uploader, "DELETE", "/api/video/1", ""))
+	assert.Equal(t, http.StatusForbidden, request(editor, "DELETE", "/api/video/1", ""))
+	assert.Equal(t, http.StatusOK, request(admin, "DELETE", "/api/video/1", ""))
+	assert.Equal(t, http.StatusOK, request(admin, "DELETE", "/api/video/2", ""))
+	_, err = repo.FindById(other.ID)
+	assert.Equal(t, videostore.ErrorVideoNotFound, err)
+}
+
+func TestUIRoles(t *testing.T) {
+	root := "test-ui-roles"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, admin := testAuth(t, fs)
+	_, viewer := testUser(t, auth, "victor", videostore.RoleViewer)
+	uploaderUser, uploader := testUser(t, auth, "ursula", videostore.RoleUploader)
+	handler := NewWriteableCUI2(func(s string) string { return s }, func(s string) string { return s }, "", fs, repo, queue, []byte("xsrf key"), auth)
+
+	_, err := repo.Save(videostore.Video{Title: "doggo", OwnerID: uploaderUser.ID})
+	assert.Nil(t, err)
+	_, err = repo.Save(videostore.Video{Title: "kitty"})
+	assert.Nil(t, err)
+
+	get := func(cookie *http.Cookie, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		asUser(handler, cookie).ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusForbidden, get(viewer, "/upload").Code)
+	assert.Equal(t, http.StatusOK, get(uploader, "/upload").Code)
+	assert.Equal(t, http.StatusOK, get(uploader, "/edit/1").Code)
+	assert.Equal(t, http.StatusForbidden, get(uploader, "/edit/2").Code)
+	assert.Equal(t, http.StatusForbidden, get(uploader, "/delete/1").Code)
+	assert.Equal(t, http.StatusOK, get(admin, "/delete/2").Code)
+
+	// only offer what they're allowed to do
+	watch := get(viewer, "/watch/1").Body.String()
+	assert.NotContains(t, watch, "/upload")
+	assert.NotContains(t, watch, "/edit/1")
+	watch = get(uploader, "/watch/1").Body.String()
+	assert.Contains(t, watch, "/edit/1")
+	assert.NotContains(t, watch, "/delete/1")
+	watch = get(admin, "/watch/2").Body.String()
+	assert.Contains(t, watch, "/edit/2")
+	assert.Contains(t, watch, "/delete/2")
+}
+
+func TestTusUploadsBelongToTheirOwner(t *testing.T) {
+	root := "test-tus-owner"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, admin := testAuth(t, fs)
+	_, uploader := testUser(t, auth, "ursula", videostore.RoleUploader)
+	handler := NewWriteableAPI(func(s string) string { return s }, fs, repo, queue, auth)
+
+	req := httptest.NewRequest("POST", "/api/uploads", nil)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	req.Header.Set(tusLengthHeader, "4")
+	req.Header.Set("Upload-Metadata", "filename ZG9nZ28ubXA0")
+	rec := httptest.NewRecorder()
+	asUser(handler, uploader).ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	location := rec.Header().Get("Location")
+
+	head := func(cookie *http.Cookie) int {
+		req := httptest.NewRequest("HEAD", location, nil)
+		req.Header.Set("Tus-Resumable", tusVersion)
+		rec := httptest.NewRecorder()
+		asUser(handler, cookie).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, head(uploader))
+	assert.Equal(t, http.StatusNotFound, head(admin))
+}
+
+func TestVideoOwners(t *testing.T) {
+	root := "test-video-owners"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, admin := testAuth(t, fs)
+	uploaderUser, uploader := testUser(t, auth, "ursula", videostore.RoleUploader)
+	ui := NewWriteableCUI2(func(s string) string { return s }, func(s string) string { return s }, "", fs, repo, queue, []byte("xsrf key"), auth)
+	api := NewWriteableAPI(func(s string) string { return s }, fs, repo, queue, auth)
+
+	own, err := repo.Save(videostore.Video{Title: "doggo", Source: "/1/video.mp4", OwnerID: uploaderUser.ID})
+	assert.Nil(t, err)
+	_, err = repo.Save(videostore.Video{Title: "kitty", Source: "/2/video.mp4"})
+	assert.Nil(t, err)
+
+	get := func(handler http.Handler, cookie *http.Cookie, target string) string {
+		rec := httptest.NewRecorder()
+		asUser(handler, cookie).ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	body := get(ui, admin, "/watch/1")
+	assert.Contains(t, body, `data-e2e="Video Owner"`)
+	assert.Contains(t, body, "ursula")
+	assert.NotContains(t, get(ui, admin, "/watch/2"), `data-e2e="Video Owner"`)
+
+	// my uploads
+	myUploads := "/search?owner_id=" + strconv.Itoa(int(uploaderUser.ID))
+	assert.Contains(t, get(ui, uploader, "/"), myUploads)
+	assert.NotContains(t, get(ui, admin, "/"), myUploads)
+	body = get(ui, uploader, myUploads)
+	assert.Contains(t, body, "doggo")
+	assert.NotContains(t, body, "kitty")
+
+	var videos []videostore.Video
+	assert.Nil(t, json.Unmarshal([]byte(get(api, admin, "/api/video?owner_id="+strconv.Itoa(int(own.OwnerID)))), &videos))
+	assert.Len(t, videos, 1)
+	assert.Equal(t, own.ID, videos[0].ID)
+}
+
+func TestVideoVisibility(t *testing.T) {
+	root := "test-video-visibility"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, _ := testAuth(t, fs)
+	owner, ownerCookie := testUser(t, auth, "ursula", videostore.RoleUploader)
+	_, viewer := testUser(t, auth, "victor", videostore.RoleViewer)
+	ui := NewWriteableCUI2(func(s string) string { return s }, func(s string) string { return s }, "", fs, repo, queue, []byte("xsrf key"), auth)
+	api := NewWriteableAPI(func(s string) string { return s }, fs, repo, queue, auth)
+	readOnlyUI := NewReadOnlyCUI2(func(s string) string { return s }, func(s string) string { return s }, "", repo)
+	readOnlyAPI := NewReadOnlyAPI(func(s string) string { return s }, fs, repo, queue)
+
+	for i, visibility := range videostore.Visibilities {
+		_, err := repo.Save(videostore.Video{
+			Title:      "doggo " + visibility,
+			Source:     "/" + strconv.Itoa(i+1) + "/video.mp4",
+			Tags:       []string{"home"},
+			OwnerID:    owner.ID,
+			Visibility: visibility,
+		})
+		assert.Nil(t, err)
+	}
+
+	get := func(handler http.Handler, cookie *http.Cookie, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", target, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	titles := func(handler http.Handler, cookie *http.Cookie) []string {
+		var videos []videostore.Video
+		assert.Nil(t, json.Unmarshal(get(handler, cookie, "/api/video?sort_field=title&sort_direction=asc").Body.Bytes(), &videos))
+		titles := []string{}
+		for _, video := range videos {
+			titles = append(titles, video.Title)
+		}
+		return titles
+	}
+
+	for _, handler := range []http.Handler{ui, readOnlyUI} {
+		for _, target := range []string{"/", "/search?text=doggo", "/sitemap.xml"} {
+			body := get(handler, viewer, target).Body.String()
+			assert.Contains(t, body, "/watch/1", target)
+			assert.NotContains(t, body, "/watch/2", target)
+			assert.NotContains(t, body, "/watch/3", target)
+		}
+
+		assert.Equal(t, http.StatusOK, get(handler, nil, "/watch/2").Code)
+		assert.Equal(t, http.StatusNotFound, get(handler, nil, "/watch/3").Code)
+	}
+
+	// owners find their own videos, but they stay out of the sitemap
+	body := get(ui, ownerCookie, "/search?text=doggo").Body.String()
+	assert.Contains(t, body, "/watch/2")
+	assert.Contains(t, body, "/watch/3")
+	assert.NotContains(t, get(ui, ownerCookie, "/sitemap.xml").Body.String(), "/watch/3")
+	assert.Equal(t, http.StatusOK, get(ui, ownerCookie, "/watch/3").Code)
+	assert.Equal(t, http.StatusNotFound, get(ui, viewer, "/watch/3").Code)
+
+	assert.Equal(t, []string{"doggo public"}, titles(api, viewer))
+	assert.Equal(t, []string{"doggo private", "doggo public", "doggo unlisted"}, titles(api, ownerCookie))
+	assert.Equal(t, []string{"doggo public"}, titles(readOnlyAPI, nil))
+	assert.Equal(t, http.StatusOK, get(readOnlyAPI, nil, "/api/video/2").Code)
+	assert.Equal(t, http.StatusNotFound, get(readOnlyAPI, nil, "/api/video/3").Code)
+	// read-only instances have no logins, so no one may see jobs
+	assert.Equal(t, http.StatusNotFound, get(readOnlyAPI, nil, "/api/jobs").Code)
+	assert.Equal(t, http.StatusNotFound, get(api, viewer, "/api/video/3").Code)
+	assert.Equal(t, http.StatusOK, get(api, ownerCookie, "/api/video/3").Code)
+
+	// videos someone can't see are missing, not forbidden
+	rec := httptest.NewRecorder()
+	asUser(api, viewer).ServeHTTP(rec, httptest.NewRequest("POST", "/api/video/3", strings.NewReader(`{"title":"mine now"}`)))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = httptest.NewRecorder()
+	asUser(api, viewer).ServeHTTP(rec, httptest.NewRequest("POST", "/api/video/1", strings.NewReader(`{"title":"mine now"}`)))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// changing visibility
+	rec = httptest.NewRecorder()
+	asUser(api, ownerCookie).ServeHTTP(rec, httptest.NewRequest("POST", "/api/video/3", strings.NewReader(`{"title":"doggo private","visibility":"public"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, http.StatusOK, get(api, viewer, "/api/video/3").Code)
+
+	rec = httptest.NewRecorder()
+	asUser(api, ownerCookie).ServeHTTP(rec, httptest.NewRequest("POST", "/api/video/3", strings.NewReader(`{"title":"doggo private","visibility":"secret"}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// leaving it out keeps it
+	rec = httptest.NewRecorder()
+	asUser(api, ownerCookie).ServeHTTP(rec, httptest.NewRequest("POST", "/api/video/2", strings.NewReader(`{"title":"doggo unlisted"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	unlisted, err := repo.FindById(2)
+	assert.Nil(t, err)
+	assert.Equal(t, videostore.VisibilityUnlisted, unlisted.Visibility)
+}
@@ -1,6 +1,8 @@
 package web
 
 import (
+	"errors"
+	"strconv"
 	"strings"
 
 	"github.com/AlbinoDrought/creamy-videos/videostore"
@@ -10,7 +12,9 @@ type stringDict interface {
 	Get(key string) string
 }
 
-func videoFilterFromDict(dict stringDict) videostore.VideoFilter {
+// videoFilterFromDict reads a filter from query parameters,
+// erroring if its query can't be parsed
+func videoFilterFromDict(dict stringDict) (videostore.VideoFilter, error) {
 	// don't do strings.Split("", ",")
 	// that would give us a slice with length=1,
 	// containing an empty string
@@ -30,14 +34,101 @@ func videoFilterFromDict(dict stringDict) videostore.VideoFilter {
 
 	if hasSortField && !hasSortDirection {
 		sortDirection = videostore.SortDirectionAscending
+		// nobody wants the worst matches first
+		if sortField == videostore.SortFieldRelevance {
+			sortDirection = videostore.SortDirectionDescending
+		}
+	}
+
+	anyText, query, err := filterQueryFromString(dict.Get("filter"))
+	if err != nil {
+		return videostore.VideoFilter{}, err
 	}
 
 	return videostore.VideoFilter{
 		Title: dict.Get("title"),
 		Tags:  tags,
-		Any:   dict.Get("filter"),
+		Any:   anyText,
+		Text:  dict.Get("q"),
+		Query: query,
+
+		// unparseable values are treated as "no filter"
+		MinDuration: parseFloatOrZero(dict.Get("min_duration")),
+		MaxDuration: parseFloatOrZero(dict.Get("max_duration")),
+		MinHeight:   int(parseFloatOrZero(dict.Get("min_height"))),
+		OwnerID:     parseUintOrZero(dict.Get("owner_id")),
 
 		SortDirection: sortDirection,
 		SortField:     sortField,
+	}, nil
+}
+
+// filterQueryFromString parses raw as a query. A lone word is left as a plain
+// Any filter.
+func filterQueryFromString(raw string) (string, *videostore.QueryNode, error) {
+	query, err := parseQuery(raw)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if query != nil && query.Op == videostore.QueryTerm && query.Field == videostore.QueryFieldAny {
+		return query.Value, nil, nil
 	}
+
+	return "", query, nil
+}
+
+// splitTags converts "foo, bar" and "foo,bar" into
+// ["foo", "bar"]
+func splitTags(raw string) []string {
+	tags := strings.Split(raw, ",")
+	for i, tag := range tags {
+		tags[i] = strings.Trim(tag, " ")
+	}
+	if len(tags) == 1 && tags[0] == "" {
+		tags = []string{}
+	}
+	return tags
+}
+
+func parseFloatOrZero(raw string) float64 {
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func parseUintOrZero(raw string) uint {
+	value, err := strconv.ParseUint(raw, 10, 0)
+	if err != nil {
+		return 0
+	}
+	return uint(value)
+}
+
+var errTimestampInvalid = errors.New("timestamp should look like 90, 1:30 or 1:01:30.5")
+
+// parseTimestamp converts "90", "1:30" and "0:01:30" into 90 seconds
+func parseTimestamp(raw string) (float64, error) {
+	parts := strings.Split(strings.TrimSpace(raw), ":")
+	if len(parts) > 3 {
+		return 0, errTimestampInvalid
+	}
+
+	seconds := 0.0
+	for i, part := range parts {
+		// only the seconds may have a fraction
+		last := i == len(parts)-1
+		if part == "" || (!last && strings.Contains(part, ".")) {
+			return 0, errTimestampInvalid
+		}
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 || (i > 0 && value >= 60) {
+			return 0, errTimestampInvalid
+		}
+		seconds = seconds*60 + value
+	}
+
+	return seconds, nil
 }
@@ -0,0 +1,41 @@
+package web
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseTimestamp(t *testing.T) {
+	valid := map[string]float64{
+		"0":          0,
+		"90":         90,
+		"1:30":       90,
+		"01:02:03.5": 3723.5,
+		" 2:00 ":     120,
+	}
+	for raw, expected := range valid {
+		seconds, err := parseTimestamp(raw)
+		assert.Nil(t, err, raw)
+		assert.Equal(t, expected, seconds, raw)
+	}
+
+	for _, raw := range []string{"", "abc", "1:60", "-5", "1:2:3:4", "1.5:30", "1::30"} {
+		_, err := parseTimestamp(raw)
+		assert.Equal(t, errTimestampInvalid, err, raw)
+	}
+}
+
+func Test_videoFilterFromDict_search(t *testing.T) {
+	filter, err := videoFilterFromDict(url.Values{
+		"q":          []string{"good dog"},
+		"sort_field": []string{"relevance"},
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, "good dog", filter.Text)
+	assert.Equal(t, videostore.SortFieldRelevance, filter.SortField)
+	assert.Equal(t, videostore.SortDirectionDescending, filter.SortDirection)
+}
@@ -0,0 +1,97 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/AlbinoDrought/creamy-videos/jobs"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/gorilla/mux"
+)
+
+// videoProcessingJobs are queued for every newly uploaded video,
+// if the queue knows how to handle them (some are opt-in)
+var videoProcessingJobs = []string{
+	videostore.JobKindProbe,
+	videostore.JobKindThumbnail,
+	videostore.JobKindPreview,
+	videostore.JobKindSprites,
+	videostore.JobKindTranscode,
+}
+
+func enqueueVideoProcessing(queue *jobs.Queue, video videostore.Video) {
+	for _, kind := range videoProcessingJobs {
+		if !queue.Handles(kind) {
+			continue
+		}
+		if _, err := queue.Enqueue(kind, video.ID); err != nil {
+			log.Printf("failed to enqueue %v for video %v: %+v", kind, video.ID, err)
+		}
+	}
+}
+
+func (a *api) ListJobs(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	pageInt, err := page(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	limit := videosPerPage
+	offset := videosPerPage * (pageInt - 1)
+	if offset < 0 {
+		offset = 0
+	}
+
+	filter := videostore.JobFilter{
+		Kind:   r.URL.Query().Get("kind"),
+		Status: r.URL.Query().Get("status"),
+	}
+	if rawVideoID := r.URL.Query().Get("video_id"); rawVideoID != "" {
+		videoID, err := strconv.Atoi(rawVideoID)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		filter.VideoID = uint(videoID)
+	}
+
+	foundJobs, err := a.Jobs.Repo.All(filter, uint(limit), uint(offset))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error listing jobs: %+v", err)
+		return
+	}
+
+	writeJSON(w, foundJobs)
+}
+
+func (a *api) ShowJob(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	vars := mux.Vars(r)
+	rawID := vars["id"]
+	id, err := strconv.Atoi(rawID)
+
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	job, err := a.Jobs.Repo.FindById(uint(id))
+	if err == videostore.ErrorJobNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error while retrieving job: %+v", err)
+		return
+	}
+
+	writeJSON(w, job)
+}
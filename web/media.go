@@ -0,0 +1,125 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+)
+
+// proxiedMediaExtensions are always served by us, even when redirecting.
+// Playlists and tracks point at other files relative to their own URL,
+// which would be resolved against the signed URL and fail.
+var proxiedMediaExtensions = map[string]bool{
+	".m3u8": true,
+	".vtt":  true,
+}
+
+type signedRedirectHandler struct {
+	signer   files.URLSigner
+	ttl      time.Duration
+	fallback http.Handler
+}
+
+// NewSignedRedirectHandler redirects media requests to short-lived signed URLs,
+// so the bytes don't have to pass through this server
+func NewSignedRedirectHandler(signer files.URLSigner, ttl time.Duration, fallback http.Handler) http.Handler {
+	return signedRedirectHandler{
+		signer,
+		ttl,
+		fallback,
+	}
+}
+
+func (h signedRedirectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || proxiedMediaExtensions[path.Ext(r.URL.Path)] {
+		h.fallback.ServeHTTP(w, r)
+		return
+	}
+
+	signed, err := h.signer.SignURL(r.URL.Path, h.ttl)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error signing media url: %+v", err)
+		return
+	}
+
+	// reuse the redirect for a while, but not past when it stops working
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int((h.ttl/2).Seconds())))
+	http.Redirect(w, r, signed, http.StatusFound)
+}
+
+type visibleMediaHandler struct {
+	repo     videostore.VideoRepo
+	shareKey []byte
+	next     http.Handler
+}
+
+// NewVisibleMediaHandler only serves the files of videos the requester
+// may watch, see videostore.User.CanView, or has a share link for.
+// Paths look like /{id}/video.mp4 and must be stored on that video,
+// anything else in the media directory isn't served at all, like the
+// JSON stores or unfinished uploads.
+func NewVisibleMediaHandler(repo videostore.VideoRepo, shareKey []byte, next http.Handler) http.Handler {
+	return visibleMediaHandler{
+		repo,
+		shareKey,
+		next,
+	}
+}
+
+// videoHasFile reports whether name is one of the files stored for video.
+// Directories are named after the ID a video was uploaded with, which
+// could belong to another video if it was copied between repos.
+func videoHasFile(video videostore.Video, name string) bool {
+	for _, stored := range videostore.VideoFiles(video) {
+		stored = path.Clean("/" + stored)
+		if name == stored || strings.HasPrefix(name, stored+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (h visibleMediaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + r.URL.Path)
+	parts := strings.SplitN(strings.TrimPrefix(name, "/"), "/", 2)
+	id, err := strconv.ParseUint(parts[0], 10, 0)
+	if len(parts) < 2 || err != nil || id == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	video, err := h.repo.FindById(uint(id))
+	if err == videostore.ErrorVideoNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error finding video for media: %+v", err)
+		return
+	}
+
+	if !videoHasFile(video, name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	// pretend private videos don't exist, like Watch does
+	if !canView(r, video) && !validShareToken(h.shareKey, shareToken(r), video.ID, time.Now()) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if video.Visibility != videostore.VisibilityPublic {
+		w.Header().Set("Cache-Control", "private")
+	}
+
+	h.next.ServeHTTP(w, r)
+}
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignedRedirectHandler(t *testing.T) {
+	signer := files.HMACURLSigner("https://media.example.com", []byte("01234567890123456789012345678901"))
+	fallback := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxied"))
+	})
+	handler := http.StripPrefix("/static/videos", NewSignedRedirectHandler(signer, time.Hour, fallback))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/static/videos/1/video.mp4", nil))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Location"), "https://media.example.com/1/video.mp4?"))
+
+	// playlists link to segments relative to themselves, keep them here
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/static/videos/1/hls/master.m3u8", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "proxied", rec.Body.String())
+}
+
+func TestVisibleMediaHandler(t *testing.T) {
+	root := "test-visible-media"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	auth, admin := testAuth(t, fs)
+	owner, ownerCookie := testUser(t, auth, "ursula", videostore.RoleUploader)
+	_, viewer := testUser(t, auth, "victor", videostore.RoleViewer)
+
+	public, err := repo.Save(videostore.Video{Title: "public", Source: "1/video.mp4", HLSPlaylist: "1/hls/master.m3u8"})
+	assert.Nil(t, err)
+	unlisted, err := repo.Save(videostore.Video{Title: "unlisted", Source: "2/video.mp4", Visibility: videostore.VisibilityUnlisted})
+	assert.Nil(t, err)
+	private, err := repo.Save(videostore.Video{Title: "private", Source: "3/video.mp4", OwnerID: owner.ID, Visibility: videostore.VisibilityPrivate})
+	assert.Nil(t, err)
+
+	served := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("served " + r.URL.Path))
+	})
+	handler := http.StripPrefix("/static/videos", auth.TokenMiddleware(NewVisibleMediaHandler(repo, []byte("xsrf key"), served)))
+
+	request := func(cookie *http.Cookie, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	video := func(video videostore.Video) string {
+		return "/static/videos/" + strconv.Itoa(int(video.ID)) + "/video.mp4"
+	}
+
+	rec := request(nil, video(public))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "served /1/video.mp4", rec.Body.String())
+	assert.Empty(t, rec.Header().Get("Cache-Control"))
+
+	// unlisted is fine for anyone with the link
+	rec = request(nil, video(unlisted))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "private", rec.Header().Get("Cache-Control"))
+
+	assert.Equal(t, http.StatusNotFound, request(nil, video(private)).Code)
+	assert.Equal(t, http.StatusNotFound, request(viewer, video(private)).Code)
+	assert.Equal(t, http.StatusOK, request(ownerCookie, video(private)).Code)
+	assert.Equal(t, http.StatusOK, request(admin, video(private)).Code)
+
+	// only files of videos are served
+	assert.Equal(t, http.StatusNotFound, request(admin, "/static/videos/users.json").Code)
+	assert.Equal(t, http.StatusNotFound, request(admin, "/static/videos/1/").Code)
+	assert.Equal(t, http.StatusNotFound, request(admin, "/static/videos/1/../users.json").Code)
+	assert.Equal(t, http.StatusNotFound, request(admin, "/static/videos/uploads/abc/info.json").Code)
+	assert.Equal(t, http.StatusNotFound, request(admin, "/static/videos/69/video.mp4").Code)
+	assert.Equal(t, http.StatusNotFound, request(admin, "/static/videos/0/video.mp4").Code)
+	assert.Equal(t, http.StatusOK, request(nil, "/static/videos/1/hls/720p/segment0.ts").Code)
+
+	// the directory must belong to the video, not just share its ID
+	assert.Equal(t, http.StatusNotFound, request(admin, "/static/videos/1/notes.txt").Code)
+	moved, err := repo.Save(videostore.Video{Title: "moved", Source: "1/video.mp4"})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, request(admin, video(moved)).Code)
+}
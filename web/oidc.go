@@ -0,0 +1,343 @@
+package web
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OpenID Connect authorization code flow, just enough of it
+// to log in with an SSO provider:
+// https://openid.net/specs/openid-connect-core-1_0.html#CodeFlowAuth
+//
+// The provider is discovered from its issuer URL on first use,
+// codes are exchanged with PKCE and a client secret, and the
+// returned ID token must be an RS256 JWT signed by a key in the
+// provider's JWKS.
+
+const oidcStateCookieName = "creamy_oidc"
+
+// oidcClockSkew forgives providers whose clocks are a little off
+const oidcClockSkew = time.Minute
+
+var errOIDCInvalidToken = errors.New("invalid id token")
+var errOIDCUsernameTaken = errors.New("username belongs to someone else")
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcJWK struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// oidcIdentity is who an ID token says logged in
+type oidcIdentity struct {
+	Issuer   string
+	Subject  string
+	Username string
+}
+
+// OIDC logs users in through an OpenID Connect provider
+type OIDC struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	// UsernameClaim names the ID token claim used as the username
+	// of new users, like preferred_username or email.
+	// Returning users are found by their subject instead.
+	UsernameClaim string
+
+	Client *http.Client
+
+	lock      sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+}
+
+func NewOIDC(issuer string, clientID string, clientSecret string, redirectURL string, usernameClaim string) *OIDC {
+	return &OIDC{
+		Issuer:        strings.TrimRight(issuer, "/"),
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		RedirectURL:   redirectURL,
+		UsernameClaim: usernameClaim,
+		Client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (o *OIDC) getJSON(url string, v interface{}) error {
+	resp, err := o.Client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v returned %v", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// provider is discovered once, failures are retried next time
+func (o *OIDC) provider() (oidcDiscovery, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.discovery != nil {
+		return *o.discovery, nil
+	}
+
+	var discovery oidcDiscovery
+	if err := o.getJSON(o.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return discovery, fmt.Errorf("failed to discover oidc provider: %w", err)
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != o.Issuer {
+		return discovery, fmt.Errorf("oidc provider says it is %v, expected %v", discovery.Issuer, o.Issuer)
+	}
+
+	o.discovery = &discovery
+	return discovery, nil
+}
+
+// key finds the signing key kid, refreshing the JWKS
+// once if it's unknown in case keys were rotated
+func (o *OIDC) key(kid string) (*rsa.PublicKey, error) {
+	discovery, err := o.provider()
+	if err != nil {
+		return nil, err
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if key, ok := o.keys[kid]; ok {
+		return key, nil
+	}
+
+	var jwks struct {
+		Keys []oidcJWK `json:"keys"`
+	}
+	if err := o.getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch oidc keys: %w", err)
+	}
+
+	o.keys = map[string]*rsa.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		o.keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	key, ok := o.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", errOIDCInvalidToken, kid)
+	}
+	return key, nil
+}
+
+// AuthCodeURL is where to send someone to log in
+func (o *OIDC) AuthCodeURL(state string, nonce string, verifier string) (string, error) {
+	discovery, err := o.provider()
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {o.ClientID},
+		"redirect_uri":          {o.RedirectURL},
+		"scope":                 {"openid profile email"},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades a code for the identity in its verified ID token
+func (o *OIDC) Exchange(code string, nonce string, verifier string, now time.Time) (oidcIdentity, error) {
+	var identity oidcIdentity
+
+	discovery, err := o.provider()
+	if err != nil {
+		return identity, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {o.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequest("POST", discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return identity, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return identity, fmt.Errorf("failed to exchange oidc code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return identity, fmt.Errorf("failed to exchange oidc code: %v", resp.Status)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return identity, fmt.Errorf("failed to decode oidc tokens: %w", err)
+	}
+
+	claims, err := o.verify(tokens.IDToken, discovery.Issuer, now)
+	if err != nil {
+		return identity, err
+	}
+	if claims["nonce"] != nonce {
+		return identity, fmt.Errorf("%w: wrong nonce", errOIDCInvalidToken)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return identity, fmt.Errorf("%w: no sub claim", errOIDCInvalidToken)
+	}
+	username, _ := claims[o.UsernameClaim].(string)
+	if username == "" {
+		return identity, fmt.Errorf("%w: no %v claim", errOIDCInvalidToken, o.UsernameClaim)
+	}
+	// some providers let anyone set any email address
+	if o.UsernameClaim == "email" && claims["email_verified"] != true {
+		return identity, fmt.Errorf("%w: email is not verified", errOIDCInvalidToken)
+	}
+
+	return oidcIdentity{
+		Issuer:   discovery.Issuer,
+		Subject:  subject,
+		Username: username,
+	}, nil
+}
+
+// verify checks the signature and standard claims of an ID token
+func (o *OIDC) verify(idToken string, issuer string, now time.Time) (map[string]interface{}, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a jwt", errOIDCInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported alg %q", errOIDCInvalidToken, header.Alg)
+	}
+
+	key, err := o.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad signature encoding", errOIDCInvalidToken)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", errOIDCInvalidToken)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	if claims["iss"] != issuer {
+		return nil, fmt.Errorf("%w: wrong issuer", errOIDCInvalidToken)
+	}
+	if !jwtAudienceContains(claims["aud"], o.ClientID) {
+		return nil, fmt.Errorf("%w: wrong audience", errOIDCInvalidToken)
+	}
+	exp, _ := claims["exp"].(float64)
+	if now.Add(-oidcClockSkew).Unix() >= int64(exp) {
+		return nil, fmt.Errorf("%w: expired", errOIDCInvalidToken)
+	}
+
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("%w: bad encoding", errOIDCInvalidToken)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: bad json", errOIDCInvalidToken)
+	}
+	return nil
+}
+
+// jwtAudienceContains handles aud being a string or a list
+func jwtAudienceContains(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, audience := range aud {
+			if audience == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// randomOIDCValue makes states, nonces and PKCE verifiers
+func randomOIDCValue() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
@@ -0,0 +1,322 @@
+package web
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/jobs"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockIssuer is just enough of an OIDC provider to log in with.
+// Codes are handed out by authorize and map to the claims
+// the token endpoint will sign.
+type mockIssuer struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+
+	// claims are signed into the next code's ID token,
+	// nonce and aud are filled in when missing
+	claims map[string]interface{}
+	// signer signs ID tokens, defaults to key
+	signer *rsa.PrivateKey
+
+	codes map[string]url.Values
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	issuer := &mockIssuer{t: t, key: key, codes: map[string]url.Values{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer.URL,
+			"authorization_endpoint": issuer.URL + "/authorize",
+			"token_endpoint":         issuer.URL + "/token",
+			"jwks_uri":               issuer.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "test",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		code := "code-" + r.FormValue("state")
+		issuer.codes[code] = r.URL.Query()
+		http.Redirect(w, r, r.FormValue("redirect_uri")+"?"+url.Values{
+			"code":  {code},
+			"state": {r.FormValue("state")},
+		}.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, clientSecret, _ := r.BasicAuth()
+		authorize, ok := issuer.codes[r.FormValue("code")]
+		if !ok || clientID != "creamy" || clientSecret != "shh" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		delete(issuer.codes, r.FormValue("code"))
+
+		challenge := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if authorize.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		claims := map[string]interface{}{
+			"iss": issuer.URL,
+			"aud": clientID,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range issuer.claims {
+			claims[k] = v
+		}
+		if _, ok := claims["nonce"]; !ok {
+			claims["nonce"] = authorize.Get("nonce")
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": issuer.sign(claims)})
+	})
+
+	issuer.Server = httptest.NewServer(mux)
+	return issuer
+}
+
+func (issuer *mockIssuer) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	signer := issuer.signer
+	if signer == nil {
+		signer = issuer.key
+	}
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, signer, crypto.SHA256, digest[:])
+	assert.Nil(issuer.t, err)
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	root := "test-oidc-login"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	issuer := newMockIssuer(t)
+	defer issuer.Close()
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, _ := testAuth(t, fs)
+	auth.ExternalRole = videostore.RoleUploader
+	auth.OIDC = NewOIDC(issuer.URL, "creamy", "shh", "http://creamy.test/login/oidc/callback", "preferred_username")
+	handler := NewWriteableCUI2(func(s string) string { return s }, func(s string) string { return s }, "", fs, repo, queue, []byte("xsrf key"), auth)
+
+	// login follows the whole flow, returning the callback response
+	login := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/login/oidc?next=/upload", nil))
+		assert.Equal(t, http.StatusFound, rec.Code)
+		cookies := rec.Result().Cookies()
+
+		resp, err := (&http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}).Get(rec.Header().Get("Location"))
+		assert.Nil(t, err)
+		resp.Body.Close()
+		callback, err := url.Parse(resp.Header.Get("Location"))
+		assert.Nil(t, err)
+		assert.Equal(t, "/login/oidc/callback", callback.Path)
+
+		req := httptest.NewRequest("GET", callback.RequestURI(), nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/login", nil))
+	assert.Contains(t, rec.Body.String(), "Log in with SSO")
+
+	issuer.claims = map[string]interface{}{"sub": "1", "preferred_username": "oscar"}
+	rec = login()
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "/upload", rec.Header().Get("Location"))
+
+	// the session works like any other
+	user, ok := auth.userFromSession(rec.Result().Cookies()[len(rec.Result().Cookies())-1].Value, time.Now())
+	assert.True(t, ok)
+	assert.Equal(t, "oscar", user.Username)
+	assert.Equal(t, videostore.RoleUploader, user.Role)
+	assert.Empty(t, user.PasswordHash)
+	assert.Equal(t, issuer.URL, user.OIDCIssuer)
+	assert.Equal(t, "1", user.OIDCSubject)
+
+	// and they're the same user next time, even if they were renamed
+	issuer.claims = map[string]interface{}{"sub": "1", "preferred_username": "oscar the grouch"}
+	rec = login()
+	assert.Equal(t, http.StatusFound, rec.Code)
+	users, err := auth.Users.All()
+	assert.Nil(t, err)
+	assert.Len(t, users, 2)
+
+	// usernames can't take over anyone else
+	issuer.claims = map[string]interface{}{"sub": "2", "preferred_username": "oscar"}
+	assert.Equal(t, http.StatusForbidden, login().Code)
+	issuer.claims = map[string]interface{}{"sub": "2", "preferred_username": "alice"}
+	assert.Equal(t, http.StatusForbidden, login().Code)
+
+	// but users from before identities were kept are linked
+	lena, err := auth.Users.Save(videostore.User{Username: "lena"})
+	assert.Nil(t, err)
+	issuer.claims = map[string]interface{}{"sub": "3", "preferred_username": "lena"}
+	assert.Equal(t, http.StatusFound, login().Code)
+	found, err := auth.Users.FindByOIDC(issuer.URL, "3")
+	assert.Nil(t, err)
+	assert.Equal(t, lena.ID, found.ID)
+
+	issuer.claims = map[string]interface{}{"preferred_username": "nobody"}
+	assert.Equal(t, http.StatusUnauthorized, login().Code)
+
+	issuer.claims = map[string]interface{}{"sub": "1", "preferred_username": "oscar", "nonce": "replayed"}
+	assert.Equal(t, http.StatusUnauthorized, login().Code)
+
+	issuer.claims = map[string]interface{}{"sub": "1", "preferred_username": "oscar", "aud": "someone else"}
+	assert.Equal(t, http.StatusUnauthorized, login().Code)
+
+	issuer.claims = map[string]interface{}{"sub": "1", "preferred_username": "oscar", "exp": time.Now().Add(-time.Hour).Unix()}
+	assert.Equal(t, http.StatusUnauthorized, login().Code)
+
+	issuer.claims = map[string]interface{}{"sub": "4", "email": "emma@example.com"}
+	assert.Equal(t, http.StatusUnauthorized, login().Code)
+
+	// emails are only trusted once the provider has verified them
+	auth.OIDC.UsernameClaim = "email"
+	assert.Equal(t, http.StatusUnauthorized, login().Code)
+	issuer.claims = map[string]interface{}{"sub": "4", "email": "emma@example.com", "email_verified": true}
+	assert.Equal(t, http.StatusFound, login().Code)
+	auth.OIDC.UsernameClaim = "preferred_username"
+
+	issuer.claims = map[string]interface{}{"sub": "1", "preferred_username": "oscar"}
+	issuer.signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusUnauthorized, login().Code)
+	issuer.signer = nil
+
+	// callbacks need the cookie from starting the login
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/login/oidc/callback?code=code-x&state=x", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/login/oidc?next=https://evil.example/", nil))
+	req := httptest.NewRequest("GET", "/login/oidc/callback?code=code-x&state=wrong", nil)
+	for _, cookie := range rec.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestOIDCDisabled(t *testing.T) {
+	root := "test-oidc-disabled"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, _ := testAuth(t, fs)
+	handler := NewWriteableCUI2(func(s string) string { return s }, func(s string) string { return s }, "", fs, repo, queue, []byte("xsrf key"), auth)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/login", nil))
+	assert.NotContains(t, rec.Body.String(), "Log in with SSO")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/login/oidc", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestProxyHeaderLogin(t *testing.T) {
+	root := "test-proxy-header-login"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	auth, cookie := testAuth(t, fs)
+	auth.ProxyHeader = "X-Forwarded-User"
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	assert.Nil(t, err)
+	auth.ProxyCIDRs = append(auth.ProxyCIDRs, proxies)
+
+	whoami := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := CurrentUser(r)
+		w.Write([]byte(user.Username + ":" + user.Role))
+	}))
+	request := func(remoteAddr string, username string, cookie *http.Cookie) string {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		if username != "" {
+			req.Header.Set("X-Forwarded-User", username)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		whoami.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, "paula:viewer", request("10.1.2.3:4567", "paula", nil))
+	assert.Equal(t, "alice:admin", request("10.1.2.3:4567", "alice", nil))
+	// the proxy wins over a session
+	assert.Equal(t, "paula:viewer", request("10.1.2.3:4567", "paula", cookie))
+
+	// anyone else can't pretend to be a proxy
+	assert.Equal(t, ":", request("192.168.1.2:4567", "alice", nil))
+	assert.Equal(t, "alice:admin", request("192.168.1.2:4567", "paula", cookie))
+	assert.Equal(t, ":", request("10.1.2.3:4567", "", nil))
+
+	// the API sees the same people
+	handler := NewWriteableAPI(func(s string) string { return s }, fs, videostore.NewDummyVideoRepo(fs), jobs.NewQueue(videostore.NewDummyJobRepo(fs)), auth)
+	req := httptest.NewRequest("POST", "/api/upload", strings.NewReader(""))
+	req.RemoteAddr = "10.1.2.3:4567"
+	req.Header.Set("X-Forwarded-User", "paula")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	req = httptest.NewRequest("POST", "/api/upload", strings.NewReader(""))
+	req.RemoteAddr = "192.168.1.2:4567"
+	req.Header.Set("X-Forwarded-User", "alice")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,237 @@
+package web
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+)
+
+var errQueryUnbalanced = errors.New("unbalanced parentheses")
+var errQueryUnterminated = errors.New("unterminated quote")
+var errQueryDangling = errors.New("operator without a term")
+var errQueryTooLong = errors.New("query is too long")
+var errQueryTooDeep = errors.New("query is nested too deeply")
+
+// queryMaxLength and queryMaxNesting keep anyone from
+// exhausting the stack with something like 500k of '('
+const queryMaxLength = 1024
+const queryMaxNesting = 64
+
+const (
+	queryTokenOpen  = "("
+	queryTokenClose = ")"
+	queryTokenOr    = "|"
+	queryTokenNot   = "-"
+	queryTokenTerm  = "term"
+)
+
+// queryFields maps field prefixes, like the `title` in `title:foo`, to what they match
+var queryFields = map[string]string{
+	"title": videostore.QueryFieldTitle,
+	"tag":   videostore.QueryFieldTag,
+	"tags":  videostore.QueryFieldTag,
+}
+
+type queryToken struct {
+	kind  string
+	field string
+	value string
+}
+
+func isQueryDelimiter(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '(' || c == ')' || c == '|'
+}
+
+// lexQuery splits a query like `cats -dogs title:"foo bar"` into tokens
+func lexQuery(raw string) ([]queryToken, error) {
+	var tokens []queryToken
+
+	for i := 0; i < len(raw); {
+		c := raw[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case c == '(' || c == ')' || c == '|':
+			tokens = append(tokens, queryToken{kind: string(c)})
+			i++
+			continue
+		case c == '-' && i+1 < len(raw) && (raw[i+1] == '(' || !isQueryDelimiter(raw[i+1])):
+			tokens = append(tokens, queryToken{kind: queryTokenNot})
+			i++
+			continue
+		}
+
+		token := queryToken{kind: queryTokenTerm}
+
+		// field prefix, anything unknown is searched for as-is
+		if colon := strings.IndexByte(raw[i:], ':'); colon > 0 {
+			prefix := raw[i : i+colon]
+			if field, ok := queryFields[strings.ToLower(prefix)]; ok {
+				token.field = field
+				i += colon + 1
+			}
+		}
+
+		if i < len(raw) && raw[i] == '"' {
+			end := strings.IndexByte(raw[i+1:], '"')
+			if end == -1 {
+				return nil, errQueryUnterminated
+			}
+			token.value = raw[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			start := i
+			for i < len(raw) && !isQueryDelimiter(raw[i]) {
+				i++
+			}
+			token.value = raw[start:i]
+		}
+
+		if token.value == "OR" && token.field == "" {
+			token = queryToken{kind: queryTokenOr}
+		} else if token.value == "" {
+			return nil, errQueryDangling
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	depth  int
+	// nesting counts the parseUnary calls in progress
+	nesting int
+}
+
+func (p *queryParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos].kind
+}
+
+// or := and ('|' and)*
+func (p *queryParser) parseOr() (videostore.QueryNode, error) {
+	node := videostore.QueryNode{Op: videostore.QueryOr}
+	for {
+		child, err := p.parseAnd()
+		if err != nil {
+			return node, err
+		}
+		node.Children = append(node.Children, child)
+
+		if p.peek() != queryTokenOr {
+			break
+		}
+		p.pos++
+	}
+
+	if len(node.Children) == 1 {
+		return node.Children[0], nil
+	}
+	return node, nil
+}
+
+// and := unary+
+func (p *queryParser) parseAnd() (videostore.QueryNode, error) {
+	node := videostore.QueryNode{Op: videostore.QueryAnd}
+	for {
+		next := p.peek()
+		if next == "" || next == queryTokenOr || next == queryTokenClose {
+			break
+		}
+
+		child, err := p.parseUnary()
+		if err != nil {
+			return node, err
+		}
+		node.Children = append(node.Children, child)
+	}
+
+	switch len(node.Children) {
+	case 0:
+		if p.depth == 0 && p.peek() == queryTokenClose {
+			return node, errQueryUnbalanced
+		}
+		return node, errQueryDangling
+	case 1:
+		return node.Children[0], nil
+	}
+	return node, nil
+}
+
+// unary := '-' unary | '(' or ')' | term
+func (p *queryParser) parseUnary() (videostore.QueryNode, error) {
+	p.nesting++
+	defer func() { p.nesting-- }()
+	if p.nesting > queryMaxNesting {
+		return videostore.QueryNode{}, errQueryTooDeep
+	}
+
+	if p.pos >= len(p.tokens) {
+		return videostore.QueryNode{}, errQueryDangling
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+
+	switch token.kind {
+	case queryTokenNot:
+		child, err := p.parseUnary()
+		if err != nil {
+			return child, err
+		}
+		return videostore.QueryNode{Op: videostore.QueryNot, Children: []videostore.QueryNode{child}}, nil
+	case queryTokenOpen:
+		p.depth++
+		node, err := p.parseOr()
+		if err != nil {
+			return node, err
+		}
+		if p.peek() != queryTokenClose {
+			return node, errQueryUnbalanced
+		}
+		p.pos++
+		p.depth--
+		return node, nil
+	case queryTokenTerm:
+		return videostore.QueryNode{Op: videostore.QueryTerm, Field: token.field, Value: token.value}, nil
+	case queryTokenClose:
+		return videostore.QueryNode{}, errQueryUnbalanced
+	}
+
+	return videostore.QueryNode{}, errQueryDangling
+}
+
+// parseQuery parses queries like `cats -dogs (funny | cute) title:"foo bar"`.
+// Words next to each other must all match, `|` or `OR` needs either side to match,
+// and `-` excludes a match. Bare words match a tag or part of the title,
+// prefix them with `title:` or `tag:` to only match one or the other.
+// A nil node is returned for a blank query.
+func parseQuery(raw string) (*videostore.QueryNode, error) {
+	if len(raw) > queryMaxLength {
+		return nil, errQueryTooLong
+	}
+
+	tokens, err := lexQuery(raw)
+	if err != nil || len(tokens) == 0 {
+		return nil, err
+	}
+
+	p := queryParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		// only a stray ')' stops parsing early
+		return nil, errQueryUnbalanced
+	}
+
+	return &node, nil
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseQuery(t *testing.T) {
+	valid := map[string]string{
+		"cats":                         "cats",
+		"cats -dogs (funny | cute)":    "(cats -dogs (funny | cute))",
+		`title:"foo bar"`:              "title:foo bar",
+		"Tag:dog TAGS:cat":             "(tag:dog tag:cat)",
+		"a | b c":                      "(a | (b c))",
+		"a OR b":                       "(a | b)",
+		"-(a b)":                       "-(a b)",
+		"--a":                          "--a",
+		"foo-bar":                      "foo-bar",
+		"a -":                          "(a -)",
+		"-":                            "-",
+		"what:ever":                    "what:ever",
+		`"cats | dogs"`:                "cats | dogs",
+		"((nested))":                   "nested",
+		"  spaced\tout  ":              "(spaced out)",
+		"(a | b) | -title:c tag:\"d\"": "((a | b) | (-title:c tag:d))",
+	}
+
+	for raw, expected := range valid {
+		query, err := parseQuery(raw)
+		assert.Nil(t, err, raw)
+		if assert.NotNil(t, query, raw) {
+			assert.Equal(t, expected, query.String(), raw)
+		}
+	}
+
+	invalid := map[string]error{
+		"(a":         errQueryUnbalanced,
+		"a)":         errQueryUnbalanced,
+		")":          errQueryUnbalanced,
+		`"a`:         errQueryUnterminated,
+		"a |":        errQueryDangling,
+		"| a":        errQueryDangling,
+		"()":         errQueryDangling,
+		"tag: a":     errQueryDangling,
+		`title:""`:   errQueryDangling,
+		"title:(a":   errQueryDangling,
+		"a (b | ) c": errQueryDangling,
+	}
+
+	for raw, expected := range invalid {
+		_, err := parseQuery(raw)
+		assert.Equal(t, expected, err, raw)
+	}
+
+	query, err := parseQuery("  ")
+	assert.Nil(t, err)
+	assert.Nil(t, query)
+
+	// deep nesting is refused instead of exhausting the stack
+	_, err = parseQuery(strings.Repeat("(", 100) + "a" + strings.Repeat(")", 100))
+	assert.Equal(t, errQueryTooDeep, err)
+	_, err = parseQuery(strings.Repeat("-", 100) + "a")
+	assert.Equal(t, errQueryTooDeep, err)
+	_, err = parseQuery(strings.Repeat("(", 500000))
+	assert.Equal(t, errQueryTooLong, err)
+	query, err = parseQuery(strings.Repeat("(", 30) + "a" + strings.Repeat(")", 30))
+	assert.Nil(t, err)
+	assert.Equal(t, "a", query.String())
+}
+
+func Test_filterQueryFromString(t *testing.T) {
+	anyText, query, err := filterQueryFromString("ba")
+	assert.Nil(t, err)
+	assert.Equal(t, "ba", anyText)
+	assert.Nil(t, query)
+
+	anyText, query, err = filterQueryFromString(`"foo bar"`)
+	assert.Nil(t, err)
+	assert.Equal(t, "foo bar", anyText)
+	assert.Nil(t, query)
+
+	anyText, query, err = filterQueryFromString("cats -dogs")
+	assert.Nil(t, err)
+	assert.Equal(t, "", anyText)
+	assert.Equal(t, videostore.QueryAnd, query.Op)
+
+	_, _, err = filterQueryFromString("(oops")
+	assert.Equal(t, errQueryUnbalanced, err)
+
+	_, err = videoFilterFromDict(url.Values{"filter": {"(oops"}})
+	assert.Equal(t, errQueryUnbalanced, err)
+}
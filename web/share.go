@@ -0,0 +1,92 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Share links let anyone watch a video until they expire,
+// even private ones, without logging in. Tokens look like
+// {expires}.{mac} and only work for the video they were made for.
+// They're signed with the XSRF key, changing it revokes every link.
+
+const shareCookieName = "creamy_share"
+
+const shareDefaultTTL = "24h"
+
+// shareTTLs are how long share links can last,
+// the choices on the share page
+var shareTTLs = []time.Duration{
+	time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+}
+
+func shareMAC(key []byte, videoID uint, expires int64) []byte {
+	mac := hmac.New(sha256.New, key)
+	// nothing else signed with this key starts with "share"
+	mac.Write([]byte(fmt.Sprintf("share:%v:%v", videoID, expires)))
+	return mac.Sum(nil)
+}
+
+// newShareToken makes a token for videoID that works until expires
+func newShareToken(key []byte, videoID uint, expires time.Time) string {
+	return fmt.Sprintf("%v.%v", expires.Unix(), base64.RawURLEncoding.EncodeToString(shareMAC(key, videoID, expires.Unix())))
+}
+
+// validShareToken reports whether token was made for videoID
+// and hasn't expired
+func validShareToken(key []byte, token string, videoID uint, now time.Time) bool {
+	// anyone could sign with an empty key
+	if len(key) == 0 || token == "" {
+		return false
+	}
+
+	rawExpires, rawMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(rawExpires, 10, 64)
+	if err != nil || now.Unix() >= expires {
+		return false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(rawMAC)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(mac, shareMAC(key, videoID, expires))
+}
+
+// shareToken finds the token sent with r, either in the URL
+// or the cookie left by Watch for the video's media
+func shareToken(r *http.Request) string {
+	if token := r.URL.Query().Get("share"); token != "" {
+		return token
+	}
+	if cookie, err := r.Cookie(shareCookieName); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// parseShareTTL only accepts one of shareTTLs
+func parseShareTTL(raw string) (time.Duration, bool) {
+	ttl, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, false
+	}
+	for _, allowed := range shareTTLs {
+		if ttl == allowed {
+			return ttl, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/jobs"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareToken(t *testing.T) {
+	key := []byte("xsrf key")
+	now := time.Now()
+	token := newShareToken(key, 3, now.Add(time.Hour))
+
+	assert.True(t, validShareToken(key, token, 3, now))
+	assert.False(t, validShareToken(key, token, 3, now.Add(time.Hour)), "expired")
+	assert.False(t, validShareToken(key, token, 4, now), "other video")
+	assert.False(t, validShareToken([]byte("other key"), token, 3, now), "other key")
+	assert.False(t, validShareToken(nil, newShareToken(nil, 3, now.Add(time.Hour)), 3, now), "no key")
+	assert.False(t, validShareToken(key, "", 3, now))
+	assert.False(t, validShareToken(key, "garbage", 3, now))
+
+	// pushing back the expiry breaks the signature
+	expires, mac, _ := strings.Cut(token, ".")
+	assert.False(t, validShareToken(key, expires+"0."+mac, 3, now))
+
+	ttl, ok := parseShareTTL("168h")
+	assert.True(t, ok)
+	assert.Equal(t, 7*24*time.Hour, ttl)
+	_, ok = parseShareTTL("8760h")
+	assert.False(t, ok)
+	_, ok = parseShareTTL("soon")
+	assert.False(t, ok)
+}
+
+func TestShareLinks(t *testing.T) {
+	root := "test-share-links"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, _ := testAuth(t, fs)
+	owner, ownerCookie := testUser(t, auth, "ursula", videostore.RoleUploader)
+	_, viewer := testUser(t, auth, "victor", videostore.RoleViewer)
+	u := &cUI2{XSRFKey: []byte("xsrf key"), Auth: auth}
+	ui := NewWriteableCUI2(
+		func(s string) string { return "http://creamy.test" + s },
+		func(s string) string { return "/static/videos/" + s },
+		"",
+		fs, repo, queue, u.XSRFKey, auth,
+	)
+	media := http.StripPrefix("/static/videos", auth.TokenMiddleware(NewVisibleMediaHandler(repo, u.XSRFKey, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("served " + r.URL.Path))
+	}))))
+
+	video, err := repo.Save(videostore.Video{Title: "doggo", Source: "1/video.mp4", OwnerID: owner.ID, Visibility: videostore.VisibilityPrivate})
+	assert.Nil(t, err)
+
+	request := func(handler http.Handler, method string, cookie *http.Cookie, target string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if form != nil {
+			form.Set("_xsrf", testXSRFToken(u, cookie))
+			req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request(ui, "GET", ownerCookie, "/watch/1", nil)
+	assert.Contains(t, rec.Body.String(), `href="/share/1"`)
+	// someone who can't see the video isn't told it exists
+	assert.Equal(t, http.StatusNotFound, request(ui, "GET", viewer, "/share/1", nil).Code)
+	assert.Equal(t, http.StatusNotFound, request(ui, "POST", viewer, "/share/1", url.Values{"ttl": {"24h"}}).Code)
+
+	rec = request(ui, "POST", ownerCookie, "/share/1", url.Values{"ttl": {"8760h"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = request(ui, "POST", ownerCookie, "/share/1", url.Values{"ttl": {"24h"}})
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	match := regexp.MustCompile(`data-e2e="Share Link" type="text" value="http://creamy.test(/watch/1\?share=([^"]+))"`).FindStringSubmatch(rec.Body.String())
+	if !assert.Len(t, match, 3) {
+		return
+	}
+	link, token := match[1], match[2]
+
+	// anyone with the link can watch, and fetch the video's files
+	assert.Equal(t, http.StatusNotFound, request(ui, "GET", nil, "/watch/1", nil).Code)
+	rec = request(ui, "GET", nil, link, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "doggo")
+	cookies := rec.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, shareCookieName, cookies[0].Name)
+		assert.Equal(t, "/static/videos/1/", cookies[0].Path)
+		assert.True(t, cookies[0].HttpOnly)
+	}
+	assert.Equal(t, http.StatusOK, request(media, "GET", cookies[0], "/static/videos/1/video.mp4", nil).Code)
+	assert.Equal(t, http.StatusOK, request(media, "GET", nil, "/static/videos/1/video.mp4?share="+token, nil).Code)
+	assert.Equal(t, http.StatusNotFound, request(media, "GET", nil, "/static/videos/1/video.mp4", nil).Code)
+
+	// but not other videos
+	_, err = repo.Save(videostore.Video{Title: "secret", Source: "2/video.mp4", OwnerID: owner.ID, Visibility: videostore.VisibilityPrivate})
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusNotFound, request(ui, "GET", nil, "/watch/2?share="+token, nil).Code)
+	assert.Equal(t, http.StatusNotFound, request(media, "GET", cookies[0], "/static/videos/2/video.mp4", nil).Code)
+
+	expired := newShareToken(u.XSRFKey, video.ID, time.Now().Add(-time.Minute))
+	assert.Equal(t, http.StatusNotFound, request(ui, "GET", nil, "/watch/1?share="+expired, nil).Code)
+	assert.Equal(t, http.StatusNotFound, request(media, "GET", nil, "/static/videos/1/video.mp4?share="+expired, nil).Code)
+}
@@ -0,0 +1,158 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/gorilla/mux"
+)
+
+const tagSuggestionLimit = 10
+
+type tagChanges struct {
+	Changed uint `json:"changed"`
+}
+
+type tagAliasChanges struct {
+	videostore.TagAlias
+	tagChanges
+}
+
+func (a *api) ListTags(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	tags, err := a.Repo.Tags(listedFor(r, videostore.VideoFilter{}))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error listing tags: %+v", err)
+		return
+	}
+
+	writeJSON(w, tags)
+}
+
+func (a *api) SuggestTags(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	tags, err := a.Repo.SuggestTags(listedFor(r, videostore.VideoFilter{}), strings.TrimSpace(r.URL.Query().Get("q")), tagSuggestionLimit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error suggesting tags: %+v", err)
+		return
+	}
+
+	writeJSON(w, tags)
+}
+
+func (a *api) mergeTags(w http.ResponseWriter, from []string, into string) {
+	changed, err := a.Repo.MergeTags(from, into)
+	if err == videostore.ErrorTagInvalid {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error merging tags %v into %v: %+v", from, into, err)
+		return
+	}
+
+	writeJSON(w, tagChanges{changed})
+}
+
+func (a *api) RenameTag(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	posted := struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	a.mergeTags(w, []string{posted.From}, posted.To)
+}
+
+func (a *api) MergeTags(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	posted := struct {
+		From []string `json:"from"`
+		Into string   `json:"into"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	a.mergeTags(w, posted.From, posted.Into)
+}
+
+func (a *api) ListTagAliases(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	aliases, err := a.Repo.TagAliases()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error listing tag aliases: %+v", err)
+		return
+	}
+
+	writeJSON(w, aliases)
+}
+
+// SetTagAlias saves the alias, then merges it into its tag on existing videos
+func (a *api) SetTagAlias(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	posted := videostore.TagAlias{}
+	if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	alias, err := a.Repo.SetTagAlias(posted.Alias, posted.Tag)
+	if err == videostore.ErrorTagInvalid || err == videostore.ErrorTagAliasCycle {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error saving tag alias: %+v", err)
+		return
+	}
+
+	changed, err := a.Repo.MergeTags([]string{alias.Alias}, alias.Tag)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error merging tag alias %v into %v: %+v", alias.Alias, alias.Tag, err)
+		return
+	}
+
+	writeJSON(w, tagAliasChanges{alias, tagChanges{changed}})
+}
+
+func (a *api) DeleteTagAlias(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	err := a.Repo.DeleteTagAlias(mux.Vars(r)["alias"])
+	if err == videostore.ErrorTagAliasNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error deleting tag alias: %+v", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,113 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/jobs"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPITags(t *testing.T) {
+	root := "test-api-tags"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, cookie := testAuth(t, fs)
+	handler := asUser(NewWriteableAPI(func(s string) string { return s }, fs, repo, queue, auth), cookie)
+
+	_, err := repo.Save(videostore.Video{Title: "doggo", Source: "/1/video.mp4", Tags: []string{"dog", "pupper"}})
+	assert.Nil(t, err)
+	_, err = repo.Save(videostore.Video{Title: "catto", Source: "/2/video.mp4", Tags: []string{"kitty"}})
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest("POST", "/api/tags/merge", strings.NewReader(`{"from":["pupper","kitty"],"into":"pet"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"changed":2}`, rec.Body.String())
+
+	req = httptest.NewRequest("POST", "/api/tags/rename", strings.NewReader(`{"from":"dog","to":"doggo"}`))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"changed":1}`, rec.Body.String())
+
+	req = httptest.NewRequest("POST", "/api/tags/rename", strings.NewReader(`{"from":"doggo","to":""}`))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req = httptest.NewRequest("GET", "/api/tags", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	tags := []videostore.TagCount{}
+	assert.Nil(t, json.NewDecoder(rec.Body).Decode(&tags))
+	assert.Equal(t, []videostore.TagCount{{Tag: "pet", Count: 2}, {Tag: "doggo", Count: 1}}, tags)
+
+	req = httptest.NewRequest("GET", "/api/tags/suggest?q=Do", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"tag":"doggo","count":1}]`, rec.Body.String())
+
+	// tags of videos someone can't find aren't listed for them either
+	_, err = repo.Save(videostore.Video{Title: "diary", Tags: []string{"secret"}, OwnerID: 99, Visibility: videostore.VisibilityPrivate})
+	assert.Nil(t, err)
+	for _, target := range []string{"/api/tags", "/api/tags/suggest?q=se"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		assert.NotContains(t, rec.Body.String(), "secret", target)
+	}
+}
+
+func TestAPITagAliases(t *testing.T) {
+	root := "test-api-tag-aliases"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, cookie := testAuth(t, fs)
+	handler := asUser(NewWriteableAPI(func(s string) string { return s }, fs, repo, queue, auth), cookie)
+
+	_, err := repo.Save(videostore.Video{Title: "catto", Tags: []string{"kitty"}})
+	assert.Nil(t, err)
+
+	req := httptest.NewRequest("POST", "/api/tags/aliases", strings.NewReader(`{"alias":"kitty","tag":"animals/cats"}`))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"alias":"kitty","tag":"animals/cats","changed":1}`, rec.Body.String())
+
+	req = httptest.NewRequest("POST", "/api/tags/aliases", strings.NewReader(`{"alias":"animals/cats","tag":"kitty"}`))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req = httptest.NewRequest("GET", "/api/tags/aliases", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"alias":"kitty","tag":"animals/cats"}]`, rec.Body.String())
+
+	req = httptest.NewRequest("DELETE", "/api/tags/aliases/kitty", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	req = httptest.NewRequest("DELETE", "/api/tags/aliases/kitty", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/jobs"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/pkg/errors"
+)
+
+// applyCustomThumbnail replaces the video's thumbnail with the uploaded
+// "thumbnail" image, or else queues taking the frame at "thumbnail_timestamp".
+// changed is false if neither was sent.
+func applyCustomThumbnail(r *http.Request, video videostore.Video, repo videostore.VideoRepo, fs files.FileSystem, queue *jobs.Queue) (updated videostore.Video, changed bool, err error) {
+	file, _, err := r.FormFile("thumbnail")
+	if err == nil {
+		defer file.Close()
+		video, err = videostore.SetThumbnailFromImage(video, repo, fs, file)
+		return video, true, err
+	}
+	if err != http.ErrMissingFile && err != http.ErrNotMultipart {
+		return video, false, errors.Wrap(err, "failed to read thumbnail")
+	}
+
+	rawTimestamp := r.FormValue("thumbnail_timestamp")
+	if rawTimestamp == "" {
+		return video, false, nil
+	}
+
+	seconds, err := parseTimestamp(rawTimestamp)
+	if err != nil {
+		return video, false, err
+	}
+
+	video, err = videostore.RequestThumbnailAt(video, repo, seconds)
+	if err != nil {
+		return video, true, err
+	}
+
+	_, err = queue.Enqueue(videostore.JobKindCustomThumbnail, video.ID)
+	return video, true, errors.Wrap(err, "failed to queue thumbnail")
+}
+
+// customThumbnailClientError reports whether err is the requester's fault
+func customThumbnailClientError(err error) bool {
+	return err == videostore.ErrorThumbnailInvalid ||
+		err == videostore.ErrorThumbnailTimestampInvalid ||
+		err == errTimestampInvalid
+}
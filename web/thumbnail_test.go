@@ -0,0 +1,83 @@
+package web
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/jobs"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIEditThumbnail(t *testing.T) {
+	root := "test-api-thumbnail"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	auth, cookie := testAuth(t, fs)
+	handler := asUser(NewWriteableAPI(func(s string) string { return s }, fs, repo, queue, auth), cookie)
+
+	video, err := repo.Save(videostore.Video{Title: "doggo", Source: "1/video.mp4"})
+	assert.Nil(t, err)
+	assert.Nil(t, fs.MkdirAll("1", os.ModePerm))
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("thumbnail", "doggo.png")
+	assert.Nil(t, png.Encode(part, image.NewRGBA(image.Rect(0, 0, 16, 9))))
+	form.Close()
+
+	req := httptest.NewRequest("POST", "/api/video/1/thumbnail", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	video, err = repo.FindById(video.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "1/thumbnail.jpg", video.Thumbnail)
+	assert.True(t, video.CustomThumbnail)
+	assert.Nil(t, video.ThumbnailTimestamp)
+
+	// timestamps are taken in the background
+	video.Duration = 10
+	video, err = repo.Save(video)
+	assert.Nil(t, err)
+	req = httptest.NewRequest("POST", "/api/video/1/thumbnail", strings.NewReader("thumbnail_timestamp=0:07"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	video, err = repo.FindById(video.ID)
+	assert.Nil(t, err)
+	assert.True(t, video.CustomThumbnail)
+	if assert.NotNil(t, video.ThumbnailTimestamp) {
+		assert.Equal(t, 7.0, *video.ThumbnailTimestamp)
+	}
+	queued, err := queue.Repo.All(videostore.JobFilter{VideoID: video.ID}, 10, 0)
+	assert.Nil(t, err)
+	if assert.Len(t, queued, 1) {
+		assert.Equal(t, videostore.JobKindCustomThumbnail, queued[0].Kind)
+	}
+
+	// bad timestamps and empty requests are rejected before ffmpeg is involved
+	for _, values := range []url.Values{{"thumbnail_timestamp": {"soon"}}, {"thumbnail_timestamp": {"0:30"}}, {}} {
+		req = httptest.NewRequest("POST", "/api/video/1/thumbnail", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
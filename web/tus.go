@@ -0,0 +1,580 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/jobs"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/gorilla/mux"
+)
+
+// Resumable uploads implementing the core tus 1.0.0 protocol
+// with the creation and termination extensions:
+// https://tus.io/protocols/resumable-upload
+//
+// Each PATCH is stored as its own chunk file beside an info.json
+// under uploads/{uploadID}/, since FileSystem has no way to append.
+// Once every byte has arrived the video is created, the chunks are
+// stitched together into the usual {id}/video.ext layout, and the
+// upload is removed.
+
+const (
+	tusVersion       = "1.0.0"
+	tusExtensions    = "creation,termination"
+	tusUploadsDir    = "uploads"
+	tusInfoFile      = "info.json"
+	tusOffsetHeader  = "Upload-Offset"
+	tusLengthHeader  = "Upload-Length"
+	tusVideoIDHeader = "Creamy-Video-ID"
+	tusContentType   = "application/offset+octet-stream"
+
+	// tusMaxLength is the biggest upload accepted, 64GiB
+	tusMaxLength = 64 << 30
+)
+
+var errTusUploadNotFound = errors.New("upload not found")
+
+type tusUpload struct {
+	ID       string            `json:"id"`
+	Length   int64             `json:"length"`
+	Offset   int64             `json:"offset"`
+	Metadata map[string]string `json:"metadata"`
+	Chunks   []string          `json:"chunks"`
+
+	// VideoID is set once finishing starts,
+	// so retries reuse the video instead of making another
+	VideoID uint `json:"video_id"`
+	OwnerID uint `json:"owner_id"`
+
+	// Finished is saved once the video has its source and before
+	// processing is enqueued, so a retry only cleans up
+	Finished bool `json:"finished"`
+}
+
+func (upload tusUpload) dir() string {
+	return path.Join(tusUploadsDir, upload.ID)
+}
+
+func (upload tusUpload) complete() bool {
+	return upload.Offset >= upload.Length
+}
+
+type tusUploads struct {
+	FS   files.FileSystem
+	Repo videostore.VideoRepo
+	Jobs *jobs.Queue
+
+	busy     map[string]bool
+	busyLock sync.Mutex
+}
+
+func newTusUploads(fs files.FileSystem, repo videostore.VideoRepo, queue *jobs.Queue) *tusUploads {
+	return &tusUploads{
+		FS:   fs,
+		Repo: repo,
+		Jobs: queue,
+		busy: map[string]bool{},
+	}
+}
+
+// lock marks an upload as busy, returning false if
+// another request is already working on it
+func (t *tusUploads) lock(id string) bool {
+	t.busyLock.Lock()
+	defer t.busyLock.Unlock()
+	if t.busy[id] {
+		return false
+	}
+	t.busy[id] = true
+	return true
+}
+
+func (t *tusUploads) unlock(id string) {
+	t.busyLock.Lock()
+	defer t.busyLock.Unlock()
+	delete(t.busy, id)
+}
+
+func (t *tusUploads) load(id string) (tusUpload, error) {
+	upload := tusUpload{}
+
+	file, err := t.FS.Open(path.Join(tusUploadsDir, id, tusInfoFile))
+	if t.FS.IsNotExist(err) {
+		return upload, errTusUploadNotFound
+	}
+	if err != nil {
+		return upload, err
+	}
+	defer file.Close()
+
+	err = json.NewDecoder(file).Decode(&upload)
+	return upload, err
+}
+
+// loadOwn is load, but uploads started by someone else
+// can't be seen
+func (t *tusUploads) loadOwn(r *http.Request, id string) (tusUpload, error) {
+	upload, err := t.load(id)
+	if err != nil {
+		return upload, err
+	}
+
+	user, _ := CurrentUser(r)
+	if upload.OwnerID != user.ID {
+		return tusUpload{}, errTusUploadNotFound
+	}
+	return upload, nil
+}
+
+func (t *tusUploads) save(upload tusUpload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+	return files.PipeTo(t.FS, path.Join(upload.dir(), tusInfoFile), strings.NewReader(string(data)))
+}
+
+func (t *tusUploads) remove(upload tusUpload) {
+	for _, chunk := range upload.Chunks {
+		if err := t.FS.Remove(path.Join(upload.dir(), chunk)); err != nil && !t.FS.IsNotExist(err) {
+			log.Printf("failed to remove upload chunk %v: %+v", chunk, err)
+		}
+	}
+	if err := t.FS.Remove(path.Join(upload.dir(), tusInfoFile)); err != nil && !t.FS.IsNotExist(err) {
+		log.Printf("failed to remove upload info %v: %+v", upload.ID, err)
+	}
+	if err := t.FS.Remove(upload.dir()); err != nil && !t.FS.IsNotExist(err) {
+		log.Printf("failed to remove upload dir %v: %+v", upload.ID, err)
+	}
+}
+
+// parseTusMetadata decodes an Upload-Metadata header:
+// comma-separated pairs of a key and an optional base64 value
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, fmt.Errorf("bad metadata pair %q", pair)
+		}
+
+		value := ""
+		if len(parts) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("bad metadata value for %v: %w", parts[0], err)
+			}
+			value = string(decoded)
+		}
+		metadata[parts[0]] = value
+	}
+
+	return metadata, nil
+}
+
+func randomUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// checkVersion rejects requests from clients speaking another tus version
+func (t *tusUploads) checkVersion(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func (t *tusUploads) writeUploadHeaders(w http.ResponseWriter, upload tusUpload) {
+	w.Header().Set(tusOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set(tusLengthHeader, strconv.FormatInt(upload.Length, 10))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+func (t *tusUploads) Options(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(tusMaxLength, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (t *tusUploads) Create(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	if !t.checkVersion(w, r) {
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get(tusLengthHeader), 10, 64)
+	if err != nil || length < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Bad Upload-Length"))
+		return
+	}
+	if length > tusMaxLength {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	metadata, err := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("Bad Upload-Metadata: %v", err)))
+		return
+	}
+	if metadata["filename"] == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Upload-Metadata must contain filename"))
+		return
+	}
+	// checked now rather than after the whole file is sent
+	if visibility := metadata["visibility"]; visibility != "" && !videostore.ValidVisibility(visibility) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(videostore.ErrorVisibilityInvalid.Error()))
+		return
+	}
+
+	id, err := randomUploadID()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error generating upload id: %+v", err)
+		return
+	}
+
+	upload := tusUpload{
+		ID:       id,
+		Length:   length,
+		Metadata: metadata,
+		Chunks:   []string{},
+	}
+	if user, ok := CurrentUser(r); ok {
+		upload.OwnerID = user.ID
+	}
+
+	if err := t.FS.MkdirAll(upload.dir(), os.ModePerm); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error creating upload dir: %+v", err)
+		return
+	}
+
+	if err := t.save(upload); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error saving upload: %+v", err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/uploads/"+upload.ID)
+	t.writeUploadHeaders(w, upload)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (t *tusUploads) Head(w http.ResponseWriter, r *http.Request) {
+	if !t.checkVersion(w, r) {
+		return
+	}
+
+	upload, err := t.loadOwn(r, mux.Vars(r)["uploadID"])
+	if err == errTusUploadNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error loading upload: %+v", err)
+		return
+	}
+
+	t.writeUploadHeaders(w, upload)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (t *tusUploads) Patch(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	if !t.checkVersion(w, r) {
+		return
+	}
+
+	if r.Header.Get("Content-Type") != tusContentType {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	id := mux.Vars(r)["uploadID"]
+	if !t.lock(id) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("Upload is busy"))
+		return
+	}
+	defer t.unlock(id)
+
+	upload, err := t.loadOwn(r, id)
+	if err == errTusUploadNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error loading upload: %+v", err)
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get(tusOffsetHeader), 10, 64)
+	if err != nil || offset != upload.Offset {
+		t.writeUploadHeaders(w, upload)
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	if !upload.complete() {
+		chunk := fmt.Sprintf("%020d.part", upload.Offset)
+		file, err := t.FS.Create(path.Join(upload.dir(), chunk))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Printf("error creating upload chunk: %+v", err)
+			return
+		}
+
+		// keep whatever made it through, even if the connection dropped
+		written, copyErr := io.Copy(file, io.LimitReader(r.Body, upload.Length-upload.Offset))
+		closeErr := file.Close()
+		if closeErr != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Printf("error closing upload chunk: %+v", closeErr)
+			return
+		}
+
+		if written > 0 {
+			upload.Chunks = append(upload.Chunks, chunk)
+			upload.Offset += written
+			if err := t.save(upload); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				log.Printf("error saving upload: %+v", err)
+				return
+			}
+		} else {
+			t.FS.Remove(path.Join(upload.dir(), chunk))
+		}
+
+		if copyErr != nil {
+			log.Printf("upload %v interrupted at offset %v: %+v", upload.ID, upload.Offset, copyErr)
+			t.writeUploadHeaders(w, upload)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	// a completed upload is retried here if finishing failed previously
+	if upload.complete() {
+		video, err := t.finish(upload)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			log.Printf("error finishing upload %v: %+v", upload.ID, err)
+			return
+		}
+		w.Header().Set(tusVideoIDHeader, strconv.Itoa(int(video.ID)))
+	}
+
+	t.writeUploadHeaders(w, upload)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finish turns a complete upload into a video.
+// Chunks are checked before the video is created,
+// and the upload is only removed once it's done.
+func (t *tusUploads) finish(upload tusUpload) (videostore.Video, error) {
+	if upload.Finished {
+		video, err := t.Repo.FindById(upload.VideoID)
+		if err != nil {
+			return video, fmt.Errorf("error finding video: %w", err)
+		}
+		t.remove(upload)
+		return video, nil
+	}
+
+	if err := t.checkChunks(upload); err != nil {
+		return videostore.Video{}, err
+	}
+
+	video, err := t.startVideo(upload)
+	if err != nil {
+		return video, err
+	}
+	upload.VideoID = video.ID
+
+	rootDir := strconv.Itoa(int(video.ID))
+	if _, err := t.FS.Stat(rootDir); t.FS.IsNotExist(err) {
+		t.FS.MkdirAll(rootDir, os.ModePerm)
+	}
+
+	videoPath := path.Join(rootDir, "video"+path.Ext(video.OriginalFileName))
+
+	file, err := t.FS.Create(videoPath)
+	if err != nil {
+		return video, fmt.Errorf("error creating video stream: %w", err)
+	}
+	var written int64
+	for _, chunk := range upload.Chunks {
+		n, err := t.appendChunk(file, path.Join(upload.dir(), chunk))
+		written += n
+		if err != nil {
+			file.Close()
+			return video, fmt.Errorf("error saving video stream: %w", err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return video, fmt.Errorf("error saving video stream: %w", err)
+	}
+	if written != upload.Length {
+		return video, fmt.Errorf("stitched %v bytes, expected %v", written, upload.Length)
+	}
+
+	video.Source = videoPath
+	video, err = t.Repo.Save(video)
+	if err != nil {
+		return video, fmt.Errorf("error setting video source: %w", err)
+	}
+
+	upload.Finished = true
+	if err := t.save(upload); err != nil {
+		return video, fmt.Errorf("error saving upload: %w", err)
+	}
+
+	enqueueVideoProcessing(t.Jobs, video)
+
+	go debug.FreeOSMemory() // hack to request our memory back :'(
+
+	t.remove(upload)
+
+	return video, nil
+}
+
+// checkChunks makes sure every byte of upload is still stored
+func (t *tusUploads) checkChunks(upload tusUpload) error {
+	var size int64
+	for _, chunk := range upload.Chunks {
+		info, err := t.FS.Stat(path.Join(upload.dir(), chunk))
+		if err != nil {
+			return fmt.Errorf("error checking upload chunk %v: %w", chunk, err)
+		}
+		size += info.Size()
+	}
+	if size != upload.Length {
+		return fmt.Errorf("upload chunks hold %v bytes, expected %v", size, upload.Length)
+	}
+	return nil
+}
+
+// startVideo creates the video for upload, or finds the one
+// created by an earlier attempt. Its ID is saved before anything
+// else can fail.
+func (t *tusUploads) startVideo(upload tusUpload) (videostore.Video, error) {
+	if upload.VideoID != 0 {
+		video, err := t.Repo.FindById(upload.VideoID)
+		if err != videostore.ErrorVideoNotFound {
+			return video, err
+		}
+		// deleted in the meantime, start over
+	}
+
+	title := upload.Metadata["title"]
+	if title == "" {
+		title = upload.Metadata["filename"]
+	}
+
+	video, err := t.Repo.Save(videostore.Video{
+		Title:            title,
+		Description:      upload.Metadata["description"],
+		OriginalFileName: upload.Metadata["filename"],
+		Tags:             splitTags(upload.Metadata["tags"]),
+		OwnerID:          upload.OwnerID,
+		Visibility:       upload.Metadata["visibility"],
+	})
+	if err != nil {
+		return video, fmt.Errorf("error creating video: %w", err)
+	}
+
+	upload.VideoID = video.ID
+	if err := t.save(upload); err != nil {
+		if deleteErr := t.Repo.Delete(video); deleteErr != nil {
+			log.Printf("failed to remove video %v of upload %v: %+v", video.ID, upload.ID, deleteErr)
+		}
+		return video, fmt.Errorf("error saving upload: %w", err)
+	}
+
+	return video, nil
+}
+
+func (t *tusUploads) appendChunk(dest io.Writer, chunkPath string) (int64, error) {
+	chunk, err := t.FS.Open(chunkPath)
+	if err != nil {
+		return 0, err
+	}
+	defer chunk.Close()
+
+	return io.Copy(dest, chunk)
+}
+
+func (t *tusUploads) Terminate(w http.ResponseWriter, r *http.Request) {
+	if !t.checkVersion(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["uploadID"]
+	if !t.lock(id) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte("Upload is busy"))
+		return
+	}
+	defer t.unlock(id)
+
+	upload, err := t.loadOwn(r, id)
+	if err == errTusUploadNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error loading upload: %+v", err)
+		return
+	}
+
+	// a video left behind by a failed finish goes too
+	if upload.VideoID != 0 {
+		video, err := t.Repo.FindById(upload.VideoID)
+		if err == nil && video.Source == "" {
+			if err := t.Repo.Delete(video); err != nil {
+				log.Printf("failed to remove video %v of upload %v: %+v", video.ID, upload.ID, err)
+			}
+		}
+	}
+
+	t.remove(upload)
+	w.WriteHeader(http.StatusNoContent)
+}
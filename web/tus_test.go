@@ -0,0 +1,247 @@
+package web
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/jobs"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseTusMetadata(t *testing.T) {
+	b64 := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+
+	metadata, err := parseTusMetadata("filename " + b64("doggo.mp4") + ",tags " + b64("dog, cute") + ",is_confidential")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		"filename":        "doggo.mp4",
+		"tags":            "dog, cute",
+		"is_confidential": "",
+	}, metadata)
+
+	metadata, err = parseTusMetadata("")
+	assert.Nil(t, err)
+	assert.Empty(t, metadata)
+
+	_, err = parseTusMetadata("filename not-base64!")
+	assert.NotNil(t, err)
+}
+
+func TestTusUploadFlow(t *testing.T) {
+	root := "test-tus-upload"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	noop := func(job videostore.Job) error { return nil }
+	queue.Handle(videostore.JobKindProbe, noop)
+	queue.Handle(videostore.JobKindThumbnail, noop)
+	auth, cookie := testAuth(t, fs)
+	handler := asUser(NewWriteableAPI(func(s string) string { return s }, fs, repo, queue, auth), cookie)
+
+	do := func(method string, target string, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Tus-Resumable", tusVersion)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	content := "pretend this is a video"
+
+	invalid := do("POST", "/api/uploads", "", map[string]string{
+		"Upload-Length":   strconv.Itoa(len(content)),
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("doggo.mp4")) + ",visibility " + base64.StdEncoding.EncodeToString([]byte("secret")),
+	})
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+
+	created := do("POST", "/api/uploads", "", map[string]string{
+		"Upload-Length":   strconv.Itoa(len(content)),
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("doggo.mp4")) + ",visibility " + base64.StdEncoding.EncodeToString([]byte("unlisted")),
+	})
+	assert.Equal(t, http.StatusCreated, created.Code)
+	location := created.Header().Get("Location")
+	assert.True(t, strings.HasPrefix(location, "/api/uploads/"))
+
+	first := do("PATCH", location, content[:10], map[string]string{
+		"Content-Type":  tusContentType,
+		"Upload-Offset": "0",
+	})
+	assert.Equal(t, http.StatusNoContent, first.Code)
+	assert.Equal(t, "10", first.Header().Get("Upload-Offset"))
+
+	// resuming from the wrong offset is rejected
+	conflict := do("PATCH", location, content[5:], map[string]string{
+		"Content-Type":  tusContentType,
+		"Upload-Offset": "5",
+	})
+	assert.Equal(t, http.StatusConflict, conflict.Code)
+
+	head := do("HEAD", location, "", nil)
+	assert.Equal(t, http.StatusOK, head.Code)
+	assert.Equal(t, "10", head.Header().Get("Upload-Offset"))
+	assert.Equal(t, "", head.Header().Get(tusVideoIDHeader))
+
+	last := do("PATCH", location, content[10:], map[string]string{
+		"Content-Type":  tusContentType,
+		"Upload-Offset": "10",
+	})
+	assert.Equal(t, http.StatusNoContent, last.Code)
+	assert.Equal(t, strconv.Itoa(len(content)), last.Header().Get("Upload-Offset"))
+
+	videoID, err := strconv.Atoi(last.Header().Get(tusVideoIDHeader))
+	assert.Nil(t, err)
+
+	video, err := repo.FindById(uint(videoID))
+	assert.Nil(t, err)
+	assert.Equal(t, "doggo.mp4", video.Title)
+	assert.Equal(t, videostore.VisibilityUnlisted, video.Visibility)
+	assert.Equal(t, strconv.Itoa(videoID)+"/video.mp4", video.Source)
+
+	file, err := fs.Open(video.Source)
+	assert.Nil(t, err)
+	stored, _ := io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, content, string(stored))
+
+	queued, err := queue.Repo.All(videostore.JobFilter{VideoID: video.ID}, 10, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(queued))
+	// newest first
+	assert.Equal(t, videostore.JobKindThumbnail, queued[0].Kind)
+	assert.Equal(t, videostore.JobKindProbe, queued[1].Kind)
+
+	// finished uploads are cleaned up
+	assert.Equal(t, http.StatusNotFound, do("HEAD", location, "", nil).Code)
+	_, err = fs.Stat(path.Join(tusUploadsDir, strings.TrimPrefix(location, "/api/uploads/"), tusInfoFile))
+	assert.True(t, fs.IsNotExist(err))
+
+	tooBig := do("POST", "/api/uploads", "", map[string]string{
+		"Upload-Length":   strconv.Itoa(tusMaxLength + 1),
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("doggo.mp4")),
+	})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, tooBig.Code)
+
+	// no video is made from an upload missing some of its bytes
+	created = do("POST", "/api/uploads", "", map[string]string{
+		"Upload-Length":   strconv.Itoa(len(content)),
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("broken.mp4")),
+	})
+	location = created.Header().Get("Location")
+	first = do("PATCH", location, content[:10], map[string]string{
+		"Content-Type":  tusContentType,
+		"Upload-Offset": "0",
+	})
+	assert.Equal(t, http.StatusNoContent, first.Code)
+	uploadDir := path.Join(tusUploadsDir, strings.TrimPrefix(location, "/api/uploads/"))
+	assert.Nil(t, fs.Remove(path.Join(uploadDir, fmt.Sprintf("%020d.part", 0))))
+
+	last = do("PATCH", location, content[10:], map[string]string{
+		"Content-Type":  tusContentType,
+		"Upload-Offset": "10",
+	})
+	assert.Equal(t, http.StatusInternalServerError, last.Code)
+	count, err := repo.Count(videostore.VideoFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), count)
+
+	terminated := do("DELETE", location, "", nil)
+	assert.Equal(t, http.StatusNoContent, terminated.Code)
+	assert.Equal(t, http.StatusNotFound, do("HEAD", location, "", nil).Code)
+}
+
+func TestTusFinishRetryReusesVideo(t *testing.T) {
+	root := "test-tus-retry"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	uploads := newTusUploads(fs, repo, jobs.NewQueue(videostore.NewDummyJobRepo(fs)))
+
+	upload := tusUpload{ID: "abc", Length: 4, Offset: 4, Metadata: map[string]string{"filename": "doggo.mp4"}, Chunks: []string{"0.part"}}
+	assert.Nil(t, fs.MkdirAll(upload.dir(), os.ModePerm))
+	assert.Nil(t, files.PipeTo(fs, path.Join(upload.dir(), "0.part"), strings.NewReader("woof")))
+	assert.Nil(t, uploads.save(upload))
+
+	// an earlier attempt got as far as creating the video
+	started, err := uploads.startVideo(upload)
+	assert.Nil(t, err)
+	upload, err = uploads.load(upload.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, started.ID, upload.VideoID)
+
+	video, err := uploads.finish(upload)
+	assert.Nil(t, err)
+	assert.Equal(t, started.ID, video.ID)
+	assert.Equal(t, "1/video.mp4", video.Source)
+
+	count, err := repo.Count(videostore.VideoFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), count)
+}
+
+func TestTusFinishRetryAfterFinished(t *testing.T) {
+	root := "test-tus-retry-finished"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	queue := jobs.NewQueue(videostore.NewDummyJobRepo(fs))
+	queue.Handle(videostore.JobKindProbe, func(job videostore.Job) error { return nil })
+	uploads := newTusUploads(fs, repo, queue)
+
+	upload := tusUpload{ID: "abc", Length: 4, Offset: 4, Metadata: map[string]string{"filename": "doggo.mp4"}, Chunks: []string{"0.part"}}
+	assert.Nil(t, fs.MkdirAll(upload.dir(), os.ModePerm))
+	assert.Nil(t, files.PipeTo(fs, path.Join(upload.dir(), "0.part"), strings.NewReader("woof")))
+	assert.Nil(t, uploads.save(upload))
+
+	// until it has a source, the video isn't listed
+	started, err := uploads.startVideo(upload)
+	assert.Nil(t, err)
+	count, err := repo.Count(videostore.VideoFilter{Listed: true})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), count)
+
+	upload, err = uploads.load(upload.ID)
+	assert.Nil(t, err)
+	video, err := uploads.finish(upload)
+	assert.Nil(t, err)
+	assert.Equal(t, started.ID, video.ID)
+
+	// pretend removing the upload failed last time
+	upload.VideoID = video.ID
+	upload.Finished = true
+	assert.Nil(t, fs.MkdirAll(upload.dir(), os.ModePerm))
+	assert.Nil(t, uploads.save(upload))
+
+	video, err = uploads.finish(upload)
+	assert.Nil(t, err)
+	assert.Equal(t, started.ID, video.ID)
+
+	queued, err := queue.Repo.All(videostore.JobFilter{VideoID: video.ID}, 10, 0)
+	assert.Nil(t, err)
+	assert.Len(t, queued, 1, "processing is only enqueued once")
+
+	_, err = uploads.load(upload.ID)
+	assert.Equal(t, errTusUploadNotFound, err)
+
+	count, err = repo.Count(videostore.VideoFilter{Listed: true})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), count)
+}
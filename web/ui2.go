@@ -10,8 +10,10 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/jobs"
 	"github.com/AlbinoDrought/creamy-videos/ui2/static"
 	"github.com/AlbinoDrought/creamy-videos/ui2/tmpl"
 	"github.com/AlbinoDrought/creamy-videos/videostore"
@@ -38,6 +40,22 @@ type CreamyVideosUI2 interface {
 
 	DeleteForm(w http.ResponseWriter, r *http.Request) // skipped for JS clients
 	Delete(w http.ResponseWriter, r *http.Request)
+
+	Tags(w http.ResponseWriter, r *http.Request)
+	MergeTags(w http.ResponseWriter, r *http.Request)
+
+	LoginForm(w http.ResponseWriter, r *http.Request)
+	Login(w http.ResponseWriter, r *http.Request)
+	OIDCLogin(w http.ResponseWriter, r *http.Request)
+	OIDCCallback(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+
+	ShareForm(w http.ResponseWriter, r *http.Request)
+	Share(w http.ResponseWriter, r *http.Request)
+
+	Tokens(w http.ResponseWriter, r *http.Request)
+	CreateToken(w http.ResponseWriter, r *http.Request)
+	RevokeToken(w http.ResponseWriter, r *http.Request)
 }
 
 type sortDir map[string]string
@@ -67,6 +85,14 @@ var sortDirs = map[string]sortDir{
 		"sort_field":     "title",
 		"sort_direction": videostore.SortDirectionDescending,
 	}),
+	"longest": sortDir(map[string]string{
+		"sort_field":     "duration",
+		"sort_direction": videostore.SortDirectionDescending,
+	}),
+	"shortest": sortDir(map[string]string{
+		"sort_field":     "duration",
+		"sort_direction": videostore.SortDirectionAscending,
+	}),
 }
 
 var defaultSortDir = "newest"
@@ -77,20 +103,38 @@ type cUI2 struct {
 	PublicAssetURL tmpl.PublicURLGenerator
 	FS             files.FileSystem
 	Repo           videostore.VideoRepo
+	Jobs           *jobs.Queue
 	XSRFKey        []byte
+	Auth           *Auth
+	MediaOrigin    string
 }
 
 var _ CreamyVideosUI2 = &cUI2{}
 
-func (u *cUI2) baseAppState() tmpl.AppState {
+// xsrfUserID binds XSRF tokens to whoever is logged in,
+// "0" when nobody is
+func xsrfUserID(r *http.Request) string {
+	if user, ok := CurrentUser(r); ok {
+		return strconv.Itoa(int(user.ID))
+	}
+	return "0"
+}
+
+func (u *cUI2) baseAppState(r *http.Request) tmpl.AppState {
 	appState := tmpl.AppState{
-		ReadOnly: u.ReadOnly,
-		PUG:      u.PublicAssetURL,
+		ReadOnly:    u.ReadOnly,
+		PUG:         u.PublicAssetURL,
+		MediaOrigin: u.MediaOrigin,
 	}
 
 	if !appState.ReadOnly {
+		userID := xsrfUserID(r)
 		appState.XSRFToken = func() string {
-			return xsrftoken.Generate(string(u.XSRFKey), "0", "0")
+			return xsrftoken.Generate(string(u.XSRFKey), userID, "0")
+		}
+
+		if user, ok := CurrentUser(r); ok {
+			appState.User = user
 		}
 	}
 
@@ -99,8 +143,8 @@ func (u *cUI2) baseAppState() tmpl.AppState {
 
 var ErrXSRFInvalid = errors.New("xsrf token invalid")
 
-func (u *cUI2) validateXSRF(val string) error {
-	if xsrftoken.Valid(val, string(u.XSRFKey), "0", "0") {
+func (u *cUI2) validateXSRF(r *http.Request, val string) error {
+	if xsrftoken.Valid(val, string(u.XSRFKey), xsrfUserID(r), "0") {
 		return nil
 	}
 	return ErrXSRFInvalid
@@ -110,7 +154,7 @@ func (u *cUI2) WriteErrorPage(w http.ResponseWriter, r *http.Request, statusCode
 	log.Printf("%v error: %v", msg, err)
 	w.Header().Add("Content-Type", "text/html")
 	w.WriteHeader(statusCode)
-	tmpl.ErrorPage(u.baseAppState(), msg).Render(r.Context(), w)
+	tmpl.ErrorPage(u.baseAppState(r), msg).Render(r.Context(), w)
 }
 
 func (u *cUI2) Home(w http.ResponseWriter, r *http.Request) {
@@ -126,11 +170,16 @@ func (u *cUI2) Home(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
-	filter := videoFilterFromDict(sortDir(map[string]string{
+	filter, err := videoFilterFromDict(sortDir(map[string]string{
 		"tags":           "home",
 		"sort_field":     "time_created",
 		"sort_direction": videostore.SortDirectionDescending,
 	}))
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusInternalServerError, err, "failed listing videos")
+		return
+	}
+	filter = listedFor(r, filter)
 
 	videos, err := u.Repo.All(filter, uint(limit), uint(offset))
 	if err != nil {
@@ -145,7 +194,7 @@ func (u *cUI2) Home(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Add("Content-Type", "text/html")
-	tmpl.Home(u.baseAppState(), tmpl.Paging{
+	tmpl.Home(u.baseAppState(r), tmpl.Paging{
 		URL: func(p int) string {
 			return fmt.Sprintf("/?page=%v", p)
 		},
@@ -205,12 +254,23 @@ func (u *cUI2) Search(w http.ResponseWriter, r *http.Request) {
 		"tags":   r.URL.Query().Get("tags"),
 		"title":  r.URL.Query().Get("title"),
 		"filter": r.URL.Query().Get("text"),
+
+		"min_duration": r.URL.Query().Get("min_duration"),
+		"max_duration": r.URL.Query().Get("max_duration"),
+		"min_height":   r.URL.Query().Get("min_height"),
+
+		"owner_id": r.URL.Query().Get("owner_id"),
 	}
 	for k, v := range sortDirs[sort] {
 		filterArgs[k] = v
 	}
 
-	filter := videoFilterFromDict(sortDir(filterArgs))
+	filter, err := videoFilterFromDict(sortDir(filterArgs))
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusBadRequest, err, "bad search: "+err.Error())
+		return
+	}
+	filter = listedFor(r, filter)
 
 	videos, err := u.Repo.All(filter, uint(limit), uint(offset))
 	if err != nil {
@@ -225,7 +285,7 @@ func (u *cUI2) Search(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Add("Content-Type", "text/html")
-	appState := u.baseAppState()
+	appState := u.baseAppState(r)
 	appState.Sortable = true
 	appState.SortDirection = sort
 	appState.SearchText = r.URL.Query().Get("text")
@@ -238,6 +298,10 @@ func (u *cUI2) Search(w http.ResponseWriter, r *http.Request) {
 					"tags", r.URL.Query().Get("tags"),
 					"title", r.URL.Query().Get("title"),
 					"text", r.URL.Query().Get("text"),
+					"min_duration", r.URL.Query().Get("min_duration"),
+					"max_duration", r.URL.Query().Get("max_duration"),
+					"min_height", r.URL.Query().Get("min_height"),
+					"owner_id", r.URL.Query().Get("owner_id"),
 					"page", strconv.Itoa(p),
 				},
 			)
@@ -266,14 +330,57 @@ func (u *cUI2) Watch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !canView(r, video) {
+		token := r.URL.Query().Get("share")
+		if !validShareToken(u.XSRFKey, token, video.ID, time.Now()) {
+			u.WriteErrorPage(w, r, http.StatusNotFound, videostore.ErrorVideoNotFound, "video not found")
+			return
+		}
+		u.shareMedia(w, video, token)
+	}
+
+	// read-only instances don't know about users
+	var owner videostore.User
+	if u.Auth != nil && video.OwnerID != 0 {
+		found, err := u.Auth.Users.FindById(video.OwnerID)
+		if err == nil {
+			owner = found
+		} else if err != videostore.ErrorUserNotFound {
+			log.Printf("failed finding owner of video %v: %+v", video.ID, err)
+		}
+	}
+
 	w.Header().Add("Content-Type", "text/html")
-	tmpl.Watch(u.baseAppState(), video).Render(r.Context(), w)
+	tmpl.Watch(u.baseAppState(r), video, owner).Render(r.Context(), w)
+}
+
+// shareMedia lets the browser fetch video's files with a share token.
+// Playlists and tracks load more files relative to themselves,
+// so the token is kept in a cookie rather than every URL.
+func (u *cUI2) shareMedia(w http.ResponseWriter, video videostore.Video, token string) {
+	mediaURL, err := url.Parse(u.PublicAssetURL(fmt.Sprintf("%v/", video.ID)))
+	if err != nil {
+		log.Printf("failed finding media path of video %v: %+v", video.ID, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     shareCookieName,
+		Value:    token,
+		Path:     mediaURL.Path,
+		HttpOnly: true,
+		Secure:   u.Auth != nil && u.Auth.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set("Cache-Control", "private")
+	w.Header().Set("Referrer-Policy", "no-referrer")
 }
 
 func (u *cUI2) UploadForm(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "text/html")
-	tmpl.UploadForm(u.baseAppState(), tmpl.VideoFormState{
-		Tags: "home",
+	tmpl.UploadForm(u.baseAppState(r), tmpl.VideoFormState{
+		Tags:       "home",
+		Visibility: videostore.VisibilityPublic,
 	}).Render(r.Context(), w)
 }
 
@@ -282,12 +389,13 @@ func (u *cUI2) Upload(w http.ResponseWriter, r *http.Request) {
 		log.Printf("%v error: %v", msg, err)
 		w.Header().Add("Content-Type", "text/html")
 		w.WriteHeader(statusCode)
-		tmpl.UploadForm(u.baseAppState(), tmpl.VideoFormState{
+		tmpl.UploadForm(u.baseAppState(r), tmpl.VideoFormState{
 			Error: msg,
 
 			Title:       r.FormValue("title"),
 			Tags:        r.FormValue("tags"),
 			Description: r.FormValue("description"),
+			Visibility:  r.FormValue("visibility"),
 		}).Render(r.Context(), w)
 	}
 
@@ -297,20 +405,12 @@ func (u *cUI2) Upload(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.MultipartForm.RemoveAll()
 
-	if err := u.validateXSRF(r.FormValue("_xsrf")); err != nil {
+	if err := u.validateXSRF(r, r.FormValue("_xsrf")); err != nil {
 		writeErrorPage(http.StatusUnprocessableEntity, err, "XSRF token expired")
 		return
 	}
 
-	// convert "foo, bar" and "foo,bar" into
-	// ["foo", "bar"]
-	tags := strings.Split(r.FormValue("tags"), ",")
-	for i, tag := range tags {
-		tags[i] = strings.Trim(tag, " ")
-	}
-	if len(tags) == 1 && tags[0] == "" {
-		tags = []string{}
-	}
+	tags := splitTags(r.FormValue("tags"))
 
 	file, header, err := r.FormFile("file")
 	if err != nil {
@@ -324,9 +424,17 @@ func (u *cUI2) Upload(w http.ResponseWriter, r *http.Request) {
 		Description:      r.FormValue("description"),
 		OriginalFileName: header.Filename,
 		Tags:             tags,
+		Visibility:       r.FormValue("visibility"),
+	}
+	if user, ok := CurrentUser(r); ok {
+		video.OwnerID = user.ID
 	}
 
 	video, err = u.Repo.Save(video)
+	if err == videostore.ErrorVisibilityInvalid {
+		writeErrorPage(http.StatusBadRequest, err, "Pick public, unlisted or private")
+		return
+	}
 	if err != nil {
 		writeErrorPage(http.StatusInternalServerError, err, "Internal error creating video resource")
 		return
@@ -353,12 +461,7 @@ func (u *cUI2) Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	go func() {
-		_, err := videostore.GenerateThumbnail(video, u.Repo, u.FS)
-		if err != nil {
-			log.Printf("failed to make thumbnail: %+v", err)
-		}
-	}()
+	enqueueVideoProcessing(u.Jobs, video)
 
 	go debug.FreeOSMemory() // hack to request our memory back :'(
 
@@ -385,10 +488,11 @@ func (u *cUI2) EditForm(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Add("Content-Type", "text/html")
-	tmpl.EditForm(u.baseAppState(), tmpl.VideoFormState{
+	tmpl.EditForm(u.baseAppState(r), tmpl.VideoFormState{
 		Title:       video.Title,
 		Tags:        strings.Join(video.Tags, ", "),
 		Description: video.Description,
+		Visibility:  video.Visibility,
 	}, video).Render(r.Context(), w)
 }
 
@@ -415,12 +519,15 @@ func (u *cUI2) Edit(w http.ResponseWriter, r *http.Request) {
 		log.Printf("%v error: %v", msg, err)
 		w.Header().Add("Content-Type", "text/html")
 		w.WriteHeader(statusCode)
-		tmpl.EditForm(u.baseAppState(), tmpl.VideoFormState{
+		tmpl.EditForm(u.baseAppState(r), tmpl.VideoFormState{
 			Error: msg,
 
 			Title:       r.FormValue("title"),
 			Tags:        r.FormValue("tags"),
 			Description: r.FormValue("description"),
+			Visibility:  r.FormValue("visibility"),
+
+			ThumbnailTimestamp: r.FormValue("thumbnail_timestamp"),
 		}, video).Render(r.Context(), w)
 	}
 
@@ -430,34 +537,126 @@ func (u *cUI2) Edit(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.MultipartForm.RemoveAll()
 
-	if err := u.validateXSRF(r.FormValue("_xsrf")); err != nil {
+	if err := u.validateXSRF(r, r.FormValue("_xsrf")); err != nil {
 		writeErrorPage(http.StatusUnprocessableEntity, err, "XSRF token expired")
 		return
 	}
 
-	// convert "foo, bar" and "foo,bar" into
-	// ["foo", "bar"]
-	tags := strings.Split(r.FormValue("tags"), ",")
-	for i, tag := range tags {
-		tags[i] = strings.Trim(tag, " ")
-	}
-	if len(tags) == 1 && tags[0] == "" {
-		tags = []string{}
-	}
+	tags := splitTags(r.FormValue("tags"))
 
 	video.Title = r.FormValue("title")
 	video.Tags = tags
 	video.Description = r.FormValue("description")
+	if visibility := r.FormValue("visibility"); visibility != "" {
+		video.Visibility = visibility
+	}
 
 	video, err = u.Repo.Save(video)
+	if err == videostore.ErrorVisibilityInvalid {
+		writeErrorPage(http.StatusBadRequest, err, "Pick public, unlisted or private")
+		return
+	}
 	if err != nil {
 		writeErrorPage(http.StatusInternalServerError, err, "Internal error updating video resource")
 		return
 	}
 
+	video, _, err = applyCustomThumbnail(r, video, u.Repo, u.FS, u.Jobs)
+	if customThumbnailClientError(err) {
+		writeErrorPage(http.StatusBadRequest, err, err.Error())
+		return
+	}
+	if err != nil {
+		writeErrorPage(http.StatusInternalServerError, err, "Internal error updating thumbnail")
+		return
+	}
+
 	http.Redirect(w, r, fmt.Sprintf("/watch/%v", video.ID), http.StatusFound)
 }
 
+func (u *cUI2) ShareForm(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	rawID := vars["id"]
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusBadRequest, err, "bad ID")
+		return
+	}
+
+	video, err := u.Repo.FindById(uint(id))
+	if err == videostore.ErrorVideoNotFound {
+		u.WriteErrorPage(w, r, http.StatusNotFound, err, "video not found")
+		return
+	}
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusInternalServerError, err, "failed finding video")
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	tmpl.Share(u.baseAppState(r), video, tmpl.ShareFormState{
+		TTL: shareDefaultTTL,
+	}).Render(r.Context(), w)
+}
+
+func (u *cUI2) Share(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	rawID := vars["id"]
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusBadRequest, err, "bad ID")
+		return
+	}
+
+	video, err := u.Repo.FindById(uint(id))
+	if err == videostore.ErrorVideoNotFound {
+		u.WriteErrorPage(w, r, http.StatusNotFound, err, "video not found")
+		return
+	}
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusInternalServerError, err, "failed finding video")
+		return
+	}
+
+	writeErrorPage := func(statusCode int, err error, msg string) {
+		log.Printf("%v error: %v", msg, err)
+		w.Header().Add("Content-Type", "text/html")
+		w.WriteHeader(statusCode)
+		tmpl.Share(u.baseAppState(r), video, tmpl.ShareFormState{
+			Error: msg,
+			TTL:   r.FormValue("ttl"),
+		}).Render(r.Context(), w)
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeErrorPage(http.StatusBadRequest, err, "Bad form")
+		return
+	}
+
+	if err := u.validateXSRF(r, r.FormValue("_xsrf")); err != nil {
+		writeErrorPage(http.StatusUnprocessableEntity, err, "XSRF token expired")
+		return
+	}
+
+	ttl, ok := parseShareTTL(r.FormValue("ttl"))
+	if !ok {
+		writeErrorPage(http.StatusBadRequest, fmt.Errorf("bad ttl %q", r.FormValue("ttl")), "Pick how long the link works for")
+		return
+	}
+
+	expires := time.Now().Add(ttl)
+	token := newShareToken(u.XSRFKey, video.ID, expires)
+
+	w.Header().Add("Content-Type", "text/html")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	tmpl.Share(u.baseAppState(r), video, tmpl.ShareFormState{
+		TTL:     r.FormValue("ttl"),
+		Link:    u.PublicRootURL(fmt.Sprintf("/watch/%v?share=%v", video.ID, url.QueryEscape(token))),
+		Expires: expires.Format(time.RFC1123),
+	}).Render(r.Context(), w)
+}
+
 func (u *cUI2) DeleteForm(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	rawID := vars["id"]
@@ -478,7 +677,7 @@ func (u *cUI2) DeleteForm(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Add("Content-Type", "text/html")
-	tmpl.DeleteForm(u.baseAppState(), tmpl.VideoFormState{}, video).Render(r.Context(), w)
+	tmpl.DeleteForm(u.baseAppState(r), tmpl.VideoFormState{}, video).Render(r.Context(), w)
 }
 
 func (u *cUI2) Delete(w http.ResponseWriter, r *http.Request) {
@@ -504,12 +703,12 @@ func (u *cUI2) Delete(w http.ResponseWriter, r *http.Request) {
 		log.Printf("%v error: %v", msg, err)
 		w.Header().Add("Content-Type", "text/html")
 		w.WriteHeader(statusCode)
-		tmpl.DeleteForm(u.baseAppState(), tmpl.VideoFormState{
+		tmpl.DeleteForm(u.baseAppState(r), tmpl.VideoFormState{
 			Error: msg,
 		}, video).Render(r.Context(), w)
 	}
 
-	if err := u.validateXSRF(r.FormValue("_xsrf")); err != nil {
+	if err := u.validateXSRF(r, r.FormValue("_xsrf")); err != nil {
 		writeErrorPage(http.StatusUnprocessableEntity, err, "XSRF token expired")
 		return
 	}
@@ -520,27 +719,275 @@ func (u *cUI2) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = u.FS.Stat(video.Source)
-	if !u.FS.IsNotExist(err) {
-		// video exists, attempt to delete
-		err = u.FS.Remove(video.Source)
-		if err != nil {
-			log.Print(errors.Wrap(err, "failed to remove video from disk"))
-		}
+	if err := videostore.RemoveVideoFiles(video, u.FS); err != nil {
+		log.Print(errors.Wrap(err, "failed to remove video from disk"))
 	}
 
-	_, err = u.FS.Stat(video.Thumbnail)
-	if !u.FS.IsNotExist(err) {
-		// thumbnail exists, attempt to delete
-		err = u.FS.Remove(video.Thumbnail)
-		if err != nil {
-			log.Print(errors.Wrap(err, "failed to remove thumbnail from disk"))
-		}
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+func (u *cUI2) renderTags(w http.ResponseWriter, r *http.Request, statusCode int, tagFormState tmpl.TagFormState) {
+	tags, err := u.Repo.Tags(listedFor(r, videostore.VideoFilter{}))
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusInternalServerError, err, "Failed to list tags")
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	w.WriteHeader(statusCode)
+	tmpl.Tags(u.baseAppState(r), tags, tagFormState).Render(r.Context(), w)
+}
+
+func (u *cUI2) Tags(w http.ResponseWriter, r *http.Request) {
+	u.renderTags(w, r, http.StatusOK, tmpl.TagFormState{})
+}
+
+func (u *cUI2) MergeTags(w http.ResponseWriter, r *http.Request) {
+	writeErrorPage := func(statusCode int, err error, msg string) {
+		log.Printf("%v error: %v", msg, err)
+		u.renderTags(w, r, statusCode, tmpl.TagFormState{
+			Error: msg,
+
+			From: r.FormValue("from"),
+			Into: r.FormValue("into"),
+		})
+	}
+
+	if err := u.validateXSRF(r, r.FormValue("_xsrf")); err != nil {
+		writeErrorPage(http.StatusUnprocessableEntity, err, "XSRF token expired")
+		return
 	}
 
+	_, err := u.Repo.MergeTags(splitTags(r.FormValue("from")), strings.TrimSpace(r.FormValue("into")))
+	if err == videostore.ErrorTagInvalid {
+		writeErrorPage(http.StatusBadRequest, err, "Tags can't be empty")
+		return
+	}
+	if err != nil {
+		writeErrorPage(http.StatusInternalServerError, err, "Internal error merging tags")
+		return
+	}
+
+	http.Redirect(w, r, "/tags", http.StatusFound)
+}
+
+func (u *cUI2) LoginForm(w http.ResponseWriter, r *http.Request) {
+	next := safeRedirect(r.FormValue("next"))
+	if _, ok := CurrentUser(r); ok {
+		http.Redirect(w, r, next, http.StatusFound)
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	tmpl.LoginForm(u.baseAppState(r), tmpl.LoginFormState{
+		Next: next,
+		SSO:  u.Auth.OIDC != nil,
+	}).Render(r.Context(), w)
+}
+
+func (u *cUI2) Login(w http.ResponseWriter, r *http.Request) {
+	writeErrorPage := func(statusCode int, err error, msg string) {
+		log.Printf("%v error: %v", msg, err)
+		w.Header().Add("Content-Type", "text/html")
+		w.WriteHeader(statusCode)
+		tmpl.LoginForm(u.baseAppState(r), tmpl.LoginFormState{
+			Error: msg,
+
+			Username: r.FormValue("username"),
+			Next:     safeRedirect(r.FormValue("next")),
+			SSO:      u.Auth.OIDC != nil,
+		}).Render(r.Context(), w)
+	}
+
+	if err := u.validateXSRF(r, r.FormValue("_xsrf")); err != nil {
+		writeErrorPage(http.StatusUnprocessableEntity, err, "XSRF token expired")
+		return
+	}
+
+	user, err := u.Auth.Users.FindByUsername(r.FormValue("username"))
+	if err != nil && err != videostore.ErrorUserNotFound {
+		writeErrorPage(http.StatusInternalServerError, err, "Internal error finding user")
+		return
+	}
+	if err == videostore.ErrorUserNotFound || !user.CheckPassword(r.FormValue("password")) {
+		writeErrorPage(http.StatusUnauthorized, err, "Wrong username or password")
+		return
+	}
+
+	u.Auth.Login(w, user)
+	http.Redirect(w, r, safeRedirect(r.FormValue("next")), http.StatusFound)
+}
+
+// OIDCLogin sends someone to the SSO provider to log in,
+// they come back to OIDCCallback
+func (u *cUI2) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	login, err := newOIDCLogin(r.FormValue("next"))
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusInternalServerError, err, "Internal error starting login")
+		return
+	}
+
+	loginURL, err := u.Auth.OIDC.AuthCodeURL(login.State, login.Nonce, login.Verifier)
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusBadGateway, err, "SSO provider is unavailable")
+		return
+	}
+
+	if err := u.Auth.startOIDCLogin(w, login); err != nil {
+		u.WriteErrorPage(w, r, http.StatusInternalServerError, err, "Internal error starting login")
+		return
+	}
+
+	http.Redirect(w, r, loginURL, http.StatusFound)
+}
+
+func (u *cUI2) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	login, ok := u.Auth.finishOIDCLogin(w, r, r.FormValue("state"))
+	if !ok {
+		u.WriteErrorPage(w, r, http.StatusBadRequest, nil, "Login expired, try again")
+		return
+	}
+
+	if providerError := r.FormValue("error"); providerError != "" {
+		u.WriteErrorPage(w, r, http.StatusUnauthorized, errors.New(providerError), "SSO provider refused login")
+		return
+	}
+
+	identity, err := u.Auth.OIDC.Exchange(r.FormValue("code"), login.Nonce, login.Verifier, time.Now())
+	if errors.Is(err, errOIDCInvalidToken) {
+		u.WriteErrorPage(w, r, http.StatusUnauthorized, err, "SSO provider sent an invalid login")
+		return
+	}
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusBadGateway, err, "SSO provider is unavailable")
+		return
+	}
+
+	user, err := u.Auth.oidcUser(identity)
+	if err == videostore.ErrorUsernameInvalid {
+		u.WriteErrorPage(w, r, http.StatusUnauthorized, err, "SSO username can't be used here")
+		return
+	}
+	if err == errOIDCUsernameTaken {
+		u.WriteErrorPage(w, r, http.StatusForbidden, err, "SSO username is already taken here")
+		return
+	}
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusInternalServerError, err, "Internal error finding user")
+		return
+	}
+
+	u.Auth.Login(w, user)
+	http.Redirect(w, r, login.Next, http.StatusFound)
+}
+
+func (u *cUI2) Logout(w http.ResponseWriter, r *http.Request) {
+	if err := u.validateXSRF(r, r.FormValue("_xsrf")); err != nil {
+		u.WriteErrorPage(w, r, http.StatusUnprocessableEntity, err, "XSRF token expired")
+		return
+	}
+
+	u.Auth.Logout(w)
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
+func (u *cUI2) renderTokens(w http.ResponseWriter, r *http.Request, statusCode int, tokenFormState tmpl.TokenFormState) {
+	user, _ := CurrentUser(r)
+	tokens, err := u.Auth.Tokens.ForUser(user.ID)
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusInternalServerError, err, "Failed to list API tokens")
+		return
+	}
+
+	w.Header().Add("Content-Type", "text/html")
+	w.WriteHeader(statusCode)
+	tmpl.Tokens(u.baseAppState(r), tokens, tokenFormState).Render(r.Context(), w)
+}
+
+func (u *cUI2) Tokens(w http.ResponseWriter, r *http.Request) {
+	u.renderTokens(w, r, http.StatusOK, tmpl.TokenFormState{
+		Scopes: []string{videostore.ScopeRead},
+	})
+}
+
+func (u *cUI2) CreateToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		u.WriteErrorPage(w, r, http.StatusBadRequest, err, "Bad form")
+		return
+	}
+
+	writeErrorPage := func(statusCode int, err error, msg string) {
+		log.Printf("%v error: %v", msg, err)
+		u.renderTokens(w, r, statusCode, tmpl.TokenFormState{
+			Error: msg,
+
+			Name:   r.FormValue("name"),
+			Scopes: r.Form["scopes"],
+		})
+	}
+
+	if err := u.validateXSRF(r, r.FormValue("_xsrf")); err != nil {
+		writeErrorPage(http.StatusUnprocessableEntity, err, "XSRF token expired")
+		return
+	}
+
+	user, _ := CurrentUser(r)
+	token, secret, err := videostore.NewAPIToken(user, r.FormValue("name"), r.Form["scopes"])
+	if err == videostore.ErrorScopeInvalid {
+		writeErrorPage(http.StatusBadRequest, err, "Pick at least one scope")
+		return
+	}
+	if err != nil {
+		writeErrorPage(http.StatusInternalServerError, err, "Internal error creating API token")
+		return
+	}
+
+	if _, err := u.Auth.Tokens.Save(token); err != nil {
+		writeErrorPage(http.StatusInternalServerError, err, "Internal error saving API token")
+		return
+	}
+
+	u.renderTokens(w, r, http.StatusCreated, tmpl.TokenFormState{
+		Scopes: []string{videostore.ScopeRead},
+		Secret: secret,
+	})
+}
+
+func (u *cUI2) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	if err := u.validateXSRF(r, r.FormValue("_xsrf")); err != nil {
+		u.WriteErrorPage(w, r, http.StatusUnprocessableEntity, err, "XSRF token expired")
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusBadRequest, err, "bad ID")
+		return
+	}
+
+	// someone else's tokens look the same as missing ones
+	user, _ := CurrentUser(r)
+	token, err := u.Auth.Tokens.FindById(uint(id))
+	if err == nil && token.UserID != user.ID {
+		err = videostore.ErrorAPITokenNotFound
+	}
+	if err == videostore.ErrorAPITokenNotFound {
+		u.WriteErrorPage(w, r, http.StatusNotFound, err, "API token not found")
+		return
+	}
+	if err != nil {
+		u.WriteErrorPage(w, r, http.StatusInternalServerError, err, "Failed finding API token")
+		return
+	}
+
+	if err := u.Auth.Tokens.Delete(token); err != nil {
+		u.WriteErrorPage(w, r, http.StatusInternalServerError, err, "Failed revoking API token")
+		return
+	}
+
+	http.Redirect(w, r, "/tokens", http.StatusFound)
+}
+
 func (u *cUI2) RobotsTXT(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
 	w.Write([]byte(fmt.Sprintf(`Sitemap: %v`, u.PublicRootURL("/sitemap.xml"))))
@@ -553,7 +1000,9 @@ func (u *cUI2) SitemapXML(w http.ResponseWriter, r *http.Request) {
 	const limit = 100
 	offset := uint(0)
 	for {
+		// only public videos, even for whoever is logged in
 		videos, err := u.Repo.All(videostore.VideoFilter{
+			Listed:        true,
 			SortDirection: videostore.SortDirectionAscending,
 			SortField:     videostore.SortFieldTimeCreated,
 		}, limit, offset)
@@ -584,9 +1033,12 @@ func (u *cUI2) SitemapXML(w http.ResponseWriter, r *http.Request) {
 func NewWriteableCUI2(
 	publicRootURL tmpl.PublicURLGenerator,
 	publicAssetURL tmpl.PublicURLGenerator,
+	mediaOrigin string,
 	fs files.FileSystem,
 	repo videostore.VideoRepo,
+	queue *jobs.Queue,
 	xsrfKey []byte,
+	auth *Auth,
 ) http.Handler {
 	u := &cUI2{
 		ReadOnly:       false,
@@ -594,10 +1046,15 @@ func NewWriteableCUI2(
 		PublicAssetURL: publicAssetURL,
 		FS:             fs,
 		Repo:           repo,
+		Jobs:           queue,
 		XSRFKey:        xsrfKey,
+		Auth:           auth,
+		MediaOrigin:    mediaOrigin,
 	}
 
+	authz := authorizer{repo, u.deny}
 	r := mux.NewRouter()
+	r.Use(auth.Middleware)
 
 	fileServer := httpgzip.FileServer(
 		http.FS(static.FS),
@@ -632,11 +1089,11 @@ func NewWriteableCUI2(
 
 	r.HandleFunc(
 		"/upload",
-		u.UploadForm,
+		authz.Require(videostore.PermissionUpload, u.UploadForm),
 	).Methods("GET")
 	r.HandleFunc(
 		"/upload",
-		u.Upload,
+		authz.Require(videostore.PermissionUpload, u.Upload),
 	).Methods("POST")
 
 	r.HandleFunc(
@@ -646,20 +1103,76 @@ func NewWriteableCUI2(
 
 	r.HandleFunc(
 		"/edit/{id:[0-9]+}",
-		u.EditForm,
+		authz.RequireVideo(videostore.ScopeEdit, videostore.User.CanEdit, u.EditForm),
 	).Methods("GET")
 	r.HandleFunc(
 		"/edit/{id:[0-9]+}",
-		u.Edit,
+		authz.RequireVideo(videostore.ScopeEdit, videostore.User.CanEdit, u.Edit),
+	).Methods("POST")
+
+	r.HandleFunc(
+		"/share/{id:[0-9]+}",
+		authz.RequireVideo(videostore.ScopeEdit, videostore.User.CanEdit, u.ShareForm),
+	).Methods("GET")
+	r.HandleFunc(
+		"/share/{id:[0-9]+}",
+		authz.RequireVideo(videostore.ScopeEdit, videostore.User.CanEdit, u.Share),
 	).Methods("POST")
 
 	r.HandleFunc(
 		"/delete/{id:[0-9]+}",
-		u.DeleteForm,
+		authz.RequireVideo(videostore.ScopeDelete, videostore.User.CanDelete, u.DeleteForm),
 	).Methods("GET")
 	r.HandleFunc(
 		"/delete/{id:[0-9]+}",
-		u.Delete,
+		authz.RequireVideo(videostore.ScopeDelete, videostore.User.CanDelete, u.Delete),
+	).Methods("POST")
+
+	r.HandleFunc(
+		"/tags",
+		u.Tags,
+	).Methods("GET")
+	r.HandleFunc(
+		"/tags",
+		authz.Require(videostore.PermissionEditAny, u.MergeTags),
+	).Methods("POST")
+
+	r.HandleFunc(
+		"/login",
+		u.LoginForm,
+	).Methods("GET")
+	r.HandleFunc(
+		"/login",
+		u.Login,
+	).Methods("POST")
+
+	r.HandleFunc(
+		"/logout",
+		u.Logout,
+	).Methods("POST")
+
+	if auth.OIDC != nil {
+		r.HandleFunc(
+			"/login/oidc",
+			u.OIDCLogin,
+		).Methods("GET")
+		r.HandleFunc(
+			"/login/oidc/callback",
+			u.OIDCCallback,
+		).Methods("GET")
+	}
+
+	r.HandleFunc(
+		"/tokens",
+		authz.RequireUser(u.Tokens),
+	).Methods("GET")
+	r.HandleFunc(
+		"/tokens",
+		authz.RequireUser(u.CreateToken),
+	).Methods("POST")
+	r.HandleFunc(
+		"/tokens/{id:[0-9]+}/revoke",
+		authz.RequireUser(u.RevokeToken),
 	).Methods("POST")
 
 	return r
@@ -668,6 +1181,7 @@ func NewWriteableCUI2(
 func NewReadOnlyCUI2(
 	publicRootURL tmpl.PublicURLGenerator,
 	publicAssetURL tmpl.PublicURLGenerator,
+	mediaOrigin string,
 	repo videostore.VideoRepo,
 ) http.Handler {
 	u := &cUI2{
@@ -675,6 +1189,7 @@ func NewReadOnlyCUI2(
 		PublicRootURL:  publicRootURL,
 		PublicAssetURL: publicAssetURL,
 		Repo:           repo,
+		MediaOrigin:    mediaOrigin,
 	}
 
 	r := mux.NewRouter()
@@ -715,5 +1230,10 @@ func NewReadOnlyCUI2(
 		u.Watch,
 	).Methods("GET")
 
+	r.HandleFunc(
+		"/tags",
+		u.Tags,
+	).Methods("GET")
+
 	return r
 }
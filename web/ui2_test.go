@@ -2,7 +2,16 @@ package web
 
 import (
 	"fmt"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
 	"testing"
+
+	"github.com/AlbinoDrought/creamy-videos/files"
+	"github.com/AlbinoDrought/creamy-videos/videostore"
+	"github.com/stretchr/testify/assert"
 )
 
 func Test_queryJoin(t *testing.T) {
@@ -56,3 +65,56 @@ func Test_queryJoin(t *testing.T) {
 		})
 	}
 }
+
+func TestSearchBadQuery(t *testing.T) {
+	root := "test-search-bad-query"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+	_, err := repo.Save(videostore.Video{Title: "(oops"})
+	assert.Nil(t, err)
+
+	ui := NewReadOnlyCUI2(func(s string) string { return s }, func(s string) string { return s }, "", repo)
+	api := NewReadOnlyAPI(func(s string) string { return s }, fs, repo, nil)
+
+	rec := httptest.NewRecorder()
+	ui.ServeHTTP(rec, httptest.NewRequest("GET", "/search?text=%28oops", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "unbalanced parentheses")
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest("GET", "/api/video?filter=%28oops", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestContentSecurityPolicyAllowsMediaOrigin(t *testing.T) {
+	root := "test-csp-media-origin"
+	fs := files.LocalFileSystem(root)
+	defer os.RemoveAll(root)
+
+	repo := videostore.NewDummyVideoRepo(fs)
+
+	csp := func(mediaOrigin string) string {
+		ui := NewReadOnlyCUI2(func(s string) string { return s }, func(s string) string { return s }, mediaOrigin, repo)
+		rec := httptest.NewRecorder()
+		ui.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		matches := regexp.MustCompile(`http-equiv="Content-Security-Policy" content="([^"]*)"`).FindStringSubmatch(rec.Body.String())
+		if !assert.Len(t, matches, 2) {
+			return ""
+		}
+		return html.UnescapeString(matches[1])
+	}
+
+	served := csp("")
+	assert.Contains(t, served, "default-src 'self'; img-src 'self';")
+	assert.NotContains(t, served, "media-src")
+
+	redirected := csp("https://media.example.com")
+	assert.Contains(t, redirected, "img-src 'self' https://media.example.com;")
+	assert.Contains(t, redirected, "media-src 'self' https://media.example.com;")
+	assert.Contains(t, redirected, "connect-src 'self' https://media.example.com;")
+	assert.Contains(t, redirected, "script-src 'self';")
+}